@@ -0,0 +1,250 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AggregateOp is the function computed by an Aggregation
+type AggregateOp string
+
+const (
+	AggCount AggregateOp = "count"
+	AggSum   AggregateOp = "sum"
+	AggMin   AggregateOp = "min"
+	AggMax   AggregateOp = "max"
+)
+
+// Aggregation computes a value over the documents of every group, see AggregateSpec
+type Aggregation struct {
+	// Name is the key of the value in AggregateGroup.Values, by default the Op, followed by _ and the Path if set,
+	// e.g. count or sum_price
+	Name string
+	Op   AggregateOp
+	// Path is the dot separated path of the aggregated field, required except for AggCount: a count without path
+	// counts the documents, with a path the documents where the field is not null.
+	// Sums only add numbers, min and max compare numbers and strings, numbers being lower than strings; other
+	// values are ignored.
+	Path string
+}
+
+// ValueName returns the key of the value of the aggregation in AggregateGroup.Values
+func (a Aggregation) ValueName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.Path == "" {
+		return string(a.Op)
+	}
+	return string(a.Op) + "_" + a.Path
+}
+
+// AggregateSpec defines the values computed by Aggregate
+type AggregateSpec struct {
+	// Filters select the aggregated documents, see Query.Filters
+	Filters []Filter
+	// GroupBy are the paths whose values split the documents in groups, documents missing a field are grouped with
+	// the ones where it is null; without GroupBy all the documents form a single group
+	GroupBy      []string
+	Aggregations []Aggregation
+}
+
+// Validate returns an error wrapping InvalidQueryErr if the spec is not valid
+func (s AggregateSpec) Validate() error {
+	if err := (Query{Filters: s.Filters, Fields: s.GroupBy}).Validate(); err != nil {
+		return err
+	}
+	if len(s.Aggregations) == 0 {
+		return fmt.Errorf("%w: no aggregations", InvalidQueryErr)
+	}
+	names := map[string]bool{}
+	for _, a := range s.Aggregations {
+		switch a.Op {
+		case AggCount:
+		case AggSum, AggMin, AggMax:
+			if a.Path == "" {
+				return fmt.Errorf("%w: %s needs a path", InvalidQueryErr, a.Op)
+			}
+		default:
+			return fmt.Errorf("%w: unknown aggregation %q", InvalidQueryErr, a.Op)
+		}
+		if names[a.ValueName()] {
+			return fmt.Errorf("%w: duplicated aggregation name %q", InvalidQueryErr, a.ValueName())
+		}
+		names[a.ValueName()] = true
+	}
+	return nil
+}
+
+// paths returns all the paths read by the spec
+func (s AggregateSpec) paths() []string {
+	paths := append([]string{}, s.GroupBy...)
+	for _, a := range s.Aggregations {
+		if a.Path != "" {
+			paths = append(paths, a.Path)
+		}
+	}
+	return paths
+}
+
+// AggregateGroup holds the aggregated values of a group of documents
+type AggregateGroup struct {
+	// Key are the values of the GroupBy paths shared by the documents of the group, by path; nil without GroupBy
+	Key map[string]any `json:"key,omitempty"`
+	// Values are the results of the aggregations by name: counts are int64, sums float64, min and max a float64,
+	// a string or nil if the group has no values to compare
+	Values map[string]any `json:"values"`
+}
+
+// Aggregator is implemented by stores able to compute aggregations natively, e.g. in the database
+type Aggregator interface {
+	Aggregate(ctx context.Context, collection string, spec AggregateSpec) ([]AggregateGroup, error)
+}
+
+// Aggregate computes counts, sums, min and max over the fields of the documents of a collection, optionally
+// grouped by fields; the groups are sorted by their key, as Query.Sort sorts the documents. If the store does not
+// implement Aggregator all the documents of the collection are read and aggregated in memory.
+func Aggregate(ctx context.Context, store JsonStorer, collection string, spec AggregateSpec) ([]AggregateGroup, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if a, ok := store.(Aggregator); ok {
+		return a.Aggregate(ctx, collection, spec)
+	}
+	return aggregateClientSide(ctx, store, collection, spec)
+}
+
+// aggregateClientSide reads all the documents of the collection and aggregates them in memory
+func aggregateClientSide(ctx context.Context, store JsonStorer, collection string, spec AggregateSpec) ([]AggregateGroup, error) {
+	agg := newAggregator(spec)
+	err := ForEach(ctx, store, collection, func(key string, value json.RawMessage) error {
+		var doc any
+		if err := json.Unmarshal(value, &doc); err != nil {
+			return fmt.Errorf("unable to decode document: %v", err)
+		}
+		return agg.add(doc)
+	})
+	if err != nil && !errors.Is(err, CollectionNotFoundErr) {
+		return nil, err
+	}
+	return agg.groups(), nil
+}
+
+// aggregator accumulates the aggregations of the groups of documents
+type aggregator struct {
+	spec   AggregateSpec
+	states map[string]*aggregateState
+}
+
+type aggregateState struct {
+	key    []any
+	counts []int64
+	sums   []float64
+	values []any
+}
+
+func newAggregator(spec AggregateSpec) *aggregator {
+	return &aggregator{spec: spec, states: map[string]*aggregateState{}}
+}
+
+// add aggregates a decoded document if it matches the filters
+func (a *aggregator) add(doc any) error {
+	for _, f := range a.spec.Filters {
+		ok, err := f.match(doc)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	key := make([]any, len(a.spec.GroupBy))
+	for i, path := range a.spec.GroupBy {
+		key[i], _ = lookup(doc, SplitPath(path))
+	}
+	id, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	state, ok := a.states[string(id)]
+	if !ok {
+		state = a.newState(key)
+		a.states[string(id)] = state
+	}
+	for i, agg := range a.spec.Aggregations {
+		var v any
+		found := true
+		if agg.Path != "" {
+			v, found = lookup(doc, SplitPath(agg.Path))
+		}
+		switch agg.Op {
+		case AggCount:
+			if found && (agg.Path == "" || v != nil) {
+				state.counts[i]++
+			}
+		case AggSum:
+			if n, ok := v.(float64); ok {
+				state.sums[i] += n
+			}
+		case AggMin, AggMax:
+			switch v.(type) {
+			case float64, string:
+			default:
+				continue
+			}
+			c := compareSort(v, state.values[i])
+			if state.values[i] == nil || (agg.Op == AggMin && c < 0) || (agg.Op == AggMax && c > 0) {
+				state.values[i] = v
+			}
+		}
+	}
+	return nil
+}
+
+func (a *aggregator) newState(key []any) *aggregateState {
+	n := len(a.spec.Aggregations)
+	return &aggregateState{key: key, counts: make([]int64, n), sums: make([]float64, n), values: make([]any, n)}
+}
+
+// groups returns the aggregated groups sorted by key, without GroupBy there is always a single group
+func (a *aggregator) groups() []AggregateGroup {
+	if len(a.spec.GroupBy) == 0 && len(a.states) == 0 {
+		a.states["[]"] = a.newState(nil)
+	}
+	groups := make([]AggregateGroup, 0, len(a.states))
+	for _, state := range a.states {
+		group := AggregateGroup{Values: map[string]any{}}
+		if len(a.spec.GroupBy) > 0 {
+			group.Key = map[string]any{}
+			for i, path := range a.spec.GroupBy {
+				group.Key[path] = state.key[i]
+			}
+		}
+		for i, agg := range a.spec.Aggregations {
+			switch agg.Op {
+			case AggCount:
+				group.Values[agg.ValueName()] = state.counts[i]
+			case AggSum:
+				group.Values[agg.ValueName()] = state.sums[i]
+			default:
+				group.Values[agg.ValueName()] = state.values[i]
+			}
+		}
+		groups = append(groups, group)
+	}
+	SortGroups(groups, a.spec.GroupBy)
+	return groups
+}
+
+// SortGroups sorts the groups by the values of their key, as returned by Aggregate; it is meant for the Aggregator
+// implementations
+func SortGroups(groups []AggregateGroup, groupBy []string) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, path := range groupBy {
+			if c := compareSort(groups[i].Key[path], groups[j].Key[path]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
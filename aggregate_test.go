@@ -0,0 +1,167 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAggregate(t *testing.T) {
+	ctx := context.Background()
+	orders := map[string]string{
+		"o1": `{"status":"paid","amount":10,"customer":"alice","date":"2024-01-02","items":1}`,
+		"o2": `{"status":"paid","amount":2.5,"customer":"bob","date":"2024-01-05","items":1.0}`,
+		"o3": `{"status":"open","amount":7,"customer":"alice","date":"2024-01-01","items":2}`,
+		"o4": `{"status":"open","amount":"n/a","customer":null,"items":false}`,
+		"o5": `{"amount":1,"customer":"carol","date":"2024-01-03"}`,
+	}
+	stores := map[string]func(t *testing.T) jsonstore.JsonStorer{
+		"MemStore":  func(t *testing.T) jsonstore.JsonStorer { return jsonstore.NewMemStore() },
+		"FileStore": func(t *testing.T) jsonstore.JsonStorer { return newJsonFile(t) },
+		"DbStore":   func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) },
+		"NamespacedDbStore": func(t *testing.T) jsonstore.JsonStorer {
+			return jsonstore.NewNamespacedStore(newDbStore(t), "tenant1")
+		},
+	}
+	for name, db := range getTargetDBs(t) {
+		if db.Dialector.Name() == "sqlite" {
+			continue
+		}
+		// the DbStore aggregates the documents client-side on the other dialects, see pgstore for postgres pushdown
+		stores["DbStore "+name] = func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewDbStore(db)
+			if err != nil {
+				t.Fatalf("NewDbStore returned an error: %v", err)
+			}
+			return store
+		}
+	}
+
+	tcs := []struct {
+		name string
+		spec jsonstore.AggregateSpec
+		want []jsonstore.AggregateGroup
+	}{
+		{
+			name: "totals",
+			spec: jsonstore.AggregateSpec{Aggregations: []jsonstore.Aggregation{
+				{Op: jsonstore.AggCount},
+				{Op: jsonstore.AggCount, Path: "customer"},
+				{Op: jsonstore.AggSum, Path: "amount"},
+				{Op: jsonstore.AggMin, Path: "amount"},
+				{Op: jsonstore.AggMax, Path: "amount"},
+				{Name: "last", Op: jsonstore.AggMax, Path: "date"},
+			}},
+			want: []jsonstore.AggregateGroup{{Values: map[string]any{
+				"count": int64(5), "count_customer": int64(4), "sum_amount": 20.5, "min_amount": 1.0, "max_amount": "n/a",
+				"last": "2024-01-05",
+			}}},
+		},
+		{
+			name: "group by",
+			spec: jsonstore.AggregateSpec{
+				GroupBy: []string{"status"},
+				Aggregations: []jsonstore.Aggregation{
+					{Op: jsonstore.AggCount},
+					{Op: jsonstore.AggSum, Path: "amount"},
+					{Op: jsonstore.AggMin, Path: "date"},
+				},
+			},
+			want: []jsonstore.AggregateGroup{
+				{Key: map[string]any{"status": nil}, Values: map[string]any{"count": int64(1), "sum_amount": 1.0, "min_date": "2024-01-03"}},
+				{Key: map[string]any{"status": "open"}, Values: map[string]any{"count": int64(2), "sum_amount": 7.0, "min_date": "2024-01-01"}},
+				{Key: map[string]any{"status": "paid"}, Values: map[string]any{"count": int64(2), "sum_amount": 12.5, "min_date": "2024-01-02"}},
+			},
+		},
+		{
+			name: "filter and group by many fields",
+			spec: jsonstore.AggregateSpec{
+				Filters: []jsonstore.Filter{{Path: "status", Op: jsonstore.OpNe, Value: "open"}},
+				GroupBy: []string{"customer", "status"},
+				Aggregations: []jsonstore.Aggregation{
+					{Op: jsonstore.AggCount},
+				},
+			},
+			want: []jsonstore.AggregateGroup{
+				{Key: map[string]any{"customer": "alice", "status": "paid"}, Values: map[string]any{"count": int64(1)}},
+				{Key: map[string]any{"customer": "bob", "status": "paid"}, Values: map[string]any{"count": int64(1)}},
+				{Key: map[string]any{"customer": "carol", "status": nil}, Values: map[string]any{"count": int64(1)}},
+			},
+		},
+		{
+			name: "group by numbers",
+			spec: jsonstore.AggregateSpec{
+				GroupBy:      []string{"items"},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}},
+			},
+			want: []jsonstore.AggregateGroup{
+				{Key: map[string]any{"items": nil}, Values: map[string]any{"count": int64(1)}},
+				{Key: map[string]any{"items": false}, Values: map[string]any{"count": int64(1)}},
+				{Key: map[string]any{"items": 1.0}, Values: map[string]any{"count": int64(2)}},
+				{Key: map[string]any{"items": 2.0}, Values: map[string]any{"count": int64(1)}},
+			},
+		},
+		{
+			name: "no match",
+			spec: jsonstore.AggregateSpec{
+				Filters:      []jsonstore.Filter{{Path: "amount", Op: jsonstore.OpGt, Value: 100}},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}, {Op: jsonstore.AggMax, Path: "amount"}},
+			},
+			want: []jsonstore.AggregateGroup{{Values: map[string]any{"count": int64(0), "max_amount": nil}}},
+		},
+	}
+
+	for name, newStore := range stores {
+		store := newStore(t)
+		for key, value := range orders {
+			if err := store.Set(ctx, "orders", key, json.RawMessage(value)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		for _, tc := range tcs {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				got, err := jsonstore.Aggregate(ctx, store, "orders", tc.spec)
+				if err != nil {
+					t.Fatalf("action: Aggregate,  returned an error: %v", err)
+				}
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("unexpected groups (-got +want):\n%s", diff)
+				}
+			})
+		}
+		t.Run(name+" missing collection", func(t *testing.T) {
+			spec := jsonstore.AggregateSpec{GroupBy: []string{"status"}, Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}}}
+			got, err := jsonstore.Aggregate(ctx, store, "missing", spec)
+			if err != nil {
+				t.Fatalf("action: Aggregate,  returned an error: %v", err)
+			}
+			if len(got) != 0 {
+				t.Errorf("expected no groups, got %v", got)
+			}
+		})
+	}
+}
+
+func TestAggregateSpecValidate(t *testing.T) {
+	tcs := []struct {
+		name string
+		spec jsonstore.AggregateSpec
+	}{
+		{name: "no aggregations", spec: jsonstore.AggregateSpec{}},
+		{name: "unknown op", spec: jsonstore.AggregateSpec{Aggregations: []jsonstore.Aggregation{{Op: "avg", Path: "a"}}}},
+		{name: "sum without path", spec: jsonstore.AggregateSpec{Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggSum}}}},
+		{name: "duplicated name", spec: jsonstore.AggregateSpec{Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}, {Op: jsonstore.AggCount}}}},
+		{name: "empty group by", spec: jsonstore.AggregateSpec{GroupBy: []string{""}, Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}}}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.spec.Validate(); !errors.Is(err, jsonstore.InvalidQueryErr) {
+				t.Errorf("expected InvalidQueryErr, got %v", err)
+			}
+		})
+	}
+}
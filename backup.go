@@ -0,0 +1,295 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupDestination stores the backups taken by a BackupScheduler, e.g. a DirSnapshotTarget, a WriterDestination
+// or an objectstore.BackupTarget. The backups are snapshots, see Snapshotter.
+type BackupDestination interface {
+	Put(ctx context.Context, name string, r io.Reader) error
+}
+
+// BackupPruner is implemented by the destinations able to delete old backups, the BackupRetention of a
+// BackupScheduler is only applied on them
+type BackupPruner interface {
+	// List returns the names of the backups in the destination
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// WriterDestination is a BackupDestination writing every backup to the writer it returns for the backup name,
+// e.g. to upload it with a custom client; the backup fails if Close returns an error
+type WriterDestination func(ctx context.Context, name string) (io.WriteCloser, error)
+
+func (d WriterDestination) Put(ctx context.Context, name string, r io.Reader) error {
+	w, err := d(ctx, name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DefaultBackupInterval is the time between the backups of a BackupScheduler if no Interval is configured
+const DefaultBackupInterval = 24 * time.Hour
+
+// BackupRetention defines the backups kept by a BackupScheduler, a backup is kept if any of the rules keeps it;
+// without rules all the backups are kept
+type BackupRetention struct {
+	// KeepLast keeps the given amount of most recent backups
+	KeepLast int
+	// KeepDaily keeps the most recent backup of each of the last days with backups, in UTC
+	KeepDaily int
+	// KeepWeekly keeps the most recent backup of each of the last ISO weeks with backups, in UTC
+	KeepWeekly int
+}
+
+func (r BackupRetention) enabled() bool {
+	return r.KeepLast > 0 || r.KeepDaily > 0 || r.KeepWeekly > 0
+}
+
+// expired returns the backups not kept by the retention, the names of other files are ignored
+func (r BackupRetention) expired(names []string) []string {
+	type backup struct {
+		name string
+		time time.Time
+	}
+	var backups []backup
+	for _, name := range names {
+		if t, ok := parseSnapshotName(name); ok {
+			backups = append(backups, backup{name: name, time: t})
+		}
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+
+	keep := map[string]bool{}
+	for i := 0; i < len(backups) && i < r.KeepLast; i++ {
+		keep[backups[i].name] = true
+	}
+	bucket := func(n int, period func(t time.Time) string) {
+		seen := map[string]bool{}
+		for _, b := range backups {
+			p := period(b.time)
+			if seen[p] {
+				continue
+			}
+			if len(seen) >= n {
+				return
+			}
+			seen[p] = true
+			keep[b.name] = true
+		}
+	}
+	bucket(r.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	bucket(r.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+
+	var expired []string
+	for _, b := range backups {
+		if !keep[b.name] {
+			expired = append(expired, b.name)
+		}
+	}
+	return expired
+}
+
+// parseSnapshotName returns the time a snapshot was taken out of its name, see snapshotName
+func parseSnapshotName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotExt) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102T150405.000000000", strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotExt))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// BackupOptions configures a BackupScheduler
+type BackupOptions struct {
+	// Interval is the time between the backups, DefaultBackupInterval if zero
+	Interval time.Duration
+	// Retention removes the old backups after every backup, if the destination is a BackupPruner
+	Retention BackupRetention
+	// OnBackup, if set, is called after every backup with its result, e.g. to export metrics
+	OnBackup func(backup BackupRun)
+}
+
+// BackupRun is the result of a backup
+type BackupRun struct {
+	// Name is the name of the backup in the destination
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	// Size is the amount of bytes of the backup; if the backup fails, the bytes sent to the destination before the
+	// failure
+	Size int64
+	// Deleted are the old backups removed by the retention
+	Deleted []string
+	Err     error
+}
+
+// BackupScheduler periodically takes a snapshot of a store and ships it to a destination, deleting the old backups
+// according to the retention. Stores implementing Snapshotter take consistent snapshots, the other stores need to
+// be a CollectionManager and are read collection by collection. The backups can be restored with
+// Snapshotter.RestoreSnapshot, or with RestoreFromRemote from a DirSnapshotTarget.
+type BackupScheduler struct {
+	store JsonStorer
+	dest  BackupDestination
+	opts  BackupOptions
+	mutex sync.Mutex
+	last  BackupRun
+}
+
+// NewBackupScheduler returns a scheduler backing up the store to dest, call Run to start it
+func NewBackupScheduler(store JsonStorer, dest BackupDestination, opts BackupOptions) *BackupScheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultBackupInterval
+	}
+	return &BackupScheduler{store: store, dest: dest, opts: opts}
+}
+
+// Run takes a backup right away and then every Interval until the context is canceled, errors of the backups are
+// passed to onErr (if not nil) and don't stop the loop. It is intended to be run as a goroutine:
+//
+//	go scheduler.Run(ctx, func(err error) { log.Print(err) })
+func (b *BackupScheduler) Run(ctx context.Context, onErr func(error)) error {
+	runOnce := func() {
+		run := b.RunOnce(ctx)
+		if run.Err != nil && onErr != nil {
+			onErr(run.Err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	runOnce()
+
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			runOnce()
+		}
+	}
+}
+
+// RunOnce takes a backup and applies the retention, the retention is not applied if the backup fails
+func (b *BackupScheduler) RunOnce(ctx context.Context) BackupRun {
+	run := BackupRun{Start: time.Now()}
+	run.Name = snapshotName(run.Start)
+	run.Size, run.Err = b.backup(ctx, run.Name)
+	if run.Err == nil {
+		run.Deleted, run.Err = b.prune(ctx)
+	}
+	run.Duration = time.Since(run.Start)
+
+	b.mutex.Lock()
+	b.last = run
+	b.mutex.Unlock()
+	if b.opts.OnBackup != nil {
+		b.opts.OnBackup(run)
+	}
+	return run
+}
+
+// LastRun returns the result of the last backup
+func (b *BackupScheduler) LastRun() BackupRun {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.last
+}
+
+// backup streams a snapshot of the store to the destination, returning the bytes read by the destination also on
+// error
+func (b *BackupScheduler) backup(ctx context.Context, name string) (int64, error) {
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(snapshotStore(ctx, b.store, counter))
+	}()
+	err := b.dest.Put(ctx, name, pr)
+	// unblock the snapshot if the destination did not read it all
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if err != nil {
+		return counter.n, fmt.Errorf("failed to backup the store: %v", err)
+	}
+	return counter.n, nil
+}
+
+// prune deletes the backups not kept by the retention
+func (b *BackupScheduler) prune(ctx context.Context) ([]string, error) {
+	pruner, ok := b.dest.(BackupPruner)
+	if !ok || !b.opts.Retention.enabled() {
+		return nil, nil
+	}
+	names, err := pruner.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %v", err)
+	}
+	var deleted []string
+	for _, name := range b.opts.Retention.expired(names) {
+		if err = pruner.Delete(ctx, name); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %v", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
+
+// snapshotStore writes a snapshot of any store, with the Snapshotter of the store if available
+func snapshotStore(ctx context.Context, store JsonStorer, w io.Writer) error {
+	if s, ok := store.(Snapshotter); ok {
+		return s.Snapshot(ctx, w)
+	}
+	cm, ok := store.(CollectionManager)
+	if !ok {
+		return fmt.Errorf("the store is neither a Snapshotter nor a CollectionManager")
+	}
+	collections, err := cm.Collections(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %v", err)
+	}
+	sw := snapshotWriter{w: w}
+	for _, collection := range collections {
+		err = ForEach(ctx, store, collection, func(key string, value json.RawMessage) error {
+			return sw.document(collection, key, value)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read collection %s: %v", collection, err)
+		}
+	}
+	return sw.close()
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
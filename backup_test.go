@@ -0,0 +1,289 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// memDestination keeps the backups in memory
+type memDestination struct {
+	mutex   sync.Mutex
+	backups map[string][]byte
+}
+
+func (m *memDestination) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.backups[name] = data
+	return nil
+}
+
+func (m *memDestination) List(ctx context.Context) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var names []string
+	for name := range m.backups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *memDestination) Delete(ctx context.Context, name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.backups, name)
+	return nil
+}
+
+// failingDestination reads the first bytes of a backup and fails, as a connection dropped during an upload
+type failingDestination struct {
+	*memDestination
+	after int64
+}
+
+func (f failingDestination) Put(ctx context.Context, name string, r io.Reader) error {
+	if _, err := io.CopyN(io.Discard, r, f.after); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func backupName(t time.Time) string {
+	return "snapshot-" + t.UTC().Format("20060102T150405.000000000") + ".json"
+}
+
+func TestBackupScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("snapshotter to directory", func(t *testing.T) {
+		store := newJsonFile(t)
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		target := jsonstore.DirSnapshotTarget{Dir: filepath.Join(t.TempDir(), "backups")}
+		scheduler := jsonstore.NewBackupScheduler(store, target, jsonstore.BackupOptions{})
+		run := scheduler.RunOnce(ctx)
+		if run.Err != nil {
+			t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+		}
+		if run.Size == 0 || scheduler.LastRun().Name != run.Name {
+			t.Errorf("unexpected run: %+v", run)
+		}
+
+		restored, err := jsonstore.RestoreFromRemote(ctx, target, filepath.Join(t.TempDir(), "restored.json"))
+		if err != nil {
+			t.Fatalf("action: RestoreFromRemote,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err = restored.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(string(got), `{"n":1}`); diff != "" {
+			t.Errorf("unexpected value (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("directory retention", func(t *testing.T) {
+		target := jsonstore.DirSnapshotTarget{Dir: t.TempDir()}
+		scheduler := jsonstore.NewBackupScheduler(jsonstore.NewMemStore(), target, jsonstore.BackupOptions{
+			Retention: jsonstore.BackupRetention{KeepLast: 1},
+		})
+		first := scheduler.RunOnce(ctx)
+		second := scheduler.RunOnce(ctx)
+		if second.Err != nil {
+			t.Fatalf("action: RunOnce,  returned an error: %v", second.Err)
+		}
+		if diff := cmp.Diff(second.Deleted, []string{first.Name}); diff != "" {
+			t.Errorf("unexpected deleted backups (-got +want):\n%s", diff)
+		}
+		names, err := target.List(ctx)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(names, []string{second.Name}); diff != "" {
+			t.Errorf("unexpected backups (-got +want):\n%s", diff)
+		}
+		if err = target.Delete(ctx, first.Name); !errors.Is(err, jsonstore.SnapshotNotFoundErr) {
+			t.Errorf("expected SnapshotNotFoundErr, got %v", err)
+		}
+	})
+
+	t.Run("collection manager to writer", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		for _, doc := range []struct{ collection, key string }{{"col1", "a"}, {"col1", "b"}, {"col2", "c"}} {
+			if err := store.Set(ctx, doc.collection, doc.key, json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		buf := &bytes.Buffer{}
+		dest := jsonstore.WriterDestination(func(ctx context.Context, name string) (io.WriteCloser, error) {
+			return nopCloser{buf}, nil
+		})
+		run := jsonstore.NewBackupScheduler(store, dest, jsonstore.BackupOptions{}).RunOnce(ctx)
+		if run.Err != nil {
+			t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+		}
+		if diff := cmp.Diff(buf.String(), `{"col1":{"a":{},"b":{}},"col2":{"c":{}}}`); diff != "" {
+			t.Errorf("unexpected backup (-got +want):\n%s", diff)
+		}
+		if run.Size != int64(buf.Len()) {
+			t.Errorf("expected size %d, got %d", buf.Len(), run.Size)
+		}
+	})
+
+	t.Run("unsupported store", func(t *testing.T) {
+		dest := &memDestination{backups: map[string][]byte{}}
+		run := jsonstore.NewBackupScheduler(&jsonstoretest.FakeStore{}, dest, jsonstore.BackupOptions{}).RunOnce(ctx)
+		if run.Err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("destination failing halfway", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		for i := 0; i < 100; i++ {
+			if err := store.Set(ctx, "col1", fmt.Sprintf("item%d", i), json.RawMessage(`{"value":"some content"}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		old := backupName(time.Now().Add(-time.Hour))
+		dest := failingDestination{memDestination: &memDestination{backups: map[string][]byte{old: nil}}, after: 100}
+		scheduler := jsonstore.NewBackupScheduler(store, dest, jsonstore.BackupOptions{
+			Retention: jsonstore.BackupRetention{KeepLast: 1},
+		})
+		run := scheduler.RunOnce(ctx)
+		if run.Err == nil {
+			t.Fatalf("expected an error")
+		}
+		if run.Size != dest.after {
+			t.Errorf("expected size %d, got %d", dest.after, run.Size)
+		}
+		if len(run.Deleted) != 0 {
+			t.Errorf("expected the retention not to be applied, deleted %v", run.Deleted)
+		}
+		if _, ok := dest.backups[old]; !ok {
+			t.Errorf("expected the old backup to be kept")
+		}
+		if scheduler.LastRun().Err == nil {
+			t.Errorf("expected the last run to hold the error")
+		}
+	})
+
+	t.Run("run backs up right away", func(t *testing.T) {
+		dest := &memDestination{backups: map[string][]byte{}}
+		runs := make(chan jsonstore.BackupRun, 1)
+		scheduler := jsonstore.NewBackupScheduler(jsonstore.NewMemStore(), dest, jsonstore.BackupOptions{
+			Interval: time.Hour,
+			OnBackup: func(run jsonstore.BackupRun) { runs <- run },
+		})
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- scheduler.Run(runCtx, nil) }()
+
+		select {
+		case run := <-runs:
+			if run.Err != nil {
+				t.Errorf("action: Run,  returned an error: %v", run.Err)
+			}
+			if _, ok := dest.backups[run.Name]; !ok {
+				t.Errorf("expected backup %s in the destination", run.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("expected a backup without waiting for the interval")
+		}
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		now := time.Now().UTC()
+		day := 24 * time.Hour
+		// two backups a day for the last 20 days
+		var names []string
+		for i := 0; i < 40; i++ {
+			names = append(names, backupName(now.Add(-time.Duration(i)*12*time.Hour)))
+		}
+		tcs := []struct {
+			name      string
+			retention jsonstore.BackupRetention
+			wantKept  int
+		}{
+			{name: "keep all", wantKept: 41},
+			{name: "keep last", retention: jsonstore.BackupRetention{KeepLast: 3}, wantKept: 3},
+			{name: "keep daily", retention: jsonstore.BackupRetention{KeepDaily: 7}, wantKept: 7},
+			{name: "keep weekly", retention: jsonstore.BackupRetention{KeepWeekly: 2}, wantKept: 2},
+			// the last 2 backups, one backup for the 5 previous days and one of the weeks before
+			{name: "combined", retention: jsonstore.BackupRetention{KeepLast: 2, KeepDaily: 5, KeepWeekly: 4}},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				dest := &memDestination{backups: map[string][]byte{"unrelated.txt": nil}}
+				for _, name := range names {
+					dest.backups[name] = nil
+				}
+				run := jsonstore.NewBackupScheduler(jsonstore.NewMemStore(), dest, jsonstore.BackupOptions{Retention: tc.retention}).RunOnce(ctx)
+				if run.Err != nil {
+					t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+				}
+				kept, _ := dest.List(ctx)
+				if _, ok := dest.backups["unrelated.txt"]; !ok {
+					t.Errorf("expected other files to be ignored")
+				}
+				if _, ok := dest.backups[run.Name]; !ok {
+					t.Errorf("expected the new backup to be kept")
+				}
+				if tc.wantKept > 0 && len(kept)-1 != tc.wantKept {
+					t.Errorf("expected %d backups, got %d", tc.wantKept, len(kept)-1)
+				}
+				if len(kept)-1+len(run.Deleted) != len(names)+1 {
+					t.Errorf("expected every backup to be kept or deleted, kept %d deleted %d", len(kept)-1, len(run.Deleted))
+				}
+				if tc.name == "combined" {
+					// the rules overlap, at most 2+5+4 backups are kept and none is older than 4 weeks
+					if len(kept)-1 < 5 || len(kept)-1 > 11 {
+						t.Errorf("unexpected amount of backups kept: %d", len(kept)-1)
+					}
+					for _, name := range kept {
+						if !strings.HasPrefix(name, "snapshot-") {
+							continue
+						}
+						ts, err := time.Parse("20060102T150405.000000000", strings.TrimSuffix(strings.TrimPrefix(name, "snapshot-"), ".json"))
+						if err != nil {
+							t.Fatalf("action: Parse,  returned an error: %v", err)
+						}
+						if now.Sub(ts) > 4*7*day {
+							t.Errorf("unexpected old backup kept: %s", name)
+						}
+					}
+				}
+			})
+		}
+	})
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,160 @@
+package badgerstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-bumbu/jsonstore"
+)
+
+// keySeparator is used to map collection and key into a single badger key: <collection>/<key>
+const keySeparator = "/"
+
+// BadgerStore uses an embedded badger database to store json documents,
+// it is intended for write-heavy workloads where a single process owns the data.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// make sure the badger store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &BadgerStore{}
+
+// NewBadgerStore returns a store using the provided badger database,
+// the caller is responsible to close the database once done.
+func NewBadgerStore(db *badger.DB) (*BadgerStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("badger db cannot be nil")
+	}
+	store := BadgerStore{
+		db: db,
+	}
+	return &store, nil
+}
+
+// colPrefix returns the badger key prefix of all the documents in a collection
+func colPrefix(collection string) ([]byte, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if strings.Contains(collection, keySeparator) {
+		return nil, fmt.Errorf("collection name cannot contain %q", keySeparator)
+	}
+	return []byte(collection + keySeparator), nil
+}
+
+func docKey(collection, key string) ([]byte, error) {
+	if key == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+	prefix, err := colPrefix(collection)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, key...), nil
+}
+
+func (store *BadgerStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	k, err := docKey(collection, key)
+	if err != nil {
+		return err
+	}
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(k, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *BadgerStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	k, err := docKey(collection, key)
+	if err != nil {
+		return err
+	}
+	err = store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		*value, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, since badger stores keys in byte order
+// pagination is done by skipping the keys of the previous pages.
+func (store *BadgerStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	prefix, err := colPrefix(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	result := map[string]json.RawMessage{}
+	var count int64
+	err = store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if count >= int64(offset) && count < int64(offset+limit) {
+				item := it.Item()
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				result[string(item.Key()[len(prefix):])] = val
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	return result, count, nil
+}
+
+func (store *BadgerStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	k, err := docKey(collection, key)
+	if err != nil {
+		return false, err
+	}
+	deleted := false
+	err = store.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		deleted = true
+		return txn.Delete(k)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,135 @@
+package badgerstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/badgerstore"
+	"github.com/go-bumbu/jsonstore/storetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newBadgerStore(t *testing.T) *badgerstore.BadgerStore {
+	opts := badger.DefaultOptions("").WithInMemory(true).WithLogger(nil)
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatalf("failed to open badger db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	store, err := badgerstore.NewBadgerStore(db)
+	if err != nil {
+		t.Fatalf("NewBadgerStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestBadgerSetGet(t *testing.T) {
+	store := newBadgerStore(t)
+	ctx := context.Background()
+
+	t.Run("get updated value", func(t *testing.T) {
+		err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"item":"my value"}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		want := json.RawMessage(`{"item":"updated value"}`)
+		err = store.Set(ctx, "col1", "item1", want)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("get missing item", func(t *testing.T) {
+		var got json.RawMessage
+		err := store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("reject collection with separator", func(t *testing.T) {
+		err := store.Set(ctx, "col/1", "item1", json.RawMessage(`{}`))
+		if err == nil {
+			t.Errorf("expected an error but got none")
+		}
+	})
+}
+
+func TestBadgerList(t *testing.T) {
+	store := newBadgerStore(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		err := store.Set(ctx, "col1", fmt.Sprintf("item%d", i),
+			json.RawMessage(fmt.Sprintf("{\"item\": \"collection1 item%d\"}", i)))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+	// a collection sharing the name prefix must not leak into col1
+	err := store.Set(ctx, "col10", "item1", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	items, total, err := store.List(ctx, "col1", 2, 2)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("expected total 3, got %d", total)
+	}
+	want := map[string]json.RawMessage{
+		"item3": json.RawMessage(`{"item": "collection1 item3"}`),
+	}
+	if diff := cmp.Diff(items, want); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
+
+func TestBadgerDelete(t *testing.T) {
+	store := newBadgerStore(t)
+	ctx := context.Background()
+
+	err := store.Set(ctx, "col1", "item1", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	deleted, err := store.Delete(ctx, "col1", "item1")
+	if err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	if !deleted {
+		t.Errorf("expect Delete to affect one entry, but got false")
+	}
+
+	deleted, err = store.Delete(ctx, "col1", "item1")
+	if err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	if deleted {
+		t.Errorf("expect Delete to NOT affect any entry, but got true")
+	}
+}
+
+func TestBadgerConformance(t *testing.T) {
+	storetest.TestStorer(t, func() jsonstore.JsonStorer { return newBadgerStore(t) })
+}
@@ -0,0 +1,161 @@
+package cassandrastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/gocql/gocql"
+)
+
+// CassandraStore stores json documents in a Cassandra or ScyllaDB table partitioned by collection,
+// with the document key as clustering column; see CreateTableStatement for the expected schema.
+// Replication is configured on the keyspace, the store works with any replication strategy.
+type CassandraStore struct {
+	session *gocql.Session
+	table   string
+}
+
+// make sure the cassandra store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &CassandraStore{}
+
+// tableName accepts plain and keyspace qualified identifiers, it is used to build the statements
+var tableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// NewCassandraStore returns a store using an existing table, e.g. "my_keyspace.documents".
+// The caller is responsible to close the session once done.
+func NewCassandraStore(session *gocql.Session, table string) (*CassandraStore, error) {
+	if session == nil {
+		return nil, fmt.Errorf("cassandra session cannot be nil")
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	store := CassandraStore{
+		session: session,
+		table:   table,
+	}
+	return &store, nil
+}
+
+// CreateTableStatement returns the CQL statement creating a table usable by the CassandraStore
+func CreateTableStatement(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    collection text,
+    key text,
+    value text,
+    PRIMARY KEY (collection, key)
+) WITH CLUSTERING ORDER BY (key ASC)`, table)
+}
+
+// partition returns the partition key of a document, validating the document key
+func partition(collection, key string) (string, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if key == "" {
+		return "", fmt.Errorf("id cannot be empty")
+	}
+	return collection, nil
+}
+
+func (store *CassandraStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	collection, err := partition(collection, key)
+	if err != nil {
+		return err
+	}
+	err = store.session.Query(
+		fmt.Sprintf("INSERT INTO %s (collection, key, value) VALUES (?, ?, ?)", store.table),
+		collection, key, string(value),
+	).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *CassandraStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	collection, err := partition(collection, key)
+	if err != nil {
+		return err
+	}
+	var data string
+	err = store.session.Query(
+		fmt.Sprintf("SELECT value FROM %s WHERE collection = ? AND key = ?", store.table),
+		collection, key,
+	).WithContext(ctx).Scan(&data)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value = json.RawMessage(data)
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, Cassandra has no offset queries
+// hence the documents of the previous pages are read and skipped.
+func (store *CassandraStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	var count int64
+	err := store.session.Query(
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE collection = ?", store.table),
+		collection,
+	).WithContext(ctx).Scan(&count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+
+	iter := store.session.Query(
+		fmt.Sprintf("SELECT key, value FROM %s WHERE collection = ? LIMIT ?", store.table),
+		collection, offset+limit,
+	).WithContext(ctx).PageSize(limit).Iter()
+
+	result := map[string]json.RawMessage{}
+	var key, data string
+	for i := 0; iter.Scan(&key, &data); i++ {
+		if i >= offset {
+			result[key] = json.RawMessage(data)
+		}
+	}
+	if err = iter.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	return result, count, nil
+}
+
+// Delete removes a document, the existence is checked beforehand with a regular read since mixing lightweight
+// transactions with the plain writes of Set is unsafe.
+func (store *CassandraStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	var value json.RawMessage
+	err := store.Get(ctx, collection, key, &value)
+	if err != nil {
+		if errors.Is(err, jsonstore.ItemNotFoundErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	collection, _ = partition(collection, key)
+	err = store.session.Query(
+		fmt.Sprintf("DELETE FROM %s WHERE collection = ? AND key = ?", store.table),
+		collection, key,
+	).WithContext(ctx).Exec()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return true, nil
+}
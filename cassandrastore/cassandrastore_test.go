@@ -0,0 +1,151 @@
+package cassandrastore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/cassandrastore"
+	"github.com/gocql/gocql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func newCassandraStore(t *testing.T) *cassandrastore.CassandraStore {
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		t.Skip("skipping cassandra tests, they depend on testcontainers")
+	}
+	ctx := context.Background()
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	req := testcontainers.ContainerRequest{
+		Image:        "cassandra:4.1",
+		ExposedPorts: []string{"9042/tcp"},
+		Env: map[string]string{
+			"MAX_HEAP_SIZE": "512M",
+			"HEAP_NEWSIZE":  "128M",
+		},
+		WaitingFor: wait.ForLog("Starting listening for CQL clients").WithStartupTimeout(180 * time.Second),
+	}
+	cassandraContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start Cassandra container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := cassandraContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate Cassandra container: %v", err)
+		}
+	})
+
+	host, err := cassandraContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get Cassandra container host: %v", err)
+	}
+	port, err := cassandraContainer.MappedPort(ctx, "9042")
+	if err != nil {
+		t.Fatalf("failed to get Cassandra container port: %v", err)
+	}
+
+	cluster := gocql.NewCluster(fmt.Sprintf("%s:%s", host, port.Port()))
+	cluster.Timeout = 10 * time.Second
+	// the container advertises its internal address, always connect to the mapped one
+	cluster.DisableInitialHostLookup = true
+	session, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("failed to connect to Cassandra: %v", err)
+	}
+	t.Cleanup(session.Close)
+
+	err = session.Query("CREATE KEYSPACE IF NOT EXISTS jsonstore WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}").Exec()
+	if err != nil {
+		t.Fatalf("failed to create keyspace: %v", err)
+	}
+	err = session.Query(cassandrastore.CreateTableStatement("jsonstore.documents")).Exec()
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	store, err := cassandrastore.NewCassandraStore(session, "jsonstore.documents")
+	if err != nil {
+		t.Fatalf("NewCassandraStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestCassandraStore(t *testing.T) {
+	store := newCassandraStore(t)
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CollectionManager is implemented by the stores that can list and drop their collections
+type CollectionManager interface {
+	// Collections returns the names of the collections of the store, sorted
+	Collections(ctx context.Context) ([]string, error)
+	// DropCollection removes a collection together with all its documents, dropping a missing collection is not
+	// an error
+	DropCollection(ctx context.Context, collection string) error
+}
+
+// CollectionCreator is implemented by the stores that prepare the storage of a collection before its first
+// document is written, e.g. the table of a DbStore with TablePerCollection
+type CollectionCreator interface {
+	CreateCollection(ctx context.Context, collection string) error
+}
+
+// StatsReporter is implemented by the stores computing the statistics of a collection without reading
+// all its documents
+type StatsReporter interface {
+	Stats(ctx context.Context, collection string) (CollectionStats, error)
+}
+
+var _ CollectionManager = &MemStore{}
+var _ CollectionManager = &FileStore{}
+var _ CollectionManager = &DirStore{}
+var _ CollectionManager = &DbStore{}
+var _ CollectionCreator = &FileStore{}
+var _ CollectionCreator = &DirStore{}
+var _ CollectionCreator = &DbStore{}
+var _ StatsReporter = &DbStore{}
+
+// CreateCollection prepares an empty collection on stores implementing CollectionCreator, on the other stores
+// collections are created by their first document and there is nothing to do
+func CreateCollection(ctx context.Context, store JsonStorer, collection string) error {
+	if c, ok := store.(CollectionCreator); ok {
+		return c.CreateCollection(ctx, collection)
+	}
+	return nil
+}
+
+// TruncateCollection deletes all the documents of a collection and returns the amount deleted, unlike
+// DropCollection the collection itself is kept
+func TruncateCollection(ctx context.Context, store JsonStorer, collection string) (int, error) {
+	// the keys are collected first, deleting while iterating would hold the store locks or a db cursor
+	var keys []string
+	err := ForEach(ctx, store, collection, func(key string, _ json.RawMessage) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, key := range keys {
+		ok, err := store.Delete(ctx, collection, key)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete document %s: %w", key, err)
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// GetStats returns the statistics of a collection, using StatsReporter if the store implements it; otherwise
+// Count and Bytes are computed reading all the documents and the update times are left zero
+func GetStats(ctx context.Context, store JsonStorer, collection string) (CollectionStats, error) {
+	if r, ok := store.(StatsReporter); ok {
+		return r.Stats(ctx, collection)
+	}
+	stats := CollectionStats{}
+	err := ForEach(ctx, store, collection, func(_ string, value json.RawMessage) error {
+		stats.Count++
+		stats.Bytes += int64(len(value))
+		return nil
+	})
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,190 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type collectionStore interface {
+	jsonstore.JsonStorer
+	jsonstore.CollectionManager
+}
+
+func TestCollections(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) collectionStore
+		// wantCreated is set if an empty created collection is listed
+		wantCreated bool
+	}{
+		{name: "MemStore", newStore: func(t *testing.T) collectionStore { return jsonstore.NewMemStore() }},
+		{name: "FileStore", newStore: func(t *testing.T) collectionStore { return newJsonFile(t) }, wantCreated: true},
+		{
+			name: "FileStore lazy load",
+			newStore: func(t *testing.T) collectionStore {
+				store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "data"), jsonstore.FilePerCollection, jsonstore.LazyLoad)
+				if err != nil {
+					t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+				}
+				return store
+			},
+			wantCreated: true,
+		},
+		{
+			name: "DirStore",
+			newStore: func(t *testing.T) collectionStore {
+				store, err := jsonstore.NewDirStore(filepath.Join(t.TempDir(), "data"))
+				if err != nil {
+					t.Fatalf("action: NewDirStore,  returned an error: %v", err)
+				}
+				return store
+			},
+			wantCreated: true,
+		},
+		{name: "DbStore", newStore: func(t *testing.T) collectionStore { return newDbStore(t) }},
+		{
+			name: "DbStore table per collection",
+			newStore: func(t *testing.T) collectionStore {
+				db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "testdb.sqlite")), &gorm.Config{Logger: logger.Discard})
+				if err != nil {
+					t.Fatalf("failed to open test database: %v", err)
+				}
+				store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{TablePerCollection: true})
+				if err != nil {
+					t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+				}
+				return store
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.newStore(t)
+			data := map[string]map[string]string{
+				"col1": {"a": `{"n":1}`, "b": `{"n":2}`},
+				"col2": {"c": `{"n":3}`},
+			}
+			for collection, docs := range data {
+				for key, value := range docs {
+					if err := store.Set(ctx, collection, key, json.RawMessage(value)); err != nil {
+						t.Fatalf("action: Set,  returned an error: %v", err)
+					}
+				}
+			}
+
+			got, err := store.Collections(ctx)
+			if err != nil {
+				t.Fatalf("action: Collections,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, []string{"col1", "col2"}); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			deleted, err := jsonstore.TruncateCollection(ctx, store, "col1")
+			if err != nil {
+				t.Fatalf("action: TruncateCollection,  returned an error: %v", err)
+			}
+			if deleted != 2 {
+				t.Errorf("expected 2 deleted documents, got %d", deleted)
+			}
+			page, err := jsonstore.ListPage(ctx, store, "col1", jsonstore.ListOptions{})
+			if err != nil {
+				t.Fatalf("action: ListPage,  returned an error: %v", err)
+			}
+			if len(page.Items) != 0 {
+				t.Errorf("expected an empty collection, got %d documents", len(page.Items))
+			}
+
+			if err = store.DropCollection(ctx, "col2"); err != nil {
+				t.Fatalf("action: DropCollection,  returned an error: %v", err)
+			}
+			if err = store.DropCollection(ctx, "missing"); err != nil {
+				t.Fatalf("action: DropCollection of a missing collection,  returned an error: %v", err)
+			}
+			var value json.RawMessage
+			if err = store.Get(ctx, "col2", "c", &value); err == nil {
+				t.Errorf("expected the document of the dropped collection to be gone, got %s", value)
+			}
+
+			if err = jsonstore.CreateCollection(ctx, store, "col3"); err != nil {
+				t.Fatalf("action: CreateCollection,  returned an error: %v", err)
+			}
+			got, err = store.Collections(ctx)
+			if err != nil {
+				t.Fatalf("action: Collections,  returned an error: %v", err)
+			}
+			for _, collection := range got {
+				if collection == "col2" {
+					t.Errorf("expected the dropped collection not to be listed, got %v", got)
+				}
+			}
+			created := false
+			for _, collection := range got {
+				created = created || collection == "col3"
+			}
+			if created != tc.wantCreated {
+				t.Errorf("expected the created collection to be listed: %v, got %v", tc.wantCreated, got)
+			}
+		})
+	}
+
+	t.Run("FileStore drop is persisted", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "data")
+		store, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+		if err != nil {
+			t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+		}
+		for _, collection := range []string{"col1", "col2"} {
+			if err = store.Set(ctx, collection, "a", json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		if err = store.DropCollection(ctx, "col1"); err != nil {
+			t.Fatalf("action: DropCollection,  returned an error: %v", err)
+		}
+		if err = store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+
+		reopened, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection, jsonstore.LazyLoad)
+		if err != nil {
+			t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+		}
+		got, err := reopened.Collections(ctx)
+		if err != nil {
+			t.Fatalf("action: Collections,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, []string{"col2"}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
+
+func TestGetStats(t *testing.T) {
+	ctx := context.Background()
+	store := jsonstore.NewMemStore()
+	for key, value := range map[string]string{"a": `{"n":1}`, "b": `{"n":22}`} {
+		if err := store.Set(ctx, "col1", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+
+	got, err := jsonstore.GetStats(ctx, store, "col1")
+	if err != nil {
+		t.Fatalf("action: GetStats,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(got, jsonstore.CollectionStats{Count: 2, Bytes: 15}); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
@@ -0,0 +1,242 @@
+package consulstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// DefaultAddress is the address of the local Consul agent
+const DefaultAddress = "http://127.0.0.1:8500"
+
+// DefaultPrefix is the key prefix used when none is provided
+const DefaultPrefix = "jsonstore"
+
+// Config identifies the Consul agent to use
+type Config struct {
+	// Address of the agent http api, DefaultAddress if empty
+	Address string
+	// Token is sent as X-Consul-Token if set
+	Token string
+	// Datacenter to use, the one of the agent if empty
+	Datacenter string
+	// Prefix of the keys, DefaultPrefix if empty
+	Prefix string
+}
+
+// ConsulStore stores json documents in the Consul KV store under the keys <prefix>/<collection>/<key>
+// using the Consul http api.
+type ConsulStore struct {
+	client *http.Client
+	cfg    Config
+}
+
+// make sure the consul store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &ConsulStore{}
+
+// NewConsulStore returns a store using the provided http client, if nil http.DefaultClient is used
+func NewConsulStore(client *http.Client, cfg Config) (*ConsulStore, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	store := ConsulStore{
+		client: client,
+		cfg:    cfg,
+	}
+	return &store, nil
+}
+
+// kvEntry is an entry returned by the KV api, Value is base64 encoded and decoded by encoding/json
+type kvEntry struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+func (store *ConsulStore) colPath(collection string) (string, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if strings.Contains(collection, "/") {
+		return "", fmt.Errorf("collection name cannot contain \"/\"")
+	}
+	return store.cfg.Prefix + "/" + collection + "/", nil
+}
+
+func (store *ConsulStore) keyPath(collection, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("id cannot be empty")
+	}
+	col, err := store.colPath(collection)
+	if err != nil {
+		return "", err
+	}
+	return col + key, nil
+}
+
+// kvUrl returns the url of a key, escaping every path segment
+func (store *ConsulStore) kvUrl(key string, params url.Values) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	if store.cfg.Datacenter != "" {
+		params.Set("dc", store.cfg.Datacenter)
+	}
+	u := store.cfg.Address + "/v1/kv/" + strings.Join(segments, "/")
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+func (store *ConsulStore) do(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if store.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", store.cfg.Token)
+	}
+	resp, err := store.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (store *ConsulStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	k, err := store.keyPath(collection, key)
+	if err != nil {
+		return err
+	}
+	resp, err := store.do(ctx, http.MethodPut, store.kvUrl(k, url.Values{}), value)
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	defer resp.Body.Close()
+	var ok bool
+	if err = json.NewDecoder(resp.Body).Decode(&ok); err != nil || !ok {
+		return fmt.Errorf("failed to save document: write not applied")
+	}
+	return nil
+}
+
+// entry returns the entry of a key, or nil if it does not exist
+func (store *ConsulStore) entry(ctx context.Context, key string) (*kvEntry, error) {
+	resp, err := store.do(ctx, http.MethodGet, store.kvUrl(key, url.Values{}), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	var entries []kvEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+func (store *ConsulStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	k, err := store.keyPath(collection, key)
+	if err != nil {
+		return err
+	}
+	entry, err := store.entry(ctx, k)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	if entry == nil {
+		return jsonstore.ItemNotFoundErr
+	}
+	*value = entry.Value
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, Consul has no paginated reads
+// hence the whole collection is read.
+func (store *ConsulStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	prefix, err := store.colPath(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	result := map[string]json.RawMessage{}
+	resp, err := store.do(ctx, http.MethodGet, store.kvUrl(prefix, url.Values{"recurse": {"true"}}), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return result, 0, nil
+	}
+	var entries []kvEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	// entries are sorted by key
+	for i, entry := range entries {
+		if i >= offset && i < offset+limit {
+			result[strings.TrimPrefix(entry.Key, prefix)] = entry.Value
+		}
+	}
+	return result, int64(len(entries)), nil
+}
+
+func (store *ConsulStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	k, err := store.keyPath(collection, key)
+	if err != nil {
+		return false, err
+	}
+	// a delete always succeeds, check beforehand whether there is something to delete
+	entry, err := store.entry(ctx, k)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	if entry == nil {
+		return false, nil
+	}
+	resp, err := store.do(ctx, http.MethodDelete, store.kvUrl(k, url.Values{}), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	resp.Body.Close()
+	return true, nil
+}
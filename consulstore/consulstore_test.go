@@ -0,0 +1,128 @@
+package consulstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/consulstore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func newConsulStore(t *testing.T) *consulstore.ConsulStore {
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		t.Skip("skipping consul tests, they depend on testcontainers")
+	}
+	ctx := context.Background()
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	req := testcontainers.ContainerRequest{
+		Image:        "hashicorp/consul:1.19",
+		ExposedPorts: []string{"8500/tcp"},
+		Cmd:          []string{"agent", "-dev", "-client", "0.0.0.0"},
+		WaitingFor:   wait.ForHTTP("/v1/status/leader").WithPort("8500/tcp").WithStartupTimeout(60 * time.Second),
+	}
+	consulContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start Consul container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := consulContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate Consul container: %v", err)
+		}
+	})
+
+	host, err := consulContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get Consul container host: %v", err)
+	}
+	port, err := consulContainer.MappedPort(ctx, "8500")
+	if err != nil {
+		t.Fatalf("failed to get Consul container port: %v", err)
+	}
+
+	store, err := consulstore.NewConsulStore(nil, consulstore.Config{Address: fmt.Sprintf("http://%s:%s", host, port.Port())})
+	if err != nil {
+		t.Fatalf("NewConsulStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestConsulStore(t *testing.T) {
+	store := newConsulStore(t)
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
@@ -5,14 +5,101 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // dbDocument represents the data columns to be stored using gorm
 type dbDocument struct {
-	ID         string          `gorm:"primaryKey"`
-	Collection string          `gorm:"primaryKey"`
-	Value      json.RawMessage `gorm:"type:json"`
+	// the (collection, id) index serves the listings and prefix scans of a collection
+	ID         dbKey `gorm:"primaryKey;index:,composite:collection_id,priority:2"`
+	Collection dbKey `gorm:"primaryKey;index:,composite:collection_id,priority:1"`
+	Value      dbJson
+	// Version is incremented on every write and used for optimistic locking
+	Version int64 `gorm:"not null;default:1"`
+	// UpdatedAt is set by gorm on every write, it is NULL for documents written before the column was added
+	UpdatedAt time.Time
+}
+
+// dbJson is the type of the value column, the column is declared with the native json type of the dialect
+type dbJson json.RawMessage
+
+// jsonTypeSetting is the gorm setting holding the DbStoreOptions.JsonColumnType used by the migrations
+const jsonTypeSetting = "jsonstore:json_column_type"
+
+// GormDBDataType returns the column type used by the migrations
+func (dbJson) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if v, ok := db.Get(jsonTypeSetting); ok {
+		if t, _ := v.(string); t != "" {
+			return t
+		}
+	}
+	return DialectJsonType(db.Dialector.Name())
+}
+
+// Scan reads the value column, some drivers return the json columns as text, e.g. sqlite for rows not
+// written through the store
+func (j *dbJson) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(dbJson{}, v...)
+	case string:
+		*j = dbJson(v)
+	default:
+		return fmt.Errorf("unsupported type %T for the value column", src)
+	}
+	return nil
+}
+
+// DialectJsonType returns the best native json column type of a gorm dialect
+func DialectJsonType(dialect string) string {
+	switch dialect {
+	case "postgres":
+		return "jsonb"
+	case "sqlserver":
+		return "nvarchar(max)"
+	}
+	// mysql, sqlite
+	return "json"
+}
+
+// dbKey is the type of the id and collection columns, on mysql the columns are declared as varchar
+// with the size of the DbStoreOptions
+type dbKey string
+
+// keySizeSetting is the gorm setting holding the column sizes by column name used by the migrations
+const keySizeSetting = "jsonstore:key_column_sizes"
+
+// DefaultMySQLKeySize is the size of the id and collection varchar columns on mysql, it is the gorm default
+// for indexed strings and keeps the primary key within the index length limit of InnoDB.
+const DefaultMySQLKeySize = 191
+
+// mysqlMaxIndexBytes is the maximum length of an InnoDB index, utf8mb4 characters take up to 4 bytes
+const mysqlMaxIndexBytes = 3072
+
+// GormDBDataType returns the column type used by the migrations, other dialects use the gorm default
+func (dbKey) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() != "mysql" {
+		return ""
+	}
+	size := DefaultMySQLKeySize
+	if v, ok := db.Get(keySizeSetting); ok {
+		if sizes, _ := v.(map[string]int); sizes[field.DBName] > 0 {
+			size = sizes[field.DBName]
+		}
+	}
+	return fmt.Sprintf("varchar(%d)", size)
 }
 
 func (d dbDocument) Validate() error {
@@ -25,138 +112,838 @@ func (d dbDocument) Validate() error {
 	return nil
 }
 
+// dbSoftDocument is the dbDocument with the deleted_at column used by gorm soft delete
+type dbSoftDocument struct {
+	Document  dbDocument     `gorm:"embedded"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
 const columnId = "ID"
 const columnValue = "value"
 const columnCollection = "collection"
+const columnDeletedAt = "deleted_at"
+const columnVersion = "version"
+const columnUpdatedAt = "updated_at"
 
 // DbStore does a setup to use a DB to store kv data
 type DbStore struct {
-	db *gorm.DB
+	db   *gorm.DB
+	opts DbStoreOptions
+	// sharedTable is the table of dbDocument as named by the gorm naming strategy
+	sharedTable string
+
+	// tables holds the per collection tables known to exist
+	tablesMu sync.RWMutex
+	tables   map[string]bool
+}
+
+// DefaultTablePrefix is the prefix of the tables created in table per collection mode
+const DefaultTablePrefix = "db_documents_"
+
+// DbStoreOptions configures the behaviour of a DbStore
+type DbStoreOptions struct {
+	// TablePerCollection stores every collection in its own table, named TablePrefix + collection and created
+	// on the first write, instead of a single shared table. This allows to vacuum and index the collections
+	// independently and to drop a collection by dropping its table.
+	// Collection names are restricted to lowercase letters, digits and underscores.
+	TablePerCollection bool
+	// TablePrefix is the prefix of the per collection tables, if empty DefaultTablePrefix is used
+	TablePrefix string
+	// JsonColumnType overrides the type of the value column, by default the native json type of the dialect
+	// is used, see DialectJsonType. Existing tables are altered by the migration if the type differs.
+	JsonColumnType string
+	// Reader, if set, is the database used by the read operations (Get, List, ForEach and Query) while writes
+	// go to the database passed to the constructor, e.g. to read from a replica. Reads might not see the latest
+	// writes if the replica lags behind. Alternatively register gorm's dbresolver plugin on the database.
+	Reader *gorm.DB
+	// MaxRetries is the amount of times a write is retried when the database reports a transient error,
+	// e.g. SQLITE_BUSY "database is locked" or a deadlock; zero disables the retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled at every further attempt;
+	// if zero DefaultRetryBackoff is used.
+	RetryBackoff time.Duration
+	// SoftDelete marks the deleted documents with a deleted_at timestamp instead of removing them, they are
+	// hidden to all the operations but can be recovered with Restore until removed with Purge.
+	SoftDelete bool
+	// Timeout is applied to every operation whose context has no deadline, so that a hung database cannot block
+	// the callers indefinitely; zero disables it. ForEach is excluded since its duration depends on the callback.
+	Timeout time.Duration
+	// KeyColumnSize and CollectionColumnSize are the varchar sizes of the id and collection columns on mysql,
+	// if zero DefaultMySQLKeySize is used. Together they need to fit the 3072 bytes index length limit of InnoDB.
+	// Longer keys and collection names are rejected by the writes, on all dialects when the sizes are set.
+	KeyColumnSize        int
+	CollectionColumnSize int
+	// ValueEncoding stores the values as CBOR or MessagePack in a blob column instead of json, to reduce the storage
+	// and I/O of large documents; the values are converted back to json on read, with the object keys sorted.
+	// Queries are then evaluated client-side. The encoding needs to be chosen when the table is created.
+	ValueEncoding ValueEncoding
+	// ValidateJson rejects the writes of values that are not valid json with InvalidJsonErr, instead of
+	// storing them or failing with a driver error on databases with strict json columns.
+	ValidateJson bool
+	// PartitionByCollection creates the shared table partitioned by the collection column (Postgres declarative
+	// partitioning), the partition of a collection is named TablePrefix + collection and created on the first write.
+	// Dropping a collection then drops its partition, and the indexes are per partition. It is only supported
+	// on postgres and needs to be chosen when the table is created; collection names are restricted as
+	// in TablePerCollection mode.
+	PartitionByCollection bool
+	// SkipMigrate disables the schema changes done by the store: the tables are not migrated on creation,
+	// and in table per collection and partition modes the tables are not created on the first write.
+	// The schema can then be created with MigrateWithOptions or out of the statements of SchemaSQLWithOptions.
+	SkipMigrate bool
+	// CloseDB closes the databases of the store (including Reader) on Close, set it when the store owns
+	// the connection pool; by default Close leaves them open for the other users of the gorm.DB.
+	CloseDB bool
+	// MaxListItems is the maximum page size of List, ListPage and Query, requests for larger pages get this
+	// amount of documents; if zero the MaxLimit of the request applies, MaxListItems by default.
+	MaxListItems int
 }
 
 // make sure the DB store fulfills the JsonStoreList interface
 var _ JsonStorer = &DbStore{}
+var _ PageLister = &DbStore{}
+var _ ForEacher = &DbStore{}
+var _ VersionedStorer = &DbStore{}
+var _ ModTimeGetter = &DbStore{}
+var _ Purger = &DbStore{}
+var _ PurgeLister = &DbStore{}
+var _ Snapshotter = &DbStore{}
+var _ Closer = &DbStore{}
 
 const DefaultCollection = "default"
 
 func NewDbStore(db *gorm.DB) (*DbStore, error) {
-	err := db.AutoMigrate(&dbDocument{})
-	if err != nil {
+	return NewDbStoreWithOptions(db, DbStoreOptions{})
+}
+
+// NewDbStoreWithoutMigrate creates a DbStore without running AutoMigrate, for environments where the schema
+// changes go through a migration pipeline, see Migrate and SchemaSQL.
+func NewDbStoreWithoutMigrate(db *gorm.DB) (*DbStore, error) {
+	return NewDbStoreWithOptions(db, DbStoreOptions{SkipMigrate: true})
+}
+
+// NewDbStoreWithOptions creates a DbStore configured with opts, the shared table is migrated unless
+// the store uses a table per collection or opts.SkipMigrate is set.
+func NewDbStoreWithOptions(db *gorm.DB, opts DbStoreOptions) (*DbStore, error) {
+	if opts.TablePrefix == "" {
+		opts.TablePrefix = DefaultTablePrefix
+	}
+	if err := opts.validate(db.Dialector.Name()); err != nil {
+		return nil, err
+	}
+	stmt := gorm.Statement{DB: db}
+	if err := stmt.Parse(&dbDocument{}); err != nil {
 		return nil, err
 	}
 	store := DbStore{
-		db: db,
+		db:          db,
+		opts:        opts,
+		sharedTable: stmt.Schema.Table,
+		tables:      map[string]bool{},
+	}
+	if opts.PartitionByCollection && !opts.SkipMigrate {
+		if err := store.createPartitionedTable(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.TablePerCollection && !opts.SkipMigrate {
+		err := store.migrator(context.Background()).Table(store.sharedTable).AutoMigrate(store.model())
+		if err != nil {
+			return nil, err
+		}
 	}
 	return &store, nil
 }
 
+var tableNameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// maxTableName is the shortest identifier limit of the supported databases, the one of Postgres
+const maxTableName = 63
+
+// tableName returns the table holding the documents of a collection in table per collection mode
+func (store *DbStore) tableName(collection string) (string, error) {
+	name := store.opts.TablePrefix + collection
+	if !tableNameRegex.MatchString(collection) || len(name) > maxTableName {
+		return "", fmt.Errorf("invalid collection name %q: only lowercase letters, digits and underscores are allowed, "+
+			"up to %d characters", collection, maxTableName-len(store.opts.TablePrefix))
+	}
+	return name, nil
+}
+
+// validate checks the options, the table prefix needs to be set
+func (opts DbStoreOptions) validate(dialect string) error {
+	if opts.TablePerCollection || opts.PartitionByCollection {
+		if !tableNameRegex.MatchString(opts.TablePrefix) {
+			return fmt.Errorf("invalid table prefix %q", opts.TablePrefix)
+		}
+	}
+	if opts.PartitionByCollection {
+		if dialect != "postgres" {
+			return fmt.Errorf("partition by collection is only supported on postgres, not on %s", dialect)
+		}
+		if opts.TablePerCollection {
+			return fmt.Errorf("partition by collection cannot be used together with table per collection")
+		}
+	}
+	if err := opts.ValueEncoding.validate(); err != nil {
+		return err
+	}
+	if opts.KeyColumnSize < 0 || opts.CollectionColumnSize < 0 {
+		return fmt.Errorf("column sizes cannot be negative")
+	}
+	if dialect == "mysql" {
+		keySize, collectionSize := opts.keySizes(dialect)
+		if (keySize+collectionSize)*4 > mysqlMaxIndexBytes {
+			return fmt.Errorf("key and collection column sizes exceed the %d bytes index length limit of mysql",
+				mysqlMaxIndexBytes)
+		}
+	}
+	return nil
+}
+
+// createPartitionedTable creates the shared table partitioned by collection if it does not exist,
+// an existing table needs to be partitioned already.
+func (store *DbStore) createPartitionedTable(ctx context.Context) error {
+	db := store.db.WithContext(ctx)
+	if db.Migrator().HasTable(store.sharedTable) {
+		var partitioned int64
+		err := db.Raw("SELECT COUNT(*) FROM pg_partitioned_table WHERE partrelid = to_regclass(?)", store.sharedTable).
+			Scan(&partitioned).Error
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", store.sharedTable, err)
+		}
+		if partitioned == 0 {
+			return fmt.Errorf("table %s exists and is not partitioned", store.sharedTable)
+		}
+		return nil
+	}
+	stmts, err := schemaStatements(store.db.Dialector.Name(), store.opts, store.sharedTable, nil)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to create partitioned table: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// keySizes returns the sizes of the id and collection columns on the dialect, zero means unlimited
+func (opts DbStoreOptions) keySizes(dialect string) (keySize, collectionSize int) {
+	keySize, collectionSize = opts.KeyColumnSize, opts.CollectionColumnSize
+	if dialect == "mysql" {
+		if keySize == 0 {
+			keySize = DefaultMySQLKeySize
+		}
+		if collectionSize == 0 {
+			collectionSize = DefaultMySQLKeySize
+		}
+	}
+	return keySize, collectionSize
+}
+
+// validate checks that the document can be written, the key and collection need to fit the columns
+func (store *DbStore) validate(doc dbDocument) error {
+	if err := doc.Validate(); err != nil {
+		return err
+	}
+	keySize, collectionSize := store.opts.keySizes(store.db.Dialector.Name())
+	if keySize > 0 && utf8.RuneCountInString(string(doc.ID)) > keySize {
+		return fmt.Errorf("id cannot be longer than %d characters", keySize)
+	}
+	if collectionSize > 0 && utf8.RuneCountInString(string(doc.Collection)) > collectionSize {
+		return fmt.Errorf("collection cannot be longer than %d characters", collectionSize)
+	}
+	if store.opts.ValidateJson && !json.Valid(doc.Value) {
+		return fmt.Errorf("%w: value of document with ID %s", InvalidJsonErr, doc.ID)
+	}
+	return nil
+}
+
+// model returns the gorm model of the documents, the soft delete one includes the deleted_at column
+func (store *DbStore) model() any {
+	if store.opts.SoftDelete {
+		return &dbSoftDocument{}
+	}
+	return &dbDocument{}
+}
+
+// withTimeout applies the default timeout of the store to a context without deadline
+func (store *DbStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if store.opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, store.opts.Timeout)
+}
+
+// migrator returns a session carrying the settings used by the migrations
+func (store *DbStore) migrator(ctx context.Context) *gorm.DB {
+	columnType := store.opts.JsonColumnType
+	if columnType == "" && store.opts.ValueEncoding.binary() {
+		columnType = DialectBlobType(store.db.Dialector.Name())
+	}
+	return store.db.WithContext(ctx).
+		Set(jsonTypeSetting, columnType).
+		Set(keySizeSetting, map[string]int{
+			"id":             store.opts.KeyColumnSize,
+			columnCollection: store.opts.CollectionColumnSize,
+		})
+}
+
+// tableTx returns a session on the table using the model of the store, with soft delete the queries
+// only see the documents not deleted.
+func (store *DbStore) tableTx(ctx context.Context, table string) *gorm.DB {
+	return store.db.WithContext(ctx).Table(table).Model(store.model())
+}
+
+// readTx returns a session on the table like tableTx, using the reader database if configured
+func (store *DbStore) readTx(ctx context.Context, table string) *gorm.DB {
+	db := store.db
+	if store.opts.Reader != nil {
+		db = store.opts.Reader
+	}
+	return db.WithContext(ctx).Table(table).Model(store.model())
+}
+
+// collectionTable returns the table holding the documents of the collection. In table per collection mode
+// the table is created if create is set and migrations are not skipped, otherwise exists is false if the
+// table has not been created yet.
+func (store *DbStore) collectionTable(ctx context.Context, collection string, create bool) (table string, exists bool, err error) {
+	if store.opts.PartitionByCollection && create {
+		if err = store.createPartition(ctx, collection); err != nil {
+			return "", false, err
+		}
+	}
+	if !store.opts.TablePerCollection {
+		return store.sharedTable, true, nil
+	}
+	table, err = store.tableName(collection)
+	if err != nil {
+		return "", false, err
+	}
+
+	store.tablesMu.RLock()
+	exists = store.tables[table]
+	store.tablesMu.RUnlock()
+	if exists {
+		return table, true, nil
+	}
+	// tables are created holding the lock so concurrent writes don't race on the creation
+	store.tablesMu.Lock()
+	defer store.tablesMu.Unlock()
+	if store.tables[table] {
+		return table, true, nil
+	}
+	exists = store.db.WithContext(ctx).Migrator().HasTable(table)
+	if !exists && create && !store.opts.SkipMigrate {
+		err = store.migrator(ctx).Table(table).AutoMigrate(store.model())
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create table for collection %s: %v", collection, err)
+		}
+		exists = true
+	}
+	if exists {
+		store.tables[table] = true
+	}
+	return table, exists, nil
+}
+
+// createPartition creates the partition of the collection if it does not exist yet
+func (store *DbStore) createPartition(ctx context.Context, collection string) error {
+	partition, err := store.tableName(collection)
+	if err != nil {
+		return err
+	}
+	store.tablesMu.RLock()
+	exists := store.tables[partition]
+	store.tablesMu.RUnlock()
+	if exists {
+		return nil
+	}
+	store.tablesMu.Lock()
+	defer store.tablesMu.Unlock()
+	if store.tables[partition] {
+		return nil
+	}
+	if store.opts.SkipMigrate {
+		if !store.db.WithContext(ctx).Migrator().HasTable(partition) {
+			return fmt.Errorf("partition %s for collection %s does not exist", partition, collection)
+		}
+	} else {
+		stmt, err := store.partitionSQL(store.sharedTable, collection)
+		if err != nil {
+			return err
+		}
+		if err = store.db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create partition for collection %s: %v", collection, err)
+		}
+	}
+	store.tables[partition] = true
+	return nil
+}
+
 func (store *DbStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
 	if collection == "" {
 		collection = DefaultCollection
 	}
 	doc := dbDocument{
-		ID:         key,
-		Collection: collection,
-		Value:      value,
+		ID:         dbKey(key),
+		Collection: dbKey(collection),
+		Value:      dbJson(value),
+		Version:    1,
 	}
 
-	err := doc.Validate()
+	err := store.validate(doc)
 	if err != nil {
 		return err
 	}
+	if doc.Value, err = store.opts.ValueEncoding.encode(value); err != nil {
+		return err
+	}
 
-	err = store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.WithContext(ctx).Save(&doc).Error; err != nil {
-			return fmt.Errorf("failed to save document: %v", err)
-		}
-		return nil
-	})
+	table, exists, err := store.collectionTable(ctx, collection, true)
 	if err != nil {
 		return err
 	}
-
+	if !exists {
+		return fmt.Errorf("failed to save document: table %s does not exist", table)
+	}
+	updates := clause.AssignmentColumns([]string{columnValue, columnUpdatedAt})
+	if store.opts.SoftDelete {
+		// writing a deleted document brings it back
+		updates = append(updates, clause.AssignmentColumns([]string{columnDeletedAt})...)
+	}
+	// the existing row is referenced by the table name, an unqualified column is ambiguous on postgres
+	updates = append(updates, clause.Assignment{
+		Column: clause.Column{Name: columnVersion},
+		Value:  gorm.Expr("? + 1", clause.Column{Table: table, Name: columnVersion}),
+	})
+	// a single upsert statement: ON CONFLICT on sqlite and postgres, ON DUPLICATE KEY UPDATE on mysql
+	err = store.retry(ctx, func() error {
+		return store.tableTx(ctx, table).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}, {Name: columnCollection}},
+				DoUpdates: updates,
+			}).
+			Create(store.row(doc)).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
 	return nil
 }
 
+// row returns the document as the model of the store
+func (store *DbStore) row(doc dbDocument) any {
+	if store.opts.SoftDelete {
+		return &dbSoftDocument{Document: doc}
+	}
+	return &doc
+}
+
+// SetIfVersion stores the document only if the stored version matches using an UPDATE ... WHERE version = ?,
+// an empty version requires the document to not exist yet. Soft deleted documents count as not existing.
+func (store *DbStore) SetIfVersion(ctx context.Context, collection, key string, value json.RawMessage, version string) (string, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	doc := dbDocument{
+		ID:         dbKey(key),
+		Collection: dbKey(collection),
+		Value:      dbJson(value),
+		Version:    1,
+	}
+	err := store.validate(doc)
+	if err != nil {
+		return "", err
+	}
+	if doc.Value, err = store.opts.ValueEncoding.encode(value); err != nil {
+		return "", err
+	}
+	table, exists, err := store.collectionTable(ctx, collection, true)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("failed to save document: table %s does not exist", table)
+	}
+	if version == "" {
+		return store.create(ctx, table, doc)
+	}
+	current, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		// a version that is not a number can never match a stored one
+		return "", VersionConflictErr
+	}
+
+	var result *gorm.DB
+	err = store.retry(ctx, func() error {
+		result = store.tableTx(ctx, table).
+			Where(fmt.Sprintf("%s = ? AND %s = ? AND %s = ?", columnId, columnCollection, columnVersion), key, collection, current).
+			Updates(map[string]any{
+				columnValue:   doc.Value,
+				columnVersion: gorm.Expr(columnVersion + " + 1"),
+			})
+		return result.Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save document: %v", err)
+	}
+	if result.RowsAffected == 0 {
+		return "", VersionConflictErr
+	}
+	return strconv.FormatInt(current+1, 10), nil
+}
+
+// create inserts a document that must not exist yet, returning its version
+func (store *DbStore) create(ctx context.Context, table string, doc dbDocument) (string, error) {
+	var version int64
+	err := store.retry(ctx, func() error {
+		return store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if store.opts.SoftDelete {
+				// a soft deleted document is brought back, keeping the version increasing so that
+				// versions read before the deletion don't match
+				result := tx.Table(table).Model(store.model()).
+					Unscoped().
+					Where(fmt.Sprintf("%s = ? AND %s = ? AND %s IS NOT NULL", columnId, columnCollection, columnDeletedAt), doc.ID, doc.Collection).
+					Updates(map[string]any{
+						columnValue:     doc.Value,
+						columnDeletedAt: nil,
+						columnVersion:   gorm.Expr(columnVersion + " + 1"),
+					})
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected > 0 {
+					return tx.Table(table).Model(store.model()).
+						Select(columnVersion).
+						Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), doc.ID, doc.Collection).
+						Scan(&version).Error
+				}
+			}
+			result := tx.Table(table).Model(store.model()).
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(store.row(doc))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return VersionConflictErr
+			}
+			version = doc.Version
+			return nil
+		})
+	})
+	if err != nil {
+		if errors.Is(err, VersionConflictErr) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to save document: %v", err)
+	}
+	return strconv.FormatInt(version, 10), nil
+}
+
+// recordNotFoundErr is returned by Get for missing documents, it matches both ItemNotFoundErr and the
+// gorm.ErrRecordNotFound returned by earlier versions
+var recordNotFoundErr = fmt.Errorf("%w: %w", ItemNotFoundErr, gorm.ErrRecordNotFound)
+
+// Get reads a document, it returns an error matching ItemNotFoundErr and gorm.ErrRecordNotFound if it does not exist
 func (store *DbStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
 	if collection == "" {
 		collection = DefaultCollection
 	}
 
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return recordNotFoundErr
+	}
 	item := dbDocument{}
-	err := store.db.Model(&dbDocument{}).
+	err = store.readTx(ctx, table).
 		Select(columnValue).
-		WithContext(ctx).
 		Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
 		First(&item).Error
-	*value = item.Value
+	*value = json.RawMessage(item.Value)
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
+			return recordNotFoundErr
 		}
 		return fmt.Errorf("failed to retrieve document: %v", err)
 	}
-	return nil
+	*value, err = store.opts.ValueEncoding.decode(item.Value)
+	return err
 }
 
+// GetWithVersion reads a document together with its current version, it returns ItemNotFoundErr
+// if the document does not exist.
+func (store *DbStore) GetWithVersion(ctx context.Context, collection, key string, value *json.RawMessage) (string, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ItemNotFoundErr
+	}
+	item := dbDocument{}
+	err = store.readTx(ctx, table).
+		Select(fmt.Sprintf("%s, %s", columnValue, columnVersion)).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
+		First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ItemNotFoundErr
+		}
+		return "", fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value, err = store.opts.ValueEncoding.decode(item.Value)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(item.Version, 10), nil
+}
+
+// GetWithModTime reads a document together with the time of its last write, the time is zero for documents
+// written before the updated_at column was added; it returns ItemNotFoundErr if the document does not exist.
+func (store *DbStore) GetWithModTime(ctx context.Context, collection, key string, value *json.RawMessage) (time.Time, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !exists {
+		return time.Time{}, ItemNotFoundErr
+	}
+	item := dbDocument{}
+	err = store.readTx(ctx, table).
+		Select(fmt.Sprintf("%s, %s", columnValue, columnUpdatedAt)).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
+		First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ItemNotFoundErr
+		}
+		return time.Time{}, fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value, err = store.opts.ValueEncoding.decode(item.Value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return item.UpdatedAt, nil
+}
+
+// MaxListItems is the default maximum page size of the lists, see ListOptions.MaxLimit
 const MaxListItems = 20
 
+// List returns the documents of a collection and the total amount of documents in it
 func (store *DbStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := store.ListPage(ctx, collection, ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage returns a page of documents of the collection together with the pagination metadata
+func (store *DbStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
 	if collection == "" {
 		collection = DefaultCollection
 	}
-	if limit == 0 || limit > MaxListItems {
-		limit = MaxListItems
+	opts, err := opts.capLimit(store.opts.MaxListItems).Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return Page{}, err
 	}
-	if page < 1 {
-		page = 1
+	if !exists {
+		if opts.SkipTotal {
+			return NewPageWithoutTotal(nil, opts.Page, opts.Limit, false), nil
+		}
+		return NewPage(nil, 0, opts.Page, opts.Limit), nil
 	}
-	offset := (page - 1) * limit
 
 	var count int64
-	// Perform a count query based on the collection column.
-	err := store.db.Model(&dbDocument{}).
-		WithContext(ctx).
-		Where(fmt.Sprintf("%s = ? ", columnCollection), collection).
-		Count(&count).Error
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	limit := opts.Limit
+	if opts.SkipTotal {
+		// fetch one more item to know if there is a next page
+		limit++
+	} else {
+		// Perform a count query based on the collection column.
+		err = store.readTx(ctx, table).
+			Where(fmt.Sprintf("%s = ? ", columnCollection), collection).
+			Scopes(store.prefixScope(opts.Prefix)).
+			Count(&count).Error
+		if err != nil {
+			return Page{}, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+		}
 	}
 
 	items := []dbDocument{}
 	// Query the database to get all the documents in the collection
-	err = store.db.
-		Model(&dbDocument{}).
-		WithContext(ctx).
+	err = store.readTx(ctx, table).
+		Select(fmt.Sprintf("id, %s", columnValue)).
 		Where(fmt.Sprintf("%s = ? ", columnCollection), collection).
+		Scopes(store.prefixScope(opts.Prefix)).
 		Order("id ASC").
 		Limit(limit).
 		Offset(offset).
 		Find(&items).Error
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+		return Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	hasNext := len(items) > opts.Limit
+	if hasNext {
+		items = items[:opts.Limit]
 	}
 
 	result := map[string]json.RawMessage{}
 	for _, item := range items {
-		result[item.ID] = item.Value
+		result[string(item.ID)], err = store.opts.ValueEncoding.decode(item.Value)
+		if err != nil {
+			return Page{}, err
+		}
+	}
+	if opts.SkipTotal {
+		return NewPageWithoutTotal(result, opts.Page, opts.Limit, hasNext), nil
+	}
+	return NewPage(result, count, opts.Page, opts.Limit), nil
+}
+
+// prefixScope restricts a query to the keys starting with prefix using LIKE, served by the (collection, id) index.
+// On sqlite LIKE is case-insensitive and does not use the index, a range on the binary ordering of the keys is added.
+func (store *DbStore) prefixScope(prefix string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if prefix == "" {
+			return tx
+		}
+		pattern := likeEscaper.Replace(prefix) + "%"
+		if store.db.Dialector.Name() == "mysql" {
+			// \ is already the default escape character, and mysql would read '\' as an unterminated string
+			tx = tx.Where("id LIKE ?", pattern)
+		} else {
+			tx = tx.Where(`id LIKE ? ESCAPE '\'`, pattern)
+		}
+		if store.db.Dialector.Name() == "sqlite" {
+			tx = tx.Where("id >= ?", prefix)
+			if upper, ok := prefixUpperBound(prefix); ok {
+				tx = tx.Where("id < ?", upper)
+			}
+		}
+		return tx
 	}
-	return result, count, nil
+}
+
+// prefixUpperBound returns the smallest string greater than all the strings starting with prefix in binary order,
+// ok is false if there is none.
+func prefixUpperBound(prefix string) (string, bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+// ForEach streams the documents of the collection in key order out of the database rows, without loading
+// the whole collection in memory. The rows are read while fn runs, fn should not write to the store since
+// databases limited to a single connection, e.g. in memory sqlite, would block.
+func (store *DbStore) ForEach(ctx context.Context, collection string, fn func(key string, value json.RawMessage) error) error {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	rows, err := store.readTx(ctx, table).
+		Select(fmt.Sprintf("id, %s", columnValue)).
+		Where(fmt.Sprintf("%s = ?", columnCollection), collection).
+		Order("id ASC").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err = rows.Scan(&key, &value); err != nil {
+			return fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		if value, err = store.opts.ValueEncoding.decode(value); err != nil {
+			return err
+		}
+		if err = fn(key, value); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	return nil
 }
 
 func (store *DbStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
 	if collection == "" {
 		collection = DefaultCollection
 	}
-	result := store.db.
-		WithContext(ctx).
-		Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
-		Delete(&dbDocument{})
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+	// with soft delete the row is updated setting deleted_at
+	var result *gorm.DB
+	err = store.retry(ctx, func() error {
+		result = store.tableTx(ctx, table).
+			Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
+			Delete(store.model())
+		return result.Error
+	})
 
 	// Check if there was an error during the deletion
-	if result.Error != nil {
-		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, result.Error)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
 	}
 	switch result.RowsAffected {
 	case 0:
@@ -168,3 +955,271 @@ func (store *DbStore) Delete(ctx context.Context, collection, key string) (bool,
 	}
 
 }
+
+// DeleteIfVersion deletes the document only if the stored version matches
+func (store *DbStore) DeleteIfVersion(ctx context.Context, collection, key, version string) error {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ItemNotFoundErr
+	}
+	current, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		current = -1 // never matches, but still allows to report a missing document
+	}
+	var result *gorm.DB
+	err = store.retry(ctx, func() error {
+		result = store.tableTx(ctx, table).
+			Where(fmt.Sprintf("%s = ? AND %s = ? AND %s = ?", columnId, columnCollection, columnVersion), key, collection, current).
+			Delete(store.model())
+		return result.Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	// tell apart a version mismatch from a missing document
+	var count int64
+	err = store.tableTx(ctx, table).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnCollection), key, collection).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	if count == 0 {
+		return ItemNotFoundErr
+	}
+	return VersionConflictErr
+}
+
+// DropCollection removes all the documents of a collection, in table per collection mode the table is dropped.
+// Dropped documents are removed even if the store uses soft delete.
+func (store *DbStore) DropCollection(ctx context.Context, collection string) error {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	if store.opts.PartitionByCollection {
+		return store.dropPartition(ctx, collection)
+	}
+	if !store.opts.TablePerCollection {
+		err = store.retry(ctx, func() error {
+			return store.tableTx(ctx, table).
+				Unscoped().
+				Where(fmt.Sprintf("%s = ?", columnCollection), collection).
+				Delete(store.model()).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete collection %s: %v", collection, err)
+		}
+		return nil
+	}
+
+	store.tablesMu.Lock()
+	defer store.tablesMu.Unlock()
+	if err = store.db.WithContext(ctx).Migrator().DropTable(table); err != nil {
+		return fmt.Errorf("failed to drop collection %s: %v", collection, err)
+	}
+	delete(store.tables, table)
+	return nil
+}
+
+// dropPartition drops the partition of a collection, a metadata operation instead of deleting its rows
+func (store *DbStore) dropPartition(ctx context.Context, collection string) error {
+	partition, err := store.tableName(collection)
+	if err != nil {
+		return err
+	}
+	store.tablesMu.Lock()
+	defer store.tablesMu.Unlock()
+	if err = store.db.WithContext(ctx).Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, partition)).Error; err != nil {
+		return fmt.Errorf("failed to drop collection %s: %v", collection, err)
+	}
+	delete(store.tables, partition)
+	return nil
+}
+
+// Collections returns the names of the collections holding documents, sorted. Collections created with
+// CreateCollection are only listed once they hold a document.
+func (store *DbStore) Collections(ctx context.Context) ([]string, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	tables, err := store.storeTables(store.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, table := range tables {
+		var collections []string
+		err = store.readTx(ctx, table).Distinct().Pluck(columnCollection, &collections).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %v", err)
+		}
+		names = append(names, collections...)
+	}
+	sort.Strings(names)
+	return slices.Compact(names), nil
+}
+
+// CreateCollection creates the table, or the partition, of a collection in table per collection or partition
+// mode; in the shared table there is nothing to create.
+func (store *DbStore) CreateCollection(ctx context.Context, collection string) error {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	_, _, err := store.collectionTable(ctx, collection, true)
+	return err
+}
+
+// Restore recovers a soft deleted document, it returns false if there is no deleted document with the key.
+func (store *DbStore) Restore(ctx context.Context, collection, key string) (bool, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if !store.opts.SoftDelete {
+		return false, fmt.Errorf("restore requires the store to use soft delete")
+	}
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+	var result *gorm.DB
+	err = store.retry(ctx, func() error {
+		result = store.tableTx(ctx, table).
+			Unscoped().
+			Where(fmt.Sprintf("%s = ? AND %s = ? AND %s IS NOT NULL", columnId, columnCollection, columnDeletedAt), key, collection).
+			Update(columnDeletedAt, nil)
+		return result.Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to restore document with ID %s: %v", key, err)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Purge permanently removes the documents of the collection soft deleted before the given time,
+// it returns the amount of removed documents.
+func (store *DbStore) Purge(ctx context.Context, collection string, deletedBefore time.Time) (int64, error) {
+	if !store.opts.SoftDelete {
+		return 0, fmt.Errorf("purge requires the store to use soft delete")
+	}
+	return store.PurgeBatch(ctx, collection, deletedBefore, 0)
+}
+
+// PurgeCollections returns the collections holding soft deleted documents, including the collections whose
+// documents are all deleted and therefore not listed by Collections
+func (store *DbStore) PurgeCollections(ctx context.Context) ([]string, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	names := []string{}
+	if !store.opts.SoftDelete {
+		return names, nil
+	}
+	tables, err := store.storeTables(store.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		var collections []string
+		err = store.readTx(ctx, table).Unscoped().Where(fmt.Sprintf("%s IS NOT NULL", columnDeletedAt)).
+			Distinct().Pluck(columnCollection, &collections).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %v", err)
+		}
+		names = append(names, collections...)
+	}
+	sort.Strings(names)
+	return slices.Compact(names), nil
+}
+
+// PurgeBatch permanently removes up to limit documents of the collection soft deleted before the given time, all of
+// them if limit is not positive; without SoftDelete there is nothing to purge. It returns the amount of removed
+// documents.
+func (store *DbStore) PurgeBatch(ctx context.Context, collection string, before time.Time, limit int) (int64, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if !store.opts.SoftDelete {
+		return 0, nil
+	}
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	where := fmt.Sprintf("%s = ? AND %s IS NOT NULL AND %s < ?", columnCollection, columnDeletedAt, columnDeletedAt)
+	var ids []string
+	if limit > 0 {
+		// DELETE ... LIMIT is not portable, the batch is selected first
+		err = store.tableTx(ctx, table).Unscoped().Where(where, collection, before).
+			Order(columnId).Limit(limit).Pluck(columnId, &ids).Error
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge collection %s: %v", collection, err)
+		}
+		if len(ids) == 0 {
+			return 0, nil
+		}
+	}
+	var result *gorm.DB
+	err = store.retry(ctx, func() error {
+		tx := store.tableTx(ctx, table).Unscoped().Where(where, collection, before)
+		if limit > 0 {
+			tx = tx.Where(fmt.Sprintf("%s IN ?", columnId), ids)
+		}
+		result = tx.Delete(store.model())
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge collection %s: %v", collection, err)
+	}
+	return result.RowsAffected, nil
+}
+
+// Close closes the databases of the store if it was created with CloseDB, otherwise it is a no-op
+func (store *DbStore) Close(ctx context.Context) error {
+	if !store.opts.CloseDB {
+		return nil
+	}
+	dbs := []*gorm.DB{store.db}
+	if store.opts.Reader != nil && store.opts.Reader != store.db {
+		dbs = append(dbs, store.opts.Reader)
+	}
+	for _, db := range dbs {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("failed to access database: %v", err)
+		}
+		if err = sqlDB.Close(); err != nil {
+			return fmt.Errorf("failed to close database: %v", err)
+		}
+	}
+	return nil
+}
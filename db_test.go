@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -23,6 +24,7 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 var _ = spew.Dump //keep the dependency
@@ -243,6 +245,42 @@ func TestDb(t *testing.T) {
 			t.Run("concurrency", func(t *testing.T) {
 				testConcurrency(t, db)
 			})
+
+			t.Run("table per collection", func(t *testing.T) {
+				testTablePerCollection(t, db)
+			})
+
+			t.Run("soft delete", func(t *testing.T) {
+				testSoftDelete(t, db)
+			})
+
+			t.Run("json column type", func(t *testing.T) {
+				testJsonColumnType(t, db)
+			})
+
+			t.Run("optimistic locking", func(t *testing.T) {
+				testOptimisticLocking(t, db)
+			})
+
+			t.Run("key column sizes", func(t *testing.T) {
+				testKeyColumnSizes(t, db)
+			})
+
+			t.Run("validate json", func(t *testing.T) {
+				testValidateJson(t, db)
+			})
+
+			t.Run("value encoding", func(t *testing.T) {
+				testValueEncoding(t, db)
+			})
+
+			t.Run("partition by collection", func(t *testing.T) {
+				testPartitionByCollection(t, db)
+			})
+
+			t.Run("upsert", func(t *testing.T) {
+				testUpsert(t, db)
+			})
 		})
 	}
 }
@@ -569,6 +607,30 @@ func testActionList(t *testing.T, db *gorm.DB) {
 			t.Errorf("unexpected value (-got +want)\n%s", diff)
 		}
 	})
+
+	t.Run("asert list without total", func(t *testing.T) {
+		page, err := store.ListPage(context.Background(), "col1", jsonstore.ListOptions{Limit: 2, SkipTotal: true})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		if page.Total != jsonstore.UnknownTotal || !page.HasNext || len(page.Items) != 2 {
+			t.Errorf("unexpected first page: %+v", page)
+		}
+
+		page, err = store.ListPage(context.Background(), "col1", jsonstore.ListOptions{Cursor: page.NextCursor, SkipTotal: true})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item": "collection1 item3"}`),
+		}
+		if diff := cmp.Diff(page.Items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		if page.HasNext {
+			t.Errorf("expected last page to have no next page")
+		}
+	})
 }
 
 func testActionDelete(t *testing.T, db *gorm.DB) {
@@ -700,3 +762,765 @@ func testConcurrency(t *testing.T, db *gorm.DB) {
 		wg.Wait()
 	})
 }
+
+func testTablePerCollection(t *testing.T, db *gorm.DB) {
+	store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{TablePerCollection: true})
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("table is created on first write", func(t *testing.T) {
+		var got json.RawMessage
+		err = store.Get(ctx, "tenant1", "item1", &got)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, got: %v", err)
+		}
+		if db.Migrator().HasTable("db_documents_tenant1") {
+			t.Fatal("expected table to not be created by a read")
+		}
+
+		value := json.RawMessage(`{"item": "my value"}`)
+		err = store.Set(ctx, "tenant1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if !db.Migrator().HasTable("db_documents_tenant1") {
+			t.Fatal("expected table db_documents_tenant1 to be created, but it does not exist")
+		}
+
+		err = store.Get(ctx, "tenant1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err = store.Set(ctx, "tenant2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "tenant2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		deleted, err := store.Delete(ctx, "tenant2", "item3")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+
+		_, total, err = store.List(ctx, "missing", 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 0 {
+			t.Errorf("expected total 0, got %d", total)
+		}
+	})
+
+	t.Run("drop collection", func(t *testing.T) {
+		err = store.DropCollection(ctx, "tenant2")
+		if err != nil {
+			t.Fatalf("action: DropCollection,  returned an error: %v", err)
+		}
+		if db.Migrator().HasTable("db_documents_tenant2") {
+			t.Fatal("expected table db_documents_tenant2 to be dropped")
+		}
+		// the collection is created again on the next write
+		err = store.Set(ctx, "tenant2", "item1", json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	})
+
+	t.Run("invalid collection name", func(t *testing.T) {
+		err = store.Set(ctx, "Tenant-1", "item1", json.RawMessage(`{}`))
+		if err == nil {
+			t.Fatal("expected an error for an invalid collection name")
+		}
+	})
+}
+
+func testSoftDelete(t *testing.T, db *gorm.DB) {
+	store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{SoftDelete: true})
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	ctx := context.Background()
+	const col = "test_soft_delete"
+
+	for i := 1; i <= 3; i++ {
+		err = store.Set(ctx, col, fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+
+	t.Run("deleted documents are hidden", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, col, "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, col, "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, col, "item1", &got)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("expected ErrRecordNotFound, got: %v", err)
+		}
+		items, total, err := store.List(ctx, col, 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 2 {
+			t.Errorf("expected total 2, got %d", total)
+		}
+		if _, ok := items["item1"]; ok {
+			t.Errorf("expected item1 to not be listed")
+		}
+
+		// the row is still in the table
+		var count int64
+		db.Table("db_documents").Where("id = ? AND collection = ?", "item1", col).Count(&count)
+		if count != 1 {
+			t.Errorf("expected the deleted row to be kept, got %d rows", count)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		restored, err := store.Restore(ctx, col, "item1")
+		if err != nil {
+			t.Fatalf("action: Restore,  returned an error: %v", err)
+		}
+		if !restored {
+			t.Errorf("expect Restore to affect one entry, but got false")
+		}
+		var got json.RawMessage
+		err = store.Get(ctx, col, "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, json.RawMessage(`{"item":"item1"}`)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("set brings back a deleted document", func(t *testing.T) {
+		_, err = store.Delete(ctx, col, "item2")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		value := json.RawMessage(`{"item":"new item2"}`)
+		err = store.Set(ctx, col, "item2", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		err = store.Get(ctx, col, "item2", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		_, err = store.Delete(ctx, col, "item3")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		purged, err := store.Purge(ctx, col, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("action: Purge,  returned an error: %v", err)
+		}
+		if purged != 1 {
+			t.Errorf("expected 1 purged document, got %d", purged)
+		}
+		restored, err := store.Restore(ctx, col, "item3")
+		if err != nil {
+			t.Fatalf("action: Restore,  returned an error: %v", err)
+		}
+		if restored {
+			t.Errorf("expect a purged document to not be restored")
+		}
+	})
+}
+
+func testOptimisticLocking(t *testing.T, db *gorm.DB) {
+	store, err := jsonstore.NewDbStore(db)
+	if err != nil {
+		t.Fatalf("NewDbStore returned an error: %v", err)
+	}
+	ctx := context.Background()
+	const col = "test_versions"
+
+	t.Run("conditional writes", func(t *testing.T) {
+		v1, err := store.SetIfVersion(ctx, col, "item1", json.RawMessage(`{}`), "")
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		_, err = store.SetIfVersion(ctx, col, "item1", json.RawMessage(`{}`), "")
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr when creating an existing item, got: %v", err)
+		}
+
+		v2, err := store.SetIfVersion(ctx, col, "item1", json.RawMessage(`{"a": 1}`), v1)
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if v2 == v1 {
+			t.Errorf("expected the version to change on write")
+		}
+		_, err = store.SetIfVersion(ctx, col, "item1", json.RawMessage(`{"a":2}`), v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on a stale version, got: %v", err)
+		}
+		_, err = store.SetIfVersion(ctx, col, "item1", json.RawMessage(`{"a":2}`), "not a version")
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on an invalid version, got: %v", err)
+		}
+
+		var got json.RawMessage
+		version, err := store.GetWithVersion(ctx, col, "item1", &got)
+		if err != nil {
+			t.Fatalf("action: GetWithVersion,  returned an error: %v", err)
+		}
+		if version != v2 {
+			t.Errorf("expected version %s, got %s", v2, version)
+		}
+		if diff := cmp.Diff(got, json.RawMessage(`{"a": 1}`)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.DeleteIfVersion(ctx, col, "item1", v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on a stale version, got: %v", err)
+		}
+		err = store.DeleteIfVersion(ctx, col, "item1", v2)
+		if err != nil {
+			t.Fatalf("action: DeleteIfVersion,  returned an error: %v", err)
+		}
+		err = store.DeleteIfVersion(ctx, col, "item1", v2)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+		_, err = store.GetWithVersion(ctx, col, "item1", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("set increments the version", func(t *testing.T) {
+		v1, err := store.SetIfVersion(ctx, col, "item2", json.RawMessage(`{}`), "")
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if err = store.Set(ctx, col, "item2", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		_, err = store.SetIfVersion(ctx, col, "item2", json.RawMessage(`{"a":2}`), v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr after a concurrent Set, got: %v", err)
+		}
+	})
+
+	t.Run("soft deleted documents", func(t *testing.T) {
+		softStore, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{SoftDelete: true})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		v1, err := softStore.SetIfVersion(ctx, col, "item3", json.RawMessage(`{}`), "")
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if err = softStore.DeleteIfVersion(ctx, col, "item3", v1); err != nil {
+			t.Fatalf("action: DeleteIfVersion,  returned an error: %v", err)
+		}
+		// the deleted document does not exist, but a version read before the deletion does not match
+		_, err = softStore.SetIfVersion(ctx, col, "item3", json.RawMessage(`{}`), v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on a deleted document, got: %v", err)
+		}
+		v2, err := softStore.SetIfVersion(ctx, col, "item3", json.RawMessage(`{"a":1}`), "")
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if v2 == v1 {
+			t.Errorf("expected the version to change when bringing back a deleted document")
+		}
+		var got json.RawMessage
+		version, err := softStore.GetWithVersion(ctx, col, "item3", &got)
+		if err != nil {
+			t.Fatalf("action: GetWithVersion,  returned an error: %v", err)
+		}
+		if version != v2 {
+			t.Errorf("expected version %s, got %s", v2, version)
+		}
+	})
+}
+
+func testKeyColumnSizes(t *testing.T, db *gorm.DB) {
+	opts := jsonstore.DbStoreOptions{
+		TablePerCollection:   true,
+		TablePrefix:          "sized_",
+		KeyColumnSize:        64,
+		CollectionColumnSize: 16,
+	}
+	store, err := jsonstore.NewDbStoreWithOptions(db, opts)
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("keys within the size are stored", func(t *testing.T) {
+		err = store.Set(ctx, "col1", strings.Repeat("k", 64), json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if db.Dialector.Name() != "mysql" {
+			return
+		}
+		columns, err := db.Migrator().ColumnTypes("sized_col1")
+		if err != nil {
+			t.Fatalf("unable to read the columns of sized_col1: %v", err)
+		}
+		got := map[string]int64{}
+		for _, c := range columns {
+			if size, ok := c.Length(); ok {
+				got[c.Name()] = size
+			}
+		}
+		want := map[string]int64{"id": 64, "collection": 16}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("longer keys are rejected", func(t *testing.T) {
+		err = store.Set(ctx, "col1", strings.Repeat("k", 65), json.RawMessage(`{}`))
+		if err == nil {
+			t.Error("expected an error for a key longer than the column")
+		}
+		err = store.Set(ctx, strings.Repeat("c", 17), "item1", json.RawMessage(`{}`))
+		if err == nil {
+			t.Error("expected an error for a collection longer than the column")
+		}
+	})
+
+	t.Run("sizes exceeding the mysql index limit", func(t *testing.T) {
+		if db.Dialector.Name() != "mysql" {
+			t.Skip("the index length limit only applies to mysql")
+		}
+		_, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{KeyColumnSize: 512, CollectionColumnSize: 512})
+		if err == nil {
+			t.Error("expected an error for column sizes exceeding the index length limit")
+		}
+	})
+}
+
+func testValidateJson(t *testing.T, db *gorm.DB) {
+	store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{ValidateJson: true})
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	ctx := context.Background()
+	const col = "test_validate_json"
+
+	err = store.Set(ctx, col, "item1", json.RawMessage(`{"item": "my value"}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	err = store.Set(ctx, col, "item2", json.RawMessage(`{"item": `))
+	if !errors.Is(err, jsonstore.InvalidJsonErr) {
+		t.Errorf("expected InvalidJsonErr, got: %v", err)
+	}
+	_, err = store.SetIfVersion(ctx, col, "item3", json.RawMessage(`not json`), "")
+	if !errors.Is(err, jsonstore.InvalidJsonErr) {
+		t.Errorf("expected InvalidJsonErr, got: %v", err)
+	}
+	var got json.RawMessage
+	err = store.Get(ctx, col, "item2", &got)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected the invalid document to not be stored, got: %v", err)
+	}
+}
+
+func testValueEncoding(t *testing.T, db *gorm.DB) {
+	encodings := []jsonstore.ValueEncoding{jsonstore.EncodingCBOR, jsonstore.EncodingMsgpack}
+	for _, encoding := range encodings {
+		t.Run(string(encoding), func(t *testing.T) {
+			opts := jsonstore.DbStoreOptions{
+				TablePerCollection: true,
+				TablePrefix:        "encoded_" + string(encoding) + "_",
+				ValueEncoding:      encoding,
+			}
+			store, err := jsonstore.NewDbStoreWithOptions(db, opts)
+			if err != nil {
+				t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+			}
+			ctx := context.Background()
+
+			value := json.RawMessage(`{"b": [1, 2.5, "x", null, true], "a": {"c": "<&>"}, "n": 9007199254740993}`)
+			// the value is returned as compact json with sorted keys
+			want := json.RawMessage(`{"a":{"c":"<&>"},"b":[1,2.5,"x",null,true],"n":9007199254740993}`)
+			if err = store.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+
+			var got json.RawMessage
+			if err = store.Get(ctx, "col1", "item1", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			items, _, err := store.List(ctx, "col1", 0, 1)
+			if err != nil {
+				t.Fatalf("action: List,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(items, map[string]json.RawMessage{"item1": want}); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			page, err := store.Query(ctx, "col1", jsonstore.Query{
+				Filters: []jsonstore.Filter{{Path: "a.c", Op: jsonstore.OpEq, Value: "<&>"}},
+			})
+			if err != nil {
+				t.Fatalf("action: Query,  returned an error: %v", err)
+			}
+			if len(page.Items) != 1 {
+				t.Errorf("expected the query to match the document, got %d items", len(page.Items))
+			}
+
+			if err = store.Set(ctx, "col1", "item2", json.RawMessage(`{"a":`)); !errors.Is(err, jsonstore.InvalidJsonErr) {
+				t.Errorf("expected InvalidJsonErr, got: %v", err)
+			}
+
+			columns, err := db.Migrator().ColumnTypes(opts.TablePrefix + "col1")
+			if err != nil {
+				t.Fatalf("unable to read the columns: %v", err)
+			}
+			for _, c := range columns {
+				if c.Name() != "value" {
+					continue
+				}
+				if diff := cmp.Diff(strings.ToLower(c.DatabaseTypeName()), jsonstore.DialectBlobType(db.Dialector.Name())); diff != "" {
+					t.Errorf("unexpected value (-got +want)\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func testPartitionByCollection(t *testing.T, db *gorm.DB) {
+	if db.Dialector.Name() != "postgres" {
+		_, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{PartitionByCollection: true})
+		if err == nil {
+			t.Errorf("expected an error for partitioning on %s", db.Dialector.Name())
+		}
+		return
+	}
+
+	// the shared table of the other tests is not partitioned, use a table prefix for a dedicated one
+	conn, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get underlying DB: %v", err)
+	}
+	partitionedDb, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger:         logger.Discard,
+		NamingStrategy: schema.NamingStrategy{TablePrefix: "part_"},
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	opts := jsonstore.DbStoreOptions{PartitionByCollection: true, TablePrefix: "part_db_documents_"}
+	store, err := jsonstore.NewDbStoreWithOptions(partitionedDb, opts)
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	ctx := context.Background()
+	for _, col := range []string{"tenant1", "tenant2"} {
+		if err = store.Set(ctx, col, "item1", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+	if !db.Migrator().HasTable("part_db_documents_tenant1") {
+		t.Fatal("expected the partition of tenant1 to be created")
+	}
+
+	if err = store.DropCollection(ctx, "tenant1"); err != nil {
+		t.Fatalf("action: DropCollection,  returned an error: %v", err)
+	}
+	if db.Migrator().HasTable("part_db_documents_tenant1") {
+		t.Error("expected the partition of tenant1 to be dropped")
+	}
+	_, total, err := store.List(ctx, "tenant2", 0, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected the other collections to be kept, got %d documents", total)
+	}
+}
+
+func testJsonColumnType(t *testing.T, db *gorm.DB) {
+	columnType := func(t *testing.T, table string) string {
+		columns, err := db.Migrator().ColumnTypes(table)
+		if err != nil {
+			t.Fatalf("unable to read the columns of %s: %v", table, err)
+		}
+		for _, c := range columns {
+			if c.Name() == "value" {
+				return strings.ToLower(c.DatabaseTypeName())
+			}
+		}
+		t.Fatalf("table %s has no value column", table)
+		return ""
+	}
+
+	t.Run("dialect default", func(t *testing.T) {
+		_, err := jsonstore.NewDbStore(db)
+		if err != nil {
+			t.Fatalf("NewDbStore returned an error: %v", err)
+		}
+		want := jsonstore.DialectJsonType(db.Dialector.Name())
+		if diff := cmp.Diff(columnType(t, "db_documents"), want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("override", func(t *testing.T) {
+		store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{
+			TablePerCollection: true,
+			TablePrefix:        "text_documents_",
+			JsonColumnType:     "text",
+		})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		err = store.Set(context.Background(), "col1", "item1", json.RawMessage(`{"item": "my value"}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(columnType(t, "text_documents_col1"), "text"); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
+
+func TestDbStoreReader(t *testing.T) {
+	writer := newSqliteDbFile(t)
+	reader := newSqliteDbFile(t)
+	ctx := context.Background()
+
+	// the reader plays a replica that is populated out of band
+	if _, err := jsonstore.NewDbStore(reader); err != nil {
+		t.Fatalf("NewDbStore returned an error: %v", err)
+	}
+	replicated := dbDocument{ID: "item1", Collection: "col1", Value: json.RawMessage(`{"item": "replicated"}`)}
+	if err := reader.Create(&replicated).Error; err != nil {
+		t.Fatalf("failed to populate the reader: %v", err)
+	}
+
+	store, err := jsonstore.NewDbStoreWithOptions(writer, jsonstore.DbStoreOptions{Reader: reader})
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+	err = store.Set(ctx, "col1", "item1", json.RawMessage(`{"item": "written"}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	t.Run("writes go to the writer", func(t *testing.T) {
+		var got dbDocument
+		if err := writer.First(&got, "ID = ? AND Collection = ?", "item1", "col1").Error; err != nil {
+			t.Fatalf("failed to retrieve document: %v", err)
+		}
+		if diff := cmp.Diff(got.Value, json.RawMessage(`{"item": "written"}`)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("reads go to the reader", func(t *testing.T) {
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, replicated.Value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		items, _, err := store.List(ctx, "col1", 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(items, map[string]json.RawMessage{"item1": replicated.Value}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
+
+func TestDbStoreTimeout(t *testing.T) {
+	db := newSqliteDbFile(t)
+
+	// record whether the statements run with a deadline
+	var deadlines []bool
+	record := func(tx *gorm.DB) {
+		_, ok := tx.Statement.Context.Deadline()
+		deadlines = append(deadlines, ok)
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register("test:deadline", record); err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("test:deadline", record); err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		deadlines = nil
+		store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{Timeout: timeout})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err = store.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		want := []bool{timeout > 0, timeout > 0}
+		if diff := cmp.Diff(deadlines, want); diff != "" {
+			t.Errorf("timeout %s: unexpected value (-got +want)\n%s", timeout, diff)
+		}
+	}
+}
+
+func TestDbStoreClose(t *testing.T) {
+	ctx := context.Background()
+	for _, closeDB := range []bool{false, true} {
+		db := newSqliteDbFile(t)
+		store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{CloseDB: closeDB})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		if err = store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf("failed to get underlying DB: %v", err)
+		}
+		if err = sqlDB.Ping(); (err != nil) != closeDB {
+			t.Errorf("CloseDB %t: unexpected ping result after Close: %v", closeDB, err)
+		}
+	}
+}
+
+func testUpsert(t *testing.T, db *gorm.DB) {
+	ctx := context.Background()
+	first := json.RawMessage(`{"item":"first"}`)
+	second := json.RawMessage(`{"item":"second"}`)
+
+	tcs := []struct {
+		name       string
+		opts       jsonstore.DbStoreOptions
+		collection string
+		// deleted deletes the document before writing it again
+		deleted bool
+	}{
+		{name: "shared table", collection: "test_upsert_shared"},
+		{
+			name:       "table per collection",
+			opts:       jsonstore.DbStoreOptions{TablePerCollection: true, TablePrefix: "upsert_"},
+			collection: "col1",
+		},
+		{
+			name:       "value encoding",
+			opts:       jsonstore.DbStoreOptions{TablePerCollection: true, TablePrefix: "upsert_cbor_", ValueEncoding: jsonstore.EncodingCBOR},
+			collection: "col1",
+		},
+		{
+			name:       "soft delete",
+			opts:       jsonstore.DbStoreOptions{SoftDelete: true},
+			collection: "test_upsert_soft",
+		},
+		{
+			name:       "soft deleted document",
+			opts:       jsonstore.DbStoreOptions{SoftDelete: true},
+			collection: "test_upsert_soft_deleted",
+			deleted:    true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := jsonstore.NewDbStoreWithOptions(db, tc.opts)
+			if err != nil {
+				t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+			}
+			if err = store.Set(ctx, tc.collection, "item1", first); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if tc.deleted {
+				if _, err = store.Delete(ctx, tc.collection, "item1"); err != nil {
+					t.Fatalf("action: Delete,  returned an error: %v", err)
+				}
+			}
+			if err = store.Set(ctx, tc.collection, "item1", second); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+
+			// the second write updates the row of the first one
+			var got json.RawMessage
+			version, err := store.GetWithVersion(ctx, tc.collection, "item1", &got)
+			if err != nil {
+				t.Fatalf("action: GetWithVersion,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, second); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+			if version != "2" {
+				t.Errorf("expected version 2, got %s", version)
+			}
+			_, total, err := store.List(ctx, tc.collection, 0, 1)
+			if err != nil {
+				t.Fatalf("action: List,  returned an error: %v", err)
+			}
+			if total != 1 {
+				t.Errorf("expected a single document, got %d", total)
+			}
+		})
+	}
+}
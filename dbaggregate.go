@@ -0,0 +1,144 @@
+package jsonstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// make sure the DB store fulfills the Aggregator interface
+var _ Aggregator = &DbStore{}
+
+// Aggregate computes the aggregations in the database when the gorm dialect is sqlite, with the same JSON1
+// functions as Query; for other dialects and binary value encodings the documents are aggregated client-side,
+// reading the whole collection: on postgres use a pgstore.PgStore to aggregate in the database.
+func (store *DbStore) Aggregate(ctx context.Context, collection string, spec AggregateSpec) ([]AggregateGroup, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if store.db.Dialector.Name() != "sqlite" || store.opts.ValueEncoding.binary() ||
+		!sqliteQueryable(Query{Filters: spec.Filters, Fields: spec.paths()}) {
+		return aggregateClientSide(ctx, store, collection, spec)
+	}
+
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return newAggregator(spec).groups(), nil
+	}
+	tx := store.readTx(ctx, table).Where(fmt.Sprintf("%s = ?", columnCollection), collection)
+	for _, f := range spec.Filters {
+		cond, args := sqliteFilter(f)
+		tx = tx.Where(cond, args...)
+	}
+	var columns []string
+	for _, path := range spec.GroupBy {
+		// the groups are the values of the field and their type, so that equal numbers are grouped whatever their
+		// json text, e.g. 1 and 1.0, while true stays apart from 1; missing fields are grouped with the null ones
+		p := sqlitePath(path)
+		typeOf := fmt.Sprintf("json_type(%s, %s)", columnValue, p)
+		tx = tx.Group(fmt.Sprintf("CASE WHEN %s IN ('integer', 'real') THEN 'number' ELSE COALESCE(%s, 'null') END", typeOf, typeOf)).
+			Group(fmt.Sprintf("json_extract(%s, %s)", columnValue, p))
+		columns = append(columns, fmt.Sprintf("MIN(COALESCE(%s -> %s, 'null'))", columnValue, p))
+	}
+	for _, a := range spec.Aggregations {
+		columns = append(columns, sqliteAggregation(a))
+	}
+	rows, err := tx.Select(strings.Join(columns, ", ")).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+	}
+	defer rows.Close()
+
+	var groups []AggregateGroup
+	for rows.Next() {
+		keys := make([]string, len(spec.GroupBy))
+		counts := make([]int64, len(spec.Aggregations))
+		sums := make([]float64, len(spec.Aggregations))
+		values := make([]sql.NullString, len(spec.Aggregations))
+		var dest []any
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		for i, a := range spec.Aggregations {
+			switch a.Op {
+			case AggCount:
+				dest = append(dest, &counts[i])
+			case AggSum:
+				dest = append(dest, &sums[i])
+			default:
+				dest = append(dest, &values[i])
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+		}
+
+		group := AggregateGroup{Values: map[string]any{}}
+		if len(spec.GroupBy) > 0 {
+			group.Key = map[string]any{}
+			for i, path := range spec.GroupBy {
+				var v any
+				if err = json.Unmarshal([]byte(keys[i]), &v); err != nil {
+					return nil, fmt.Errorf("unable to decode group key: %v", err)
+				}
+				group.Key[path] = v
+			}
+		}
+		for i, a := range spec.Aggregations {
+			switch a.Op {
+			case AggCount:
+				group.Values[a.ValueName()] = counts[i]
+			case AggSum:
+				group.Values[a.ValueName()] = sums[i]
+			default:
+				var v any
+				if values[i].Valid {
+					if err = json.Unmarshal([]byte(values[i].String), &v); err != nil {
+						return nil, fmt.Errorf("unable to decode aggregated value: %v", err)
+					}
+				}
+				group.Values[a.ValueName()] = v
+			}
+		}
+		groups = append(groups, group)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+	}
+	if groups == nil {
+		groups = []AggregateGroup{}
+	}
+	// sqlite orders the json text of the keys, they are sorted as the values are sorted by Query
+	SortGroups(groups, spec.GroupBy)
+	return groups, nil
+}
+
+// sqliteAggregation returns the column computing an aggregation, ignoring the values of types not aggregated by
+// the operation; min and max are returned as json
+func sqliteAggregation(a Aggregation) string {
+	if a.Path == "" {
+		return "COUNT(*)"
+	}
+	p := sqlitePath(a.Path)
+	typeOf := fmt.Sprintf("json_type(%s, %s)", columnValue, p)
+	extract := fmt.Sprintf("json_extract(%s, %s)", columnValue, p)
+	switch a.Op {
+	case AggCount:
+		return fmt.Sprintf("COUNT(CASE WHEN %s != 'null' THEN 1 END)", typeOf)
+	case AggSum:
+		return fmt.Sprintf("TOTAL(CASE WHEN %s IN ('integer', 'real') THEN %s END)", typeOf, extract)
+	}
+	// sqlite sorts numbers before text, as compareSort
+	return fmt.Sprintf("json_quote(%s(CASE WHEN %s IN ('integer', 'real', 'text') THEN %s END))",
+		strings.ToUpper(string(a.Op)), typeOf, extract)
+}
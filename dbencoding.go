@@ -0,0 +1,130 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ValueEncoding is the format used by the DbStore to store the values
+type ValueEncoding string
+
+const (
+	// EncodingJson stores the values as they are in a json column, it is the default
+	EncodingJson ValueEncoding = ""
+	// EncodingCBOR stores the values as CBOR (RFC 8949) in a blob column
+	EncodingCBOR ValueEncoding = "cbor"
+	// EncodingMsgpack stores the values as MessagePack in a blob column
+	EncodingMsgpack ValueEncoding = "msgpack"
+)
+
+// DialectBlobType returns the binary column type of a gorm dialect, used by the binary value encodings
+func DialectBlobType(dialect string) string {
+	switch dialect {
+	case "postgres":
+		return "bytea"
+	case "mysql":
+		return "longblob"
+	case "sqlserver":
+		return "varbinary(max)"
+	}
+	// sqlite
+	return "blob"
+}
+
+// cborDecMode decodes maps with string keys so that they can be encoded back to json
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any{})}.DecMode()
+
+// cborEncMode sorts the keys of the maps, so that the same document is always encoded to the same bytes
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// binary returns true if the encoding stores the values in a blob column
+func (e ValueEncoding) binary() bool {
+	return e != EncodingJson
+}
+
+func (e ValueEncoding) validate() error {
+	switch e {
+	case EncodingJson, EncodingCBOR, EncodingMsgpack:
+		return nil
+	}
+	return fmt.Errorf("unsupported value encoding %q", e)
+}
+
+// encode converts a json value into the stored format, the value needs to be valid json
+func (e ValueEncoding) encode(value json.RawMessage) ([]byte, error) {
+	if !e.binary() {
+		return value, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidJsonErr, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after the value", InvalidJsonErr)
+	}
+	v = jsonNumbers(v)
+	if e == EncodingCBOR {
+		return cborEncMode.Marshal(v)
+	}
+	buf := bytes.Buffer{}
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode converts a stored value back into json, objects are written with sorted keys
+func (e ValueEncoding) decode(data []byte) (json.RawMessage, error) {
+	if !e.binary() {
+		return data, nil
+	}
+	var v any
+	var err error
+	if e == EncodingCBOR {
+		err = cborDecMode.Unmarshal(data, &v)
+	} else {
+		err = msgpack.Unmarshal(data, &v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s value: %v", e, err)
+	}
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err = enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode %s value as json: %v", e, err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonNumbers replaces the json numbers with integers where possible, to use the compact integer encodings,
+// and with floats otherwise
+func jsonNumbers(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			val[k] = jsonNumbers(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = jsonNumbers(item)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	}
+	return v
+}
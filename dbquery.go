@@ -0,0 +1,208 @@
+package jsonstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// make sure the DB store fulfills the Querier interface
+var _ Querier = &DbStore{}
+
+// Query runs the query in the database when the gorm dialect is sqlite, using the JSON1 functions to filter,
+// sort and project the documents; for other dialects and binary value encodings the query is evaluated client-side.
+func (store *DbStore) Query(ctx context.Context, collection string, q Query) (Page, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	q.ListOptions = q.ListOptions.capLimit(store.opts.MaxListItems)
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	if store.db.Dialector.Name() != "sqlite" || store.opts.ValueEncoding.binary() || !sqliteQueryable(q) {
+		return queryClientSide(ctx, store, collection, q)
+	}
+	opts, err := q.ListOptions.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return Page{}, err
+	}
+	if !exists {
+		return NewPage(nil, 0, opts.Page, opts.Limit), nil
+	}
+	tx := store.readTx(ctx, table).
+		Where(fmt.Sprintf("%s = ?", columnCollection), collection).
+		Scopes(store.prefixScope(opts.Prefix))
+	for _, f := range q.Filters {
+		cond, args := sqliteFilter(f)
+		tx = tx.Where(cond, args...)
+	}
+	if q.Search != "" {
+		tx = tx.Where(fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, columnValue), "%"+likeEscaper.Replace(q.Search)+"%")
+	}
+
+	var count int64
+	err = tx.Count(&count).Error
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+
+	// only the projected fields are read, the documents are assembled afterward
+	columns := []string{"id", columnValue}
+	if len(q.Fields) > 0 {
+		columns = columns[:1]
+		for _, field := range q.Fields {
+			columns = append(columns, fmt.Sprintf("%s -> %s", columnValue, sqlitePath(field)))
+		}
+	}
+	for _, field := range q.Sort {
+		dir := "ASC"
+		if field.Desc {
+			dir = "DESC"
+		}
+		tx = tx.Order(fmt.Sprintf("json_extract(%s, %s) %s", columnValue, sqlitePath(field.Path), dir))
+	}
+	rows, err := tx.Select(strings.Join(columns, ", ")).
+		Order("id ASC").
+		Limit(opts.Limit).
+		Offset(offset).
+		Rows()
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	defer rows.Close()
+
+	result := map[string]json.RawMessage{}
+	var keys []string
+	for rows.Next() {
+		var key string
+		values := make([]sql.NullString, len(columns)-1)
+		dest := []any{&key}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		keys = append(keys, key)
+		if len(q.Fields) == 0 {
+			result[key] = json.RawMessage(values[0].String)
+			continue
+		}
+		doc := map[string]any{}
+		for i, field := range q.Fields {
+			if !values[i].Valid {
+				continue
+			}
+			SetPath(doc, SplitPath(field), json.RawMessage(values[i].String))
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return Page{}, fmt.Errorf("failed to project document: %v", err)
+		}
+		result[key] = b
+	}
+	if err = rows.Err(); err != nil {
+		return Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	p := NewPage(result, count, opts.Page, opts.Limit)
+	if len(q.Sort) > 0 {
+		p.Keys = keys
+	}
+	return p, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern using \ as escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// sqliteQueryable reports whether the query can be expressed with the JSON1 functions: paths containing
+// quotes cannot be expressed, and arrays and objects are compared client-side.
+func sqliteQueryable(q Query) bool {
+	paths := append([]string{}, q.Fields...)
+	for _, f := range q.Sort {
+		paths = append(paths, f.Path)
+	}
+	for _, f := range q.Filters {
+		paths = append(paths, f.Path)
+		b, _ := json.Marshal(f.Value)
+		var v any
+		_ = json.Unmarshal(b, &v)
+		if !isScalar(v) {
+			return false
+		}
+	}
+	for _, p := range paths {
+		if strings.ContainsAny(p, `"'`) {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlitePath returns the JSON path literal of a dot separated path
+func sqlitePath(path string) string {
+	keys := SplitPath(path)
+	return `'$."` + strings.Join(keys, `"."`) + `"'`
+}
+
+var sqliteOps = map[FilterOp]string{
+	OpEq:  "=",
+	OpGt:  ">",
+	OpGte: ">=",
+	OpLt:  "<",
+	OpLte: "<=",
+}
+
+// sqliteFilter returns the condition of a filter on a scalar value, it compares only values of the same json type
+func sqliteFilter(f Filter) (string, []any) {
+	b, _ := json.Marshal(f.Value)
+	var v any
+	_ = json.Unmarshal(b, &v)
+
+	p := sqlitePath(f.Path)
+	typeOf := fmt.Sprintf("json_type(%s, %s)", columnValue, p)
+	extract := fmt.Sprintf("json_extract(%s, %s)", columnValue, p)
+
+	op := sqliteOps[f.Op]
+	if f.Op == OpNe {
+		op = "="
+	}
+	var cond string
+	var args []any
+	switch val := v.(type) {
+	case nil:
+		// null only equals null
+		cond = fmt.Sprintf("%s = 'null'", typeOf)
+		if f.Op == OpGt || f.Op == OpLt {
+			cond = "0"
+		}
+	case bool:
+		// json_extract returns booleans as 0 and 1
+		n := 0
+		if val {
+			n = 1
+		}
+		cond = fmt.Sprintf("%s IN ('true', 'false') AND %s %s ?", typeOf, extract, op)
+		args = append(args, n)
+	case float64:
+		cond = fmt.Sprintf("%s IN ('integer', 'real') AND %s %s ?", typeOf, extract, op)
+		args = append(args, val)
+	case string:
+		cond = fmt.Sprintf("%s = 'text' AND %s %s ?", typeOf, extract, op)
+		args = append(args, val)
+	}
+	if f.Op == OpNe {
+		// documents without the field are not equal
+		cond = fmt.Sprintf("NOT COALESCE((%s), 0)", cond)
+	}
+	return cond, args
+}
@@ -0,0 +1,79 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+// TestDbStoreQuery verifies that the queries run with the sqlite JSON1 functions return the same
+// results as the client-side evaluation
+func TestDbStoreQuery(t *testing.T) {
+	ctx := context.Background()
+	dbStore := newDbStore(t)
+	memStore := jsonstore.NewMemStore()
+	docs := map[string]string{
+		"alice": `{"name":"Alice","age":31,"admin":true,"address":{"city":"Zurich"},"tags":["admin"]}`,
+		"bob":   `{"name":"Bob","age":25,"admin":false,"address":{"city":"Berlin"}}`,
+		"carol": `{"name":"Carol","age":"unknown","address":{"city":"Zurich"},"note":null}`,
+		"dave":  `{"name":"Dave","age":25.5}`,
+	}
+	for key, value := range docs {
+		for _, store := range []jsonstore.JsonStorer{dbStore, memStore} {
+			if err := store.Set(ctx, "users", key, json.RawMessage(value)); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+		}
+	}
+
+	tcs := []struct {
+		name  string
+		query jsonstore.Query
+	}{
+		{name: "eq string", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "address.city", Op: jsonstore.OpEq, Value: "Zurich"}}}},
+		{name: "gte number", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "age", Op: jsonstore.OpGte, Value: 25}}}},
+		{name: "lt string", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "name", Op: jsonstore.OpLt, Value: "C"}}}},
+		{name: "eq bool", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "admin", Op: jsonstore.OpEq, Value: false}}}},
+		{name: "ne bool", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "admin", Op: jsonstore.OpNe, Value: false}}}},
+		{name: "eq null", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "note", Op: jsonstore.OpEq, Value: nil}}}},
+		{name: "eq array", query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "tags", Op: jsonstore.OpEq, Value: []string{"admin"}}}}},
+		{name: "search", query: jsonstore.Query{Search: "ZURICH"}},
+		{name: "projection", query: jsonstore.Query{Fields: []string{"name", "address.city", "tags"}}},
+		{name: "sort", query: jsonstore.Query{Sort: []jsonstore.SortField{{Path: "age"}}, Fields: []string{"age"}}},
+		{name: "sort desc paginated", query: jsonstore.Query{
+			ListOptions: jsonstore.ListOptions{Limit: 2, Page: 2},
+			Sort:        []jsonstore.SortField{{Path: "age", Desc: true}},
+		}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := dbStore.Query(ctx, "users", tc.query)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			want, err := jsonstore.RunQuery(ctx, memStore, "users", tc.query)
+			if err != nil {
+				t.Fatalf("RunQuery failed: %v", err)
+			}
+			if diff := cmp.Diff(normalizePage(t, got), normalizePage(t, want)); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+// normalizePage decodes the items to compare them regardless of the json formatting
+func normalizePage(t *testing.T, p jsonstore.Page) map[string]any {
+	t.Helper()
+	items := map[string]any{}
+	for k, v := range p.Items {
+		var doc any
+		if err := json.Unmarshal(v, &doc); err != nil {
+			t.Fatalf("invalid json %s: %v", v, err)
+		}
+		items[k] = doc
+	}
+	return map[string]any{"items": items, "total": p.Total}
+}
@@ -0,0 +1,53 @@
+package jsonstore
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// DefaultRetryBackoff is the first wait between the retries of a DbStore write, doubled at every attempt
+const DefaultRetryBackoff = 10 * time.Millisecond
+
+// transientErrs are the messages of the errors that go away retrying the statement:
+// a busy or locked sqlite database, and deadlocks or serialization failures on mysql and postgres.
+var transientErrs = []string{
+	"database is locked",
+	"database table is locked",
+	"sqlite_busy",
+	"deadlock found",    // mysql 1213
+	"lock wait timeout", // mysql 1205
+	"sqlstate 40p01",    // postgres deadlock_detected
+	"sqlstate 40001",    // postgres serialization_failure
+}
+
+// isTransientErr reports whether the database error is worth a retry
+func isTransientErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, e := range transientErrs {
+		if strings.Contains(msg, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry runs fn retrying it with exponential backoff as long as it fails with a transient error,
+// up to DbStoreOptions.MaxRetries times or until ctx is done.
+func (store *DbStore) retry(ctx context.Context, fn func() error) error {
+	backoff := store.opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	err := fn()
+	for i := 0; i < store.opts.MaxRetries && err != nil && isTransientErr(err); i++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// lockSqlite holds an exclusive lock on the sqlite database file until the returned function is called
+func lockSqlite(t *testing.T, file string) func() {
+	db, err := gorm.Open(sqlite.Open(file+"?_busy_timeout=0"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get underlying DB: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get a connection: %v", err)
+	}
+	if _, err = conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("failed to lock the database: %v", err)
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "COMMIT")
+		conn.Close()
+	}
+}
+
+func TestDbStoreRetry(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "testdb.sqlite")
+	// fail immediately on a locked database instead of waiting in the driver
+	db, err := gorm.Open(sqlite.Open(file+"?_busy_timeout=0"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get underlying DB: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	ctx := context.Background()
+
+	t.Run("without retries the error is returned", func(t *testing.T) {
+		store, err := jsonstore.NewDbStore(db)
+		if err != nil {
+			t.Fatalf("NewDbStore returned an error: %v", err)
+		}
+		unlock := lockSqlite(t, file)
+		defer unlock()
+
+		err = store.Set(ctx, "col1", "item1", json.RawMessage(`{}`))
+		if err == nil || !strings.Contains(err.Error(), "database is locked") {
+			t.Errorf("expected a database is locked error, got: %v", err)
+		}
+	})
+
+	t.Run("retry until the lock is released", func(t *testing.T) {
+		store, err := jsonstore.NewDbStoreWithOptions(db, jsonstore.DbStoreOptions{
+			MaxRetries:   10,
+			RetryBackoff: 5 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		unlock := lockSqlite(t, file)
+		time.AfterFunc(50*time.Millisecond, unlock)
+
+		err = store.Set(ctx, "col1", "item1", json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		deleted, err := store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+	})
+}
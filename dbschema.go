@@ -0,0 +1,161 @@
+package jsonstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Migrate creates or updates the table used by a DbStore with the default options,
+// it is meant to be used together with NewDbStoreWithoutMigrate.
+func Migrate(db *gorm.DB) error {
+	return MigrateWithOptions(db, DbStoreOptions{})
+}
+
+// MigrateWithOptions creates or updates the tables used by a DbStore configured with opts.
+// In table per collection mode the tables of the given collections are migrated,
+// in partition by collection mode their partitions are created.
+func MigrateWithOptions(db *gorm.DB, opts DbStoreOptions, collections ...string) error {
+	opts.SkipMigrate = false
+	store, err := NewDbStoreWithOptions(db, opts)
+	if err != nil {
+		return err
+	}
+	if opts.PartitionByCollection {
+		for _, collection := range collections {
+			if err = store.createPartition(context.Background(), collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !opts.TablePerCollection {
+		return nil
+	}
+	for _, collection := range collections {
+		table, err := store.tableName(collection)
+		if err != nil {
+			return err
+		}
+		err = store.migrator(context.Background()).Table(table).AutoMigrate(store.model())
+		if err != nil {
+			return fmt.Errorf("failed to migrate table for collection %s: %v", collection, err)
+		}
+	}
+	return nil
+}
+
+// SchemaSQL returns the DDL statements creating the table of a DbStore with the default options,
+// to be reviewed and applied by a migration pipeline. The dialect is the name of the gorm dialect:
+// "sqlite", "mysql" or "postgres".
+func SchemaSQL(dialect string) (string, error) {
+	return SchemaSQLWithOptions(dialect, DbStoreOptions{})
+}
+
+// SchemaSQLWithOptions returns the DDL statements creating the tables of a DbStore configured with opts,
+// in table per collection and partition by collection modes the tables of the given collections are included.
+// The statements assume the default gorm naming strategy, i.e. a shared table named db_documents.
+func SchemaSQLWithOptions(dialect string, opts DbStoreOptions, collections ...string) (string, error) {
+	stmts, err := schemaStatements(dialect, opts, "db_documents", collections)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(stmts, "\n"), nil
+}
+
+// schemaStatements returns the DDL statements of SchemaSQLWithOptions using sharedTable as shared table
+func schemaStatements(dialect string, opts DbStoreOptions, sharedTable string, collections []string) ([]string, error) {
+	if opts.TablePrefix == "" {
+		opts.TablePrefix = DefaultTablePrefix
+	}
+	quote := func(s string) string { return "`" + s + "`" }
+	idType, collectionType := "text", "text"
+	timeType := "datetime"
+	versionType := "bigint"
+	switch dialect {
+	case "sqlite":
+		versionType = "integer"
+	case "mysql":
+		keySize, collectionSize := opts.keySizes(dialect)
+		idType = fmt.Sprintf("varchar(%d)", keySize)
+		collectionType = fmt.Sprintf("varchar(%d)", collectionSize)
+		timeType = "datetime(3)"
+	case "postgres":
+		quote = func(s string) string { return `"` + s + `"` }
+		timeType = "timestamptz"
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	if err := opts.validate(dialect); err != nil {
+		return nil, err
+	}
+	jsonType := opts.JsonColumnType
+	if jsonType == "" {
+		jsonType = DialectJsonType(dialect)
+		if opts.ValueEncoding.binary() {
+			jsonType = DialectBlobType(dialect)
+		}
+	}
+
+	store := DbStore{opts: opts}
+	tables := []string{sharedTable}
+	if opts.TablePerCollection {
+		tables = tables[:0]
+		for _, collection := range collections {
+			table, err := store.tableName(collection)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, table)
+		}
+	}
+
+	stmts := []string{}
+	for _, table := range tables {
+		columns := []string{
+			fmt.Sprintf("%s %s", quote("id"), idType),
+			fmt.Sprintf("%s %s", quote(columnCollection), collectionType),
+			fmt.Sprintf("%s %s", quote(columnValue), jsonType),
+			fmt.Sprintf("%s %s NOT NULL DEFAULT 1", quote(columnVersion), versionType),
+			fmt.Sprintf("%s %s", quote(columnUpdatedAt), timeType),
+		}
+		if opts.SoftDelete {
+			columns = append(columns, fmt.Sprintf("%s %s", quote(columnDeletedAt), timeType))
+		}
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s,%s)", quote("id"), quote(columnCollection)))
+		partition := ""
+		if opts.PartitionByCollection {
+			partition = fmt.Sprintf(" PARTITION BY LIST (%s)", quote(columnCollection))
+		}
+		stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s (%s)%s;", quote(table), strings.Join(columns, ","), partition))
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s ON %s (%s,%s);",
+			quote("idx_"+table+"_collection_id"), quote(table), quote(columnCollection), quote("id")))
+		if opts.SoftDelete {
+			stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s ON %s (%s);",
+				quote("idx_"+table+"_"+columnDeletedAt), quote(table), quote(columnDeletedAt)))
+		}
+	}
+	if opts.PartitionByCollection {
+		for _, collection := range collections {
+			stmt, err := store.partitionSQL(sharedTable, collection)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt+";")
+		}
+	}
+	return stmts, nil
+}
+
+// partitionSQL returns the statement creating the partition of a collection of the postgres partitioned table
+func (store *DbStore) partitionSQL(sharedTable, collection string) (string, error) {
+	partition, err := store.tableName(collection)
+	if err != nil {
+		return "", err
+	}
+	// the collection name is restricted by tableName and can be used as literal
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" PARTITION OF "%s" FOR VALUES IN ('%s')`,
+		partition, sharedTable, collection), nil
+}
@@ -0,0 +1,145 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewDbStoreWithoutMigrate(t *testing.T) {
+	db := newSqliteDbFile(t)
+	store, err := jsonstore.NewDbStoreWithoutMigrate(db)
+	if err != nil {
+		t.Fatalf("NewDbStoreWithoutMigrate returned an error: %v", err)
+	}
+	if db.Migrator().HasTable("db_documents") {
+		t.Fatal("expected the table to not be created")
+	}
+
+	if err = jsonstore.Migrate(db); err != nil {
+		t.Fatalf("Migrate returned an error: %v", err)
+	}
+	if !db.Migrator().HasTable("db_documents") {
+		t.Fatal("expected the table to be created by Migrate")
+	}
+	err = store.Set(context.Background(), "col1", "item1", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+}
+
+func TestSchemaSQL(t *testing.T) {
+	t.Run("postgres", func(t *testing.T) {
+		got, err := jsonstore.SchemaSQL("postgres")
+		if err != nil {
+			t.Fatalf("SchemaSQL returned an error: %v", err)
+		}
+		want := `CREATE TABLE "db_documents" ("id" text,"collection" text,"value" jsonb,"version" bigint NOT NULL DEFAULT 1,"updated_at" timestamptz,PRIMARY KEY ("id","collection"));` + "\n" +
+			`CREATE INDEX "idx_db_documents_collection_id" ON "db_documents" ("collection","id");`
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("mysql table per collection", func(t *testing.T) {
+		got, err := jsonstore.SchemaSQLWithOptions("mysql", jsonstore.DbStoreOptions{TablePerCollection: true}, "tenant1")
+		if err != nil {
+			t.Fatalf("SchemaSQLWithOptions returned an error: %v", err)
+		}
+		want := "CREATE TABLE `db_documents_tenant1` (`id` varchar(191),`collection` varchar(191),`value` json," +
+			"`version` bigint NOT NULL DEFAULT 1,`updated_at` datetime(3),PRIMARY KEY (`id`,`collection`));\n" +
+			"CREATE INDEX `idx_db_documents_tenant1_collection_id` ON `db_documents_tenant1` (`collection`,`id`);"
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("mysql column sizes", func(t *testing.T) {
+		opts := jsonstore.DbStoreOptions{KeyColumnSize: 255, CollectionColumnSize: 64}
+		got, err := jsonstore.SchemaSQLWithOptions("mysql", opts)
+		if err != nil {
+			t.Fatalf("SchemaSQLWithOptions returned an error: %v", err)
+		}
+		want := "CREATE TABLE `db_documents` (`id` varchar(255),`collection` varchar(64),`value` json," +
+			"`version` bigint NOT NULL DEFAULT 1,`updated_at` datetime(3),PRIMARY KEY (`id`,`collection`));\n" +
+			"CREATE INDEX `idx_db_documents_collection_id` ON `db_documents` (`collection`,`id`);"
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("postgres binary value encoding", func(t *testing.T) {
+		got, err := jsonstore.SchemaSQLWithOptions("postgres", jsonstore.DbStoreOptions{ValueEncoding: jsonstore.EncodingCBOR})
+		if err != nil {
+			t.Fatalf("SchemaSQLWithOptions returned an error: %v", err)
+		}
+		if !strings.Contains(got, `"value" bytea`) {
+			t.Errorf("expected the value column to be bytea, got: %s", got)
+		}
+	})
+
+	t.Run("postgres partition by collection", func(t *testing.T) {
+		got, err := jsonstore.SchemaSQLWithOptions("postgres", jsonstore.DbStoreOptions{PartitionByCollection: true}, "tenant1")
+		if err != nil {
+			t.Fatalf("SchemaSQLWithOptions returned an error: %v", err)
+		}
+		want := `CREATE TABLE "db_documents" ("id" text,"collection" text,"value" jsonb,"version" bigint NOT NULL DEFAULT 1,` +
+			`"updated_at" timestamptz,PRIMARY KEY ("id","collection")) PARTITION BY LIST ("collection");` + "\n" +
+			`CREATE INDEX "idx_db_documents_collection_id" ON "db_documents" ("collection","id");` + "\n" +
+			`CREATE TABLE IF NOT EXISTS "db_documents_tenant1" PARTITION OF "db_documents" FOR VALUES IN ('tenant1');`
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		_, err = jsonstore.SchemaSQLWithOptions("mysql", jsonstore.DbStoreOptions{PartitionByCollection: true})
+		if err == nil {
+			t.Error("expected an error for partitioning on mysql")
+		}
+	})
+
+	t.Run("unsupported dialect", func(t *testing.T) {
+		_, err := jsonstore.SchemaSQL("oracle")
+		if err == nil {
+			t.Fatal("expected an error for an unsupported dialect")
+		}
+	})
+
+	t.Run("applied schema is usable by the store", func(t *testing.T) {
+		db := newSqliteDbFile(t)
+		opts := jsonstore.DbStoreOptions{SoftDelete: true, SkipMigrate: true}
+		ddl, err := jsonstore.SchemaSQLWithOptions("sqlite", opts)
+		if err != nil {
+			t.Fatalf("SchemaSQLWithOptions returned an error: %v", err)
+		}
+		if err = db.Exec(ddl).Error; err != nil {
+			t.Fatalf("failed to apply the schema: %v", err)
+		}
+
+		store, err := jsonstore.NewDbStoreWithOptions(db, opts)
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		ctx := context.Background()
+		value := json.RawMessage(`{"item": "my value"}`)
+		if err = store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if _, err = store.Delete(ctx, "col1", "item1"); err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if _, err = store.Restore(ctx, "col1", "item1"); err != nil {
+			t.Fatalf("action: Restore,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err = store.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
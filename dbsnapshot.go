@@ -0,0 +1,155 @@
+package jsonstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// snapshotTxOptions read all the tables of a snapshot out of the same consistent view of the database
+var snapshotTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// restoreBatchSize is the amount of documents inserted by a single statement of RestoreSnapshot
+const restoreBatchSize = 500
+
+// storeTables returns the tables holding the documents of the store, sorted by name
+func (store *DbStore) storeTables(tx *gorm.DB) ([]string, error) {
+	if !store.opts.TablePerCollection {
+		return []string{store.sharedTable}, nil
+	}
+	all, err := tx.Migrator().GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	tables := []string{}
+	for _, table := range all {
+		if strings.HasPrefix(table, store.opts.TablePrefix) && tableNameRegex.MatchString(table[len(store.opts.TablePrefix):]) {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// Snapshot writes a point-in-time dump of all the collections to w, in the format of the FileStore file.
+// The documents are read in a single repeatable read transaction, so that the dump is consistent without
+// stopping the writes; soft deleted documents are not included. Like ForEach the store Timeout is not applied.
+func (store *DbStore) Snapshot(ctx context.Context, w io.Writer) error {
+	return store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tables, err := store.storeTables(tx)
+		if err != nil {
+			return err
+		}
+		sw := snapshotWriter{w: w}
+		for _, table := range tables {
+			rows, err := tx.Table(table).Model(store.model()).
+				Select(fmt.Sprintf("%s, id, %s", columnCollection, columnValue)).
+				Order(fmt.Sprintf("%s ASC, id ASC", columnCollection)).
+				Rows()
+			if err != nil {
+				return fmt.Errorf("failed to read documents: %v", err)
+			}
+			err = store.snapshotRows(rows, &sw)
+			rows.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return sw.close()
+	}, snapshotTxOptions)
+}
+
+func (store *DbStore) snapshotRows(rows *sql.Rows, sw *snapshotWriter) error {
+	for rows.Next() {
+		var collection, key string
+		var value []byte
+		if err := rows.Scan(&collection, &key, &value); err != nil {
+			return fmt.Errorf("failed to read documents: %v", err)
+		}
+		value, err := store.opts.ValueEncoding.decode(value)
+		if err != nil {
+			return err
+		}
+		if err = sw.document(collection, key, value); err != nil {
+			return fmt.Errorf("failed to write snapshot: %v", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read documents: %v", err)
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the whole content of the store with a snapshot written by Snapshot, in a single
+// transaction: the documents of the collections not in the snapshot are removed, also with SoftDelete.
+// The tables of the new collections are created before the transaction. Like ForEach the store Timeout is
+// not applied.
+func (store *DbStore) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	content, err := readSnapshot(r)
+	if err != nil {
+		return err
+	}
+
+	docs := map[string][]dbDocument{}
+	for collection, items := range content {
+		if collection == "" {
+			collection = DefaultCollection
+		}
+		table, exists, err := store.collectionTable(ctx, collection, true)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("failed to restore snapshot: table %s does not exist", table)
+		}
+		for key, value := range items {
+			doc := dbDocument{ID: dbKey(key), Collection: dbKey(collection), Value: dbJson(value), Version: 1}
+			if err = store.validate(doc); err != nil {
+				return fmt.Errorf("collection %s: %w", collection, err)
+			}
+			if doc.Value, err = store.opts.ValueEncoding.encode(value); err != nil {
+				return fmt.Errorf("collection %s: %w", collection, err)
+			}
+			docs[table] = append(docs[table], doc)
+		}
+	}
+
+	return store.retry(ctx, func() error {
+		return store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			tables, err := store.storeTables(tx)
+			if err != nil {
+				return err
+			}
+			for _, table := range tables {
+				err = tx.Table(table).Session(&gorm.Session{AllowGlobalUpdate: true}).
+					Unscoped().Delete(store.model()).Error
+				if err != nil {
+					return fmt.Errorf("failed to delete documents: %v", err)
+				}
+			}
+			for table, tableDocs := range docs {
+				if err = tx.Table(table).CreateInBatches(store.rows(tableDocs), restoreBatchSize).Error; err != nil {
+					return fmt.Errorf("failed to restore documents: %v", err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// rows returns the documents as a slice of the model of the store
+func (store *DbStore) rows(docs []dbDocument) any {
+	if !store.opts.SoftDelete {
+		return docs
+	}
+	rows := make([]dbSoftDocument, len(docs))
+	for i, doc := range docs {
+		rows[i] = dbSoftDocument{Document: doc}
+	}
+	return rows
+}
@@ -0,0 +1,106 @@
+package jsonstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CollectionStats holds the aggregated figures of a collection
+type CollectionStats struct {
+	// Count is the amount of documents
+	Count int64 `json:"count"`
+	// Bytes is the total size of the values: the length of the json text, or of the encoded values
+	// when the store uses a binary ValueEncoding
+	Bytes int64 `json:"bytes"`
+	// MinUpdatedAt and MaxUpdatedAt are the oldest and latest write times of the documents, they are zero if the
+	// collection is empty or its documents were written before the updated_at column was added
+	MinUpdatedAt time.Time `json:"minUpdatedAt"`
+	MaxUpdatedAt time.Time `json:"maxUpdatedAt"`
+}
+
+// Stats returns the statistics of a collection computed by a single aggregate query, without reading the documents.
+func (store *DbStore) Stats(ctx context.Context, collection string) (CollectionStats, error) {
+	ctx, cancel := store.withTimeout(ctx)
+	defer cancel()
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	table, exists, err := store.collectionTable(ctx, collection, false)
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	if !exists {
+		return CollectionStats{}, nil
+	}
+
+	stats := CollectionStats{}
+	var minUpdated, maxUpdated any
+	row := store.readTx(ctx, table).
+		Select(fmt.Sprintf("COUNT(*), COALESCE(SUM(%s), 0), MIN(%s), MAX(%s)",
+			store.valueSizeExpr(), columnUpdatedAt, columnUpdatedAt)).
+		Where(fmt.Sprintf("%s = ?", columnCollection), collection).
+		Row()
+	if err = row.Scan(&stats.Count, &stats.Bytes, &minUpdated, &maxUpdated); err != nil {
+		return CollectionStats{}, fmt.Errorf("failed to compute stats of collection %s: %v", collection, err)
+	}
+	if stats.MinUpdatedAt, err = parseDbTime(minUpdated); err != nil {
+		return CollectionStats{}, err
+	}
+	if stats.MaxUpdatedAt, err = parseDbTime(maxUpdated); err != nil {
+		return CollectionStats{}, err
+	}
+	return stats, nil
+}
+
+// valueSizeExpr returns the expression computing the size in bytes of the value column
+func (store *DbStore) valueSizeExpr() string {
+	switch store.db.Dialector.Name() {
+	case "postgres":
+		if store.opts.ValueEncoding.binary() {
+			return fmt.Sprintf("octet_length(%s)", columnValue)
+		}
+		return fmt.Sprintf("octet_length(%s::text)", columnValue)
+	case "mysql":
+		return fmt.Sprintf("LENGTH(%s)", columnValue)
+	case "sqlserver":
+		return fmt.Sprintf("DATALENGTH(%s)", columnValue)
+	}
+	// sqlite counts characters of text values, the cast counts the bytes
+	return fmt.Sprintf("length(CAST(%s AS BLOB))", columnValue)
+}
+
+// dbTimeLayouts are the text formats of the timestamps returned by drivers that don't parse the aggregates,
+// e.g. sqlite returns MIN and MAX of a datetime column as text
+var dbTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+}
+
+// parseDbTime converts a scanned timestamp into a time, nil is the zero time
+func parseDbTime(v any) (time.Time, error) {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return val, nil
+	case []byte:
+		s = string(val)
+	case string:
+		s = val
+	default:
+		return time.Time{}, fmt.Errorf("unexpected timestamp type %T", v)
+	}
+	for _, layout := range dbTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse timestamp %q", s)
+}
@@ -0,0 +1,87 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDbStoreStats(t *testing.T) {
+	store := newDbStore(t)
+	ctx := context.Background()
+
+	t.Run("empty collection", func(t *testing.T) {
+		got, err := store.Stats(ctx, "empty")
+		if err != nil {
+			t.Fatalf("action: Stats,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, jsonstore.CollectionStats{}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("collection with documents", func(t *testing.T) {
+		before := time.Now().Add(-time.Second)
+		values := map[string]json.RawMessage{
+			"item1": json.RawMessage(`{"a":1}`),
+			"item2": json.RawMessage(`{"name":"ü"}`), // multibyte characters count as bytes
+		}
+		for key, value := range values {
+			if err := store.Set(ctx, "col1", key, value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		if _, err := store.SetIfVersion(ctx, "col1", "item1", json.RawMessage(`{"a":2}`), "1"); err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if err := store.Set(ctx, "col2", "item1", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		after := time.Now().Add(time.Second)
+
+		got, err := store.Stats(ctx, "col1")
+		if err != nil {
+			t.Fatalf("action: Stats,  returned an error: %v", err)
+		}
+		if got.Count != 2 {
+			t.Errorf("expected count 2, got %d", got.Count)
+		}
+		if got.Bytes != int64(len(`{"a":2}`)+len(`{"name":"ü"}`)) {
+			t.Errorf("unexpected bytes: %d", got.Bytes)
+		}
+		if got.MinUpdatedAt.Before(before) || got.MaxUpdatedAt.After(after) || got.MaxUpdatedAt.Before(got.MinUpdatedAt) {
+			t.Errorf("unexpected update times: %v - %v", got.MinUpdatedAt, got.MaxUpdatedAt)
+		}
+	})
+
+	t.Run("documents written before the updated_at column", func(t *testing.T) {
+		db := newSqliteDbFile(t)
+		err := db.Exec(`CREATE TABLE db_documents (id text, collection text, value json, PRIMARY KEY (id, collection));` +
+			`INSERT INTO db_documents VALUES ('item1', 'col1', '{}');`).Error
+		if err != nil {
+			t.Fatalf("failed to create the table: %v", err)
+		}
+		oldStore, err := jsonstore.NewDbStore(db)
+		if err != nil {
+			t.Fatalf("NewDbStore returned an error: %v", err)
+		}
+		got, err := oldStore.Stats(ctx, "col1")
+		if err != nil {
+			t.Fatalf("action: Stats,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, jsonstore.CollectionStats{Count: 1, Bytes: 2}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		items, _, err := oldStore.List(ctx, "col1", 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if len(items) != 1 {
+			t.Errorf("expected 1 item, got %d", len(items))
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirStore stores every document in its own file <root>/<collection>/<key>.json, a Set only rewrites
+// the affected document, and the data directory can be inspected or tracked with git.
+// Collection and key names are path escaped to be used as file names, e.g. the key "a/b" is stored as "a%2Fb.json".
+// Documents are written atomically, see the durability model of the FileStore.
+type DirStore struct {
+	root  string
+	fs    fileSystem
+	mutex sync.RWMutex
+}
+
+// make sure the directory store fulfills the JsonStore interface
+var _ JsonStorer = &DirStore{}
+var _ PageLister = &DirStore{}
+var _ ModTimeGetter = &DirStore{}
+
+const docExtension = ".json"
+
+// NewDirStore returns a store writing into the root directory, the directory is created if it does not exist
+func NewDirStore(root string) (*DirStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("root directory cannot be empty")
+	}
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create root directory: %v", err)
+	}
+	return &DirStore{root: root, fs: osFs{}}, nil
+}
+
+// escapeName turns a collection or key name into a safe file name
+func escapeName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("name cannot be empty")
+	}
+	escaped := url.PathEscape(name)
+	if escaped == "." || escaped == ".." {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	return escaped, nil
+}
+
+func (d *DirStore) colDir(collection string) (string, error) {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	name, err := escapeName(collection)
+	if err != nil {
+		return "", fmt.Errorf("invalid collection: %v", err)
+	}
+	return filepath.Join(d.root, name), nil
+}
+
+func (d *DirStore) docFile(collection, key string) (string, error) {
+	dir, err := d.colDir(collection)
+	if err != nil {
+		return "", err
+	}
+	name, err := escapeName(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid key: %v", err)
+	}
+	return filepath.Join(dir, name+docExtension), nil
+}
+
+func (d *DirStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	file, err := d.docFile(collection, key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create collection directory: %v", err)
+	}
+	return writeFileAtomic(d.fs, file, value, 0644, true)
+}
+
+func (d *DirStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	file, err := d.docFile(collection, key)
+	if err != nil {
+		return err
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ItemNotFoundErr
+		}
+		return fmt.Errorf("unable to read file: %v", err)
+	}
+	*value = data
+	return nil
+}
+
+// GetWithModTime reads a document together with the modification time of its file
+func (d *DirStore) GetWithModTime(ctx context.Context, collection, key string, value *json.RawMessage) (time.Time, error) {
+	file, err := d.docFile(collection, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, ItemNotFoundErr
+		}
+		return time.Time{}, fmt.Errorf("unable to read file: %v", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read file: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to read file: %v", err)
+	}
+	*value = data
+	return stat.ModTime(), nil
+}
+
+// List returns the documents of a collection and the total amount of documents in it
+func (d *DirStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := d.ListPage(ctx, collection, ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage returns a page of documents of the collection sorted by key, a missing collection is an empty one
+func (d *DirStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	dir, err := d.colDir(collection)
+	if err != nil {
+		return Page{}, err
+	}
+	opts, err = opts.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Page{}, fmt.Errorf("unable to read collection directory: %v", err)
+	}
+	keys := make([]string, 0, len(entries))
+	files := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, docExtension) {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSuffix(name, docExtension))
+		if err != nil {
+			// not written by the store
+			continue
+		}
+		keys = append(keys, key)
+		files[key] = filepath.Join(dir, name)
+	}
+	sort.Strings(keys)
+	keys = filterPrefix(keys, opts.Prefix)
+
+	pageKeys := pageKeys(keys, opts)
+	result := make(map[string]json.RawMessage, len(pageKeys))
+	for _, key := range pageKeys {
+		data, err := os.ReadFile(files[key])
+		if err != nil {
+			return Page{}, fmt.Errorf("unable to read file: %v", err)
+		}
+		result[key] = data
+	}
+	return NewPage(result, int64(len(keys)), opts.Page, opts.Limit), nil
+}
+
+func (d *DirStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	file, err := d.docFile(collection, key)
+	if err != nil {
+		return false, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	err = os.Remove(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return true, nil
+}
+
+// Collections returns the names of the collection directories, sorted
+func (d *DirStore) Collections(ctx context.Context) ([]string, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read root directory: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		collection, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			// not written by the store
+			continue
+		}
+		names = append(names, collection)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CreateCollection creates the directory of an empty collection
+func (d *DirStore) CreateCollection(ctx context.Context, collection string) error {
+	dir, err := d.colDir(collection)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create collection directory: %v", err)
+	}
+	return nil
+}
+
+// DropCollection removes the directory of a collection with all its documents
+func (d *DirStore) DropCollection(ctx context.Context, collection string) error {
+	dir, err := d.colDir(collection)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err = os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete collection %s: %v", collection, err)
+	}
+	return nil
+}
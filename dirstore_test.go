@@ -0,0 +1,87 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newDirStore(t *testing.T) *jsonstore.DirStore {
+	store, err := jsonstore.NewDirStore(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatalf("NewDirStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestDirStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := jsonstore.NewDirStore(root)
+	if err != nil {
+		t.Fatalf("NewDirStore returned an error: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("one file per document", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(root, "col1", "item1.json"))
+		if err != nil {
+			t.Fatalf("unable to read document file: %v", err)
+		}
+		if diff := cmp.Diff(string(got), string(value)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("keys are escaped", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "nested"}`)
+		err := store.Set(ctx, "col1", "../a/b", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if _, err = os.Stat(filepath.Join(root, "col1", "..%2Fa%2Fb.json")); err != nil {
+			t.Errorf("expected the escaped file to exist: %v", err)
+		}
+
+		items, total, err := store.List(ctx, "col1", 10, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 2 {
+			t.Errorf("expected total 2, got %d", total)
+		}
+		if diff := cmp.Diff(items["../a/b"], value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Set(ctx, "col1", "..", value)
+		if err == nil {
+			t.Errorf("expected an error on an invalid key")
+		}
+	})
+
+	t.Run("missing items", func(t *testing.T) {
+		var got json.RawMessage
+		err := store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+		deleted, err := store.Delete(ctx, "missing", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
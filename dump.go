@@ -0,0 +1,193 @@
+package jsonstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExportFormat is the format of the collection dumps of Export and Import
+type ExportFormat string
+
+const (
+	// ExportNdjson is one NdjsonItem per line, {"key":"<key>","value":<document>}
+	ExportNdjson ExportFormat = "ndjson"
+	// ExportJson is a single object of the documents by key, {"<key>":<document>}
+	ExportJson ExportFormat = "json"
+)
+
+// ConflictPolicy defines what Import does with the documents whose key already exists in the collection
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces the existing documents, the default
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip keeps the existing documents
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictFail stops the import at the first existing document with ImportConflictErr, the documents
+	// imported before are kept
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// ImportConflictErr is returned by Import with ConflictFail when a document already exists
+var ImportConflictErr = errors.New("document already exists")
+
+// ImportResult counts the documents of an Import
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// Export writes all the documents of a collection to w in key order; documents are read with ForEach and written
+// as they come, so that collections larger than the memory can be exported.
+func Export(ctx context.Context, store JsonStorer, collection string, w io.Writer, format ExportFormat) error {
+	bw := bufio.NewWriter(w)
+	first := true
+	var err error
+	switch format {
+	case ExportNdjson, "":
+		err = ForEach(ctx, store, collection, func(key string, value json.RawMessage) error {
+			line, err := json.Marshal(NdjsonItem{Key: key, Value: value})
+			if err != nil {
+				return fmt.Errorf("unable to encode document %s: %w", key, err)
+			}
+			_, err = bw.Write(append(line, '\n'))
+			return err
+		})
+	case ExportJson:
+		if _, err = bw.WriteString("{"); err != nil {
+			return err
+		}
+		err = ForEach(ctx, store, collection, func(key string, value json.RawMessage) error {
+			if !json.Valid(value) {
+				return fmt.Errorf("unable to encode document %s: %w", key, InvalidJsonErr)
+			}
+			k, _ := json.Marshal(key)
+			if !first {
+				if err := bw.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := bw.Write(k); err != nil {
+				return err
+			}
+			if err := bw.WriteByte(':'); err != nil {
+				return err
+			}
+			_, err := bw.Write(value)
+			return err
+		})
+		if err == nil {
+			_, err = bw.WriteString("}\n")
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import reads the documents of a dump in the given format from r and stores them in the collection, existing
+// documents are handled according to the ConflictPolicy, ConflictOverwrite if empty. The dump is decoded as it
+// is read, so that it can be larger than the memory; an invalid dump stops the import with InvalidJsonErr,
+// keeping the documents imported before.
+func Import(ctx context.Context, store JsonStorer, collection string, r io.Reader, format ExportFormat, policy ConflictPolicy) (ImportResult, error) {
+	return importDocuments(ctx, store, collection, r, format, policy, nil)
+}
+
+// importDocuments is Import calling check, if not nil, with every document before storing it; an error of check
+// stops the import like an invalid dump.
+func importDocuments(ctx context.Context, store JsonStorer, collection string, r io.Reader, format ExportFormat, policy ConflictPolicy,
+	check func(doc json.RawMessage) error) (ImportResult, error) {
+	result := ImportResult{}
+	switch policy {
+	case "":
+		policy = ConflictOverwrite
+	case ConflictOverwrite, ConflictSkip, ConflictFail:
+	default:
+		return result, fmt.Errorf("unknown conflict policy %q", policy)
+	}
+
+	put := func(key string, value json.RawMessage) error {
+		var current json.RawMessage
+		err := store.Get(ctx, collection, key, &current)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to read document %s: %w", key, err)
+		}
+		exists := err == nil
+		if exists {
+			switch policy {
+			case ConflictSkip:
+				result.Skipped++
+				return nil
+			case ConflictFail:
+				return fmt.Errorf("%w: %s", ImportConflictErr, key)
+			}
+		}
+		if check != nil {
+			if err := check(value); err != nil {
+				return fmt.Errorf("invalid document %s: %w", key, err)
+			}
+		}
+		if err := store.Set(ctx, collection, key, value); err != nil {
+			return fmt.Errorf("failed to store document %s: %w", key, err)
+		}
+		if exists {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(r)
+	switch format {
+	case ExportNdjson, "":
+		for {
+			item := NdjsonItem{}
+			err := dec.Decode(&item)
+			if errors.Is(err, io.EOF) {
+				return result, nil
+			}
+			if err != nil {
+				return result, fmt.Errorf("%w: %v", InvalidJsonErr, err)
+			}
+			if item.Key == "" {
+				return result, fmt.Errorf("%w: item without key", InvalidJsonErr)
+			}
+			if err = put(item.Key, item.Value); err != nil {
+				return result, err
+			}
+		}
+	case ExportJson:
+		if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+			return result, fmt.Errorf("%w: expected an object of documents", InvalidJsonErr)
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return result, fmt.Errorf("%w: %v", InvalidJsonErr, err)
+			}
+			key, _ := t.(string)
+			var value json.RawMessage
+			if err = dec.Decode(&value); err != nil {
+				return result, fmt.Errorf("%w: %v", InvalidJsonErr, err)
+			}
+			if err = put(key, value); err != nil {
+				return result, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return result, fmt.Errorf("%w: %v", InvalidJsonErr, err)
+		}
+		return result, nil
+	}
+	return result, fmt.Errorf("unknown export format %q", format)
+}
@@ -0,0 +1,112 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	tcs := []struct {
+		name   string
+		format jsonstore.ExportFormat
+		want   string
+	}{
+		{name: "ndjson", format: jsonstore.ExportNdjson, want: "{\"key\":\"a\",\"value\":{\"n\":1}}\n{\"key\":\"b\",\"value\":[2]}\n"},
+		{name: "json", format: jsonstore.ExportJson, want: "{\"a\":{\"n\":1},\"b\":[2]}\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			for key, value := range map[string]string{"a": `{"n":1}`, "b": `[2]`} {
+				if err := store.Set(ctx, "col1", key, json.RawMessage(value)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			buf := bytes.Buffer{}
+			if err := jsonstore.Export(ctx, store, "col1", &buf, tc.format); err != nil {
+				t.Fatalf("action: Export,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(buf.String(), tc.want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			// the dump imported into another store is the same collection
+			other := newDbStore(t)
+			result, err := jsonstore.Import(ctx, other, "col1", &buf, tc.format, "")
+			if err != nil {
+				t.Fatalf("action: Import,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(result, jsonstore.ImportResult{Created: 2}); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+			var got json.RawMessage
+			if err = other.Get(ctx, "col1", "b", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), `[2]`); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	dump := "{\"key\":\"a\",\"value\":{\"n\":10}}\n{\"key\":\"c\",\"value\":{\"n\":30}}\n"
+
+	tcs := []struct {
+		name       string
+		format     jsonstore.ExportFormat
+		dump       string
+		policy     jsonstore.ConflictPolicy
+		want       jsonstore.ImportResult
+		wantErr    error
+		wantValueA string
+	}{
+		{name: "overwrite", format: jsonstore.ExportNdjson, dump: dump, want: jsonstore.ImportResult{Created: 1, Updated: 1}, wantValueA: `{"n":10}`},
+		{name: "skip", format: jsonstore.ExportNdjson, dump: dump, policy: jsonstore.ConflictSkip, want: jsonstore.ImportResult{Created: 1, Skipped: 1}, wantValueA: `{"n":1}`},
+		{name: "fail", format: jsonstore.ExportNdjson, dump: dump, policy: jsonstore.ConflictFail, wantErr: jsonstore.ImportConflictErr, wantValueA: `{"n":1}`},
+		{name: "json", format: jsonstore.ExportJson, dump: `{"c":{"n":30},"a":{"n":10}}`, want: jsonstore.ImportResult{Created: 1, Updated: 1}, wantValueA: `{"n":10}`},
+		{name: "invalid ndjson", format: jsonstore.ExportNdjson, dump: "{\"key\":\"c\",\"value\":1}\nnot json", want: jsonstore.ImportResult{Created: 1},
+			wantErr: jsonstore.InvalidJsonErr, wantValueA: `{"n":1}`},
+		{name: "json array", format: jsonstore.ExportJson, dump: `[1,2]`, wantErr: jsonstore.InvalidJsonErr, wantValueA: `{"n":1}`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			if err := store.Set(ctx, "col1", "a", json.RawMessage(`{"n":1}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			result, err := jsonstore.Import(ctx, store, "col1", strings.NewReader(tc.dump), tc.format, tc.policy)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("action: Import,  returned an error: %v", err)
+			}
+			if tc.wantErr == nil || tc.want != (jsonstore.ImportResult{}) {
+				if diff := cmp.Diff(result, tc.want); diff != "" {
+					t.Errorf("unexpected value (-got +want)\n%s", diff)
+				}
+			}
+			var got json.RawMessage
+			if err = store.Get(ctx, "col1", "a", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), tc.wantValueA); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
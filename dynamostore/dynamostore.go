@@ -0,0 +1,302 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/go-bumbu/jsonstore"
+)
+
+// attribute names of the table, collection is the partition key and key the sort key
+const attrCollection = "collection"
+const attrKey = "key"
+const attrValue = "value"
+const attrVersion = "version"
+
+// DynamoAPI is the subset of the dynamodb client used by the store, it is satisfied by *dynamodb.Client
+type DynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+// DynamoStore stores json documents in a DynamoDB table using the collection as partition key
+// and the document key as sort key. Every document carries a numeric version incremented on each write,
+// which is used by conditional writes to implement the jsonstore.VersionedStorer interface.
+type DynamoStore struct {
+	client DynamoAPI
+	table  string
+}
+
+// make sure the dynamo store fulfills the JsonStorer and VersionedStorer interfaces
+var _ jsonstore.JsonStorer = &DynamoStore{}
+var _ jsonstore.VersionedStorer = &DynamoStore{}
+
+// NewDynamoStore returns a store using an existing table, see CreateTableInput for the expected schema.
+func NewDynamoStore(client DynamoAPI, table string) (*DynamoStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("dynamodb client cannot be nil")
+	}
+	if table == "" {
+		return nil, fmt.Errorf("table name cannot be empty")
+	}
+	store := DynamoStore{
+		client: client,
+		table:  table,
+	}
+	return &store, nil
+}
+
+// CreateTableInput returns the definition of a table usable by the DynamoStore, billed on demand;
+// adjust it as needed before calling CreateTable.
+func CreateTableInput(table string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attrCollection), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrKey), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrCollection), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrKey), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+}
+
+func itemKey(collection, key string) (map[string]types.AttributeValue, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if key == "" {
+		return nil, fmt.Errorf("id cannot be empty")
+	}
+	return map[string]types.AttributeValue{
+		attrCollection: &types.AttributeValueMemberS{Value: collection},
+		attrKey:        &types.AttributeValueMemberS{Value: key},
+	}, nil
+}
+
+func (store *DynamoStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	_, err := store.update(ctx, collection, key, value, nil)
+	return err
+}
+
+// SetIfVersion stores the document only if the stored version matches, an empty version
+// requires the document to not exist yet.
+func (store *DynamoStore) SetIfVersion(ctx context.Context, collection, key string, value json.RawMessage, version string) (string, error) {
+	cond := &condition{expr: "attribute_not_exists(#key)", names: map[string]string{"#key": attrKey}}
+	if version != "" {
+		if _, err := strconv.ParseInt(version, 10, 64); err != nil {
+			// a version that is not a number can never match a stored one
+			return "", jsonstore.VersionConflictErr
+		}
+		cond = &condition{
+			expr:   "#ver = :expected",
+			values: map[string]types.AttributeValue{":expected": &types.AttributeValueMemberN{Value: version}},
+		}
+	}
+	return store.update(ctx, collection, key, value, cond)
+}
+
+type condition struct {
+	expr   string
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+// update writes the value incrementing the version atomically, optionally only if cond is met
+func (store *DynamoStore) update(ctx context.Context, collection, key string, value json.RawMessage, cond *condition) (string, error) {
+	k, err := itemKey(collection, key)
+	if err != nil {
+		return "", err
+	}
+	input := dynamodb.UpdateItemInput{
+		TableName:        aws.String(store.table),
+		Key:              k,
+		UpdateExpression: aws.String("SET #val = :val ADD #ver :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#val": attrValue,
+			"#ver": attrVersion,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":val": &types.AttributeValueMemberS{Value: string(value)},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+	if cond != nil {
+		input.ConditionExpression = aws.String(cond.expr)
+		for n, v := range cond.names {
+			input.ExpressionAttributeNames[n] = v
+		}
+		for n, v := range cond.values {
+			input.ExpressionAttributeValues[n] = v
+		}
+	}
+
+	out, err := store.client.UpdateItem(ctx, &input)
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return "", jsonstore.VersionConflictErr
+		}
+		return "", fmt.Errorf("failed to save document: %v", err)
+	}
+	return numberAttr(out.Attributes, attrVersion), nil
+}
+
+func (store *DynamoStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	_, err := store.GetWithVersion(ctx, collection, key, value)
+	return err
+}
+
+// GetWithVersion reads a document together with its current version
+func (store *DynamoStore) GetWithVersion(ctx context.Context, collection, key string, value *json.RawMessage) (string, error) {
+	k, err := itemKey(collection, key)
+	if err != nil {
+		return "", err
+	}
+	out, err := store.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(store.table),
+		Key:            k,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	if len(out.Item) == 0 {
+		return "", jsonstore.ItemNotFoundErr
+	}
+	*value = stringAttr(out.Item, attrValue)
+	return numberAttr(out.Item, attrVersion), nil
+}
+
+func (store *DynamoStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	query := func(sel types.Select, startKey map[string]types.AttributeValue) (*dynamodb.QueryOutput, error) {
+		return store.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(store.table),
+			KeyConditionExpression:    aws.String("#col = :col"),
+			ExpressionAttributeNames:  map[string]string{"#col": attrCollection},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":col": &types.AttributeValueMemberS{Value: collection}},
+			ConsistentRead:            aws.Bool(true),
+			Select:                    sel,
+			ExclusiveStartKey:         startKey,
+		})
+	}
+
+	var count int64
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := query(types.SelectCount, startKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+		}
+		count += int64(out.Count)
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			break
+		}
+	}
+
+	// items are returned sorted by the sort key, skip the ones of the previous pages
+	result := map[string]json.RawMessage{}
+	i := 0
+	startKey = nil
+	for i < offset+limit {
+		out, err := query(types.SelectAllAttributes, startKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		for _, item := range out.Items {
+			if i >= offset && i < offset+limit {
+				result[string(stringAttr(item, attrKey))] = stringAttr(item, attrValue)
+			}
+			i++
+		}
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			break
+		}
+	}
+	return result, count, nil
+}
+
+func (store *DynamoStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	k, err := itemKey(collection, key)
+	if err != nil {
+		return false, err
+	}
+	out, err := store.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:    aws.String(store.table),
+		Key:          k,
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return len(out.Attributes) > 0, nil
+}
+
+// DeleteIfVersion deletes the document only if the stored version matches
+func (store *DynamoStore) DeleteIfVersion(ctx context.Context, collection, key, version string) error {
+	k, err := itemKey(collection, key)
+	if err != nil {
+		return err
+	}
+	if _, err = strconv.ParseInt(version, 10, 64); err != nil {
+		version = "-1" // never matches, but still allows to report a missing document
+	}
+	_, err = store.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(store.table),
+		Key:                       k,
+		ConditionExpression:       aws.String("#ver = :expected"),
+		ExpressionAttributeNames:  map[string]string{"#ver": attrVersion},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":expected": &types.AttributeValueMemberN{Value: version}},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &condErr) {
+			return fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+		}
+		// tell apart a version mismatch from a missing document
+		var v json.RawMessage
+		if _, err = store.GetWithVersion(ctx, collection, key, &v); err != nil {
+			return err
+		}
+		return jsonstore.VersionConflictErr
+	}
+	return nil
+}
+
+func stringAttr(item map[string]types.AttributeValue, name string) json.RawMessage {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return json.RawMessage(v.Value)
+	}
+	return nil
+}
+
+func numberAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		return v.Value
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package dynamostore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/dynamostore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func newDynamoStore(t *testing.T) *dynamostore.DynamoStore {
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		t.Skip("skipping dynamodb tests, they depend on testcontainers")
+	}
+	ctx := context.Background()
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	req := testcontainers.ContainerRequest{
+		Image:        "amazon/dynamodb-local:2.5.2",
+		ExposedPorts: []string{"8000/tcp"},
+		WaitingFor:   wait.ForListeningPort("8000/tcp").WithStartupTimeout(60 * time.Second),
+	}
+	dynamoContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start DynamoDB container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := dynamoContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate DynamoDB container: %v", err)
+		}
+	})
+
+	host, err := dynamoContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get DynamoDB container host: %v", err)
+	}
+	port, err := dynamoContainer.MappedPort(ctx, "8000")
+	if err != nil {
+		t.Fatalf("failed to get DynamoDB container port: %v", err)
+	}
+
+	cfg := aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	}
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		o.BaseEndpoint = aws.String(fmt.Sprintf("http://%s:%s", host, port.Port()))
+	})
+
+	_, err = client.CreateTable(ctx, dynamostore.CreateTableInput("documents"))
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	store, err := dynamostore.NewDynamoStore(client, "documents")
+	if err != nil {
+		t.Fatalf("NewDynamoStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestDynamoStore(t *testing.T) {
+	store := newDynamoStore(t)
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("conditional writes", func(t *testing.T) {
+		v1, err := store.SetIfVersion(ctx, "col3", "item1", json.RawMessage(`{}`), "")
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		_, err = store.SetIfVersion(ctx, "col3", "item1", json.RawMessage(`{}`), "")
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr when creating an existing item, got: %v", err)
+		}
+
+		v2, err := store.SetIfVersion(ctx, "col3", "item1", json.RawMessage(`{"a":1}`), v1)
+		if err != nil {
+			t.Fatalf("action: SetIfVersion,  returned an error: %v", err)
+		}
+		if v2 == v1 {
+			t.Errorf("expected the version to change on write")
+		}
+		_, err = store.SetIfVersion(ctx, "col3", "item1", json.RawMessage(`{"a":2}`), v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on a stale version, got: %v", err)
+		}
+
+		err = store.DeleteIfVersion(ctx, "col3", "item1", v1)
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr on a stale version, got: %v", err)
+		}
+		err = store.DeleteIfVersion(ctx, "col3", "item1", v2)
+		if err != nil {
+			t.Fatalf("action: DeleteIfVersion,  returned an error: %v", err)
+		}
+		err = store.DeleteIfVersion(ctx, "col3", "item1", v2)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package etcdstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultPrefix is the etcd key prefix used when none is provided
+const DefaultPrefix = "/jsonstore/"
+
+// EtcdStore stores json documents in etcd under the keys <prefix><collection>/<key>,
+// it is intended for small strongly-consistent configuration stores and supports watching
+// collections for changes.
+type EtcdStore struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// make sure the etcd store fulfills the JsonStorer and Watcher interfaces
+var _ jsonstore.JsonStorer = &EtcdStore{}
+var _ jsonstore.Watcher = &EtcdStore{}
+
+// NewEtcdStore returns a store using the provided client, keys are created under prefix, if empty DefaultPrefix is used.
+// The caller is responsible to close the client once done.
+func NewEtcdStore(client *clientv3.Client, prefix string) (*EtcdStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("etcd client cannot be nil")
+	}
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	store := EtcdStore{
+		client: client,
+		prefix: prefix,
+	}
+	return &store, nil
+}
+
+// colPrefix returns the etcd key prefix of all the documents in a collection
+func (store *EtcdStore) colPrefix(collection string) (string, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if strings.Contains(collection, "/") {
+		return "", fmt.Errorf("collection name cannot contain \"/\"")
+	}
+	return store.prefix + collection + "/", nil
+}
+
+func (store *EtcdStore) docKey(collection, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("id cannot be empty")
+	}
+	prefix, err := store.colPrefix(collection)
+	if err != nil {
+		return "", err
+	}
+	return prefix + key, nil
+}
+
+func (store *EtcdStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	k, err := store.docKey(collection, key)
+	if err != nil {
+		return err
+	}
+	_, err = store.client.Put(ctx, k, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *EtcdStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	k, err := store.docKey(collection, key)
+	if err != nil {
+		return err
+	}
+	resp, err := store.client.Get(ctx, k)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return jsonstore.ItemNotFoundErr
+	}
+	*value = resp.Kvs[0].Value
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, etcd has no offset queries
+// hence the documents of the previous pages are read and skipped.
+func (store *EtcdStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	prefix, err := store.colPrefix(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	resp, err := store.client.Get(ctx, prefix,
+		clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		clientv3.WithLimit(int64(offset+limit)),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+
+	result := map[string]json.RawMessage{}
+	for i, kv := range resp.Kvs {
+		if i < offset {
+			continue
+		}
+		result[strings.TrimPrefix(string(kv.Key), prefix)] = kv.Value
+	}
+	// Count holds the amount of keys in the range regardless of the limit
+	return result, resp.Count, nil
+}
+
+func (store *EtcdStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	k, err := store.docKey(collection, key)
+	if err != nil {
+		return false, err
+	}
+	resp, err := store.client.Delete(ctx, k)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return resp.Deleted > 0, nil
+}
+
+// Watch notifies the changes of the collection using an etcd watch on the collection prefix
+func (store *EtcdStore) Watch(ctx context.Context, collection string) (<-chan jsonstore.Event, error) {
+	prefix, err := store.colPrefix(collection)
+	if err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+
+	wch := store.client.Watch(ctx, prefix, clientv3.WithPrefix())
+	events := make(chan jsonstore.Event)
+	go func() {
+		defer close(events)
+		for resp := range wch {
+			if resp.Err() != nil {
+				return
+			}
+			for _, ev := range resp.Events {
+				event := jsonstore.Event{
+					Collection: collection,
+					Key:        strings.TrimPrefix(string(ev.Kv.Key), prefix),
+				}
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					event.Type = jsonstore.EventSet
+					event.Value = ev.Kv.Value
+				case clientv3.EventTypeDelete:
+					event.Type = jsonstore.EventDelete
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,182 @@
+package etcdstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/etcdstore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func newEtcdStore(t *testing.T) *etcdstore.EtcdStore {
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		t.Skip("skipping etcd tests, they depend on testcontainers")
+	}
+	ctx := context.Background()
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	req := testcontainers.ContainerRequest{
+		Image:        "quay.io/coreos/etcd:v3.5.16",
+		ExposedPorts: []string{"2379/tcp"},
+		Cmd: []string{"etcd",
+			"--listen-client-urls", "http://0.0.0.0:2379",
+			"--advertise-client-urls", "http://0.0.0.0:2379",
+		},
+		WaitingFor: wait.ForListeningPort("2379/tcp").WithStartupTimeout(60 * time.Second),
+	}
+	etcdContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start etcd container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := etcdContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate etcd container: %v", err)
+		}
+	})
+
+	host, err := etcdContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get etcd container host: %v", err)
+	}
+	port, err := etcdContainer.MappedPort(ctx, "2379")
+	if err != nil {
+		t.Fatalf("failed to get etcd container port: %v", err)
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{fmt.Sprintf("%s:%s", host, port.Port())},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to etcd: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	store, err := etcdstore.NewEtcdStore(client, "")
+	if err != nil {
+		t.Fatalf("NewEtcdStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestEtcdStore(t *testing.T) {
+	store := newEtcdStore(t)
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		wctx, cancel := context.WithCancel(ctx)
+		events, err := store.Watch(wctx, "col3")
+		if err != nil {
+			t.Fatalf("action: Watch,  returned an error: %v", err)
+		}
+
+		if err = store.Set(ctx, "col3", "item1", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		// changes to other collections are not notified
+		if err = store.Set(ctx, "col4", "item1", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if _, err = store.Delete(ctx, "col3", "item1"); err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+
+		want := []jsonstore.Event{
+			{Type: jsonstore.EventSet, Collection: "col3", Key: "item1", Value: json.RawMessage(`{"a":1}`)},
+			{Type: jsonstore.EventDelete, Collection: "col3", Key: "item1"},
+		}
+		var got []jsonstore.Event
+		for len(got) < len(want) {
+			select {
+			case ev := <-events:
+				got = append(got, ev)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for events, got: %v", got)
+			}
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		cancel()
+		for range events {
+			// drain until the channel is closed
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, "col1", "item1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
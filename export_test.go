@@ -0,0 +1,23 @@
+package jsonstore
+
+import "sort"
+
+// aliases to allow the external tests to inject file system failures
+type FileSystem = fileSystem
+type WritableFile = writableFile
+
+func SetFileSystem(f *FileStore, fsys FileSystem) {
+	f.fs = fsys
+}
+
+// LoadedCollections returns the sorted collections held in memory by the store
+func LoadedCollections(f *FileStore) []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	cols := make([]string, 0, len(f.content))
+	for col := range f.content {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
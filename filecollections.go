@@ -0,0 +1,147 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout of the FilePerCollection mode:
+// the store file is a directory holding a <collection>.json file per collection, collection names are path
+// escaped like in the DirStore. A flush only writes the collections changed since the previous one, each of them
+// with the atomic write described in filewrite.go.
+
+// collectionFile returns the file of a collection
+func (f *FileStore) collectionFile(collection string) (string, error) {
+	name, err := escapeName(collection)
+	if err != nil {
+		return "", fmt.Errorf("invalid collection: %v", err)
+	}
+	return filepath.Join(f.file, name+f.format.extension()), nil
+}
+
+// markDirty records a changed collection to be written by the next flush
+func (f *FileStore) markDirty(collection string) {
+	if !f.perCollection {
+		return
+	}
+	if f.dirtyCols == nil {
+		f.dirtyCols = map[string]struct{}{}
+	}
+	f.dirtyCols[collection] = struct{}{}
+}
+
+// openCollectionsDir creates the directory of the store, discards interrupted writes and loads the collections
+func (f *FileStore) openCollectionsDir() error {
+	if !f.readOnly {
+		err := os.MkdirAll(f.file, f.dirMode)
+		if err != nil {
+			return fmt.Errorf("unable to create directory: %v", err)
+		}
+	}
+	entries, err := os.ReadDir(f.file)
+	if err != nil {
+		return fmt.Errorf("unable to read directory: %v", err)
+	}
+	for _, entry := range entries {
+		if !f.readOnly && strings.HasSuffix(entry.Name(), f.format.extension()+tmpSuffix) {
+			err = discardInterruptedWrite(f.fs, filepath.Join(f.file, strings.TrimSuffix(entry.Name(), tmpSuffix)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if f.lazy {
+		return nil
+	}
+	return f.readFile()
+}
+
+// diskCollections returns the collections having a file in the directory
+func (f *FileStore) diskCollections() (map[string]string, error) {
+	entries, err := os.ReadDir(f.file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory: %v", err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), f.format.extension()) {
+			continue
+		}
+		collection, err := url.PathUnescape(strings.TrimSuffix(entry.Name(), f.format.extension()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid collection file name %q: %v", entry.Name(), err)
+		}
+		files[collection] = filepath.Join(f.file, entry.Name())
+	}
+	return files, nil
+}
+
+// readCollections reads and parses the files of the collections
+func (f *FileStore) readCollections() (map[string]map[string]json.RawMessage, error) {
+	files, err := f.diskCollections()
+	if err != nil {
+		return nil, err
+	}
+	content := map[string]map[string]json.RawMessage{}
+	for collection, file := range files {
+		content[collection], err = f.readCollection(file)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %v", collection, err)
+		}
+	}
+	return content, nil
+}
+
+// readCollection reads and parses the file of a collection
+func (f *FileStore) readCollection(file string) (map[string]json.RawMessage, error) {
+	bytes, err := f.readFileData(file)
+	if err != nil {
+		return nil, err
+	}
+	var items map[string]any
+	if err = json.Unmarshal(bytes, &items); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal file: %v", err)
+	}
+	return documents(items)
+}
+
+// flushCollections writes the files of the collections changed since the last flush
+func (f *FileStore) flushCollections() error {
+	for collection := range f.dirtyCols {
+		file, err := f.collectionFile(collection)
+		if err != nil {
+			return err
+		}
+		docs, ok := f.content[collection]
+		if !ok {
+			// the collection was removed, e.g. by restoring a snapshot
+			if err = f.fs.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("unable to remove collection file: %v", err)
+			}
+			delete(f.dirtyCols, collection)
+			continue
+		}
+		if docs == nil {
+			docs = map[string]json.RawMessage{}
+		}
+		bytes, err := f.marshal(docs)
+		if err != nil {
+			return fmt.Errorf("collection %s: %v", collection, err)
+		}
+		bytes, err = f.encodeFile(bytes)
+		if err != nil {
+			return err
+		}
+		err = writeFileAtomic(f.fs, file, bytes, f.fileMode, f.Durability != DurabilityNoSync)
+		if err != nil {
+			return err
+		}
+		delete(f.dirtyCols, collection)
+	}
+	return nil
+}
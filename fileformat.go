@@ -0,0 +1,213 @@
+package jsonstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Format of the FileStore file: the json content (or its yaml or toml conversion, see fileserialize.go), optionally preceded by the checksum header of the Recoverable
+// flag (see filerecovery.go), gzip compressed and then encrypted.
+// An encrypted file starts with a header made of encryptedMagic, the header version, the key mode,
+// the scrypt salt (only when the key is derived from a passphrase) and the nonce, followed by the
+// AES-GCM sealed content; the header is authenticated together with the content.
+
+// gzipMagic are the first bytes of a gzip stream, used to detect compressed files on open
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encryptedMagic are the first bytes of a file encrypted by the FileStore
+var encryptedMagic = []byte("JSENC")
+
+const (
+	encryptionVersion byte = 1
+	keyModeKey        byte = 0
+	keyModePassphrase byte = 1
+	saltSize               = 16
+)
+
+// FileEncryption configures the AES-256-GCM encryption of the FileStore file, set either Key or Passphrase
+type FileEncryption struct {
+	// Key is the 32 bytes AES-256 key
+	Key []byte
+	// Passphrase derives the key with scrypt, using a random salt stored in the header of the file
+	Passphrase string
+}
+
+// fileEncryption holds the cipher of an encrypted FileStore
+type fileEncryption struct {
+	mode       byte
+	passphrase string
+	salt       []byte
+	aead       cipher.AEAD
+}
+
+func (f *FileStore) setEncryption(enc FileEncryption) error {
+	if (len(enc.Key) == 0) == (enc.Passphrase == "") {
+		return fmt.Errorf("file encryption requires either a key or a passphrase")
+	}
+	if enc.Passphrase != "" {
+		// the key is derived once the salt is known: read from the file or generated on the first flush
+		f.encryption = &fileEncryption{mode: keyModePassphrase, passphrase: enc.Passphrase}
+		return nil
+	}
+	if len(enc.Key) != 32 {
+		return fmt.Errorf("file encryption key must be 32 bytes long, got %d", len(enc.Key))
+	}
+	aead, err := newAead(enc.Key)
+	if err != nil {
+		return err
+	}
+	f.encryption = &fileEncryption{mode: keyModeKey, aead: aead}
+	return nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey sets the cipher of the key derived from the passphrase and salt
+func (e *fileEncryption) deriveKey(salt []byte) error {
+	key, err := scrypt.Key([]byte(e.passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return fmt.Errorf("unable to derive key: %v", err)
+	}
+	aead, err := newAead(key)
+	if err != nil {
+		return err
+	}
+	e.salt = salt
+	e.aead = aead
+	return nil
+}
+
+func (e *fileEncryption) encrypt(data []byte) ([]byte, error) {
+	if e.aead == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("unable to generate salt: %v", err)
+		}
+		if err := e.deriveKey(salt); err != nil {
+			return nil, err
+		}
+	}
+	header := append([]byte{}, encryptedMagic...)
+	header = append(header, encryptionVersion, e.mode)
+	if e.mode == keyModePassphrase {
+		header = append(header, e.salt...)
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %v", err)
+	}
+	header = append(header, nonce...)
+	return e.aead.Seal(header, nonce, data, header), nil
+}
+
+func (e *fileEncryption) decrypt(data []byte) ([]byte, error) {
+	pos := len(encryptedMagic)
+	if len(data) < pos+2 {
+		return nil, fmt.Errorf("encrypted file header is truncated")
+	}
+	if data[pos] != encryptionVersion {
+		return nil, fmt.Errorf("unsupported encrypted file version %d", data[pos])
+	}
+	if data[pos+1] != e.mode {
+		if e.mode == keyModeKey {
+			return nil, fmt.Errorf("file is encrypted with a passphrase, not a key")
+		}
+		return nil, fmt.Errorf("file is encrypted with a key, not a passphrase")
+	}
+	pos += 2
+	if e.mode == keyModePassphrase {
+		if len(data) < pos+saltSize {
+			return nil, fmt.Errorf("encrypted file header is truncated")
+		}
+		salt := data[pos : pos+saltSize]
+		if e.aead == nil || !bytes.Equal(salt, e.salt) {
+			if err := e.deriveKey(append([]byte{}, salt...)); err != nil {
+				return nil, err
+			}
+		}
+		pos += saltSize
+	}
+	if len(data) < pos+e.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file header is truncated")
+	}
+	nonce := data[pos : pos+e.aead.NonceSize()]
+	header := data[:pos+e.aead.NonceSize()]
+	out, err := e.aead.Open(nil, nonce, data[len(header):], header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt file, wrong key or corrupted file: %v", err)
+	}
+	return out, nil
+}
+
+// encodeFile converts the json content into the format persisted to the file
+func (f *FileStore) encodeFile(data []byte) ([]byte, error) {
+	data, err := f.format.fromJson(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.recoverable {
+		data = addChecksum(data)
+	}
+	if f.gzip {
+		buf := bytes.Buffer{}
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("unable to compress file: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("unable to compress file: %v", err)
+		}
+		data = buf.Bytes()
+	}
+	if f.encryption != nil {
+		return f.encryption.encrypt(data)
+	}
+	return data, nil
+}
+
+// decodeFile converts the content of the file back into json, the format is detected from the data so that
+// a store can open files written with or without compression, and plain files with encryption enabled
+func (f *FileStore) decodeFile(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, encryptedMagic) {
+		if f.encryption == nil {
+			return nil, fmt.Errorf("file is encrypted, open it with NewEncryptedFileStore")
+		}
+		var err error
+		data, err = f.encryption.decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress file: %v", err)
+		}
+		defer zr.Close()
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress file: %v", err)
+		}
+	}
+	if hasChecksumHeader(data) {
+		var err error
+		data, err = verifyChecksum(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f.format.toJson(data)
+}
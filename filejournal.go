@@ -0,0 +1,133 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Journal model of the FileStore:
+// with the AppendJournal flag every Set and Delete appends a single line record to <file>.journal instead of
+// rewriting the whole file. On open the records are replayed on top of the content of the file; once the journal
+// reaches CompactAfter records (and on Flush and Close) the content is written to the file with the atomic flush
+// described in filewrite.go and the journal is truncated. A crash between the two steps only leaves records
+// already contained in the file, replaying them again is harmless. A record partially written by a crash is
+// discarded on open.
+
+// journalSuffix is appended to the store file name to create the journal
+const journalSuffix = ".journal"
+
+// DefaultCompactAfter is the amount of journal records that triggers a compaction if CompactAfter is not set
+const DefaultCompactAfter = 1000
+
+func (f *FileStore) journalFile() string {
+	return f.file + journalSuffix
+}
+
+// openJournal replays the records of an existing journal, compacts them into the file and opens the journal
+// for appending
+func (f *FileStore) openJournal() error {
+	n, err := f.replayJournal()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if err = f.flushToFile(); err != nil {
+			return err
+		}
+	}
+	// truncating also discards a partially written record
+	j, err := f.fs.OpenFile(f.journalFile(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, f.fileMode)
+	if err != nil {
+		return fmt.Errorf("unable to open journal: %v", err)
+	}
+	f.journal = j
+	f.journalRecords = 0
+	return nil
+}
+
+// replayJournal applies the records of the journal to the content and returns the amount of replayed records
+func (f *FileStore) replayJournal() (int, error) {
+	data, err := os.ReadFile(f.journalFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to read journal: %v", err)
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	n := 0
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		record := Event{}
+		if err = json.Unmarshal(line, &record); err != nil {
+			// only the last record can be incomplete, it was being written during a crash
+			if i == len(lines)-1 {
+				break
+			}
+			return 0, fmt.Errorf("corrupted journal record at line %d: %v", i+1, err)
+		}
+		if err = f.loadCollection(record.Collection); err != nil {
+			return 0, err
+		}
+		f.markDirty(record.Collection)
+		switch record.Type {
+		case EventSet:
+			if !f.colExists(record.Collection) {
+				f.content[record.Collection] = map[string]json.RawMessage{}
+			}
+			f.content[record.Collection][record.Key] = record.Value
+		case EventDelete:
+			delete(f.content[record.Collection], record.Key)
+		default:
+			return 0, fmt.Errorf("unknown journal record type %q at line %d", record.Type, i+1)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// appendJournal writes a record to the journal and compacts it once it reaches CompactAfter records,
+// it needs to be called holding the write lock
+func (f *FileStore) appendJournal(record Event) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to encode journal record: %v", err)
+	}
+	_, err = f.journal.Write(append(line, '\n'))
+	if err == nil && f.Durability == DurabilitySync {
+		err = f.journal.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to append to journal: %v", err)
+	}
+	f.journalRecords++
+
+	compactAfter := f.CompactAfter
+	if compactAfter <= 0 {
+		compactAfter = DefaultCompactAfter
+	}
+	if f.journalRecords >= compactAfter {
+		return f.flushToFile()
+	}
+	return nil
+}
+
+// truncateJournal discards the records of the journal once they are contained in the file
+func (f *FileStore) truncateJournal() error {
+	if err := f.journal.Close(); err != nil {
+		return fmt.Errorf("unable to close journal: %v", err)
+	}
+	j, err := f.fs.OpenFile(f.journalFile(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, f.fileMode)
+	if err != nil {
+		f.journal = nil
+		return fmt.Errorf("unable to truncate journal: %v", err)
+	}
+	f.journal = j
+	f.journalRecords = 0
+	return nil
+}
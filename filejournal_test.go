@@ -0,0 +1,137 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileStoreJournal(t *testing.T) {
+	ctx := context.Background()
+
+	newJournalStore := func(t *testing.T, file string) *jsonstore.FileStore {
+		t.Helper()
+		store, err := jsonstore.NewFileStore(file, jsonstore.AppendJournal)
+		if err != nil {
+			t.Fatalf("unable to open the store: %v", err)
+		}
+		return store
+	}
+	journalLines := func(t *testing.T, file string) int {
+		t.Helper()
+		data, err := os.ReadFile(file + ".journal")
+		if err != nil {
+			t.Fatalf("unable to read the journal: %v", err)
+		}
+		return bytes.Count(data, []byte("\n"))
+	}
+	assertItems := func(t *testing.T, store *jsonstore.FileStore, want map[string]json.RawMessage) {
+		t.Helper()
+		got, _, err := store.List(ctx, "col1", 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	}
+
+	t.Run("writes are appended and replayed on open", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		store := newJournalStore(t, file)
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if err := store.Set(ctx, "col1", "item2", json.RawMessage(`{"a":2}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if _, err := store.Delete(ctx, "col1", "item1"); err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if stat, err := os.Stat(file); err != nil || stat.Size() != 0 {
+			t.Errorf("expected the file not to be rewritten")
+		}
+		if got := journalLines(t, file); got != 3 {
+			t.Errorf("expected 3 journal records, got %d", got)
+		}
+
+		reopened := newJournalStore(t, file)
+		assertItems(t, reopened, map[string]json.RawMessage{"item2": json.RawMessage(`{"a":2}`)})
+		// the replayed records are compacted on open
+		if got := journalLines(t, file); got != 0 {
+			t.Errorf("expected an empty journal after open, got %d records", got)
+		}
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		store := newJournalStore(t, file)
+		store.CompactAfter = 2
+		for i := 0; i < 3; i++ {
+			if err := store.Set(ctx, "col1", fmt.Sprintf("item%d", i), json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		if got := len(readJsonFile(t, file).(map[string]interface{})["col1"].(map[string]interface{})); got != 2 {
+			t.Errorf("expected 2 compacted items in the file, got %d", got)
+		}
+		if got := journalLines(t, file); got != 1 {
+			t.Errorf("expected 1 journal record, got %d", got)
+		}
+
+		if err := store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		if got := len(readJsonFile(t, file).(map[string]interface{})["col1"].(map[string]interface{})); got != 3 {
+			t.Errorf("expected 3 items in the file after close, got %d", got)
+		}
+		if got := journalLines(t, file); got != 0 {
+			t.Errorf("expected an empty journal after close, got %d records", got)
+		}
+	})
+
+	t.Run("interrupted append", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		store := newJournalStore(t, file)
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		// simulate a crash while appending the next record
+		j, err := os.OpenFile(file+".journal", os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = j.WriteString(`{"type":"set","collection":"col1","key":"ite`); err != nil {
+			t.Fatal(err)
+		}
+		j.Close()
+
+		reopened := newJournalStore(t, file)
+		assertItems(t, reopened, map[string]json.RawMessage{"item1": json.RawMessage(`{"a":1}`)})
+		if err = reopened.Set(ctx, "col1", "item2", json.RawMessage(`{"a":2}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		assertItems(t, newJournalStore(t, file), map[string]json.RawMessage{
+			"item1": json.RawMessage(`{"a":1}`),
+			"item2": json.RawMessage(`{"a":2}`),
+		})
+	})
+
+	t.Run("corrupted record", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		content := "not json\n" + `{"type":"set","collection":"col1","key":"item1","value":{}}` + "\n"
+		if err := os.WriteFile(file+".journal", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jsonstore.NewFileStore(file, jsonstore.AppendJournal); err == nil {
+			t.Errorf("expected an error opening a store with a corrupted journal")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// With LazyLoad a FilePerCollection store only reads the file of a collection on its first access, and once more
+// than MaxLoadedCollections are loaded it evicts the least recently used ones that have no pending changes.
+
+// readLock takes the lock for reading a collection, with LazyLoad the collection might need to be loaded,
+// which requires the write lock
+func (f *FileStore) readLock(collection string) (func(), error) {
+	if !f.lazy {
+		f.mutex.RLock()
+		return f.mutex.RUnlock, nil
+	}
+	f.mutex.Lock()
+	if err := f.loadCollection(collection); err != nil {
+		f.mutex.Unlock()
+		return nil, err
+	}
+	return f.mutex.Unlock, nil
+}
+
+// loadCollection reads the file of the collection if it is not loaded yet, it needs to be called holding
+// the write lock
+func (f *FileStore) loadCollection(collection string) error {
+	if !f.lazy {
+		return nil
+	}
+	f.accessClock++
+	if f.lastAccess == nil {
+		f.lastAccess = map[string]uint64{}
+	}
+	f.lastAccess[collection] = f.accessClock
+	if f.colExists(collection) {
+		return nil
+	}
+
+	file, err := f.collectionFile(collection)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	docs, err := f.readCollection(file)
+	if err != nil {
+		return fmt.Errorf("collection %s: %v", collection, err)
+	}
+	f.content[collection] = docs
+	f.evict()
+	return nil
+}
+
+// evict unloads the least recently used collections without pending changes
+// until at most MaxLoadedCollections are loaded
+func (f *FileStore) evict() {
+	for f.MaxLoadedCollections > 0 && len(f.content) > f.MaxLoadedCollections {
+		coldest := ""
+		for collection := range f.content {
+			if _, dirty := f.dirtyCols[collection]; dirty {
+				continue
+			}
+			if coldest == "" || f.lastAccess[collection] < f.lastAccess[coldest] {
+				coldest = collection
+			}
+		}
+		if coldest == "" {
+			return
+		}
+		delete(f.content, coldest)
+		delete(f.lastAccess, coldest)
+	}
+}
@@ -0,0 +1,46 @@
+package jsonstore
+
+import (
+	"fmt"
+	"os"
+)
+
+// With FileStoreOptions.Lock the FileStore takes an exclusive advisory lock on <file>.lock for its whole lifetime,
+// so that a second store opening the same file, in this or another process, fails with FileLockedErr instead of
+// silently overwriting the writes of the first one. The lock is released by Close, or by the os when the process
+// exits; the lock file itself is left in place.
+
+// lockSuffix is appended to the store file name to create the lock file
+const lockSuffix = ".lock"
+
+// acquireLock takes the lock of the store
+func (f *FileStore) acquireLock() error {
+	lock, err := lockFile(f.file+lockSuffix, f.fileMode)
+	if err != nil {
+		return err
+	}
+	f.lock = lock
+	return nil
+}
+
+// releaseLock releases the lock of the store, if taken
+func (f *FileStore) releaseLock() error {
+	if f.lock == nil {
+		return nil
+	}
+	err := f.lock.Close()
+	f.lock = nil
+	if err != nil {
+		return fmt.Errorf("unable to release lock: %v", err)
+	}
+	return nil
+}
+
+// openLockFile opens or creates the lock file
+func openLockFile(file string, perm os.FileMode) (*os.File, error) {
+	lock, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file: %v", err)
+	}
+	return lock, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package jsonstore
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile is not supported on this platform
+func lockFile(file string, perm os.FileMode) (*os.File, error) {
+	return nil, fmt.Errorf("file locking is not supported on this platform")
+}
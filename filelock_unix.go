@@ -0,0 +1,27 @@
+//go:build unix
+
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on file without waiting, it returns FileLockedErr if it is held by someone else
+func lockFile(file string, perm os.FileMode) (*os.File, error) {
+	lock, err := openLockFile(file, perm)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		lock.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, FileLockedErr
+		}
+		return nil, fmt.Errorf("unable to lock file: %v", err)
+	}
+	return lock, nil
+}
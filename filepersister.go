@@ -0,0 +1,139 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Persister loads and saves the content of a FileStore kept somewhere else than in a file, e.g. a key of another
+// configuration system, a custom blob API or a buffer in tests. The content is the one of the store file,
+// including compression and encryption if the flags enable them.
+type Persister interface {
+	// Load returns the content written by the last Save, nil or empty if nothing was saved yet
+	Load() ([]byte, error)
+	// Save replaces the persisted content with data
+	Save(data []byte) error
+}
+
+// PersisterFuncs adapts a pair of load and save callbacks to a Persister
+type PersisterFuncs struct {
+	LoadFunc func() ([]byte, error)
+	SaveFunc func(data []byte) error
+}
+
+func (p PersisterFuncs) Load() ([]byte, error)  { return p.LoadFunc() }
+func (p PersisterFuncs) Save(data []byte) error { return p.SaveFunc(data) }
+
+// NewPersistedStore returns a FileStore loading its content from p on creation and saving it to p on every flush,
+// according to the Durability of the store. The layout, journal and recovery flags (FilePerCollection, LazyLoad,
+// AppendJournal and Recoverable) rely on files and are not supported, as is AutoReload.
+func NewPersistedStore(p Persister, flags ...FileStoreFlag) (*FileStore, error) {
+	opts, err := flagOptions(flags)
+	if err != nil {
+		return nil, err
+	}
+	return newFileStore("", opts, p)
+}
+
+// NewReadWriteSeekerStore returns a FileStore persisted to rws, see ReadWriteSeekerPersister
+func NewReadWriteSeekerStore(rws io.ReadWriteSeeker, flags ...FileStoreFlag) (*FileStore, error) {
+	return NewPersistedStore(&ReadWriteSeekerPersister{RWS: rws}, flags...)
+}
+
+// NewFSStore returns a read only FileStore serving the file at path of fsys, e.g. a dataset embedded into the
+// binary with go:embed; Set, Delete and RestoreSnapshot fail with ReadOnlyErr. The flags of NewPersistedStore
+// apply, a gzip compressed file is detected regardless of the flags.
+func NewFSStore(fsys fs.FS, path string, flags ...FileStoreFlag) (*FileStore, error) {
+	p := PersisterFuncs{
+		LoadFunc: func() ([]byte, error) { return fs.ReadFile(fsys, path) },
+		SaveFunc: func([]byte) error { return ReadOnlyErr },
+	}
+	opts, err := flagOptions(flags)
+	if err != nil {
+		return nil, err
+	}
+	opts.ReadOnly = true
+	return newFileStore("", opts, p)
+}
+
+// ReadWriteSeekerPersister is a Persister rewriting the content of an io.ReadWriteSeeker from its start, e.g. an
+// *os.File opened by the caller. If RWS has a Truncate(size int64) error method, like *os.File, the leftover of
+// a longer previous content is removed, otherwise saving a shorter content fails. If RWS has a Sync() error method
+// it is called after every write.
+type ReadWriteSeekerPersister struct {
+	RWS io.ReadWriteSeeker
+	// size is the length of the content currently in RWS
+	size int64
+}
+
+type truncater interface {
+	Truncate(size int64) error
+}
+
+type syncer interface {
+	Sync() error
+}
+
+func (p *ReadWriteSeekerPersister) Load() ([]byte, error) {
+	if _, err := p.RWS.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(p.RWS)
+	if err != nil {
+		return nil, err
+	}
+	p.size = int64(len(data))
+	return data, nil
+}
+
+func (p *ReadWriteSeekerPersister) Save(data []byte) error {
+	t, canTruncate := p.RWS.(truncater)
+	if !canTruncate && int64(len(data)) < p.size {
+		return errors.New("the content shrinks and the writer cannot be truncated")
+	}
+	if _, err := p.RWS.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := p.RWS.Write(data); err != nil {
+		return err
+	}
+	if canTruncate {
+		if err := t.Truncate(int64(len(data))); err != nil {
+			return err
+		}
+	}
+	p.size = int64(len(data))
+	if s, ok := p.RWS.(syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// openPersister validates the options of a persisted store and loads its content
+func (f *FileStore) openPersister(p Persister, opts FileStoreOptions) error {
+	if opts.FilePerCollection || opts.LazyLoad || opts.AppendJournal || opts.Recoverable || opts.Lock {
+		return fmt.Errorf("the FilePerCollection, LazyLoad, AppendJournal, Recoverable and Lock options are not supported by a persisted store")
+	}
+	f.persister = p
+	f.inMemory = false
+	return f.readFile()
+}
+
+// loadPersisted loads and parses the content of the persister
+func (f *FileStore) loadPersisted() (map[string]map[string]json.RawMessage, error) {
+	data, err := f.persister.Load()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load content: %v", err)
+	}
+	if len(data) == 0 {
+		return map[string]map[string]json.RawMessage{}, nil
+	}
+	data, err = f.decodeFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseContent(data)
+}
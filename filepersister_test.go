@@ -0,0 +1,185 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPersistedStore(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	t.Run("callbacks", func(t *testing.T) {
+		var saved []byte
+		saves := 0
+		p := jsonstore.PersisterFuncs{
+			LoadFunc: func() ([]byte, error) { return saved, nil },
+			SaveFunc: func(data []byte) error {
+				saved = append([]byte(nil), data...)
+				saves++
+				return nil
+			},
+		}
+		store, err := jsonstore.NewPersistedStore(p, jsonstore.MinimizedJson)
+		if err != nil {
+			t.Fatalf("NewPersistedStore returned an error: %v", err)
+		}
+		if err = store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if saves != 1 {
+			t.Errorf("expected 1 save, got %d", saves)
+		}
+		if diff := cmp.Diff(string(saved), `{"col1":{"item1":{"item":"my value"}}}`); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		reopened, err := jsonstore.NewPersistedStore(p)
+		if err != nil {
+			t.Fatalf("NewPersistedStore returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err = reopened.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("read write seeker", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(t.TempDir(), "store.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		store, err := jsonstore.NewReadWriteSeekerStore(f, jsonstore.GzipCompressed)
+		if err != nil {
+			t.Fatalf("NewReadWriteSeekerStore returned an error: %v", err)
+		}
+		for _, key := range []string{"item1", "item2"} {
+			if err = store.Set(ctx, "col1", key, value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		// the content shrinks, the file is truncated
+		if _, err = store.Delete(ctx, "col1", "item2"); err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+
+		reopened, err := jsonstore.NewReadWriteSeekerStore(f)
+		if err != nil {
+			t.Fatalf("NewReadWriteSeekerStore returned an error: %v", err)
+		}
+		if diff := cmp.Diff(takeSnapshot(t, reopened), map[string]map[string]any{
+			"col1": {"item1": map[string]any{"item": "my value"}},
+		}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("seeker without truncate", func(t *testing.T) {
+		store, err := jsonstore.NewReadWriteSeekerStore(&memSeeker{})
+		if err != nil {
+			t.Fatalf("NewReadWriteSeekerStore returned an error: %v", err)
+		}
+		if err = store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if _, err = store.Delete(ctx, "col1", "item1"); err == nil {
+			t.Errorf("expected an error saving a shorter content without truncate")
+		}
+	})
+
+	t.Run("unsupported flags", func(t *testing.T) {
+		for _, flag := range []jsonstore.FileStoreFlag{jsonstore.FilePerCollection, jsonstore.AppendJournal, jsonstore.Recoverable} {
+			if _, err := jsonstore.NewReadWriteSeekerStore(&memSeeker{}, flag); err == nil {
+				t.Errorf("expected an error with flag %d", flag)
+			}
+		}
+	})
+}
+
+// memSeeker is an in memory io.ReadWriteSeeker without Truncate
+type memSeeker struct {
+	data []byte
+	pos  int
+}
+
+func (m *memSeeker) Read(p []byte) (int, error) {
+	if m.pos >= len(m.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[m.pos:])
+	m.pos += n
+	return n, nil
+}
+
+func (m *memSeeker) Write(p []byte) (int, error) {
+	if end := m.pos + len(p); end > len(m.data) {
+		m.data = append(m.data, make([]byte, end-len(m.data))...)
+	}
+	n := copy(m.data[m.pos:], p)
+	m.pos += n
+	return n, nil
+}
+
+func (m *memSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		m.pos = int(offset)
+	case io.SeekCurrent:
+		m.pos += int(offset)
+	case io.SeekEnd:
+		m.pos = len(m.data) + int(offset)
+	}
+	return int64(m.pos), nil
+}
+
+func TestFSStore(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"data/countries.json": {Data: []byte(`{"countries":{"ch":{"name":"Switzerland"}}}`)},
+		"data/countries.yaml": {Data: []byte("countries:\n  ch:\n    name: Switzerland\n")},
+	}
+
+	tcs := []struct {
+		name  string
+		path  string
+		flags []jsonstore.FileStoreFlag
+	}{
+		{name: "json", path: "data/countries.json"},
+		{name: "yaml", path: "data/countries.yaml", flags: []jsonstore.FileStoreFlag{jsonstore.YamlFormat}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := jsonstore.NewFSStore(fsys, tc.path, tc.flags...)
+			if err != nil {
+				t.Fatalf("NewFSStore returned an error: %v", err)
+			}
+			var got json.RawMessage
+			if err = store.Get(ctx, "countries", "ch", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), `{"name":"Switzerland"}`); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+			if err = store.Set(ctx, "countries", "it", json.RawMessage(`{}`)); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr from Set, got: %v", err)
+			}
+		})
+	}
+
+	if _, err := jsonstore.NewFSStore(fsys, "data/missing.json"); err == nil {
+		t.Errorf("expected an error opening a missing file")
+	}
+}
@@ -0,0 +1,123 @@
+package jsonstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Recovery model of the Recoverable flag:
+// the json content is preceded by a header line holding the format version and the sha256 checksum of the
+// content, and every flush moves the replaced file to <file>.bak. If on open the file fails to parse or to match
+// its checksum, or is empty because of a crash between the two renames of a flush, the content is recovered from
+// the backup: the corrupted file is kept as <file>.corrupt for inspection and the recovery is reported
+// by Recovered.
+
+const (
+	backupSuffix  = ".bak"
+	corruptSuffix = ".corrupt"
+	fileVersion   = 1
+)
+
+// checksumMagic starts the header line of the files written with the Recoverable flag
+var checksumMagic = []byte("jsonstore/")
+
+// checksumHeader matches a whole header line, the magic alone can also start the content, e.g. a yaml file whose
+// first collection is jsonstore/users
+var checksumHeader = regexp.MustCompile(`^jsonstore/[0-9]+ sha256:[0-9a-f]{64}\n`)
+
+// RecoveryReport describes the recovery of a FileStore from its backup
+type RecoveryReport struct {
+	// File is the file that failed to load because of Cause, it was moved to CorruptFile
+	// (left in place, with an empty CorruptFile, by a read only store)
+	File        string
+	Cause       error
+	CorruptFile string
+	// Backup is the file the content was recovered from, the writes done after BackupTime are lost
+	Backup     string
+	BackupTime time.Time
+}
+
+func (r RecoveryReport) String() string {
+	return fmt.Sprintf("file %s could not be loaded: %v; recovered the content from %s written at %s",
+		r.File, r.Cause, r.Backup, r.BackupTime.Format(time.RFC3339))
+}
+
+// Recovered returns the report of the recovery from the backup done when opening the store, nil if the file
+// was loaded without errors
+func (f *FileStore) Recovered() *RecoveryReport {
+	return f.recovery
+}
+
+// addChecksum prepends the header line with the format version and checksum to the content
+func addChecksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	header := fmt.Sprintf("%s%d sha256:%s\n", checksumMagic, fileVersion, hex.EncodeToString(sum[:]))
+	return append([]byte(header), data...)
+}
+
+// hasChecksumHeader returns true if the content starts with the header line written by addChecksum
+func hasChecksumHeader(data []byte) bool {
+	return checksumHeader.Match(data)
+}
+
+// verifyChecksum checks the header line of the content and returns the content without it
+func verifyChecksum(data []byte) ([]byte, error) {
+	header, content, found := bytes.Cut(data, []byte("\n"))
+	if !found {
+		return nil, fmt.Errorf("file header is truncated")
+	}
+	var version int
+	var sum string
+	_, err := fmt.Sscanf(string(header[len(checksumMagic):]), "%d sha256:%s", &version, &sum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file header: %v", err)
+	}
+	if version != fileVersion {
+		return nil, fmt.Errorf("unsupported file format version %d", version)
+	}
+	got := sha256.Sum256(content)
+	if hex.EncodeToString(got[:]) != sum {
+		return nil, fmt.Errorf("file checksum mismatch")
+	}
+	return content, nil
+}
+
+// recoverFromBackup loads the content of the backup after the file failed to load because of cause,
+// and writes it back to the file
+func (f *FileStore) recoverFromBackup(cause error) error {
+	backup := f.file + backupSuffix
+	stat, err := os.Stat(backup)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%v, no backup to recover from", cause)
+		}
+		return fmt.Errorf("%v, unable to access backup: %v", cause, err)
+	}
+	content, err := f.readContentFrom(backup)
+	if err != nil {
+		return fmt.Errorf("%v, unable to recover from backup: %v", cause, err)
+	}
+
+	report := RecoveryReport{File: f.file, Cause: cause, Backup: backup, BackupTime: stat.ModTime()}
+	if f.readOnly {
+		f.content = content
+		f.recovery = &report
+		return nil
+	}
+	// keep the corrupted file out of the way, the next flush would otherwise move it over the backup
+	if fileStat, err := os.Stat(f.file); err == nil && fileStat.Size() > 0 {
+		report.CorruptFile = f.file + corruptSuffix
+		if err = f.fs.Rename(f.file, report.CorruptFile); err != nil {
+			return fmt.Errorf("%v, unable to move corrupted file: %v", cause, err)
+		}
+	}
+	f.content = content
+	f.recovery = &report
+	return f.flushToFile()
+}
@@ -0,0 +1,173 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileStoreRecovery(t *testing.T) {
+	ctx := context.Background()
+	first := json.RawMessage(`{"item":"first value"}`)
+	second := json.RawMessage(`{"item":"second value"}`)
+
+	// newStore writes two flushes, leaving the first one in the backup
+	newStore := func(t *testing.T) string {
+		t.Helper()
+		file := filepath.Join(t.TempDir(), "test.json")
+		store, err := jsonstore.NewFileStore(file, jsonstore.Recoverable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range []json.RawMessage{first, second} {
+			if err = store.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		return file
+	}
+	assertValue := func(t *testing.T, store *jsonstore.FileStore, want json.RawMessage) {
+		t.Helper()
+		var got json.RawMessage
+		if err := store.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	}
+
+	t.Run("checksum header", func(t *testing.T) {
+		file := newStore(t)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("jsonstore/1 sha256:")) {
+			t.Errorf("expected a checksum header, got %q", data[:min(len(data), 30)])
+		}
+		store, err := jsonstore.NewFileStore(file, jsonstore.Recoverable)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		if store.Recovered() != nil {
+			t.Errorf("expected no recovery, got %v", store.Recovered())
+		}
+		assertValue(t, store, second)
+	})
+
+	t.Run("corrupted file", func(t *testing.T) {
+		file := newStore(t)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrupted := bytes.Replace(data, []byte("second"), []byte("sec0nd"), 1)
+		if err = os.WriteFile(file, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		store, err := jsonstore.NewFileStore(file, jsonstore.Recoverable)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		report := store.Recovered()
+		if report == nil {
+			t.Fatal("expected a recovery report")
+		}
+		if report.CorruptFile != file+".corrupt" || report.Backup != file+".bak" {
+			t.Errorf("unexpected recovery report: %v", report)
+		}
+		assertValue(t, store, first)
+		got, err := os.ReadFile(file + ".corrupt")
+		if err != nil {
+			t.Fatalf("expected the corrupted file to be kept: %v", err)
+		}
+		if diff := cmp.Diff(got, corrupted); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		// the recovered content was written back
+		reopened, err := jsonstore.NewFileStore(file, jsonstore.Recoverable)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		if reopened.Recovered() != nil {
+			t.Errorf("expected no recovery, got %v", reopened.Recovered())
+		}
+		assertValue(t, reopened, first)
+	})
+
+	t.Run("crash between backup and rename", func(t *testing.T) {
+		file := newStore(t)
+		if err := os.Rename(file, file+".bak"); err != nil {
+			t.Fatal(err)
+		}
+		store, err := jsonstore.NewFileStore(file, jsonstore.Recoverable)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		if store.Recovered() == nil {
+			t.Fatal("expected a recovery report")
+		}
+		assertValue(t, store, second)
+	})
+
+	t.Run("corrupted file without backup", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		if err := os.WriteFile(file, []byte(`{"col1":`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jsonstore.NewFileStore(file, jsonstore.Recoverable); err == nil {
+			t.Errorf("expected an error opening a corrupted file without backup")
+		}
+	})
+}
+
+func TestFileStoreChecksumHeaderDetection(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"name":"alice"}`)
+	tcs := []struct {
+		name string
+		opts jsonstore.FileStoreOptions
+	}{
+		{name: "yaml", opts: jsonstore.FileStoreOptions{Format: jsonstore.FormatYaml}},
+		{name: "recoverable yaml", opts: jsonstore.FileStoreOptions{Format: jsonstore.FormatYaml, Recoverable: true}},
+		{name: "json", opts: jsonstore.FileStoreOptions{}},
+		{name: "recoverable json", opts: jsonstore.FileStoreOptions{Recoverable: true}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			// the first collection of the file starts like the checksum header
+			file := filepath.Join(t.TempDir(), "store")
+			store, err := jsonstore.NewFileStoreWithOptions(file, tc.opts)
+			if err != nil {
+				t.Fatalf("NewFileStoreWithOptions returned an error: %v", err)
+			}
+			if err = store.Set(ctx, "jsonstore/users", "u1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+
+			reopened, err := jsonstore.NewFileStoreWithOptions(file, tc.opts)
+			if err != nil {
+				t.Fatalf("unable to reopen the store: %v", err)
+			}
+			if reopened.Recovered() != nil {
+				t.Errorf("expected no recovery, got %v", reopened.Recovered())
+			}
+			var got json.RawMessage
+			if err = reopened.Get(ctx, "jsonstore/users", "u1", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, value); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
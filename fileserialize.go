@@ -0,0 +1,168 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// The FileStore keeps its content as json, with the YamlFormat and TomlFormat flags the file is converted from and
+// to the selected format on read and write, before the steps described in fileformat.go. Files containing json are
+// read regardless of the format, so that an existing store (or a snapshot restored from remote) is converted on the
+// next flush.
+
+// FileFormat is the format of the documents in the files of the FileStore
+type FileFormat int
+
+const (
+	FormatJson FileFormat = iota
+	FormatYaml
+	FormatToml
+)
+
+// extension is the extension of the collection files with FilePerCollection
+func (s FileFormat) extension() string {
+	switch s {
+	case FormatYaml:
+		return ".yaml"
+	case FormatToml:
+		return ".toml"
+	default:
+		return docExtension
+	}
+}
+
+// fromJson converts json data into the format of the file
+func (s FileFormat) fromJson(data []byte) ([]byte, error) {
+	switch s {
+	case FormatYaml:
+		return jsonToYaml(data)
+	case FormatToml:
+		return jsonToToml(data)
+	default:
+		return data, nil
+	}
+}
+
+// toJson converts the data of the file into json
+func (s FileFormat) toJson(data []byte) ([]byte, error) {
+	if s == FormatJson || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return data, nil
+	}
+	var v any
+	var err error
+	if s == FormatYaml {
+		err = yaml.Unmarshal(data, &v)
+	} else {
+		m := map[string]any{}
+		err = toml.Unmarshal(data, &m)
+		v = m
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse file: %v", err)
+	}
+	if v == nil {
+		v = map[string]any{}
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert file to json: %v", err)
+	}
+	return out, nil
+}
+
+// jsonToYaml converts json into block style yaml, json being valid yaml the node tree keeps the order of the keys
+// and the literal representation of the numbers
+func jsonToYaml(data []byte) ([]byte, error) {
+	v, err := decodeJsonNumbers(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert json to yaml: %v", err)
+	}
+	// re-encoding drops the escapes that json allows but yaml does not, e.g. \/
+	data, err = json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert json to yaml: %v", err)
+	}
+	node := yaml.Node{}
+	if err = yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("unable to convert json to yaml: %v", err)
+	}
+	setBlockStyle(&node)
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, fmt.Errorf("unable to encode yaml: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("unable to encode yaml: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func setBlockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		setBlockStyle(child)
+	}
+}
+
+// jsonToToml converts json into toml, toml has no null value: documents holding null cannot be written
+func jsonToToml(data []byte) ([]byte, error) {
+	v, err := decodeJsonNumbers(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert json to toml: %v", err)
+	}
+	v, err = tomlValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert json to toml: %v", err)
+	}
+	buf := bytes.Buffer{}
+	if err = toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, fmt.Errorf("unable to encode toml: %v", err)
+	}
+	if buf.Len() == 0 {
+		// an empty collection, the file of the store is never empty
+		buf.WriteString("# empty\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeJsonNumbers parses json keeping the numbers as json.Number
+func decodeJsonNumbers(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// tomlValue converts the json numbers into integers or floats, the encoder would silently drop null values
+func tomlValue(v any) (any, error) {
+	var err error
+	switch val := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("null values are not supported by toml")
+	case map[string]any:
+		for k, item := range val {
+			if val[k], err = tomlValue(item); err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+		}
+	case []any:
+		for i, item := range val {
+			if val[i], err = tomlValue(item); err != nil {
+				return nil, err
+			}
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	}
+	return v, nil
+}
@@ -0,0 +1,208 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay coalesces the bursts of file system events produced by a single external write
+const reloadDelay = 50 * time.Millisecond
+
+// Watch returns a channel receiving the changes to the documents of the collection, both the ones done through
+// the store and the ones loaded by AutoReload, until ctx is canceled.
+func (f *FileStore) Watch(ctx context.Context, collection string) (<-chan Event, error) {
+	return f.watchers.watch(ctx, collection), nil
+}
+
+// AutoReload watches the file for external changes, e.g. edited by an operator or synced by another process,
+// and reloads the content of the store when it changes, notifying the changed documents to the watchers.
+// Changes not yet flushed by the store take precedence: the file is not reloaded while they are pending.
+// Errors while reloading are passed to onErr (if not nil) and don't stop the watch.
+// It blocks until the context is canceled and is intended to be run as a goroutine:
+//
+//	go store.AutoReload(ctx, func(err error) { log.Print(err) })
+func (f *FileStore) AutoReload(ctx context.Context, onErr func(error)) error {
+	if f.inMemory || f.persister != nil {
+		return fmt.Errorf("auto reload requires a file")
+	}
+	if f.journal != nil {
+		return fmt.Errorf("auto reload is not supported with the append journal")
+	}
+	if f.lazy {
+		return fmt.Errorf("auto reload is not supported with lazy loading")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	// watch the directory: atomic writes replace the file, removing it from a watch on the file itself
+	file := filepath.Clean(f.file)
+	dir := filepath.Dir(file)
+	changed := func(name string) bool { return filepath.Clean(name) == file }
+	if f.perCollection {
+		dir = file
+		changed = func(name string) bool { return filepath.Ext(name) == f.format.extension() }
+	}
+	if err = watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch file: %v", err)
+	}
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if changed(event.Name) && event.Has(fsnotify.Write|fsnotify.Create) {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if onErr != nil {
+				onErr(fmt.Errorf("file watcher error: %v", err))
+			}
+		case <-timer.C:
+			if err = f.reload(); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+// reload replaces the content of the store with the content of the file and notifies the changes
+func (f *FileStore) reload() error {
+	content, err := f.readContent()
+	if err != nil {
+		return fmt.Errorf("failed to reload file: %v", err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.pending > 0 {
+		return nil
+	}
+	f.replaceContent(content)
+	return nil
+}
+
+// replaceContent replaces the content of the store notifying the changed documents to the watchers,
+// it needs to be called holding the write lock
+func (f *FileStore) replaceContent(content map[string]map[string]json.RawMessage) {
+	for collection, items := range f.content {
+		for key, value := range items {
+			newValue, ok := content[collection][key]
+			if !ok {
+				f.watchers.publish(Event{Type: EventDelete, Collection: collection, Key: key})
+				continue
+			}
+			// keep the stored representation of the unchanged documents, e.g. the own writes of the store
+			if jsonEqual(value, newValue) {
+				content[collection][key] = value
+			}
+		}
+	}
+	for collection, items := range content {
+		for key, value := range items {
+			oldValue, ok := f.content[collection][key]
+			if !ok || !jsonEqual(oldValue, value) {
+				f.watchers.publish(Event{Type: EventSet, Collection: collection, Key: key, Value: value})
+			}
+		}
+	}
+	f.content = content
+}
+
+// jsonEqual compares two json values regardless of their formatting
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// watchHub distributes the change events to the watchers of the collections, the zero value is ready to use
+type watchHub struct {
+	mutex    sync.Mutex
+	watchers map[*collectionWatcher]struct{}
+}
+
+// collectionWatcher queues the events of a collection, so that publishing never blocks on a slow consumer
+type collectionWatcher struct {
+	collection string
+	mutex      sync.Mutex
+	queue      []Event
+	notify     chan struct{}
+}
+
+func (h *watchHub) watch(ctx context.Context, collection string) <-chan Event {
+	w := &collectionWatcher{collection: collection, notify: make(chan struct{}, 1)}
+	h.mutex.Lock()
+	if h.watchers == nil {
+		h.watchers = map[*collectionWatcher]struct{}{}
+	}
+	h.watchers[w] = struct{}{}
+	h.mutex.Unlock()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			h.mutex.Lock()
+			delete(h.watchers, w)
+			h.mutex.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.notify:
+			}
+			w.mutex.Lock()
+			queue := w.queue
+			w.queue = nil
+			w.mutex.Unlock()
+			for _, event := range queue {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func (h *watchHub) publish(event Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for w := range h.watchers {
+		if w.collection != event.Collection {
+			continue
+		}
+		w.mutex.Lock()
+		w.queue = append(w.queue, event)
+		w.mutex.Unlock()
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileStoreWatch(t *testing.T) {
+	store, _ := getjsonFileStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "col1")
+	if err != nil {
+		t.Fatalf("action: Watch,  returned an error: %v", err)
+	}
+	value := json.RawMessage(`{"item":"my value"}`)
+	if err = store.Set(ctx, "col1", "item1", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if err = store.Set(ctx, "col2", "item1", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if _, err = store.Delete(ctx, "col1", "item1"); err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+
+	want := []jsonstore.Event{
+		{Type: jsonstore.EventSet, Collection: "col1", Key: "item1", Value: value},
+		{Type: jsonstore.EventDelete, Collection: "col1", Key: "item1"},
+	}
+	got := receiveEvents(t, events, len(want))
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestFileStoreAutoReload(t *testing.T) {
+	store, file := getjsonFileStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"item": "my value"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if err := store.Set(ctx, "col1", "item2", json.RawMessage(`{"item":"deleted"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	events, err := store.Watch(ctx, "col1")
+	if err != nil {
+		t.Fatalf("action: Watch,  returned an error: %v", err)
+	}
+
+	reloadErrs := make(chan error, 10)
+	go func() {
+		_ = store.AutoReload(ctx, func(err error) { reloadErrs <- err })
+	}()
+
+	// edit the file externally until the watch is set up and the change is picked up,
+	// item1 is unchanged besides formatting and must not produce an event
+	var got []jsonstore.Event
+	for i := 0; len(got) == 0; i++ {
+		if i == 100 {
+			t.Fatal("the external change was not reloaded")
+		}
+		content := fmt.Sprintf(`{"col1":{"item1":{"item":"my value"},"item3":{"n":%d}}}`, i)
+		if err = os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case err = <-reloadErrs:
+			t.Fatalf("AutoReload returned an error: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	got = append(got, receiveEvents(t, events, 1)...)
+
+	want := []jsonstore.Event{
+		{Type: jsonstore.EventDelete, Collection: "col1", Key: "item2"},
+		{Type: jsonstore.EventSet, Collection: "col1", Key: "item3", Value: got[1].Value},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	var value json.RawMessage
+	if err = store.Get(ctx, "col1", "item3", &value); err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(value, got[1].Value); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
+
+func receiveEvents(t *testing.T, events <-chan jsonstore.Event, n int) []jsonstore.Event {
+	t.Helper()
+	got := make([]jsonstore.Event, 0, n)
+	for len(got) < n {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for events, received %d of %d", len(got), n)
+		}
+	}
+	return got
+}
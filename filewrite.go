@@ -0,0 +1,90 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Durability model of the FileStore:
+// every flush writes the whole content to a temporary file next to the target, syncs it to disk
+// (unless the store uses DurabilityNoSync) and atomically renames it over the target. A crash at any point of a flush leaves either the previous
+// or the new content in place, never a partially written file. A leftover temporary file is the
+// trace of an interrupted flush and is discarded when the store is opened again.
+
+// tmpSuffix is appended to the store file name to create the temporary file used during a flush
+const tmpSuffix = ".tmp"
+
+// writableFile is the subset of *os.File needed to persist data
+type writableFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
+
+// fileSystem abstracts the file operations used to persist the store, it allows to inject failures in tests
+type fileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (writableFile, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// osFs is the fileSystem backed by the os package
+type osFs struct{}
+
+func (osFs) OpenFile(name string, flag int, perm os.FileMode) (writableFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (osFs) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+func (osFs) Remove(name string) error             { return os.Remove(name) }
+
+// writeFileAtomic replaces the content of file with data, see the durability model above.
+// If sync is false the data is not synced to disk before the rename.
+func writeFileAtomic(fsys fileSystem, file string, data []byte, perm os.FileMode, sync bool) error {
+	return writeFileBackup(fsys, file, "", data, perm, sync)
+}
+
+// writeFileBackup works like writeFileAtomic, if backup is not empty the replaced file is moved to backup
+// right before renaming the temporary file over it
+func writeFileBackup(fsys fileSystem, file, backup string, data []byte, perm os.FileMode, sync bool) error {
+	tmp := file + tmpSuffix
+	f, err := fsys.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	_, err = f.Write(data)
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = fsys.Remove(tmp)
+		return fmt.Errorf("unable to write temporary file: %v", err)
+	}
+
+	if backup != "" {
+		err = fsys.Rename(file, backup)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = fsys.Remove(tmp)
+			return fmt.Errorf("unable to create backup file: %v", err)
+		}
+	}
+	err = fsys.Rename(tmp, file)
+	if err != nil {
+		_ = fsys.Remove(tmp)
+		return fmt.Errorf("unable to replace file: %v", err)
+	}
+	return nil
+}
+
+// discardInterruptedWrite removes the temporary file left behind by a flush that did not complete
+func discardInterruptedWrite(fsys fileSystem, file string) error {
+	err := fsys.Remove(file + tmpSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove temporary file: %v", err)
+	}
+	return nil
+}
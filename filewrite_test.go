@@ -0,0 +1,148 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errCrash = errors.New("simulated crash")
+
+// crashingFs simulates a process crash during a flush: after writing crashAfter bytes
+// (or when renaming if crashOnRename is set) every further file operation fails,
+// the same way a dead process would not perform any cleanup.
+type crashingFs struct {
+	crashAfter    int
+	crashOnRename bool
+	written       int
+	crashed       bool
+}
+
+type crashingFile struct {
+	*os.File
+	fs *crashingFs
+}
+
+func (c *crashingFile) Write(p []byte) (int, error) {
+	remaining := c.fs.crashAfter - c.fs.written
+	if remaining < len(p) {
+		n, _ := c.File.Write(p[:max(remaining, 0)])
+		c.fs.written += n
+		c.fs.crashed = true
+		return n, errCrash
+	}
+	n, err := c.File.Write(p)
+	c.fs.written += n
+	return n, err
+}
+
+func (c *crashingFile) Sync() error {
+	if c.fs.crashed {
+		return errCrash
+	}
+	return c.File.Sync()
+}
+
+func (c *crashingFile) Close() error {
+	// the os would close the handle of a dead process anyway
+	return c.File.Close()
+}
+
+func (c *crashingFs) OpenFile(name string, flag int, perm os.FileMode) (jsonstore.WritableFile, error) {
+	if c.crashed {
+		return nil, errCrash
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &crashingFile{File: f, fs: c}, nil
+}
+
+func (c *crashingFs) Rename(oldpath, newpath string) error {
+	if c.crashed || c.crashOnRename {
+		c.crashed = true
+		return errCrash
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (c *crashingFs) Remove(name string) error {
+	if c.crashed {
+		return errCrash
+	}
+	return os.Remove(name)
+}
+
+func TestCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	committed := json.RawMessage(`{"item":"committed value"}`)
+	lost := json.RawMessage(`{"item":"value written during a crash"}`)
+
+	assertRecovered := func(t *testing.T, file string) {
+		t.Helper()
+		store, err := jsonstore.NewFileStore(file)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, committed); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		if _, err := os.Stat(file + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected the temporary file to be removed on open")
+		}
+	}
+
+	newCommittedStore := func(t *testing.T) (*jsonstore.FileStore, string) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		store, err := jsonstore.NewFileStore(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Set(ctx, "col1", "item1", committed); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		return store, file
+	}
+
+	t.Run("crash at every byte of a flush", func(t *testing.T) {
+		// increase the crash point until the flush is able to complete
+		for i := 0; ; i++ {
+			store, file := newCommittedStore(t)
+			jsonstore.SetFileSystem(store, &crashingFs{crashAfter: i})
+
+			err := store.Set(ctx, "col1", "item1", lost)
+			if err == nil {
+				if i == 0 {
+					t.Fatal("expected the flush to fail when crashing before writing any byte")
+				}
+				break
+			}
+			assertRecovered(t, file)
+		}
+	})
+
+	t.Run("crash before rename", func(t *testing.T) {
+		store, file := newCommittedStore(t)
+		jsonstore.SetFileSystem(store, &crashingFs{crashAfter: 1 << 20, crashOnRename: true})
+
+		err := store.Set(ctx, "col1", "item1", lost)
+		if err == nil {
+			t.Fatal("expected the flush to fail")
+		}
+		if _, err := os.Stat(file + ".tmp"); err != nil {
+			t.Fatalf("expected a complete temporary file to be left behind: %v", err)
+		}
+		assertRecovered(t, file)
+	})
+}
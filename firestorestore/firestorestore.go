@@ -0,0 +1,274 @@
+package firestorestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// DefaultEndpoint is the production Firestore REST endpoint
+const DefaultEndpoint = "https://firestore.googleapis.com"
+
+// DefaultDatabase is the name of the default Firestore database of a project
+const DefaultDatabase = "(default)"
+
+// valueField is the document field holding the json value
+const valueField = "value"
+
+// Config identifies the Firestore database to use
+type Config struct {
+	// Endpoint of the REST api, DefaultEndpoint if empty, set it to the address of the emulator for local development
+	Endpoint string
+	Project  string
+	// Database name, DefaultDatabase if empty
+	Database string
+}
+
+// FirestoreStore stores json documents in Google Cloud Firestore using its REST api,
+// every jsonstore collection maps to a Firestore collection and the key is used as document id.
+// The value is stored as native Firestore value in the "value" field, note that the json returned
+// by Get/List is re-encoded (e.g. whitespace and key order are not preserved).
+type FirestoreStore struct {
+	client   *http.Client
+	endpoint string
+	parent   string // resource name of the documents root, projects/<p>/databases/<d>/documents
+}
+
+// make sure the firestore store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &FirestoreStore{}
+
+// NewFirestoreStore returns a store using the provided http client, the client is responsible to authenticate
+// the requests, e.g. one created with golang.org/x/oauth2/google.DefaultClient.
+func NewFirestoreStore(client *http.Client, cfg Config) (*FirestoreStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("http client cannot be nil")
+	}
+	if cfg.Project == "" {
+		return nil, fmt.Errorf("project cannot be empty")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.Database == "" {
+		cfg.Database = DefaultDatabase
+	}
+	store := FirestoreStore{
+		client:   client,
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		parent:   fmt.Sprintf("projects/%s/databases/%s/documents", cfg.Project, cfg.Database),
+	}
+	return &store, nil
+}
+
+type document struct {
+	Name   string                `json:"name,omitempty"`
+	Fields map[string]fieldValue `json:"fields"`
+}
+
+func validName(name string) error {
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("name cannot contain \"/\"")
+	}
+	if strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") {
+		return fmt.Errorf("names matching __.*__ are reserved")
+	}
+	return nil
+}
+
+func (store *FirestoreStore) collectionPath(collection string) (string, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if err := validName(collection); err != nil {
+		return "", fmt.Errorf("invalid collection: %v", err)
+	}
+	return collection, nil
+}
+
+func (store *FirestoreStore) docUrl(collection, key string) (string, error) {
+	collection, err := store.collectionPath(collection)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("id cannot be empty")
+	}
+	if err = validName(key); err != nil {
+		return "", fmt.Errorf("invalid key: %v", err)
+	}
+	return fmt.Sprintf("%s/v1/%s/%s/%s", store.endpoint, store.parent, url.PathEscape(collection), url.PathEscape(key)), nil
+}
+
+func (store *FirestoreStore) do(ctx context.Context, method, u string, body any, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := store.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		payload := struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}{}
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		if payload.Error.Status == "" {
+			return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return resp.StatusCode, fmt.Errorf("%s: %s", payload.Error.Status, payload.Error.Message)
+	}
+	if out != nil {
+		if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (store *FirestoreStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	u, err := store.docUrl(collection, key)
+	if err != nil {
+		return err
+	}
+	v, err := toValue(value)
+	if err != nil {
+		return fmt.Errorf("failed to convert document: %v", err)
+	}
+	// a patch without update mask replaces the whole document, creating it if needed
+	_, err = store.do(ctx, http.MethodPatch, u, document{Fields: map[string]fieldValue{valueField: v}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *FirestoreStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	u, err := store.docUrl(collection, key)
+	if err != nil {
+		return err
+	}
+	doc := document{}
+	status, err := store.do(ctx, http.MethodGet, u, nil, &doc)
+	if status == http.StatusNotFound {
+		return jsonstore.ItemNotFoundErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	raw, err := toJson(doc.Fields[valueField])
+	if err != nil {
+		return fmt.Errorf("failed to convert document: %v", err)
+	}
+	*value = raw
+	return nil
+}
+
+type runQueryResult struct {
+	Document *document `json:"document"`
+}
+
+type aggregationResult struct {
+	Result struct {
+		AggregateFields map[string]fieldValue `json:"aggregateFields"`
+	} `json:"result"`
+}
+
+// List returns the documents of a collection ordered by key, using a structured query with offset and limit
+func (store *FirestoreStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	collection, err := store.collectionPath(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	from := []map[string]any{{"collectionId": collection}}
+
+	var counts []aggregationResult
+	_, err = store.do(ctx, http.MethodPost, store.endpoint+"/v1/"+store.parent+":runAggregationQuery", map[string]any{
+		"structuredAggregationQuery": map[string]any{
+			"structuredQuery": map[string]any{"from": from},
+			"aggregations":    []map[string]any{{"alias": "count", "count": map[string]any{}}},
+		},
+	}, &counts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+	var total int64
+	for _, c := range counts {
+		if n, ok := c.Result.AggregateFields["count"].toAny().(json.Number); ok {
+			total, _ = n.Int64()
+		}
+	}
+
+	var results []runQueryResult
+	_, err = store.do(ctx, http.MethodPost, store.endpoint+"/v1/"+store.parent+":runQuery", map[string]any{
+		"structuredQuery": map[string]any{
+			"from":    from,
+			"orderBy": []map[string]any{{"field": map[string]string{"fieldPath": "__name__"}, "direction": "ASCENDING"}},
+			"offset":  offset,
+			"limit":   limit,
+		},
+	}, &results)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+
+	items := map[string]json.RawMessage{}
+	for _, r := range results {
+		if r.Document == nil {
+			continue
+		}
+		raw, err := toJson(r.Document.Fields[valueField])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to convert document: %v", err)
+		}
+		key := r.Document.Name[strings.LastIndex(r.Document.Name, "/")+1:]
+		items[key] = raw
+	}
+	return items, total, nil
+}
+
+func (store *FirestoreStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	u, err := store.docUrl(collection, key)
+	if err != nil {
+		return false, err
+	}
+	// the precondition makes the delete fail on missing documents, to report if anything was deleted
+	status, err := store.do(ctx, http.MethodDelete, u+"?currentDocument.exists=true", nil, nil)
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return true, nil
+}
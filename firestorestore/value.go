@@ -0,0 +1,114 @@
+package firestorestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// fieldValue is the json representation of a firestore Value, see
+// https://firebase.google.com/docs/firestore/reference/rest/v1/Value
+type fieldValue struct {
+	NullValue      *string     `json:"nullValue,omitempty"`
+	BooleanValue   *bool       `json:"booleanValue,omitempty"`
+	IntegerValue   *string     `json:"integerValue,omitempty"`
+	DoubleValue    *float64    `json:"doubleValue,omitempty"`
+	StringValue    *string     `json:"stringValue,omitempty"`
+	TimestampValue *string     `json:"timestampValue,omitempty"`
+	BytesValue     *string     `json:"bytesValue,omitempty"`
+	ReferenceValue *string     `json:"referenceValue,omitempty"`
+	ArrayValue     *arrayValue `json:"arrayValue,omitempty"`
+	MapValue       *mapValue   `json:"mapValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []fieldValue `json:"values,omitempty"`
+}
+
+type mapValue struct {
+	Fields map[string]fieldValue `json:"fields,omitempty"`
+}
+
+// toValue converts a json document into a native firestore value
+func toValue(in json.RawMessage) (fieldValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return fieldValue{}, fmt.Errorf("invalid json: %v", err)
+	}
+	return fromAny(data), nil
+}
+
+func fromAny(in any) fieldValue {
+	switch v := in.(type) {
+	case nil:
+		null := "NULL_VALUE"
+		return fieldValue{NullValue: &null}
+	case bool:
+		return fieldValue{BooleanValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return fieldValue{IntegerValue: &s}
+		}
+		f, _ := v.Float64()
+		return fieldValue{DoubleValue: &f}
+	case string:
+		return fieldValue{StringValue: &v}
+	case []any:
+		arr := &arrayValue{Values: make([]fieldValue, 0, len(v))}
+		for _, item := range v {
+			arr.Values = append(arr.Values, fromAny(item))
+		}
+		return fieldValue{ArrayValue: arr}
+	case map[string]any:
+		m := &mapValue{Fields: make(map[string]fieldValue, len(v))}
+		for k, item := range v {
+			m.Fields[k] = fromAny(item)
+		}
+		return fieldValue{MapValue: m}
+	}
+	return fieldValue{}
+}
+
+// toJson converts a firestore value back into json, timestamps, bytes and references are returned as strings
+func toJson(v fieldValue) (json.RawMessage, error) {
+	return json.Marshal(v.toAny())
+}
+
+func (v fieldValue) toAny() any {
+	switch {
+	case v.BooleanValue != nil:
+		return *v.BooleanValue
+	case v.IntegerValue != nil:
+		if _, err := strconv.ParseInt(*v.IntegerValue, 10, 64); err == nil {
+			return json.Number(*v.IntegerValue)
+		}
+		return *v.IntegerValue
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.TimestampValue != nil:
+		return *v.TimestampValue
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ReferenceValue != nil:
+		return *v.ReferenceValue
+	case v.ArrayValue != nil:
+		out := make([]any, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			out = append(out, item.toAny())
+		}
+		return out
+	case v.MapValue != nil:
+		out := make(map[string]any, len(v.MapValue.Fields))
+		for k, item := range v.MapValue.Fields {
+			out[k] = item.toAny()
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Package grpcstore exposes a jsonstore.JsonStorer as a gRPC service and provides a client implementing
+// jsonstore.JsonStorer on top of it, for environments where HTTP/JSON is not the transport of choice.
+// The service is defined in storepb/jsonstore.proto.
+package grpcstore
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative storepb/jsonstore.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/grpcstore/storepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details of the status errors returned by the Server
+const ErrorDomain = "jsonstore"
+
+// storeErrors are the errors of the stores sent to the clients, checked in order with errors.Is;
+// the reason identifies the error in the ErrorInfo of the status so that the Client can return it again
+var storeErrors = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{jsonstore.ItemNotFoundErr, codes.NotFound, "ITEM_NOT_FOUND"},
+	{jsonstore.CollectionNotFoundErr, codes.NotFound, "COLLECTION_NOT_FOUND"},
+	{jsonstore.InvalidJsonErr, codes.InvalidArgument, "INVALID_JSON"},
+	{jsonstore.InvalidCursorErr, codes.InvalidArgument, "INVALID_CURSOR"},
+	{jsonstore.VersionConflictErr, codes.Aborted, "VERSION_CONFLICT"},
+	{jsonstore.ReadOnlyErr, codes.FailedPrecondition, "READ_ONLY"},
+}
+
+// toStatus converts the error of a store into a status error
+func toStatus(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	for _, e := range storeErrors {
+		if errors.Is(err, e.err) {
+			st, detailErr := status.New(e.code, err.Error()).WithDetails(&errdetails.ErrorInfo{Reason: e.reason, Domain: ErrorDomain})
+			if detailErr != nil {
+				return status.Error(e.code, err.Error())
+			}
+			return st.Err()
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// fromStatus converts a status error into the error of the store returned by the server, wrapping the status error
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != ErrorDomain {
+			continue
+		}
+		for _, e := range storeErrors {
+			if e.reason == info.Reason {
+				return fmt.Errorf("%w: %w", e.err, err)
+			}
+		}
+	}
+	return err
+}
+
+// Server implements the JsonStore service on top of a store, register it with storepb.RegisterJsonStoreServer
+type Server struct {
+	storepb.UnimplementedJsonStoreServer
+	store jsonstore.JsonStorer
+}
+
+// NewServer returns the JsonStore service of the store, Watch is only available if the store is a jsonstore.Watcher
+func NewServer(store jsonstore.JsonStorer) *Server {
+	return &Server{store: store}
+}
+
+func (s *Server) Set(ctx context.Context, req *storepb.SetRequest) (*storepb.SetResponse, error) {
+	if !json.Valid(req.Value) {
+		return nil, toStatus(jsonstore.InvalidJsonErr)
+	}
+	if err := s.store.Set(ctx, req.Collection, req.Key, req.Value); err != nil {
+		return nil, toStatus(err)
+	}
+	return &storepb.SetResponse{}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *storepb.GetRequest) (*storepb.GetResponse, error) {
+	var value json.RawMessage
+	if err := s.store.Get(ctx, req.Collection, req.Key, &value); err != nil {
+		return nil, toStatus(err)
+	}
+	return &storepb.GetResponse{Value: value}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *storepb.DeleteRequest) (*storepb.DeleteResponse, error) {
+	deleted, err := s.store.Delete(ctx, req.Collection, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &storepb.DeleteResponse{Deleted: deleted}, nil
+}
+
+func (s *Server) List(ctx context.Context, req *storepb.ListRequest) (*storepb.ListResponse, error) {
+	page, err := jsonstore.ListPage(ctx, s.store, req.Collection, jsonstore.ListOptions{
+		Limit:     int(req.Limit),
+		Page:      int(req.Page),
+		Cursor:    req.Cursor,
+		Prefix:    req.Prefix,
+		SkipTotal: req.SkipTotal,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	items := make(map[string][]byte, len(page.Items))
+	for key, value := range page.Items {
+		items[key] = value
+	}
+	return &storepb.ListResponse{
+		Items:      items,
+		Total:      page.Total,
+		Page:       int32(page.Page),
+		Limit:      int32(page.Limit),
+		HasNext:    page.HasNext,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Keys:       page.Keys,
+	}, nil
+}
+
+func (s *Server) Watch(req *storepb.WatchRequest, stream storepb.JsonStore_WatchServer) error {
+	watcher, ok := s.store.(jsonstore.Watcher)
+	if !ok {
+		return status.Error(codes.Unimplemented, "the store does not support watching changes")
+	}
+	events, err := watcher.Watch(stream.Context(), req.Collection)
+	if err != nil {
+		return toStatus(err)
+	}
+	for event := range events {
+		msg := &storepb.Event{Collection: event.Collection, Key: event.Key, Value: event.Value}
+		switch event.Type {
+		case jsonstore.EventSet:
+			msg.Type = storepb.Event_TYPE_SET
+		case jsonstore.EventDelete:
+			msg.Type = storepb.Event_TYPE_DELETE
+		}
+		if err = stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Client is a jsonstore.JsonStorer calling a remote JsonStore service, the errors of the remote store are returned
+// as the errors of the jsonstore package, e.g. Get returns jsonstore.ItemNotFoundErr for a missing document.
+type Client struct {
+	client storepb.JsonStoreClient
+}
+
+// make sure the client fulfills the JsonStorer, PageLister and Watcher interfaces
+var _ jsonstore.JsonStorer = &Client{}
+var _ jsonstore.PageLister = &Client{}
+var _ jsonstore.Watcher = &Client{}
+
+// NewClient returns a store using the provided connection, the caller is responsible to close the connection.
+func NewClient(conn grpc.ClientConnInterface) (*Client, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("connection cannot be nil")
+	}
+	return &Client{client: storepb.NewJsonStoreClient(conn)}, nil
+}
+
+func (c *Client) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	_, err := c.client.Set(ctx, &storepb.SetRequest{Collection: collection, Key: key, Value: value})
+	if err != nil {
+		return fromStatus(err)
+	}
+	return nil
+}
+
+func (c *Client) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	resp, err := c.client.Get(ctx, &storepb.GetRequest{Collection: collection, Key: key})
+	if err != nil {
+		return fromStatus(err)
+	}
+	*value = resp.Value
+	return nil
+}
+
+func (c *Client) Delete(ctx context.Context, collection, key string) (bool, error) {
+	resp, err := c.client.Delete(ctx, &storepb.DeleteRequest{Collection: collection, Key: key})
+	if err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Deleted, nil
+}
+
+func (c *Client) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := c.ListPage(ctx, collection, jsonstore.ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage requests a page of the collection, the page size is capped by the remote store instead of opts.MaxLimit
+func (c *Client) ListPage(ctx context.Context, collection string, opts jsonstore.ListOptions) (jsonstore.Page, error) {
+	resp, err := c.client.List(ctx, &storepb.ListRequest{
+		Collection: collection,
+		Limit:      int32(opts.Limit),
+		Page:       int32(opts.Page),
+		Cursor:     opts.Cursor,
+		Prefix:     opts.Prefix,
+		SkipTotal:  opts.SkipTotal,
+	})
+	if err != nil {
+		return jsonstore.Page{}, fromStatus(err)
+	}
+	items := make(map[string]json.RawMessage, len(resp.Items))
+	for key, value := range resp.Items {
+		items[key] = value
+	}
+	return jsonstore.Page{
+		Items:      items,
+		Total:      resp.Total,
+		Page:       int(resp.Page),
+		Limit:      int(resp.Limit),
+		HasNext:    resp.HasNext,
+		NextCursor: resp.NextCursor,
+		PrevCursor: resp.PrevCursor,
+		Keys:       resp.Keys,
+	}, nil
+}
+
+// Watch streams the changes of the collection from the remote store, the channel is closed once ctx is canceled
+// or the stream ends
+func (c *Client) Watch(ctx context.Context, collection string) (<-chan jsonstore.Event, error) {
+	stream, err := c.client.Watch(ctx, &storepb.WatchRequest{Collection: collection})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	events := make(chan jsonstore.Event)
+	go func() {
+		defer close(events)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			event := jsonstore.Event{Type: jsonstore.EventSet, Collection: msg.Collection, Key: msg.Key}
+			if msg.Type == storepb.Event_TYPE_DELETE {
+				event.Type = jsonstore.EventDelete
+			} else {
+				event.Value = msg.Value
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,182 @@
+package grpcstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/grpcstore"
+	"github.com/go-bumbu/jsonstore/grpcstore/storepb"
+	"github.com/go-bumbu/jsonstore/storetest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves the store on an in memory listener and returns a client connected to it
+func newClient(t *testing.T, store jsonstore.JsonStorer) *grpcstore.Client {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	storepb.RegisterJsonStoreServer(server, grpcstore.NewServer(store))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client, err := grpcstore.NewClient(conn)
+	if err != nil {
+		t.Fatalf("action: NewClient,  returned an error: %v", err)
+	}
+	return client
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, jsonstore.NewMemStore())
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := client.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err := client.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(string(got), `{"n":1}`); diff != "" {
+			t.Errorf("unexpected value (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var got json.RawMessage
+		err := client.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got %v", err)
+		}
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected the NotFound status to be wrapped, got %v", status.Code(err))
+		}
+		if err = client.Set(ctx, "col1", "item2", json.RawMessage(`{`)); !errors.Is(err, jsonstore.InvalidJsonErr) {
+			t.Errorf("expected InvalidJsonErr, got %v", err)
+		}
+		if _, err = client.ListPage(ctx, "col1", jsonstore.ListOptions{Cursor: "invalid"}); !errors.Is(err, jsonstore.InvalidCursorErr) {
+			t.Errorf("expected InvalidCursorErr, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for _, key := range []string{"k1", "k2", "k3"} {
+			if err := client.Set(ctx, "col2", key, json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := client.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(items, map[string]json.RawMessage{"k3": json.RawMessage(`{}`)}); diff != "" {
+			t.Errorf("unexpected items (-got +want):\n%s", diff)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		page, err := client.ListPage(ctx, "col2", jsonstore.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		if len(page.Items) != 2 || !page.HasNext || page.NextCursor == "" {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := client.Delete(ctx, "col1", "item1")
+		if err != nil || !deleted {
+			t.Errorf("expected the document to be deleted, got %v %v", deleted, err)
+		}
+		deleted, err = client.Delete(ctx, "col1", "item1")
+		if err != nil || deleted {
+			t.Errorf("expected nothing to be deleted, got %v %v", deleted, err)
+		}
+	})
+
+	t.Run("watch unsupported", func(t *testing.T) {
+		events, err := client.Watch(ctx, "col1")
+		if err != nil {
+			t.Fatalf("action: Watch,  returned an error: %v", err)
+		}
+		// the stream fails on the first receive, closing the channel
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Errorf("expected no events")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the channel was not closed")
+		}
+	})
+}
+
+func TestClientWatch(t *testing.T) {
+	store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+	}
+	client := newClient(t, store)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := client.Watch(ctx, "col1")
+	if err != nil {
+		t.Fatalf("action: Watch,  returned an error: %v", err)
+	}
+	// the server registers the watch asynchronously, write until the first event is received
+	var got []jsonstore.Event
+	for len(got) == 0 {
+		if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+	}
+	if _, err = store.Delete(ctx, "col1", "item1"); err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	for event := range events {
+		got = append(got, event)
+		if event.Type == jsonstore.EventDelete {
+			break
+		}
+	}
+	want := jsonstore.Event{Type: jsonstore.EventSet, Collection: "col1", Key: "item1", Value: json.RawMessage(`{"n":1}`)}
+	if diff := cmp.Diff(got[0], want); diff != "" {
+		t.Errorf("unexpected event (-got +want):\n%s", diff)
+	}
+	last := got[len(got)-1]
+	if last.Type != jsonstore.EventDelete || last.Key != "item1" || len(last.Value) != 0 {
+		t.Errorf("unexpected last event: %+v", last)
+	}
+}
+
+func TestClientConformance(t *testing.T) {
+	storetest.TestStorer(t, func() jsonstore.JsonStorer { return newClient(t, jsonstore.NewMemStore()) })
+}
@@ -0,0 +1,306 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: storepb/jsonstore.proto
+
+package storepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	JsonStore_Set_FullMethodName    = "/jsonstore.v1.JsonStore/Set"
+	JsonStore_Get_FullMethodName    = "/jsonstore.v1.JsonStore/Get"
+	JsonStore_Delete_FullMethodName = "/jsonstore.v1.JsonStore/Delete"
+	JsonStore_List_FullMethodName   = "/jsonstore.v1.JsonStore/List"
+	JsonStore_Watch_FullMethodName  = "/jsonstore.v1.JsonStore/Watch"
+)
+
+// JsonStoreClient is the client API for JsonStore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// JsonStore mirrors the operations of the jsonstore.JsonStorer interface, the documents are json encoded bytes.
+type JsonStoreClient interface {
+	// Set stores a document, replacing the existing one
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	// Get reads a document, it fails with NOT_FOUND if the document does not exist
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Delete deletes a document, deleted is false if the document did not exist
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// List returns a page of the documents of a collection
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Watch streams the changes of the documents of a collection until the call is canceled,
+	// it fails with UNIMPLEMENTED if the store cannot notify changes
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (JsonStore_WatchClient, error)
+}
+
+type jsonStoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJsonStoreClient(cc grpc.ClientConnInterface) JsonStoreClient {
+	return &jsonStoreClient{cc}
+}
+
+func (c *jsonStoreClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, JsonStore_Set_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jsonStoreClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, JsonStore_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jsonStoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, JsonStore_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jsonStoreClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, JsonStore_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jsonStoreClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (JsonStore_WatchClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JsonStore_ServiceDesc.Streams[0], JsonStore_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jsonStoreWatchClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JsonStore_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type jsonStoreWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *jsonStoreWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JsonStoreServer is the server API for JsonStore service.
+// All implementations must embed UnimplementedJsonStoreServer
+// for forward compatibility
+//
+// JsonStore mirrors the operations of the jsonstore.JsonStorer interface, the documents are json encoded bytes.
+type JsonStoreServer interface {
+	// Set stores a document, replacing the existing one
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	// Get reads a document, it fails with NOT_FOUND if the document does not exist
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Delete deletes a document, deleted is false if the document did not exist
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// List returns a page of the documents of a collection
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Watch streams the changes of the documents of a collection until the call is canceled,
+	// it fails with UNIMPLEMENTED if the store cannot notify changes
+	Watch(*WatchRequest, JsonStore_WatchServer) error
+	mustEmbedUnimplementedJsonStoreServer()
+}
+
+// UnimplementedJsonStoreServer must be embedded to have forward compatible implementations.
+type UnimplementedJsonStoreServer struct {
+}
+
+func (UnimplementedJsonStoreServer) Set(context.Context, *SetRequest) (*SetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedJsonStoreServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedJsonStoreServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedJsonStoreServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedJsonStoreServer) Watch(*WatchRequest, JsonStore_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedJsonStoreServer) mustEmbedUnimplementedJsonStoreServer() {}
+
+// UnsafeJsonStoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JsonStoreServer will
+// result in compilation errors.
+type UnsafeJsonStoreServer interface {
+	mustEmbedUnimplementedJsonStoreServer()
+}
+
+func RegisterJsonStoreServer(s grpc.ServiceRegistrar, srv JsonStoreServer) {
+	s.RegisterService(&JsonStore_ServiceDesc, srv)
+}
+
+func _JsonStore_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JsonStoreServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JsonStore_Set_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JsonStoreServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JsonStore_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JsonStoreServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JsonStore_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JsonStoreServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JsonStore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JsonStoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JsonStore_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JsonStoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JsonStore_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JsonStoreServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JsonStore_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JsonStoreServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JsonStore_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JsonStoreServer).Watch(m, &jsonStoreWatchServer{ServerStream: stream})
+}
+
+type JsonStore_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type jsonStoreWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *jsonStoreWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// JsonStore_ServiceDesc is the grpc.ServiceDesc for JsonStore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JsonStore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "jsonstore.v1.JsonStore",
+	HandlerType: (*JsonStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Set",
+			Handler:    _JsonStore_Set_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _JsonStore_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _JsonStore_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _JsonStore_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _JsonStore_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "storepb/jsonstore.proto",
+}
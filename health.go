@@ -0,0 +1,166 @@
+package jsonstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// HealthChecker is implemented by stores able to check that their backend is usable, e.g. that the database
+// answers or that the file can still be written
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// make sure the stores fulfill the HealthChecker interface
+var _ HealthChecker = &MemStore{}
+var _ HealthChecker = &DirStore{}
+var _ HealthChecker = &FileStore{}
+var _ HealthChecker = &DbStore{}
+
+// CheckHealth checks the backend of the store, stores not implementing HealthChecker are probed by listing
+// a single document of the DefaultCollection
+func CheckHealth(ctx context.Context, store JsonStorer) error {
+	if hc, ok := store.(HealthChecker); ok {
+		return hc.Health(ctx)
+	}
+	_, _, err := store.List(ctx, DefaultCollection, 1, 1)
+	if err != nil && !errors.Is(err, CollectionNotFoundErr) {
+		return err
+	}
+	return nil
+}
+
+// healthProbeFile is created and removed again in a directory to check that it can be written,
+// the tmpSuffix keeps it out of the collections and documents of the stores
+const healthProbeFile = ".health-probe" + tmpSuffix
+
+// checkWritableDir checks that a file can be created in dir
+func checkWritableDir(fsys fileSystem, dir string, perm os.FileMode) error {
+	probe := filepath.Join(dir, healthProbeFile)
+	f, err := fsys.OpenFile(probe, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %v", dir, err)
+	}
+	err = f.Close()
+	if rmErr := fsys.Remove(probe); err == nil {
+		err = rmErr
+	}
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %v", dir, err)
+	}
+	return nil
+}
+
+// Health of the MemStore is always ok
+func (s *MemStore) Health(ctx context.Context) error {
+	return nil
+}
+
+// Health checks that a file can be written in the root directory
+func (d *DirStore) Health(ctx context.Context) error {
+	return checkWritableDir(d.fs, d.root, 0644)
+}
+
+// Health checks that the file of the store can be written, or only read for a read only store.
+// An in memory store is always healthy, and a store using a Persister is if the Persister is a healthy HealthChecker.
+func (f *FileStore) Health(ctx context.Context) error {
+	switch {
+	case f.inMemory:
+		return nil
+	case f.persister != nil:
+		if hc, ok := f.persister.(HealthChecker); ok {
+			return hc.Health(ctx)
+		}
+		return nil
+	case f.readOnly:
+		if _, err := os.Stat(f.file); err != nil {
+			return fmt.Errorf("file is not readable: %v", err)
+		}
+		return nil
+	case f.perCollection:
+		return checkWritableDir(f.fs, f.file, f.fileMode)
+	default:
+		return checkWritableDir(f.fs, filepath.Dir(f.file), f.fileMode)
+	}
+}
+
+// Health pings the database, and the Reader database if set
+func (store *DbStore) Health(ctx context.Context) error {
+	sqlDB, err := store.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access database: %v", err)
+	}
+	if err = sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+	if store.opts.Reader == nil || store.opts.Reader == store.db {
+		return nil
+	}
+	sqlDB, err = store.opts.Reader.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access reader database: %v", err)
+	}
+	if err = sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping reader database: %v", err)
+	}
+	return nil
+}
+
+// DefaultHealthTimeout is the maximum duration of a check of the HealthHandler if none is configured
+const DefaultHealthTimeout = 5 * time.Second
+
+// HealthHandler serves the Kubernetes style probes of a store:
+//
+//	/healthz  200 "ok" if the store is healthy, see CheckHealth, 503 otherwise
+//	/readyz   the same, additionally checking Ready
+//
+// Mount it on both paths, e.g. mux.Handle("/healthz", h) and mux.Handle("/readyz", h); any other path is a 404.
+type HealthHandler struct {
+	Store JsonStorer
+	// Ready, if set, is additionally checked by /readyz, e.g. to hold back the traffic until a cache is warmed up
+	// or while the server drains
+	Ready func(ctx context.Context) error
+	// Timeout bounds the duration of a check, if zero DefaultHealthTimeout is used
+	Timeout time.Duration
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	probe := path.Base(r.URL.Path)
+	if probe != "healthz" && probe != "readyz" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	err := CheckHealth(ctx, h.Store)
+	if err == nil && probe == "readyz" && h.Ready != nil {
+		err = h.Ready(ctx)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("failing: " + err.Error() + "\n"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,167 @@
+package jsonstore_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+)
+
+func TestCheckHealth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("MemStore", func(t *testing.T) {
+		if err := jsonstore.CheckHealth(ctx, jsonstore.NewMemStore()); err != nil {
+			t.Errorf("action: CheckHealth,  returned an error: %v", err)
+		}
+	})
+
+	t.Run("DirStore", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "root")
+		store, err := jsonstore.NewDirStore(root)
+		if err != nil {
+			t.Fatalf("action: NewDirStore,  returned an error: %v", err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err != nil {
+			t.Errorf("action: CheckHealth,  returned an error: %v", err)
+		}
+		collections, err := store.Collections(ctx)
+		if err != nil {
+			t.Fatalf("action: Collections,  returned an error: %v", err)
+		}
+		if len(collections) != 0 {
+			t.Errorf("expected the probe to leave no collection, got %v", collections)
+		}
+
+		if err = os.RemoveAll(root); err != nil {
+			t.Fatal(err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err == nil {
+			t.Errorf("expected an error once the root directory is gone")
+		}
+	})
+
+	t.Run("FileStore", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "data")
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		store, err := jsonstore.NewFileStore(filepath.Join(dir, "store.json"))
+		if err != nil {
+			t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err != nil {
+			t.Errorf("action: CheckHealth,  returned an error: %v", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), "health") {
+				t.Errorf("expected the probe file to be removed, got %s", entry.Name())
+			}
+		}
+
+		if err = os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err == nil {
+			t.Errorf("expected an error once the directory is gone")
+		}
+	})
+
+	t.Run("FileStore in memory", func(t *testing.T) {
+		store, err := jsonstore.NewFileStore(jsonstore.InMemoryDb)
+		if err != nil {
+			t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err != nil {
+			t.Errorf("action: CheckHealth,  returned an error: %v", err)
+		}
+	})
+
+	t.Run("DbStore", func(t *testing.T) {
+		store := newDbStore(t)
+		if err := jsonstore.CheckHealth(ctx, store); err != nil {
+			t.Errorf("action: CheckHealth,  returned an error: %v", err)
+		}
+	})
+
+	t.Run("DbStore closed", func(t *testing.T) {
+		store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), jsonstore.DbStoreOptions{CloseDB: true})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		if err = store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		if err = jsonstore.CheckHealth(ctx, store); err == nil {
+			t.Errorf("expected an error on a closed database")
+		}
+	})
+
+	t.Run("probe by listing", func(t *testing.T) {
+		err := jsonstore.CheckHealth(ctx, &jsonstoretest.FakeStore{Err: errors.New("connection refused")})
+		if err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("expected the list error, got %v", err)
+		}
+	})
+}
+
+// readyErr is returned by the Ready check of the HealthHandler tests
+var readyErr = errors.New("warming up")
+
+func TestHealthHandler(t *testing.T) {
+	tcs := []struct {
+		name       string
+		store      jsonstore.JsonStorer
+		ready      error
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "healthy", store: jsonstore.NewMemStore(), path: "/healthz", wantStatus: http.StatusOK, wantBody: "ok\n"},
+		{name: "ready", store: jsonstore.NewMemStore(), path: "/readyz", wantStatus: http.StatusOK, wantBody: "ok\n"},
+		{name: "mounted under a prefix", store: jsonstore.NewMemStore(), path: "/api/readyz", wantStatus: http.StatusOK, wantBody: "ok\n"},
+		{name: "failing store", store: &jsonstoretest.FakeStore{Err: errors.New("connection refused")}, path: "/healthz",
+			wantStatus: http.StatusServiceUnavailable, wantBody: "failing: connection refused\n"},
+		{name: "not ready", store: jsonstore.NewMemStore(), ready: readyErr, path: "/readyz",
+			wantStatus: http.StatusServiceUnavailable, wantBody: "failing: warming up\n"},
+		{name: "alive while not ready", store: jsonstore.NewMemStore(), ready: readyErr, path: "/healthz",
+			wantStatus: http.StatusOK, wantBody: "ok\n"},
+		{name: "head", store: jsonstore.NewMemStore(), method: http.MethodHead, path: "/healthz", wantStatus: http.StatusOK},
+		{name: "post", store: jsonstore.NewMemStore(), method: http.MethodPost, path: "/healthz", wantStatus: http.StatusMethodNotAllowed},
+		{name: "unknown path", store: jsonstore.NewMemStore(), path: "/livez", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := jsonstore.HealthHandler{Store: tc.store}
+			if tc.ready != nil {
+				handler.Ready = func(ctx context.Context) error { return tc.ready }
+			}
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(method, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+				t.Errorf("expected body %q, got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,146 +1,793 @@
 package jsonstore
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 // Handler is a sample implementation of an http handler that is capable of storing json data into a jsonStorer
 // note that the handler intentionally extends the HttpStorer to allow more flexibility in the ServeHTTP method;
-// e.g. if you want to use a different mux, like gorilla you don't need to use the basic GetReqKey function
-// you might also want to implement some collection name change, e.g. to make one collection per user
-// for the same endpoint.
+// e.g. if you want to use a different mux, like gorilla you don't need to use the basic GetReqKey function,
+// set KeyFunc to read the key from the route instead.
+// to serve a different collection per request, e.g. one collection per user for the same endpoint, set
+// CollectionFunc.
 type Handler struct {
 	HttpStorer
 	Collection string
+	// CollectionFunc resolves the collection of every request, e.g. from the authenticated user, a header or a path
+	// segment, replacing Collection. Requests it fails for get a 400 Bad Request, or a 404 Not Found if the error
+	// wraps CollectionNotFoundErr; an empty collection is not found as well.
+	CollectionFunc func(r *http.Request) (string, error)
+	// KeyFunc extracts the document key of every request, GetReqKey if nil; see PathValueKey, VarsKey and
+	// URLParamKey to use the route of a mux
+	KeyFunc KeyFunc
+	// Cors enables the CORS headers and the preflight responses for browser applications served from other origins
+	Cors *CorsOptions
+	// Admin enables the collection management endpoints: listing the collections, creating, dropping or
+	// truncating the collection and reading its stats, see serveAdmin. Expose them only to trusted clients.
+	Admin bool
 }
 
 // ServeHTTP is the main handler function
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	key := GetReqKey(r)
+	if h.Cors != nil && h.Cors.handle(w, r) {
+		return
+	}
+	collection, ok := h.collection(w, r)
+	if !ok {
+		return
+	}
+	key := h.key(r)
+	if h.Admin && h.serveAdmin(w, r, collection, key) {
+		return
+	}
+	h.serve(w, r, collection, key)
+}
+
+// collection returns the collection of the request, writing the error response if CollectionFunc fails
+func (h *Handler) collection(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.CollectionFunc == nil {
+		return h.Collection, true
+	}
+	collection, err := h.CollectionFunc(r)
+	if err == nil && collection == "" {
+		err = CollectionNotFoundErr
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, CollectionNotFoundErr) {
+			status = http.StatusNotFound
+		}
+		h.writeError(w, r, status, fmt.Errorf("failed to resolve collection: %w", err))
+		return "", false
+	}
+	return collection, true
+}
 
+// serve routes a request on a document, or on the collection root if key is empty, to the handler of its method
+func (h *HttpStorer) serve(w http.ResponseWriter, r *http.Request, collection, key string) {
+	if h.ChangeStream && key == changesPath && r.Method == http.MethodGet {
+		if h.authorize(w, r, OperationWatch, collection, "") {
+			h.StreamChanges(w, r, collection)
+		}
+		return
+	}
+	if h.LiveSync != nil && key == syncPath && r.Method == http.MethodGet {
+		if h.authorize(w, r, OperationWatch, collection, "") {
+			h.ServeLiveSync(w, r, collection)
+		}
+		return
+	}
+	if op, ok := requestOperation(r.Method, key); ok && !h.authorize(w, r, op, collection, key) {
+		return
+	}
+	idempotent := h.Idempotency != nil && r.Header.Get(IdempotencyKeyHeader) != ""
 	switch {
+	case r.Method == http.MethodPost && idempotent:
+		h.serveIdempotent(w, r, collection, key, h.Set)
 	case r.Method == http.MethodPost:
-		h.Set(w, r, h.Collection, key)
-	case r.Method == http.MethodGet:
+		h.Set(w, r, collection, key)
+	case r.Method == http.MethodPut && idempotent:
+		h.serveIdempotent(w, r, collection, key, h.Put)
+	case r.Method == http.MethodPut:
+		h.Put(w, r, collection, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
 		if key == "" {
-			h.List(w, r, h.Collection)
+			h.List(w, r, collection)
 		} else {
-			h.Get(w, r, h.Collection, key)
+			h.Get(w, r, collection, key)
 		}
+	case r.Method == http.MethodPatch:
+		h.Patch(w, r, collection, key)
 	case r.Method == http.MethodDelete:
-		h.Delete(w, r, h.Collection, key)
+		h.Delete(w, r, collection, key)
+	case r.Method == http.MethodOptions:
+		methods := handlerMethods
+		if h.ReadOnly {
+			methods = readOnlyMethods
+		}
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		w.WriteHeader(http.StatusNoContent)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		h.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
 	}
 }
 
-// GetReqKey extracts the last item from the url path to be used as key
+// GetReqKey extracts the last item from the url path to be used as key, percent-decoded: keys with a / are
+// requested as /path/a%2Fb
 func GetReqKey(r *http.Request) string {
-	if strings.HasSuffix(r.URL.Path, "/") {
+	escaped := r.URL.EscapedPath()
+	if strings.HasSuffix(escaped, "/") {
 		return ""
 	}
-	return path.Base(r.URL.Path)
+	key, err := url.PathUnescape(path.Base(escaped))
+	if err != nil {
+		return path.Base(r.URL.Path)
+	}
+	return key
 }
 
+// KeyFunc extracts the document key of a request, an empty key addresses the whole collection
+type KeyFunc func(r *http.Request) string
+
+// PathValueKey reads the key from a wildcard of the pattern of a Go 1.22 http.ServeMux, e.g.
+//
+//	mux.Handle("/items/{key}", &jsonstore.Handler{KeyFunc: jsonstore.PathValueKey("key"), ...})
+func PathValueKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.PathValue(name)
+	}
+}
+
+// VarsKey reads the key from the route variables of a mux, e.g. VarsKey(mux.Vars, "key") for gorilla/mux
+func VarsKey(vars func(r *http.Request) map[string]string, name string) KeyFunc {
+	return func(r *http.Request) string {
+		return vars(r)[name]
+	}
+}
+
+// URLParamKey reads the key from a route parameter of a mux, e.g. URLParamKey(chi.URLParam, "key") for chi
+func URLParamKey(param func(r *http.Request, name string) string, name string) KeyFunc {
+	return func(r *http.Request) string {
+		return param(r, name)
+	}
+}
+
+func (h *Handler) key(r *http.Request) string {
+	if h.KeyFunc == nil {
+		return h.queryKey(r, GetReqKey(r))
+	}
+	return h.queryKey(r, h.KeyFunc(r))
+}
+
+// queryKey returns the KeyParam query parameter of the request if the key of the path is empty
+func (h *HttpStorer) queryKey(r *http.Request, key string) string {
+	if key != "" || h.KeyParam == "" {
+		return key
+	}
+	return r.URL.Query().Get(h.KeyParam)
+}
+
+// DefaultPageSize is the page size of the lists of an HttpStorer without DefaultPageSize
+const DefaultPageSize = 10
+
+// DefaultMaxBodySize is the maximum size of the request bodies of an HttpStorer without MaxBodySize
+const DefaultMaxBodySize = 10 << 20 // 10 MiB
+
 // HttpStorer extends the default JsonStorer and adds HTTP methods to interact with the json store
 type HttpStorer struct {
 	Storer JsonStorer
+	// ValidateJson rejects request bodies that are not valid json with a 400 Bad Request before reaching the store
+	ValidateJson bool
+	// ContentTypes are the media types accepted for the documents of POST and PUT requests, e.g.
+	// "application/json"; requests with another Content-Type get a 415 Unsupported Media Type. Any if empty.
+	ContentTypes []string
+	// NewKey generates the key of the documents created by a POST without key, UUIDKey if nil
+	NewKey KeyGenerator
+	// KeyParam is the name of a query parameter holding the key of the requests without key in the path, e.g. "key"
+	// for GET /path/?key=a/b, for clients or proxies not keeping the %2F of the keys with a /; disabled if empty
+	KeyParam string
+	// ListEnvelope shapes the body of the list responses, e.g. ArrayEnvelope or JsonApiEnvelope; the Page if nil
+	ListEnvelope ListShaper
+	// DefaultPageSize is the page size of the lists without limit, DefaultPageSize if 0; MaxPageSize is the maximum
+	// page size, larger limits are lowered to it, MaxListItems if 0. Stores configured with a lower maximum page size
+	// return smaller pages, the limit of the response is always the one applied.
+	DefaultPageSize int
+	MaxPageSize     int
+	// MaxBodySize is the maximum size in bytes of the request bodies, larger requests get a 413 Request Entity Too
+	// Large response without reaching the store; DefaultMaxBodySize if 0, no limit if negative
+	MaxBodySize int64
+	// Compression configures the gzip and zstd compression of the responses, enabled by default
+	Compression CompressionOptions
+	// ErrorMapper converts errors into the application/problem+json body of the error responses,
+	// DefaultErrorMapper if nil
+	ErrorMapper ErrorMapper
+	// Authorize is called by the Handler and the MultiHandler before every operation, a request it returns an error
+	// for gets a 401 Unauthorized if the error wraps UnauthorizedErr and a 403 Forbidden otherwise. The methods of
+	// the HttpStorer called from another mux don't call it.
+	Authorize func(r *http.Request, op Operation, collection, key string) error
+	// ChangeStream enables the Server-Sent Events stream of the changes of the collection on the _changes key,
+	// see StreamChanges
+	ChangeStream bool
+	// LiveSync enables the WebSocket on the _sync key reading and writing the documents of the collection and
+	// pushing its changes, see ServeLiveSync
+	LiveSync *LiveSyncOptions
+	// Idempotency enables the Idempotency-Key header on POST and PUT requests, replaying the response of the
+	// retries of a write instead of writing again, see IdempotencyOptions
+	Idempotency *IdempotencyOptions
+	// ReadOnly rejects the writes with a 405 Method Not Allowed before reaching the store, including the ones of the
+	// admin endpoints and of the live sync, so that the collection can be served publicly for reading
+	ReadOnly bool
+	// BinaryFormats accepts CBOR and MessagePack documents besides json, and sends the responses in them to the
+	// requests preferring them in the Accept header; the documents are transcoded, the store keeps json
+	BinaryFormats bool
+	// Schemas validates the documents written by POST, PUT and PATCH requests, the live sync and the _import admin
+	// endpoint; invalid documents get a 422 Unprocessable Entity listing the violations, see SchemaValidator
+	Schemas *SchemaValidator
 }
 
-// Set handles requests to create or update a document, normally this would be a POST request
+// Set handles requests to create or update a document, normally this would be a POST request.
+// With an If-Match header the document is only written if its ETag matches, see checkIfMatch.
+// Without key, e.g. a POST to the collection root, a new key is generated with NewKey; the response then has
+// a Location header with the URL of the document and the key in a {"key":"<key>"} body.
 func (h *HttpStorer) Set(w http.ResponseWriter, r *http.Request, collection, key string) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+	body, ok := h.readBody(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
+	if key == "" {
+		h.create(w, r, collection, body)
+		return
+	}
+	version, ok := h.checkIfMatch(w, r, collection, key)
+	if !ok {
+		return
+	}
+	if !h.store(w, r, collection, key, body, version) {
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
 
-	err = h.Storer.Set(r.Context(), collection, key, body)
+// create stores a document under a generated key
+func (h *HttpStorer) create(w http.ResponseWriter, r *http.Request, collection string, body []byte) {
+	newKey := h.NewKey
+	if newKey == nil {
+		newKey = UUIDKey
+	}
+	key, err := newKey()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to store data: %v", err), http.StatusInternalServerError)
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to generate key: %w", err))
 		return
 	}
+	if !h.store(w, r, collection, key, body, "") {
+		return
+	}
+	location := r.URL.EscapedPath()
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+	resp, _ := json.Marshal(map[string]string{"key": key})
+	w.Header().Set("Location", location+url.PathEscape(key))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(resp)
+}
+
+// Put handles requests to create or replace a document, normally this would be a PUT on /path/<key>;
+// it responds 201 Created if the document did not exist and 204 No Content if it was replaced.
+// With an If-Match header the document is only replaced if its ETag matches, see checkIfMatch.
+func (h *HttpStorer) Put(w http.ResponseWriter, r *http.Request, collection, key string) {
+	if key == "" {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to store data: %w", missingKeyErr))
+		return
+	}
+	body, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+	version, ok := h.checkIfMatch(w, r, collection, key)
+	if !ok {
+		return
+	}
+	var existing json.RawMessage
+	err := h.Storer.Get(r.Context(), collection, key, &existing)
+	if err != nil && !isNotFound(err) {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to store data: %w", err))
+		return
+	}
+	if !h.store(w, r, collection, key, body, version) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Patch handles requests to update parts of a document, normally this would be a PATCH on /path/<key> with an
+// application/merge-patch+json or application/json-patch+json body; it responds 200 OK with the patched document
+func (h *HttpStorer) Patch(w http.ResponseWriter, r *http.Request, collection, key string) {
+	if key == "" {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to patch data: %w", missingKeyErr))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	patchType := PatchType(mediaType)
+	if patchType != MergePatch && patchType != JsonPatch {
+		w.Header().Set("Accept-Patch", string(MergePatch)+", "+string(JsonPatch))
+		h.writeError(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("failed to patch data: %w %q", unsupportedMediaTypeErr, mediaType))
+		return
+	}
+	body, ok := h.readRequestBody(w, r)
+	if !ok {
+		return
+	}
+
+	patched, err := patchDocument(r.Context(), h.Storer, collection, key, body, patchType, h.schemaCheck(collection))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case isNotFound(err):
+			status = http.StatusNotFound
+		case errors.Is(err, SchemaViolationErr):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, InvalidPatchErr), errors.Is(err, InvalidJsonErr):
+			status = http.StatusBadRequest
+		case errors.Is(err, PatchConflictErr):
+			status = http.StatusConflict
+		case errors.Is(err, ReadOnlyErr):
+			status = http.StatusMethodNotAllowed
+		}
+		h.writeError(w, r, status, fmt.Errorf("failed to patch data: %w", err))
+		return
+	}
+	h.writeJson(w, r, patched)
 }
 
-// Get handles requests to read a single item in the collection, normally this would be a GET on /path/<itemKey>
+// readBody reads the document of a write request, writing the error response if it fails
+func (h *HttpStorer) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if len(h.ContentTypes) > 0 && !slices.Contains(h.ContentTypes, mediaType) {
+		h.writeError(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("failed to store data: %w %q", unsupportedMediaTypeErr, mediaType))
+		return nil, false
+	}
+	body, ok := h.readRequestBody(w, r)
+	if !ok {
+		return nil, false
+	}
+	if encoding := mediaEncoding(mediaType); h.BinaryFormats && encoding.binary() {
+		doc, err := encoding.decode(body)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to store data: %w: %v", InvalidJsonErr, err))
+			return nil, false
+		}
+		return doc, true
+	}
+
+	if h.ValidateJson && !json.Valid(body) {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to store data: %w", InvalidJsonErr))
+		return nil, false
+	}
+	return body, true
+}
+
+// readRequestBody reads the body of a request up to MaxBodySize, writing a 413 Request Entity Too Large response
+// if it is larger
+func (h *HttpStorer) readRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	defer r.Body.Close()
+	reader := r.Body
+	limit := h.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+	if limit > 0 {
+		reader = http.MaxBytesReader(w, r.Body, limit)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeError(w, r, http.StatusRequestEntityTooLarge,
+				fmt.Errorf("failed to read request body: %w: the limit is %d bytes", bodyTooLargeErr, tooLarge.Limit))
+			return nil, false
+		}
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to read request body: %w", err))
+		return nil, false
+	}
+	return body, true
+}
+
+// store writes the document, writing the error response if it fails; with a version, returned by checkIfMatch,
+// the document is only written if it did not change in the meantime
+func (h *HttpStorer) store(w http.ResponseWriter, r *http.Request, collection, key string, body []byte, version string) bool {
+	if !h.validate(w, r, collection, body) {
+		return false
+	}
+	var err error
+	if version != "" {
+		_, err = h.Storer.(VersionedStorer).SetIfVersion(r.Context(), collection, key, body, version)
+	} else {
+		err = h.Storer.Set(r.Context(), collection, key, body)
+	}
+	if err != nil {
+		if errors.Is(err, VersionConflictErr) {
+			h.writeError(w, r, http.StatusPreconditionFailed, fmt.Errorf("failed to store data: %w", err))
+			return false
+		}
+		if errors.Is(err, InvalidJsonErr) {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to store data: %w", err))
+			return false
+		}
+		if errors.Is(err, ReadOnlyErr) {
+			h.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("failed to store data: %w", err))
+			return false
+		}
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to store data: %w", err))
+		return false
+	}
+	return true
+}
+
+// checkIfMatch evaluates the If-Match header of a write request against the ETag of the stored document, writing
+// a 412 Precondition Failed response if none of the listed tags matches or if the document does not exist. "*"
+// matches any existing document. On a VersionedStorer it returns the version of the matched document, so that the
+// write fails if the document changes between the check and the write; on other stores the check is best effort.
+func (h *HttpStorer) checkIfMatch(w http.ResponseWriter, r *http.Request, collection, key string) (string, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return "", true
+	}
+	var doc json.RawMessage
+	var version string
+	var err error
+	if vs, ok := h.Storer.(VersionedStorer); ok {
+		version, err = vs.GetWithVersion(r.Context(), collection, key, &doc)
+	} else {
+		err = h.Storer.Get(r.Context(), collection, key, &doc)
+	}
+	if err != nil && !isNotFound(err) {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to check precondition: %w", err))
+		return "", false
+	}
+	if err != nil || doc == nil || !etagMatches(header, etag(doc)) {
+		h.writeError(w, r, http.StatusPreconditionFailed, fmt.Errorf("%w: the document does not match If-Match", VersionConflictErr))
+		return "", false
+	}
+	return version, true
+}
+
+// etagMatches reports whether the If-Match header lists tag, weak tags never match as If-Match uses the strong
+// comparison
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound reports whether the error of a Get means that the document does not exist,
+// the DbStore returns gorm.ErrRecordNotFound
+func isNotFound(err error) bool {
+	return errors.Is(err, ItemNotFoundErr) || errors.Is(err, CollectionNotFoundErr) || errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// Get handles requests to read a single item in the collection, normally this would be a GET on /path/<itemKey>;
+// a HEAD request gets the same status and headers without the body. The fields query parameter returns only
+// the given fields of the document, see parseFields, and the populate parameter resolves its references, see
+// populate.
 func (h *HttpStorer) Get(w http.ResponseWriter, r *http.Request, collection, key string) {
+	fields := parseFields(r.URL.Query())
+	populate := parsePopulate(r.URL.Query())
+	if err := (Query{Fields: append(fields, populate...)}).Validate(); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to retrieve item: %w", err))
+		return
+	}
 	var value json.RawMessage
-	err := h.Storer.Get(r.Context(), collection, key, &value)
+	var modTime time.Time
+	var err error
+	// the modification time of a populated document does not cover the referenced ones
+	if mg, ok := h.Storer.(ModTimeGetter); ok && len(populate) == 0 {
+		modTime, err = mg.GetWithModTime(r.Context(), collection, key, &value)
+	} else {
+		err = h.Storer.Get(r.Context(), collection, key, &value)
+	}
 	if err != nil {
-		if errors.Is(err, ItemNotFoundErr) {
-			http.Error(w, fmt.Sprintf("Failed to retrieve item: %v", err), http.StatusNotFound)
+		if isNotFound(err) {
+			h.writeError(w, r, http.StatusNotFound, fmt.Errorf("failed to retrieve item: %w", err))
 			return
 		}
 
-		http.Error(w, fmt.Sprintf("Failed to retrieve item: %v", err), http.StatusInternalServerError)
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to retrieve item: %w", err))
+		return
+	}
+	if value, err = h.newPopulator(r, collection).populate(r.Context(), value, populate); err != nil {
+		h.writePopulateError(w, r, fmt.Errorf("failed to retrieve item: %w", err))
+		return
+	}
+	if value, err = Project(value, fields); err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to retrieve item: %w", err))
 		return
 	}
 
-	// Write the response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(value)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		if notModifiedSince(r, modTime) {
+			w.Header().Set("ETag", etag(value))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	h.writeJson(w, r, value)
+}
+
+// refScopeKey is the context key of the function checking that the handler serves the collection of a reference,
+// see withRefScope
+type refScopeKey struct{}
+
+// withRefScope returns the request with the function checking that the handler serves the collections referenced
+// by the documents, e.g. the allow-list of a MultiHandler; without it only the collection of the request is served
+func withRefScope(r *http.Request, scope func(collection string) error) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), refScopeKey{}, scope))
+}
+
+// newPopulator returns a populator for the populate query parameter of a request on collection. A reference is only
+// resolved if the handler serves its collection, by default only the collection of the request, and the Authorize
+// hook allows to get the document; otherwise populating fails with ForbiddenErr. The idempotency records are
+// never served.
+func (h *HttpStorer) newPopulator(r *http.Request, collection string) *populator {
+	get := storeRefGetter(h.Storer)
+	scope, _ := r.Context().Value(refScopeKey{}).(func(collection string) error)
+	return newPopulator(func(ctx context.Context, ref Reference) (json.RawMessage, error) {
+		var err error
+		switch {
+		case h.Idempotency != nil && ref.Collection == h.Idempotency.collection():
+			err = fmt.Errorf("collection %s is reserved", ref.Collection)
+		case scope != nil:
+			err = scope(ref.Collection)
+		case ref.Collection != collection:
+			err = fmt.Errorf("collection %s is not served", ref.Collection)
+		}
+		if err == nil && h.Authorize != nil {
+			err = h.Authorize(r, OperationGet, ref.Collection, ref.Key)
+		}
+		if err != nil {
+			if !errors.Is(err, UnauthorizedErr) && !errors.Is(err, ForbiddenErr) {
+				err = fmt.Errorf("%w: %v", ForbiddenErr, err)
+			}
+			return nil, fmt.Errorf("reference %s: %w", ref, err)
+		}
+		return get(ctx, ref)
+	})
+}
+
+// writePopulateError writes the error of populating the references of a response, a 401 or 403 if reading a
+// referenced document is not allowed, see newPopulator
+func (h *HttpStorer) writePopulateError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, UnauthorizedErr):
+		h.writeError(w, r, http.StatusUnauthorized, err)
+	case errors.Is(err, ForbiddenErr):
+		h.writeError(w, r, http.StatusForbidden, err)
+	default:
+		h.writeError(w, r, http.StatusInternalServerError, err)
+	}
+}
+
+// notModifiedSince returns true if the document modified at modTime was not modified since the If-Modified-Since
+// header of the request; as required by RFC 7232 the header is ignored if the request has an If-None-Match
+func notModifiedSince(r *http.Request, modTime time.Time) bool {
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// the header has a resolution of seconds
+	return !modTime.Truncate(time.Second).After(since)
 }
 
 // List handles requests to read a list of items in the collection, normally this would be a GET on /path/
 // note that the methods makes use of query parameters limit and page to allow for pagination
-// it will also return the total amount of items to facilitate navigation to the last page
+// it will also return the total amount of items to facilitate navigation to the last page,
+// alternatively the cursor query parameter can be set to the nextCursor of a previous response.
+// With total=false the store may skip counting the items, the total is then -1.
+// The prefix query parameter restricts the list to the keys starting with it, the filter parameters to the
+// documents matching them, see parseFilters; the sort parameter orders them, see parseSort, the fields
+// parameter projects them, see parseFields, and the populate parameter resolves their references.
+// The Link header points to the next, previous and first pages.
+// As with Get, a HEAD request gets the headers without the body.
+// With format=ndjson or "Accept: application/x-ndjson" the whole collection is streamed instead, see streamList.
 func (h *HttpStorer) List(w http.ResponseWriter, r *http.Request, collection string) {
+	if r.Method == http.MethodGet && wantsNdjson(r) {
+		h.streamList(w, r, collection)
+		return
+	}
 
 	query := r.URL.Query()
-	limit := 10 // Default limit
-	page := 1   // Default page
+	maxLimit := h.MaxPageSize
+	if maxLimit <= 0 {
+		maxLimit = MaxListItems
+	}
+	opts := ListOptions{
+		Page:     1, // Default page
+		Cursor:   query.Get("cursor"),
+		Prefix:   query.Get("prefix"),
+		MaxLimit: maxLimit,
+	}
+	if opts.Cursor == "" {
+		// when using a cursor the limit is taken from it
+		opts.Limit = h.DefaultPageSize
+		if opts.Limit <= 0 {
+			opts.Limit = DefaultPageSize
+		}
+	}
 
 	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
-		limit = l
+		opts.Limit = l
+	}
+	if opts.Limit > maxLimit {
+		opts.Limit = maxLimit
 	}
 	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
-		page = p
+		opts.Page = p
+	}
+	if t, err := strconv.ParseBool(query.Get("total")); err == nil && !t {
+		opts.SkipTotal = true
 	}
 
-	// Call the List method on the Storer
-	items, total, err := h.Storer.List(r.Context(), collection, limit, page)
+	q := Query{ListOptions: opts, Sort: parseSort(query), Fields: parseFields(query)}
+	var err error
+	if q.Filters, err = parseFilters(query); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to fetch items: %w", err))
+		return
+	}
+	// the documents are projected once populated, so the fields can select the fields of the referenced documents
+	populate := parsePopulate(query)
+	fields := q.Fields
+	if len(populate) > 0 {
+		if err = (Query{Fields: populate}).Validate(); err != nil {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to fetch items: %w", err))
+			return
+		}
+		q.Fields = nil
+	}
+
+	// Call the List method on the Storer, or run a query if the documents are filtered, sorted or projected
+	var page Page
+	if len(q.Filters) > 0 || len(q.Sort) > 0 || len(q.Fields) > 0 {
+		page, err = RunQuery(r.Context(), h.Storer, collection, q)
+	} else {
+		page, err = ListPage(r.Context(), h.Storer, collection, opts)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fetch items: %v", err), http.StatusInternalServerError)
+		if errors.Is(err, InvalidCursorErr) || errors.Is(err, InvalidQueryErr) {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to fetch items: %w", err))
+			return
+		}
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to fetch items: %w", err))
 		return
 	}
 
-	// Construct the response
-	response := map[string]interface{}{
-		"items": items,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+	if len(populate) > 0 {
+		p := h.newPopulator(r, collection)
+		for key, value := range page.Items {
+			if value, err = p.populate(r.Context(), value, populate); err != nil {
+				h.writePopulateError(w, r, fmt.Errorf("failed to fetch items: %w", err))
+				return
+			}
+			if value, err = Project(value, fields); err != nil {
+				h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to fetch items: %w", err))
+				return
+			}
+			page.Items[key] = value
+		}
+	}
+
+	if link := pageLinks(r, page); link != "" {
+		w.Header().Set("Link", link)
 	}
 
 	// Respond with JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	var envelope any = page
+	if h.ListEnvelope != nil {
+		if envelope, err = h.ListEnvelope(r, collection, page); err != nil {
+			h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %w", err))
+			return
+		}
 	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+	h.writeJson(w, r, append(body, '\n'))
+}
+
+// pageLinks returns the RFC 8288 Link header pointing to the next, previous and first pages of a list response,
+// the links keep the query parameters of the request and replace the pagination ones with a cursor
+func pageLinks(r *http.Request, page Page) string {
+	link := func(cursor, rel string) string {
+		return fmt.Sprintf("<%s>; rel=%q", cursorURL(r, cursor), rel)
+	}
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, link(page.NextCursor, "next"))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, link(page.PrevCursor, "prev"), link(encodeCursor(1, page.Limit), "first"))
+	}
+	return strings.Join(links, ", ")
+}
+
+// cursorURL returns the url of the request with the pagination query parameters replaced by a cursor
+func cursorURL(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Del("page")
+	query.Del("limit")
+	query.Set("cursor", cursor)
+	return r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+// etag returns the strong entity tag of a response body, the quoted hex of the first 16 bytes of its sha256
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
 }
 
 // Delete handles requests to delete an item in the collection, normally this would be a DELETE on /path/<key>
+// With an If-Match header the document is only deleted if its ETag matches, see checkIfMatch.
 func (h *HttpStorer) Delete(w http.ResponseWriter, r *http.Request, collection, key string) {
+	version, ok := h.checkIfMatch(w, r, collection, key)
+	if !ok {
+		return
+	}
 
-	deleted, err := h.Storer.Delete(r.Context(), collection, key)
+	deleted := true
+	var err error
+	if version != "" {
+		err = h.Storer.(VersionedStorer).DeleteIfVersion(r.Context(), collection, key, version)
+		if errors.Is(err, ItemNotFoundErr) {
+			deleted, err = false, nil
+		}
+	} else {
+		deleted, err = h.Storer.Delete(r.Context(), collection, key)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete data: %v", err), http.StatusInternalServerError)
+		if errors.Is(err, VersionConflictErr) {
+			h.writeError(w, r, http.StatusPreconditionFailed, fmt.Errorf("failed to delete data: %w", err))
+			return
+		}
+		if errors.Is(err, ReadOnlyErr) {
+			h.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("failed to delete data: %w", err))
+			return
+		}
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to delete data: %w", err))
 		return
 	}
 	if !deleted {
-		http.Error(w, "Item not found", http.StatusNotFound)
+		h.writeError(w, r, http.StatusNotFound, fmt.Errorf("failed to delete data: %w", ItemNotFoundErr))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -6,11 +6,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
 	"github.com/google/go-cmp/cmp"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetKey(t *testing.T) {
@@ -42,6 +49,16 @@ func TestGetKey(t *testing.T) {
 			urlPath:     "/keyOnly",
 			expectedKey: "keyOnly",
 		},
+		{
+			name:        "Escaped slash in key",
+			urlPath:     "/db/collection/a%2Fb",
+			expectedKey: "a/b",
+		},
+		{
+			name:        "Escaped space and unicode in key",
+			urlPath:     "/db/collection/my%20caf%C3%A9",
+			expectedKey: "my café",
+		},
 	}
 
 	for _, tt := range tcs {
@@ -56,8 +73,167 @@ func TestGetKey(t *testing.T) {
 	}
 }
 
+func TestHandlerKeyFunc(t *testing.T) {
+	ctx := context.Background()
+	// routeVars stands in for the route variables of a mux like gorilla/mux or chi
+	routeVars := func(r *http.Request) map[string]string {
+		return map[string]string{"key": r.URL.Query().Get("id")}
+	}
+	routeParam := func(r *http.Request, name string) string {
+		return routeVars(r)[name]
+	}
+
+	tcs := []struct {
+		name    string
+		keyFunc jsonstore.KeyFunc
+		pattern string
+		url     string
+	}{
+		{name: "ServeMux path value", keyFunc: jsonstore.PathValueKey("key"), pattern: "/items/{key}/raw", url: "/items/item1/raw"},
+		{name: "route variables", keyFunc: jsonstore.VarsKey(routeVars, "key"), pattern: "/items", url: "/items?id=item1"},
+		{name: "route parameter", keyFunc: jsonstore.URLParamKey(routeParam, "key"), pattern: "/items", url: "/items?id=item1"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			mux := http.NewServeMux()
+			mux.Handle(tc.pattern, &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1", KeyFunc: tc.keyFunc})
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if diff := cmp.Diff(rec.Body.String(), `{"n":1}`); diff != "" {
+				t.Errorf("unexpected body (-got +want):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("empty key lists the collection", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		mux := http.NewServeMux()
+		handler := &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1", KeyFunc: jsonstore.PathValueKey("key")}
+		mux.Handle("/items", handler)
+		mux.Handle("/items/{key}", handler)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+		var page jsonstore.Page
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if page.Total != 1 {
+			t.Errorf("expected a list with 1 document, got: %+v", page)
+		}
+	})
+}
+
+func TestHandlerEscapedKeys(t *testing.T) {
+	keys := []string{"a/b", "my café", "100% sure", "x?y#z"}
+
+	handlers := []struct {
+		name    string
+		handler http.Handler
+		prefix  string
+	}{
+		{name: "Handler", prefix: "/items/", handler: &jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()}, Collection: "items"}},
+		{name: "MultiHandler", prefix: "/items/", handler: &jsonstore.MultiHandler{
+			HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()}}},
+	}
+
+	for _, hc := range handlers {
+		t.Run(hc.name, func(t *testing.T) {
+			for _, key := range keys {
+				docURL := hc.prefix + url.PathEscape(key)
+				do := func(method, target, body string) *httptest.ResponseRecorder {
+					req := httptest.NewRequest(method, target, strings.NewReader(body))
+					if method == http.MethodPatch {
+						req.Header.Set("Content-Type", string(jsonstore.MergePatch))
+					}
+					rec := httptest.NewRecorder()
+					hc.handler.ServeHTTP(rec, req)
+					return rec
+				}
+
+				if rec := do(http.MethodPut, docURL, `{"n":1}`); rec.Code != http.StatusCreated {
+					t.Fatalf("PUT %s: expected status %d, got %d: %s", key, http.StatusCreated, rec.Code, rec.Body.String())
+				}
+				if rec := do(http.MethodGet, docURL, ""); rec.Code != http.StatusOK || rec.Body.String() != `{"n":1}` {
+					t.Errorf("GET %s: unexpected response %d: %s", key, rec.Code, rec.Body.String())
+				}
+				if rec := do(http.MethodPatch, docURL, `{"m":2}`); rec.Code != http.StatusOK {
+					t.Errorf("PATCH %s: unexpected status %d: %s", key, rec.Code, rec.Body.String())
+				}
+				if rec := do(http.MethodHead, docURL, ""); rec.Code != http.StatusOK {
+					t.Errorf("HEAD %s: unexpected status %d", key, rec.Code)
+				}
+
+				rec := do(http.MethodGet, hc.prefix, "")
+				var page jsonstore.Page
+				if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if _, ok := page.Items[key]; !ok {
+					t.Errorf("expected %q in the list, got %v", key, page.Items)
+				}
+
+				if rec := do(http.MethodDelete, docURL, ""); rec.Code != http.StatusOK {
+					t.Errorf("DELETE %s: unexpected status %d: %s", key, rec.Code, rec.Body.String())
+				}
+				if rec := do(http.MethodGet, docURL, ""); rec.Code != http.StatusNotFound {
+					t.Errorf("GET %s after DELETE: expected status %d, got %d", key, http.StatusNotFound, rec.Code)
+				}
+			}
+		})
+	}
+
+	t.Run("Location of a generated key", func(t *testing.T) {
+		handler := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore(), NewKey: func() (string, error) { return "a b/c", nil }},
+			Collection: "items",
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/my%20items/", strings.NewReader(`{"n":1}`)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+		if diff := cmp.Diff(rec.Header().Get("Location"), "/my%20items/a%20b%2Fc"); diff != "" {
+			t.Errorf("unexpected Location (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("key query parameter", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		handlers := []http.Handler{
+			&jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store, KeyParam: "key"}, Collection: "items"},
+			&jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store, KeyParam: "key"}},
+		}
+		for i, handler := range handlers {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/items/?key=a/b", strings.NewReader(`{"n":1}`)))
+			if rec.Code != http.StatusCreated && rec.Code != http.StatusNoContent {
+				t.Fatalf("handler %d: PUT: unexpected status %d: %s", i, rec.Code, rec.Body.String())
+			}
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/a%2Fb", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("handler %d: GET: unexpected status %d: %s", i, rec.Code, rec.Body.String())
+			}
+		}
+	})
+}
+
 func TestHandlerGet(t *testing.T) {
-	mockStorer := &MockStorer{
+	mockStorer := &jsonstoretest.FakeStore{
 		Data: make(map[string]map[string]json.RawMessage),
 	}
 	handler := jsonstore.Handler{
@@ -108,7 +284,7 @@ func TestHandlerGet(t *testing.T) {
 
 		// Check error message
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Failed to retrieve item: item not found\n"
+		expectedError := `{"type":"urn:jsonstore:item-not-found","title":"Not Found","status":404,"detail":"failed to retrieve item: item not found","instance":"/keyNotFound"}`
 		if string(body) != expectedError {
 			t.Errorf("expected body %q, got %q", expectedError, string(body))
 		}
@@ -131,7 +307,7 @@ func TestHandlerGet(t *testing.T) {
 
 		// Check error message
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Failed to retrieve item: storage error\n"
+		expectedError := `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"failed to retrieve item: storage error","instance":"/key1"}`
 		if string(body) != expectedError {
 			t.Errorf("expected body %q, got %q", expectedError, string(body))
 		}
@@ -139,7 +315,7 @@ func TestHandlerGet(t *testing.T) {
 }
 
 func TestHandlerSet(t *testing.T) {
-	mockStorer := &MockStorer{
+	mockStorer := &jsonstoretest.FakeStore{
 		Data: make(map[string]map[string]json.RawMessage),
 	}
 	handler := jsonstore.Handler{
@@ -188,12 +364,156 @@ func TestHandlerSet(t *testing.T) {
 
 		// Check error message
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Failed to read request body\n"
+		expectedError := `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"failed to read request body: broken reader error","instance":"/key1"}`
 		if string(body) != expectedError {
 			t.Errorf("expected body %q, got %q", expectedError, string(body))
 		}
 	})
 
+	t.Run("Set - invalid json", func(t *testing.T) {
+		validating := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: mockStorer, ValidateJson: true},
+			Collection: "test_collection",
+		}
+		req := httptest.NewRequest(http.MethodPost, "/key3", bytes.NewReader([]byte(`{"foo":`)))
+		rec := httptest.NewRecorder()
+
+		validating.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if _, exists := mockStorer.Data["test_collection"]["key3"]; exists {
+			t.Errorf("invalid json should not have been stored")
+		}
+	})
+
+	t.Run("Set - invalid json rejected by the store", func(t *testing.T) {
+		store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), jsonstore.DbStoreOptions{ValidateJson: true})
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		dbHandler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}}
+		req := httptest.NewRequest(http.MethodPost, "/key3", bytes.NewReader([]byte(`{"foo":`)))
+		rec := httptest.NewRecorder()
+
+		dbHandler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Set - read only store", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store.json")
+		if err := os.WriteFile(file, []byte(`{}`), 0444); err != nil {
+			t.Fatal(err)
+		}
+		store, err := jsonstore.NewFileStoreReadOnly(file)
+		if err != nil {
+			t.Fatalf("NewFileStoreReadOnly returned an error: %v", err)
+		}
+		roHandler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}}
+		for _, method := range []string{http.MethodPost, http.MethodDelete} {
+			req := httptest.NewRequest(method, "/key3", bytes.NewReader([]byte(`{"foo":"bar"}`)))
+			rec := httptest.NewRecorder()
+
+			roHandler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s: expected status %d, got %d", method, http.StatusMethodNotAllowed, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Set - content type", func(t *testing.T) {
+		strict := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: mockStorer, ContentTypes: []string{"application/json"}, ValidateJson: true},
+			Collection: "test_collection",
+		}
+		tcs := []struct {
+			method      string
+			contentType string
+			body        string
+			wantStatus  int
+		}{
+			{method: http.MethodPost, contentType: "application/json", body: `{"foo":"bar"}`, wantStatus: http.StatusCreated},
+			{method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{"foo":"bar"}`, wantStatus: http.StatusCreated},
+			{method: http.MethodPost, contentType: "text/plain", body: `{"foo":"bar"}`, wantStatus: http.StatusUnsupportedMediaType},
+			{method: http.MethodPost, contentType: "", body: `{"foo":"bar"}`, wantStatus: http.StatusUnsupportedMediaType},
+			{method: http.MethodPut, contentType: "application/x-www-form-urlencoded", body: `foo=bar`, wantStatus: http.StatusUnsupportedMediaType},
+			{method: http.MethodPut, contentType: "application/json", body: `not json`, wantStatus: http.StatusBadRequest},
+		}
+		for _, tc := range tcs {
+			req := httptest.NewRequest(tc.method, "/key5", bytes.NewReader([]byte(tc.body)))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			strict.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("%s %q: expected status %d, got %d", tc.method, tc.contentType, tc.wantStatus, rec.Code)
+			}
+		}
+	})
+
+	t.Run("Set - body too large", func(t *testing.T) {
+		limited := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: mockStorer, MaxBodySize: 16},
+			Collection: "test_collection",
+		}
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch} {
+			req := httptest.NewRequest(method, "/key4", bytes.NewReader([]byte(`{"foo":"a value longer than the limit"}`)))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			rec := httptest.NewRecorder()
+
+			limited.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("%s: expected status %d, got %d", method, http.StatusRequestEntityTooLarge, rec.Code)
+			}
+		}
+		if _, exists := mockStorer.Data["test_collection"]["key4"]; exists {
+			t.Errorf("a body larger than the limit should not have been stored")
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/key4", bytes.NewReader([]byte(`{"foo":"bar"}`)))
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("expected status %d within the limit, got %d", http.StatusCreated, rec.Code)
+		}
+	})
+
+	t.Run("Set - generated key", func(t *testing.T) {
+		keyHandler := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: mockStorer, NewKey: func() (string, error) { return "new key", nil }},
+			Collection: "test_collection",
+		}
+		req := httptest.NewRequest(http.MethodPost, "/items/", bytes.NewReader([]byte(`{"foo":"bar"}`)))
+		rec := httptest.NewRecorder()
+
+		keyHandler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+		}
+		if diff := cmp.Diff(rec.Header().Get("Location"), "/items/new%20key"); diff != "" {
+			t.Errorf("unexpected Location (-got +want):\n%s", diff)
+		}
+		if diff := cmp.Diff(rec.Body.String(), `{"key":"new key"}`); diff != "" {
+			t.Errorf("unexpected body (-got +want):\n%s", diff)
+		}
+		if diff := cmp.Diff(mockStorer.Data["test_collection"]["new key"], json.RawMessage(`{"foo":"bar"}`)); diff != "" {
+			t.Errorf("unexpected stored data (-got +want):\n%s", diff)
+		}
+		if _, exists := mockStorer.Data["test_collection"][""]; exists {
+			t.Errorf("document should not be stored under an empty key")
+		}
+	})
+
 	t.Run("Set - storage error", func(t *testing.T) {
 		mockStorer.Err = fmt.Errorf("storage error")
 		reqBody := []byte(`{"baz":"qux"}`)
@@ -212,7 +532,7 @@ func TestHandlerSet(t *testing.T) {
 
 		// Check error message
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Failed to store data: storage error\n"
+		expectedError := `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"failed to store data: storage error","instance":"/"}`
 		if string(body) != expectedError {
 			t.Errorf("expected body %q, got %q", expectedError, string(body))
 		}
@@ -224,10 +544,382 @@ func TestHandlerSet(t *testing.T) {
 	})
 }
 
+func TestHandlerPut(t *testing.T) {
+	mockStorer := &jsonstoretest.FakeStore{
+		Data: make(map[string]map[string]json.RawMessage),
+	}
+	handler := jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: mockStorer},
+		Collection: "test_collection",
+	}
+
+	tcs := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{name: "Put - create", url: "/key1", body: `{"foo":"bar"}`, wantStatus: http.StatusCreated},
+		{name: "Put - replace", url: "/key1", body: `{"foo":"baz"}`, wantStatus: http.StatusNoContent},
+		{name: "Put - missing key", url: "/", body: `{"foo":"bar"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tc.url, bytes.NewReader([]byte(tc.body)))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
+	if diff := cmp.Diff(mockStorer.Data["test_collection"]["key1"], json.RawMessage(`{"foo":"baz"}`)); diff != "" {
+		t.Errorf("unexpected stored data (-got +want):\n%s", diff)
+	}
+
+	t.Run("Put - storage error", func(t *testing.T) {
+		mockStorer.Err = fmt.Errorf("storage error")
+		defer func() { mockStorer.Err = nil }()
+		req := httptest.NewRequest(http.MethodPut, "/key2", bytes.NewReader([]byte(`{}`)))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
+
+func TestHandlerHead(t *testing.T) {
+	mockStorer := &jsonstoretest.FakeStore{
+		Data: map[string]map[string]json.RawMessage{
+			"test_collection": {"key1": json.RawMessage(`{"foo":"bar"}`)},
+		},
+	}
+	handler := jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: mockStorer},
+		Collection: "test_collection",
+	}
+
+	tcs := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{name: "Head - item", url: "/key1", wantStatus: http.StatusOK},
+		{name: "Head - list", url: "/", wantStatus: http.StatusOK},
+		{name: "Head - missing item", url: "/key2", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			getRec := httptest.NewRecorder()
+			handler.ServeHTTP(getRec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, tc.url, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("expected an empty body, got %q", rec.Body.String())
+			}
+			if diff := cmp.Diff(rec.Header().Get("Content-Length"), strconv.Itoa(getRec.Body.Len())); diff != "" {
+				t.Errorf("unexpected Content-Length (-got +want):\n%s", diff)
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Errorf("expected an ETag header")
+			}
+			if diff := cmp.Diff(rec.Header().Get("ETag"), getRec.Header().Get("ETag")); diff != "" {
+				t.Errorf("unexpected ETag (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerCollectionFunc(t *testing.T) {
+	mockStorer := &jsonstoretest.FakeStore{
+		Data: map[string]map[string]json.RawMessage{
+			"alice": {"key1": json.RawMessage(`{"owner":"alice"}`)},
+			"bob":   {"key1": json.RawMessage(`{"owner":"bob"}`)},
+		},
+	}
+	handler := jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: mockStorer},
+		Collection: "ignored",
+		CollectionFunc: func(r *http.Request) (string, error) {
+			user := r.Header.Get("X-User")
+			if user == "mallory" {
+				return "", fmt.Errorf("unknown user %s: %w", user, jsonstore.CollectionNotFoundErr)
+			}
+			if strings.ContainsAny(user, "/.") {
+				return "", fmt.Errorf("invalid user %q", user)
+			}
+			return user, nil
+		},
+	}
+
+	tcs := []struct {
+		name       string
+		user       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "alice", user: "alice", wantStatus: http.StatusOK, wantBody: `{"owner":"alice"}`},
+		{name: "bob", user: "bob", wantStatus: http.StatusOK, wantBody: `{"owner":"bob"}`},
+		{name: "not found", user: "mallory", wantStatus: http.StatusNotFound},
+		{name: "invalid", user: "../alice", wantStatus: http.StatusBadRequest},
+		{name: "empty", user: "", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+			req.Header.Set("X-User", tc.user)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantBody == "" {
+				return
+			}
+			if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+				t.Errorf("unexpected body (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerIfMatch(t *testing.T) {
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) jsonstore.JsonStorer
+	}{
+		{name: "FakeStore", newStore: func(t *testing.T) jsonstore.JsonStorer {
+			return &jsonstoretest.FakeStore{Data: make(map[string]map[string]json.RawMessage)}
+		}},
+		{name: "DbStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) }},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: tc.newStore(t)},
+				Collection: "test_collection",
+			}
+			do := func(method, url, body, ifMatch string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, url, bytes.NewReader([]byte(body)))
+				if ifMatch != "" {
+					req.Header.Set("If-Match", ifMatch)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			if rec := do(http.MethodPut, "/key1", `{"foo":"bar"}`, "*"); rec.Code != http.StatusPreconditionFailed {
+				t.Errorf("expected status %d creating with If-Match, got %d", http.StatusPreconditionFailed, rec.Code)
+			}
+			if rec := do(http.MethodPut, "/key1", `{"foo":"bar"}`, ""); rec.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+			}
+			tag := do(http.MethodGet, "/key1", "", "").Header().Get("ETag")
+
+			if rec := do(http.MethodPut, "/key1", `{"foo":"baz"}`, `"stale"`); rec.Code != http.StatusPreconditionFailed {
+				t.Errorf("expected status %d with a stale ETag, got %d", http.StatusPreconditionFailed, rec.Code)
+			}
+			if rec := do(http.MethodPost, "/key1", `{"foo":"baz"}`, "W/"+tag); rec.Code != http.StatusPreconditionFailed {
+				t.Errorf("expected status %d with a weak ETag, got %d", http.StatusPreconditionFailed, rec.Code)
+			}
+			if rec := do(http.MethodPut, "/key1", `{"foo":"baz"}`, `"other", `+tag); rec.Code != http.StatusNoContent {
+				t.Errorf("expected status %d with a matching ETag, got %d", http.StatusNoContent, rec.Code)
+			}
+			// the document changed, the old tag does not match anymore
+			if rec := do(http.MethodDelete, "/key1", "", tag); rec.Code != http.StatusPreconditionFailed {
+				t.Errorf("expected status %d deleting with a stale ETag, got %d", http.StatusPreconditionFailed, rec.Code)
+			}
+			tag = do(http.MethodGet, "/key1", "", "").Header().Get("ETag")
+			if rec := do(http.MethodPost, "/key1", `{"foo":"qux"}`, tag); rec.Code != http.StatusCreated {
+				t.Errorf("expected status %d with a matching ETag, got %d", http.StatusCreated, rec.Code)
+			}
+			if rec := do(http.MethodDelete, "/key1", "", "*"); rec.Code != http.StatusOK {
+				t.Errorf("expected status %d deleting with If-Match *, got %d", http.StatusOK, rec.Code)
+			}
+			if rec := do(http.MethodGet, "/key1", "", ""); rec.Code != http.StatusNotFound {
+				t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerIfModifiedSince(t *testing.T) {
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) jsonstore.JsonStorer
+	}{
+		{name: "DbStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) }},
+		{name: "DirStore", newStore: func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewDirStore(filepath.Join(t.TempDir(), "data"))
+			if err != nil {
+				t.Fatalf("action: NewDirStore,  returned an error: %v", err)
+			}
+			return store
+		}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: tc.newStore(t)},
+				Collection: "test_collection",
+			}
+			do := func(header, value string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+				if header != "" {
+					req.Header.Set(header, value)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			put := httptest.NewRequest(http.MethodPut, "/key1", bytes.NewReader([]byte(`{"foo":"bar"}`)))
+			handler.ServeHTTP(httptest.NewRecorder(), put)
+
+			rec := do("", "")
+			lastModified := rec.Header().Get("Last-Modified")
+			modTime, err := http.ParseTime(lastModified)
+			if err != nil {
+				t.Fatalf("expected a Last-Modified header, got %q", lastModified)
+			}
+
+			rec = do("If-Modified-Since", lastModified)
+			if rec.Code != http.StatusNotModified {
+				t.Errorf("expected status %d, got %d", http.StatusNotModified, rec.Code)
+			}
+			if rec.Body.Len() != 0 || rec.Header().Get("ETag") == "" {
+				t.Errorf("expected an ETag and no body, got %q", rec.Body.String())
+			}
+
+			before := modTime.Add(-time.Second).Format(http.TimeFormat)
+			if rec = do("If-Modified-Since", before); rec.Code != http.StatusOK {
+				t.Errorf("expected status %d modified since %s, got %d", http.StatusOK, before, rec.Code)
+			}
+			if rec = do("If-Modified-Since", "yesterday"); rec.Code != http.StatusOK {
+				t.Errorf("expected status %d with an invalid date, got %d", http.StatusOK, rec.Code)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+			req.Header.Set("If-Modified-Since", lastModified)
+			req.Header.Set("If-None-Match", `"other"`)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected If-Modified-Since to be ignored with If-None-Match, got %d", rec.Code)
+			}
+		})
+	}
+
+	t.Run("documents written before the updated_at column", func(t *testing.T) {
+		db := newSqliteDbFile(t)
+		err := db.Exec(`CREATE TABLE db_documents (id text, collection text, value json, PRIMARY KEY (id, collection));` +
+			`INSERT INTO db_documents VALUES ('key1', 'col1', '{}');`).Error
+		if err != nil {
+			t.Fatalf("failed to create the table: %v", err)
+		}
+		store, err := jsonstore.NewDbStore(db)
+		if err != nil {
+			t.Fatalf("NewDbStore returned an error: %v", err)
+		}
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1"}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key1", nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Last-Modified") != "" {
+			t.Errorf("expected status %d without Last-Modified, got %d %q", http.StatusOK, rec.Code, rec.Header().Get("Last-Modified"))
+		}
+	})
+
+	t.Run("store without modification times", func(t *testing.T) {
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()}, Collection: "col1"}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/key1", bytes.NewReader([]byte(`{}`))))
+		req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+		req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Last-Modified") != "" {
+			t.Errorf("expected status %d without Last-Modified, got %d %q", http.StatusOK, rec.Code, rec.Header().Get("Last-Modified"))
+		}
+	})
+}
+
+func TestHandlerPatch(t *testing.T) {
+	mockStorer := &jsonstoretest.FakeStore{
+		Data: map[string]map[string]json.RawMessage{
+			"test_collection": {"key1": json.RawMessage(`{"name":"bob","tags":["a"]}`)},
+		},
+	}
+	handler := jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: mockStorer},
+		Collection: "test_collection",
+	}
+
+	tcs := []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "merge patch", url: "/key1", contentType: "application/merge-patch+json",
+			body: `{"age":3,"tags":null}`, wantStatus: http.StatusOK, wantBody: `{"age":3,"name":"bob"}`},
+		{name: "json patch", url: "/key1", contentType: "application/json-patch+json; charset=utf-8",
+			body: `[{"op":"replace","path":"/name","value":"alice"}]`, wantStatus: http.StatusOK, wantBody: `{"age":3,"name":"alice"}`},
+		{name: "failed test", url: "/key1", contentType: "application/json-patch+json",
+			body: `[{"op":"test","path":"/name","value":"bob"}]`, wantStatus: http.StatusConflict},
+		{name: "invalid patch", url: "/key1", contentType: "application/merge-patch+json",
+			body: `{"age":`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported content type", url: "/key1", contentType: "application/json",
+			body: `{"age":4}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing document", url: "/key2", contentType: "application/merge-patch+json",
+			body: `{"age":4}`, wantStatus: http.StatusNotFound},
+		{name: "missing key", url: "/", contentType: "application/merge-patch+json",
+			body: `{"age":4}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, tc.url, bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantBody == "" {
+				return
+			}
+			if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+				t.Errorf("unexpected body (-got +want):\n%s", diff)
+			}
+		})
+	}
+	if diff := cmp.Diff(string(mockStorer.Data["test_collection"]["key1"]), `{"age":3,"name":"alice"}`); diff != "" {
+		t.Errorf("unexpected stored data (-got +want):\n%s", diff)
+	}
+}
+
 func TestHandlerDelete(t *testing.T) {
 	t.Run("Delete - successful", func(t *testing.T) {
 
-		mockStorer := &MockStorer{
+		mockStorer := &jsonstoretest.FakeStore{
 			Data: map[string]map[string]json.RawMessage{
 				"test_collection": {
 					"key1": []byte(`{"foo":"bar"}`),
@@ -267,7 +959,7 @@ func TestHandlerDelete(t *testing.T) {
 
 	t.Run("Delete - item not found", func(t *testing.T) {
 
-		mockStorer := &MockStorer{
+		mockStorer := &jsonstoretest.FakeStore{
 			Data: map[string]map[string]json.RawMessage{
 				"test_collection": {
 					"key1": []byte(`{"foo":"bar"}`),
@@ -294,14 +986,14 @@ func TestHandlerDelete(t *testing.T) {
 
 		// Check the response body for the "Item not found" message
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Item not found\n"
+		expectedError := `{"type":"urn:jsonstore:item-not-found","title":"Not Found","status":404,"detail":"failed to delete data: item not found","instance":"/key2"}`
 		if diff := cmp.Diff(string(body), expectedError); diff != "" {
 			t.Errorf("unexpected error message (-want +got):\n%s", diff)
 		}
 	})
 
 	t.Run("Delete - error during deletion", func(t *testing.T) {
-		mockStorer := &MockStorer{
+		mockStorer := &jsonstoretest.FakeStore{
 			Data: map[string]map[string]json.RawMessage{
 				"test_collection": {
 					"key1": []byte(`{"foo":"bar"}`),
@@ -329,14 +1021,14 @@ func TestHandlerDelete(t *testing.T) {
 
 		// Check the error message in the response body
 		body, _ := io.ReadAll(res.Body)
-		expectedError := "Failed to delete data: storage error\n"
+		expectedError := `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"failed to delete data: storage error","instance":"/key1"}`
 		if diff := cmp.Diff(string(body), expectedError); diff != "" {
 			t.Errorf("unexpected error message (-want +got):\n%s", diff)
 		}
 	})
 }
 func TestHandlerList(t *testing.T) {
-	mockStorer := &MockStorer{
+	mockStorer := &jsonstoretest.FakeStore{
 		Data: map[string]map[string]json.RawMessage{
 			"test_collection": {
 				"key1": []byte(`{"name":"item1"}`),
@@ -410,6 +1102,122 @@ func TestHandlerList(t *testing.T) {
 		}
 	})
 
+	t.Run("List - follow next cursor", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test-collection/?limit=1", nil)
+		rec := httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		var first jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&first); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if !first.HasNext || first.NextCursor == "" {
+			t.Fatalf("expected a next cursor in the response, got: %+v", first)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/test-collection/?cursor="+first.NextCursor, nil)
+		rec = httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		var second jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&second); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if second.Page != 2 || second.Limit != 1 || second.HasNext {
+			t.Errorf("unexpected second page: %+v", second)
+		}
+	})
+
+	t.Run("List - link header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test-collection/?limit=1&total=true", nil)
+		rec := httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		var first jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&first); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if first.PrevCursor != "" {
+			t.Errorf("expected no previous cursor on the first page, got %q", first.PrevCursor)
+		}
+		wantLink := `</test-collection/?cursor=` + first.NextCursor + `&total=true>; rel="next"`
+		if diff := cmp.Diff(rec.Header().Get("Link"), wantLink); diff != "" {
+			t.Errorf("unexpected Link header (-got +want):\n%s", diff)
+		}
+
+		// follow the link
+		req = httptest.NewRequest(http.MethodGet, strings.TrimSuffix(strings.TrimPrefix(wantLink, "<"), `>; rel="next"`), nil)
+		rec = httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		var second jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&second); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if second.Page != 2 || second.PrevCursor == "" {
+			t.Fatalf("unexpected second page: %+v", second)
+		}
+		wantLink = `</test-collection/?cursor=` + second.PrevCursor + `&total=true>; rel="prev", ` +
+			`</test-collection/?cursor=` + second.PrevCursor + `&total=true>; rel="first"`
+		if diff := cmp.Diff(rec.Header().Get("Link"), wantLink); diff != "" {
+			t.Errorf("unexpected Link header (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("List - skip total", func(t *testing.T) {
+		store := newDbStore(t)
+		for i := 1; i <= 3; i++ {
+			err := store.Set(context.Background(), "test_collection", fmt.Sprintf("key%d", i), json.RawMessage(`{}`))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		dbHandler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}}
+
+		req := httptest.NewRequest(http.MethodGet, "/test-collection/?limit=2&total=false", nil)
+		rec := httptest.NewRecorder()
+
+		dbHandler.List(rec, req, "test_collection")
+
+		var got jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if got.Total != jsonstore.UnknownTotal || !got.HasNext || len(got.Items) != 2 {
+			t.Errorf("unexpected page: %+v", got)
+		}
+	})
+
+	t.Run("List - prefix", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test-collection/?prefix=key2", nil)
+		rec := httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		var got jsonstore.Page
+		if err := json.NewDecoder(rec.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if got.Total != 1 || len(got.Items) != 1 || got.Items["key2"] == nil {
+			t.Errorf("unexpected page: %+v", got)
+		}
+	})
+
+	t.Run("List - invalid cursor", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test-collection/?cursor=invalid", nil)
+		rec := httptest.NewRecorder()
+
+		handler.List(rec, req, "test_collection")
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
 	t.Run("List - error fetching items", func(t *testing.T) {
 		mockStorer.Err = fmt.Errorf("storage error") // Simulate an error during deletion
 
@@ -428,7 +1236,7 @@ func TestHandlerList(t *testing.T) {
 
 		// Check error message
 		body := rec.Body.String()
-		expectedError := "Failed to fetch items: storage error\n"
+		expectedError := `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"failed to fetch items: storage error","instance":"/list"}`
 		if body != expectedError {
 			t.Errorf("expected error %q, got %q", expectedError, body)
 		}
@@ -436,81 +1244,67 @@ func TestHandlerList(t *testing.T) {
 
 }
 
-// BrokenReader is a reader that always returns an error to simulate read errors
-type BrokenReader struct{}
-
-func (r *BrokenReader) Read(p []byte) (n int, err error) {
-	return 0, fmt.Errorf("broken reader error")
-}
-
-type MockStorer struct {
-	Data map[string]map[string]json.RawMessage
-	Err  error
-}
+func TestHandlerPageSize(t *testing.T) {
+	ctx := context.Background()
 
-func (m *MockStorer) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
-	if m.Err != nil {
-		return m.Err
-	}
-	if m.Data == nil {
-		m.Data = make(map[string]map[string]json.RawMessage)
-	}
-	if m.Data[collection] == nil {
-		m.Data[collection] = make(map[string]json.RawMessage)
+	tcs := []struct {
+		name      string
+		storer    func(t *testing.T) jsonstore.JsonStorer
+		http      jsonstore.HttpStorer
+		query     string
+		wantLimit int
+	}{
+		{name: "default page size", query: "", wantLimit: jsonstore.DefaultPageSize},
+		{name: "configured default", http: jsonstore.HttpStorer{DefaultPageSize: 5}, query: "", wantLimit: 5},
+		{name: "limit within the maximum", query: "?limit=15", wantLimit: 15},
+		{name: "limit above the default maximum", query: "?limit=100", wantLimit: jsonstore.MaxListItems},
+		{name: "configured maximum", http: jsonstore.HttpStorer{MaxPageSize: 25}, query: "?limit=100", wantLimit: 25},
+		{name: "default above the maximum", http: jsonstore.HttpStorer{DefaultPageSize: 15, MaxPageSize: 8}, query: "", wantLimit: 8},
+		{name: "lower store maximum", storer: func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), jsonstore.DbStoreOptions{MaxListItems: 3})
+			if err != nil {
+				t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+			}
+			return store
+		}, http: jsonstore.HttpStorer{MaxPageSize: 25}, query: "?limit=10", wantLimit: 3},
 	}
-	m.Data[collection][key] = value
-	return nil
-}
 
-func (m *MockStorer) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
-	if m.Err != nil {
-		return m.Err
-	}
-	if _, ok := m.Data[collection]; ok {
-		if val, ok2 := m.Data[collection][key]; ok2 {
-			*value = val
-			return nil
-		} else {
-			return jsonstore.ItemNotFoundErr
-		}
-	} else {
-		return jsonstore.CollectionNotFoundErr
-	}
-}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var store jsonstore.JsonStorer = jsonstore.NewMemStore()
+			if tc.storer != nil {
+				store = tc.storer(t)
+			}
+			for i := 0; i < 30; i++ {
+				if err := store.Set(ctx, "col1", fmt.Sprintf("key-%02d", i), json.RawMessage(`{"n":1}`)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			tc.http.Storer = store
+			handler := jsonstore.Handler{HttpStorer: tc.http, Collection: "col1"}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tc.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
 
-func (m *MockStorer) Delete(ctx context.Context, collection, key string) (bool, error) {
-	if m.Err != nil {
-		return false, m.Err
-	}
-	if col, ok := m.Data[collection]; ok {
-		if _, ok := col[key]; ok {
-			delete(col, key)
-			return true, nil
-		}
+			var page jsonstore.Page
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if page.Limit != tc.wantLimit {
+				t.Errorf("expected limit %d, got %d", tc.wantLimit, page.Limit)
+			}
+			if len(page.Items) != tc.wantLimit {
+				t.Errorf("expected %d items, got %d", tc.wantLimit, len(page.Items))
+			}
+		})
 	}
-	return false, nil
 }
 
-func (m *MockStorer) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
-	items := make(map[string]json.RawMessage)
-	if m.Err != nil {
-		return items, 0, m.Err
-	}
-	col, exists := m.Data[collection]
-	if !exists {
-		return nil, 0, nil
-	}
-
-	count := int64(len(col))
-	start := (page - 1) * limit
-	end := start + limit
+// BrokenReader is a reader that always returns an error to simulate read errors
+type BrokenReader struct{}
 
-	i := 0
-	for k, v := range col {
-		if i >= start && i < end {
-			items[k] = v
-		}
-		i++
-	}
-	return items, count, nil
+func (r *BrokenReader) Read(p []byte) (n int, err error) {
+	return 0, fmt.Errorf("broken reader error")
 }
@@ -0,0 +1,166 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Admin endpoints of a Handler or MultiHandler, relative to the collection root:
+//
+//	GET    _collections       lists the collections of the store
+//	GET    _stats             returns the CollectionStats of the collection
+//	PUT    /                  creates the collection
+//	DELETE /                  drops the collection with all its documents
+//	DELETE /?truncate=true    deletes all the documents and keeps the collection
+//	GET    _export            downloads the documents, see ExportCollection
+//	POST   _import            uploads documents, see ImportCollection
+//
+// With Admin set the keys _collections, _stats, _export and _import are reserved for these endpoints.
+const (
+	collectionsPath = "_collections"
+	statsPath       = "_stats"
+)
+
+// serveAdmin handles the admin endpoints, it returns false if the request is not one of them
+func (h *HttpStorer) serveAdmin(w http.ResponseWriter, r *http.Request, collection, key string) bool {
+	var op Operation
+	switch {
+	case key == collectionsPath && r.Method == http.MethodGet:
+		op = OperationListCollections
+	case key == statsPath && r.Method == http.MethodGet:
+		op = OperationStats
+	case key == "" && r.Method == http.MethodPut:
+		op = OperationCreateCollection
+	case key == "" && r.Method == http.MethodDelete:
+		op = OperationDropCollection
+	case key == exportPath && r.Method == http.MethodGet:
+		op = OperationExport
+	case key == importPath && r.Method == http.MethodPost:
+		op = OperationImport
+	default:
+		return false
+	}
+	if !h.authorize(w, r, op, collection, "") {
+		return true
+	}
+	switch op {
+	case OperationListCollections:
+		h.ListCollections(w, r)
+	case OperationStats:
+		h.CollectionStats(w, r, collection)
+	case OperationCreateCollection:
+		h.CreateCollection(w, r, collection)
+	case OperationDropCollection:
+		h.DropCollection(w, r, collection)
+	case OperationExport:
+		h.ExportCollection(w, r, collection)
+	case OperationImport:
+		h.ImportCollection(w, r, collection)
+	}
+	return true
+}
+
+// ListCollections writes the names of the collections of the store as {"collections":[...]}, it requires the
+// store to implement CollectionManager
+func (h *HttpStorer) ListCollections(w http.ResponseWriter, r *http.Request) {
+	manager, ok := h.Storer.(CollectionManager)
+	if !ok {
+		h.writeError(w, r, http.StatusNotImplemented, fmt.Errorf("failed to list collections: not supported by the store"))
+		return
+	}
+	names, err := manager.Collections(r.Context())
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to list collections: %w", err))
+		return
+	}
+	body, err := json.Marshal(map[string][]string{"collections": names})
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode collections: %w", err))
+		return
+	}
+	h.writeJson(w, r, body)
+}
+
+// CollectionStats writes the CollectionStats of a collection, see GetStats
+func (h *HttpStorer) CollectionStats(w http.ResponseWriter, r *http.Request, collection string) {
+	stats, err := GetStats(r.Context(), h.Storer, collection)
+	if err != nil && !isNotFound(err) {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to compute stats: %w", err))
+		return
+	}
+	body, err := json.Marshal(stats)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode stats: %w", err))
+		return
+	}
+	h.writeJson(w, r, body)
+}
+
+// CreateCollection creates an empty collection and responds 201 Created, see CreateCollection.
+// Collection schemas are not supported: a request with a body gets a 501 Not Implemented.
+func (h *HttpStorer) CreateCollection(w http.ResponseWriter, r *http.Request, collection string) {
+	body, ok := h.readRequestBody(w, r)
+	if !ok {
+		return
+	}
+	if len(body) > 0 {
+		h.writeError(w, r, http.StatusNotImplemented, fmt.Errorf("failed to create collection: schemas are not supported"))
+		return
+	}
+	if err := CreateCollection(r.Context(), h.Storer, collection); err != nil {
+		h.writeError(w, r, adminErrStatus(err), fmt.Errorf("failed to create collection: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// DropCollection drops a collection and responds 204 No Content; stores not implementing CollectionManager have
+// all the documents of the collection deleted instead.
+// With the truncate=true query parameter the documents are deleted and the collection is kept, the response is
+// then {"deleted":<amount>}.
+func (h *HttpStorer) DropCollection(w http.ResponseWriter, r *http.Request, collection string) {
+	truncate := false
+	if param := r.URL.Query().Get("truncate"); param != "" {
+		var err error
+		if truncate, err = strconv.ParseBool(param); err != nil {
+			h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: invalid truncate parameter %q", InvalidQueryErr, param))
+			return
+		}
+	}
+	manager, ok := h.Storer.(CollectionManager)
+	if !truncate && ok {
+		if err := manager.DropCollection(r.Context(), collection); err != nil {
+			h.writeError(w, r, adminErrStatus(err), fmt.Errorf("failed to drop collection: %w", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	deleted, err := TruncateCollection(r.Context(), h.Storer, collection)
+	if err != nil && !isNotFound(err) {
+		h.writeError(w, r, adminErrStatus(err), fmt.Errorf("failed to truncate collection: %w", err))
+		return
+	}
+	if !truncate {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	body, err := json.Marshal(map[string]int{"deleted": deleted})
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+	h.writeJson(w, r, body)
+}
+
+// adminErrStatus returns the status of the errors of the admin endpoints
+func adminErrStatus(err error) int {
+	if errors.Is(err, ReadOnlyErr) {
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,97 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	newHandler := func(t *testing.T, admin bool) (*jsonstore.Handler, *jsonstore.MemStore) {
+		store := jsonstore.NewMemStore()
+		for _, collection := range []string{"users", "orders"} {
+			for key, value := range map[string]string{"a": `{"n":1}`, "b": `{"n":22}`} {
+				if err := store.Set(ctx, collection, key, json.RawMessage(value)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+		}
+		return &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "users", Admin: admin}, store
+	}
+
+	tcs := []struct {
+		name       string
+		disabled   bool
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantBody   string
+		// wantCollections are the collections left in the store after the request
+		wantCollections []string
+	}{
+		{name: "list collections", method: http.MethodGet, url: "/users/_collections", wantStatus: http.StatusOK,
+			wantBody: `{"collections":["orders","users"]}`, wantCollections: []string{"orders", "users"}},
+		{name: "stats", method: http.MethodGet, url: "/users/_stats", wantStatus: http.StatusOK,
+			wantBody:        `{"count":2,"bytes":15,"minUpdatedAt":"0001-01-01T00:00:00Z","maxUpdatedAt":"0001-01-01T00:00:00Z"}`,
+			wantCollections: []string{"orders", "users"}},
+		{name: "create", method: http.MethodPut, url: "/users/", wantStatus: http.StatusCreated, wantCollections: []string{"orders", "users"}},
+		{name: "create with schema", method: http.MethodPut, url: "/users/", body: `{"type":"object"}`,
+			wantStatus: http.StatusNotImplemented, wantCollections: []string{"orders", "users"}},
+		{name: "drop", method: http.MethodDelete, url: "/users/", wantStatus: http.StatusNoContent, wantCollections: []string{"orders"}},
+		{name: "truncate", method: http.MethodDelete, url: "/users/?truncate=true", wantStatus: http.StatusOK,
+			wantBody: `{"deleted":2}`, wantCollections: []string{"orders"}},
+		{name: "invalid truncate", method: http.MethodDelete, url: "/users/?truncate=maybe", wantStatus: http.StatusBadRequest,
+			wantCollections: []string{"orders", "users"}},
+		{name: "documents are still served", method: http.MethodGet, url: "/users/a", wantStatus: http.StatusOK,
+			wantBody: `{"n":1}`, wantCollections: []string{"orders", "users"}},
+		{name: "disabled", disabled: true, method: http.MethodGet, url: "/users/_collections", wantStatus: http.StatusNotFound,
+			wantCollections: []string{"orders", "users"}},
+		{name: "disabled drop", disabled: true, method: http.MethodDelete, url: "/users/", wantStatus: http.StatusNotFound,
+			wantCollections: []string{"orders", "users"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, store := newHandler(t, !tc.disabled)
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantBody != "" {
+				if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+			}
+			got, err := store.Collections(ctx)
+			if err != nil {
+				t.Fatalf("action: Collections,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, tc.wantCollections); diff != "" {
+				t.Errorf("unexpected collections (-got +want):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("store without collection management", func(t *testing.T) {
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: &jsonstoretest.FakeStore{}}, Collection: "users", Admin: true}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/_collections", nil))
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("expected status %d, got %d", http.StatusNotImplemented, rec.Code)
+		}
+	})
+}
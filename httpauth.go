@@ -0,0 +1,108 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// UnauthorizedErr is returned by an Authorize hook to reject a request without valid credentials with a
+// 401 Unauthorized
+var UnauthorizedErr = errors.New("unauthorized")
+
+// ForbiddenErr is returned by an Authorize hook to reject a request of a client not allowed to perform the
+// operation with a 403 Forbidden; any other error is handled the same way
+var ForbiddenErr = errors.New("forbidden")
+
+// Operation is the operation of an HTTP request passed to the Authorize hook
+type Operation string
+
+const (
+	// OperationGet reads a document, with a GET or HEAD request
+	OperationGet Operation = "get"
+	// OperationList lists the documents of the collection, including the streamed list
+	OperationList Operation = "list"
+	// OperationCreate stores a document under a generated key, a POST to the collection root
+	OperationCreate Operation = "create"
+	// OperationSet creates or updates a document, a POST
+	OperationSet Operation = "set"
+	// OperationReplace replaces an existing document, a PUT
+	OperationReplace Operation = "replace"
+	// OperationPatch partially updates a document, a PATCH
+	OperationPatch Operation = "patch"
+	// OperationDelete deletes a document
+	OperationDelete Operation = "delete"
+	// OperationWatch streams the changes of the collection, see StreamChanges
+	OperationWatch Operation = "watch"
+
+	// OperationListCollections, OperationStats, OperationCreateCollection, OperationDropCollection,
+	// OperationExport and OperationImport are the collection management endpoints enabled by Admin;
+	// a truncate is an OperationDropCollection
+	OperationListCollections  Operation = "list-collections"
+	OperationStats            Operation = "stats"
+	OperationCreateCollection Operation = "create-collection"
+	OperationDropCollection   Operation = "drop-collection"
+	OperationExport           Operation = "export"
+	OperationImport           Operation = "import"
+)
+
+// IsWrite reports whether the operation changes the documents or the collections
+func (op Operation) IsWrite() bool {
+	switch op {
+	case OperationCreate, OperationSet, OperationReplace, OperationPatch, OperationDelete,
+		OperationCreateCollection, OperationDropCollection, OperationImport:
+		return true
+	}
+	return false
+}
+
+// readOnlyMethods are the methods allowed by a ReadOnly HttpStorer, announced in the Allow header of its 405 responses
+var readOnlyMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+// requestOperation returns the operation of a request on a document, or on the collection root if key is empty;
+// false for the methods not reaching the store, like OPTIONS
+func requestOperation(method, key string) (Operation, bool) {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		if key == "" {
+			return OperationList, true
+		}
+		return OperationGet, true
+	case http.MethodPost:
+		if key == "" {
+			return OperationCreate, true
+		}
+		return OperationSet, true
+	case http.MethodPut:
+		return OperationReplace, true
+	case http.MethodPatch:
+		return OperationPatch, true
+	case http.MethodDelete:
+		return OperationDelete, true
+	}
+	return "", false
+}
+
+// authorize rejects the writes of a ReadOnly HttpStorer and calls the Authorize hook, writing the error response
+// if the request is rejected
+func (h *HttpStorer) authorize(w http.ResponseWriter, r *http.Request, op Operation, collection, key string) bool {
+	if h.ReadOnly && op.IsWrite() {
+		w.Header().Set("Allow", strings.Join(readOnlyMethods, ", "))
+		h.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed: %w", op, ReadOnlyErr))
+		return false
+	}
+	if h.Authorize == nil {
+		return true
+	}
+	err := h.Authorize(r, op, collection, key)
+	if err == nil {
+		return true
+	}
+	status := http.StatusForbidden
+	if errors.Is(err, UnauthorizedErr) {
+		status = http.StatusUnauthorized
+	}
+	h.writeError(w, r, status, fmt.Errorf("%s not authorized: %w", op, err))
+	return false
+}
@@ -0,0 +1,158 @@
+package jsonstore_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerAuthorize(t *testing.T) {
+	type call struct {
+		Op         jsonstore.Operation
+		Collection string
+		Key        string
+	}
+
+	tcs := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		user       string
+		wantStatus int
+		wantCall   call
+	}{
+		{name: "get", method: http.MethodGet, url: "/key1", user: "reader", wantStatus: http.StatusOK,
+			wantCall: call{Op: jsonstore.OperationGet, Collection: "test_collection", Key: "key1"}},
+		{name: "head", method: http.MethodHead, url: "/key1", user: "reader", wantStatus: http.StatusOK,
+			wantCall: call{Op: jsonstore.OperationGet, Collection: "test_collection", Key: "key1"}},
+		{name: "list", method: http.MethodGet, url: "/", user: "reader", wantStatus: http.StatusOK,
+			wantCall: call{Op: jsonstore.OperationList, Collection: "test_collection"}},
+		{name: "set forbidden", method: http.MethodPost, url: "/key2", body: `{}`, user: "reader", wantStatus: http.StatusForbidden,
+			wantCall: call{Op: jsonstore.OperationSet, Collection: "test_collection", Key: "key2"}},
+		{name: "set", method: http.MethodPost, url: "/key2", body: `{}`, user: "writer", wantStatus: http.StatusCreated,
+			wantCall: call{Op: jsonstore.OperationSet, Collection: "test_collection", Key: "key2"}},
+		{name: "create", method: http.MethodPost, url: "/", body: `{}`, user: "writer", wantStatus: http.StatusCreated,
+			wantCall: call{Op: jsonstore.OperationCreate, Collection: "test_collection"}},
+		{name: "replace", method: http.MethodPut, url: "/key1", body: `{}`, user: "writer", wantStatus: http.StatusNoContent,
+			wantCall: call{Op: jsonstore.OperationReplace, Collection: "test_collection", Key: "key1"}},
+		{name: "delete", method: http.MethodDelete, url: "/key1", user: "reader", wantStatus: http.StatusForbidden,
+			wantCall: call{Op: jsonstore.OperationDelete, Collection: "test_collection", Key: "key1"}},
+		{name: "anonymous", method: http.MethodGet, url: "/key1", wantStatus: http.StatusUnauthorized,
+			wantCall: call{Op: jsonstore.OperationGet, Collection: "test_collection", Key: "key1"}},
+		{name: "admin", method: http.MethodGet, url: "/_stats", user: "reader", wantStatus: http.StatusForbidden,
+			wantCall: call{Op: jsonstore.OperationStats, Collection: "test_collection"}},
+		{name: "admin allowed", method: http.MethodGet, url: "/_stats", user: "admin", wantStatus: http.StatusOK,
+			wantCall: call{Op: jsonstore.OperationStats, Collection: "test_collection"}},
+		{name: "options", method: http.MethodOptions, url: "/key1", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var got call
+			mockStorer := &jsonstoretest.FakeStore{
+				Data: map[string]map[string]json.RawMessage{
+					"test_collection": {"key1": json.RawMessage(`{"foo":"bar"}`)},
+				},
+			}
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{
+					Storer: mockStorer,
+					Authorize: func(r *http.Request, op jsonstore.Operation, collection, key string) error {
+						got = call{Op: op, Collection: collection, Key: key}
+						user := r.Header.Get("X-User")
+						switch {
+						case user == "":
+							return jsonstore.UnauthorizedErr
+						case user == "admin":
+							return nil
+						case strings.HasPrefix(string(op), "list-") || op == jsonstore.OperationStats ||
+							strings.HasSuffix(string(op), "-collection"):
+							return fmt.Errorf("%s is not an admin: %w", user, jsonstore.ForbiddenErr)
+						case user == "writer":
+							return nil
+						case op == jsonstore.OperationGet || op == jsonstore.OperationList:
+							return nil
+						}
+						return fmt.Errorf("%s is read only", user)
+					},
+				},
+				Collection: "test_collection",
+				Admin:      true,
+			}
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.user != "" {
+				req.Header.Set("X-User", tc.user)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if diff := cmp.Diff(got, tc.wantCall); diff != "" {
+				t.Errorf("unexpected Authorize call (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	tcs := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "get", method: http.MethodGet, url: "/key1", wantStatus: http.StatusOK},
+		{name: "list", method: http.MethodGet, url: "/", wantStatus: http.StatusOK},
+		{name: "head", method: http.MethodHead, url: "/key1", wantStatus: http.StatusOK},
+		{name: "stats", method: http.MethodGet, url: "/_stats", wantStatus: http.StatusOK},
+		{name: "options", method: http.MethodOptions, url: "/key1", wantStatus: http.StatusNoContent, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "post", method: http.MethodPost, url: "/key2", body: `{}`, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "create", method: http.MethodPost, url: "/", body: `{}`, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "put", method: http.MethodPut, url: "/key1", body: `{}`, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "patch", method: http.MethodPatch, url: "/key1", body: `{}`, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "delete", method: http.MethodDelete, url: "/key1", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "drop collection", method: http.MethodDelete, url: "/", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+		{name: "import", method: http.MethodPost, url: "/_import", body: `{}`, wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD, OPTIONS"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &jsonstoretest.FakeStore{Data: map[string]map[string]json.RawMessage{
+				"test_collection": {"key1": json.RawMessage(`{"a":1}`)},
+			}}
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: store, ReadOnly: true},
+				Collection: "test_collection",
+				Admin:      true,
+			}
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if diff := cmp.Diff(rec.Header().Get("Allow"), tc.wantAllow); diff != "" {
+				t.Errorf("unexpected Allow header (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(string(store.Data["test_collection"]["key1"]), `{"a":1}`); diff != "" {
+				t.Errorf("unexpected stored value (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
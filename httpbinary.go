@@ -0,0 +1,65 @@
+package jsonstore
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CborContentType is the media type of the CBOR (RFC 8949) bodies, see HttpStorer.BinaryFormats
+	CborContentType = "application/cbor"
+	// MsgpackContentType is the media type of the MessagePack bodies, see HttpStorer.BinaryFormats
+	MsgpackContentType = "application/msgpack"
+)
+
+// mediaEncoding returns the binary encoding of a media type, EncodingJson for the other media types;
+// MessagePack has no registered media type, the common ones are accepted
+func mediaEncoding(mediaType string) ValueEncoding {
+	switch mediaType {
+	case CborContentType:
+		return EncodingCBOR
+	case MsgpackContentType, "application/x-msgpack", "application/vnd.msgpack":
+		return EncodingMsgpack
+	}
+	return EncodingJson
+}
+
+// contentType returns the media type of the bodies in the encoding
+func (e ValueEncoding) contentType() string {
+	switch e {
+	case EncodingCBOR:
+		return CborContentType
+	case EncodingMsgpack:
+		return MsgpackContentType
+	}
+	return "application/json"
+}
+
+// negotiateEncoding returns the encoding of the response preferred by an Accept header, json unless CBOR or
+// MessagePack has a higher quality than json; on equal qualities the first listed wins
+func negotiateEncoding(accept string) ValueEncoding {
+	best, bestQ := EncodingJson, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		switch {
+		case mediaEncoding(mediaType).binary():
+			best, bestQ = mediaEncoding(mediaType), q
+		case mediaType == "application/json" || mediaType == "application/*" || mediaType == "*/*":
+			best, bestQ = EncodingJson, q
+		}
+	}
+	return best
+}
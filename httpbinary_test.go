@@ -0,0 +1,138 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestHandlerBinaryFormats(t *testing.T) {
+	ctx := context.Background()
+	doc := map[string]any{"name": "sensor-1", "temp": int64(21)}
+	cborDoc, err := cbor.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgpackDoc, err := msgpack.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("write", func(t *testing.T) {
+		tcs := []struct {
+			name        string
+			contentType string
+			body        []byte
+			wantStatus  int
+		}{
+			{name: "cbor", contentType: jsonstore.CborContentType, body: cborDoc, wantStatus: http.StatusCreated},
+			{name: "msgpack", contentType: jsonstore.MsgpackContentType, body: msgpackDoc, wantStatus: http.StatusCreated},
+			{name: "x-msgpack", contentType: "application/x-msgpack", body: msgpackDoc, wantStatus: http.StatusCreated},
+			{name: "json", contentType: "application/json", body: []byte(`{"name":"sensor-1","temp":21}`), wantStatus: http.StatusCreated},
+			{name: "invalid cbor", contentType: jsonstore.CborContentType, body: []byte{0xff, 0x00}, wantStatus: http.StatusBadRequest},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				store := jsonstore.NewMemStore()
+				handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store, BinaryFormats: true}, Collection: "col1"}
+				req := httptest.NewRequest(http.MethodPut, "/item1", bytes.NewReader(tc.body))
+				req.Header.Set("Content-Type", tc.contentType)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != tc.wantStatus {
+					t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+				}
+				if tc.wantStatus != http.StatusCreated {
+					return
+				}
+				var got json.RawMessage
+				if err := store.Get(ctx, "col1", "item1", &got); err != nil {
+					t.Fatalf("action: Get,  returned an error: %v", err)
+				}
+				if diff := cmp.Diff(string(got), `{"name":"sensor-1","temp":21}`); diff != "" {
+					t.Errorf("unexpected stored document (-got +want):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("read", func(t *testing.T) {
+		tcs := []struct {
+			name            string
+			accept          string
+			wantContentType string
+		}{
+			{name: "cbor", accept: jsonstore.CborContentType, wantContentType: jsonstore.CborContentType},
+			{name: "msgpack", accept: "application/vnd.msgpack", wantContentType: jsonstore.MsgpackContentType},
+			{name: "json preferred", accept: "application/json, application/cbor;q=0.5", wantContentType: "application/json"},
+			{name: "cbor preferred", accept: "application/json;q=0.5, application/cbor", wantContentType: jsonstore.CborContentType},
+			{name: "any", accept: "*/*", wantContentType: "application/json"},
+			{name: "no accept", wantContentType: "application/json"},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				store := jsonstore.NewMemStore()
+				if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"name":"sensor-1","temp":21}`)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+				handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store, BinaryFormats: true}, Collection: "col1"}
+				req := httptest.NewRequest(http.MethodGet, "/item1", nil)
+				if tc.accept != "" {
+					req.Header.Set("Accept", tc.accept)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+				}
+				if diff := cmp.Diff(rec.Header().Get("Content-Type"), tc.wantContentType); diff != "" {
+					t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+				}
+				// the documents are compared decoded, the numbers decode to different types in every format
+				var got any
+				var err error
+				switch tc.wantContentType {
+				case jsonstore.CborContentType:
+					err = cbor.Unmarshal(rec.Body.Bytes(), &got)
+				case jsonstore.MsgpackContentType:
+					err = msgpack.Unmarshal(rec.Body.Bytes(), &got)
+				default:
+					err = json.Unmarshal(rec.Body.Bytes(), &got)
+				}
+				if err != nil {
+					t.Fatalf("failed to decode the body: %v", err)
+				}
+				if diff := cmp.Diff(fmt.Sprint(got), "map[name:sensor-1 temp:21]"); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+				if rec.Header().Get("Vary") == "" {
+					t.Errorf("expected a Vary header")
+				}
+			})
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1"}
+		req := httptest.NewRequest(http.MethodGet, "/item1", nil)
+		req.Header.Set("Accept", jsonstore.CborContentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if diff := cmp.Diff(rec.Header().Get("Content-Type"), "application/json"); diff != "" {
+			t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+		}
+	})
+}
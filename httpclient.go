@@ -0,0 +1,269 @@
+package jsonstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultClientMaxConnsPerHost is the number of idle connections to the remote handler kept open by a ClientStore
+const DefaultClientMaxConnsPerHost = 16
+
+// ClientStoreOptions configures a ClientStore
+type ClientStoreOptions struct {
+	// Client sends the requests; if nil a client is created with a transport pooling up to MaxIdleConnsPerHost
+	// connections to the remote handler
+	Client *http.Client
+	// MaxIdleConnsPerHost is the size of the connection pool of the default client,
+	// DefaultClientMaxConnsPerHost if zero; it is ignored if Client is set
+	MaxIdleConnsPerHost int
+	// Header is added to every request, e.g. a static Authorization header
+	Header http.Header
+	// Authorization returns the value of the Authorization header of every request, e.g. "Bearer <token>";
+	// it is called before every attempt so that refreshed tokens are picked up
+	Authorization func(ctx context.Context) (string, error)
+	// MaxRetries is the number of times a request is retried on connection errors and on the 429, 502, 503
+	// and 504 statuses; zero disables the retries
+	MaxRetries int
+	// RetryBackoff is the first wait between the retries, doubled at every attempt, DefaultRetryBackoff if zero;
+	// a Retry-After header of the response takes precedence
+	RetryBackoff time.Duration
+}
+
+// ClientStore is a JsonStorer backed by a remote MultiHandler, it allows a service to consume the store embedded
+// by another one through the same Go interface. The documents are read and written with the HTTP API of the
+// handler: GET, PUT and DELETE on <url>/<collection>/<key> and GET on <url>/<collection>/ with the default list
+// envelope. The problems returned by the handler are mapped back to the errors of this package, e.g. a 404 on Get
+// is an ItemNotFoundErr.
+type ClientStore struct {
+	url  string
+	opts ClientStoreOptions
+}
+
+var _ JsonStorer = &ClientStore{}
+var _ PageLister = &ClientStore{}
+
+// NewClientStore returns a ClientStore for the MultiHandler served on baseURL
+func NewClientStore(baseURL string, opts ClientStoreOptions) (*ClientStore, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url: unsupported scheme %q", u.Scheme)
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.Client == nil {
+		conns := opts.MaxIdleConnsPerHost
+		if conns <= 0 {
+			conns = DefaultClientMaxConnsPerHost
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = conns
+		transport.MaxIdleConnsPerHost = conns
+		opts.Client = &http.Client{Transport: transport}
+	}
+	return &ClientStore{url: strings.TrimSuffix(u.String(), "/"), opts: opts}, nil
+}
+
+// docURL returns the url of a document, or the one of the collection if key is empty
+func (c *ClientStore) docURL(collection, key string) string {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	return c.url + "/" + url.PathEscape(collection) + "/" + url.PathEscape(key)
+}
+
+func (c *ClientStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if !json.Valid(value) {
+		return InvalidJsonErr
+	}
+	resp, err := c.do(ctx, http.MethodPut, c.docURL(collection, key), value)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to store document: %w", responseErr(resp, collection))
+	}
+	return nil
+}
+
+func (c *ClientStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	resp, err := c.do(ctx, http.MethodGet, c.docURL(collection, key), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to retrieve document: %w", responseErr(resp, collection))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value = data
+	return nil
+}
+
+func (c *ClientStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodDelete, c.docURL(collection, key), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to delete document: %w", responseErr(resp, collection))
+}
+
+func (c *ClientStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := c.ListPage(ctx, collection, ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage requests a page of the collection, the page size is capped by the MaxPageSize of the remote handler
+// instead of opts.MaxLimit; a missing collection is an empty one
+func (c *ClientStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	query := url.Values{}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	} else {
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if opts.Page > 0 {
+			query.Set("page", strconv.Itoa(opts.Page))
+		}
+	}
+	if opts.Prefix != "" {
+		query.Set("prefix", opts.Prefix)
+	}
+	if opts.SkipTotal {
+		query.Set("total", "false")
+	}
+	u := c.docURL(collection, "")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := c.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Page{}, fmt.Errorf("failed to list documents: %w", responseErr(resp, collection))
+	}
+	page := Page{}
+	if err = json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return Page{}, fmt.Errorf("failed to decode list response: %v", err)
+	}
+	if page.Items == nil {
+		page.Items = map[string]json.RawMessage{}
+	}
+	return page, nil
+}
+
+// do sends a request retrying it according to the options, the body is sent as json
+func (c *ClientStore) do(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	backoff := c.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u, body)
+		if attempt >= c.opts.MaxRetries || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := backoff
+		if resp != nil {
+			if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after >= 0 {
+				wait = time.Duration(after) * time.Second
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// send sends a single attempt of a request
+func (c *ClientStore) send(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.opts.Header {
+		req.Header[name] = values
+	}
+	if c.opts.Authorization != nil {
+		auth, err := c.opts.Authorization(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the authorization: %v", err)
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.opts.Client.Do(req)
+}
+
+// retryable reports whether a request failed with a connection error or a status worth a retry,
+// the errors of the context are not retried
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// responseErr returns the error of a failed response, the error of this package named by the type of the problem
+// if the body is a Problem of a known type; a *SchemaError if it lists the schema violations of the document
+func responseErr(resp *http.Response, collection string) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	problem := Problem{}
+	if json.Unmarshal(data, &problem) == nil && strings.HasPrefix(problem.Type, ProblemTypePrefix) {
+		if len(problem.Violations) > 0 {
+			return &SchemaError{Collection: collection, Violations: problem.Violations}
+		}
+		name := strings.TrimPrefix(problem.Type, ProblemTypePrefix)
+		for _, pt := range problemTypes {
+			if pt.name == name {
+				return fmt.Errorf("%w: %s", pt.err, problem.Detail)
+			}
+		}
+	}
+	if problem.Detail != "" {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, problem.Detail)
+	}
+	return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
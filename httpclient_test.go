@@ -0,0 +1,184 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newClientStore(t *testing.T, handler http.Handler, opts jsonstore.ClientStoreOptions) *jsonstore.ClientStore {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	store, err := jsonstore.NewClientStore(server.URL+"/api/", opts)
+	if err != nil {
+		t.Fatalf("action: NewClientStore,  returned an error: %v", err)
+	}
+	return store
+}
+
+func TestClientStore(t *testing.T) {
+	ctx := context.Background()
+	remote := jsonstore.NewMemStore()
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: remote}}))
+	store := newClientStore(t, mux, jsonstore.ClientStoreOptions{})
+
+	t.Run("set and get", func(t *testing.T) {
+		if err := store.Set(ctx, "col1", "a/b", json.RawMessage(`{"n":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		var got json.RawMessage
+		if err := remote.Get(ctx, "col1", "a/b", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if err := store.Get(ctx, "col1", "a/b", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(string(got), `{"n":1}`); diff != "" {
+			t.Errorf("unexpected value (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		var got json.RawMessage
+		if err := store.Get(ctx, "col1", "missing", &got); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got %v", err)
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		if err := store.Set(ctx, "col1", "k", json.RawMessage(`{`)); !errors.Is(err, jsonstore.InvalidJsonErr) {
+			t.Errorf("expected InvalidJsonErr, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for _, key := range []string{"k1", "k2", "k3"} {
+			if err := store.Set(ctx, "col2", key, json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(items, map[string]json.RawMessage{"k3": json.RawMessage(`{}`)}); diff != "" {
+			t.Errorf("unexpected items (-got +want):\n%s", diff)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+
+		page, err := store.ListPage(ctx, "col2", jsonstore.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		next, err := store.ListPage(ctx, "col2", jsonstore.ListOptions{Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		if len(page.Items) != 2 || len(next.Items) != 1 || next.HasNext {
+			t.Errorf("unexpected pages: %+v %+v", page, next)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "a/b")
+		if err != nil || !deleted {
+			t.Errorf("expected the document to be deleted, got %v %v", deleted, err)
+		}
+		deleted, err = store.Delete(ctx, "col1", "a/b")
+		if err != nil || deleted {
+			t.Errorf("expected nothing to be deleted, got %v %v", deleted, err)
+		}
+	})
+}
+
+func TestClientStoreRemoteErrors(t *testing.T) {
+	ctx := context.Background()
+	validator, err := jsonstore.NewSchemaValidator(map[string]json.RawMessage{
+		"people": json.RawMessage(`{"type":"object","required":["name"]}`),
+	})
+	if err != nil {
+		t.Fatalf("action: NewSchemaValidator,  returned an error: %v", err)
+	}
+	store := newClientStore(t, http.StripPrefix("/api", &jsonstore.MultiHandler{
+		HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore(), Schemas: validator},
+	}), jsonstore.ClientStoreOptions{})
+
+	err = store.Set(ctx, "people", "p1", json.RawMessage(`{}`))
+	var schemaErr *jsonstore.SchemaError
+	if !errors.As(err, &schemaErr) || !errors.Is(err, jsonstore.SchemaViolationErr) {
+		t.Fatalf("expected a SchemaError, got %v", err)
+	}
+	if schemaErr.Collection != "people" || len(schemaErr.Violations) != 1 {
+		t.Errorf("unexpected schema error: %+v", schemaErr)
+	}
+	if err = store.Set(ctx, "_invalid", "p1", json.RawMessage(`{}`)); err == nil {
+		t.Errorf("expected an error on an invalid collection")
+	}
+}
+
+func TestClientStoreRetries(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	remote := &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()}}
+	flaky := http.StripPrefix("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Tenant") != "t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		remote.ServeHTTP(w, r)
+	}))
+
+	tcs := []struct {
+		name       string
+		maxRetries int
+		wantErr    bool
+	}{
+		{name: "retried", maxRetries: 2},
+		{name: "too few retries", maxRetries: 1, wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			calls.Store(0)
+			store := newClientStore(t, flaky, jsonstore.ClientStoreOptions{
+				Header:        http.Header{"X-Tenant": []string{"t1"}},
+				Authorization: func(ctx context.Context) (string, error) { return "Bearer token", nil },
+				MaxRetries:    tc.maxRetries,
+			})
+			err := store.Set(ctx, "col1", "k1", json.RawMessage(`{}`))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("expected 3 attempts, got %d", got)
+			}
+		})
+	}
+
+	t.Run("missing authorization", func(t *testing.T) {
+		store := newClientStore(t, flaky, jsonstore.ClientStoreOptions{MaxRetries: 3})
+		var got json.RawMessage
+		if err := store.Get(ctx, "col1", "k1", &got); err == nil {
+			t.Errorf("expected an error without authorization")
+		}
+	})
+}
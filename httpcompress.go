@@ -0,0 +1,121 @@
+package jsonstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressMinSize is the size of the smallest response compressed by an HttpStorer without MinSize
+const DefaultCompressMinSize = 1 << 10 // 1 KiB
+
+// CompressionOptions configures the compression of the json responses of the HttpStorer (GET, HEAD and PATCH),
+// applied when the client lists a supported encoding in Accept-Encoding
+type CompressionOptions struct {
+	// Disabled sends the responses uncompressed regardless of Accept-Encoding
+	Disabled bool
+	// MinSize is the size in bytes of the smallest response worth compressing, DefaultCompressMinSize if 0
+	MinSize int
+	// Zstd offers zstd besides gzip, preferred by the clients accepting both with the same quality
+	Zstd bool
+}
+
+// encoding selects the content coding of a response from the Accept-Encoding header, empty for none
+func (c CompressionOptions) encoding(acceptEncoding string) string {
+	if c.Disabled || acceptEncoding == "" {
+		return ""
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		switch {
+		case name == "zstd" && c.Zstd && q >= bestQ:
+			best, bestQ = "zstd", q
+		case name == "gzip" && q > bestQ:
+			best, bestQ = "gzip", q
+		}
+	}
+	return best
+}
+
+// compress encodes body with the encoding returned by encoding
+func compress(body []byte, encoding string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	switch encoding {
+	case "gzip":
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJson writes a 200 OK json response with its Content-Length and ETag, compressed according to the
+// Compression options; the body is omitted for HEAD requests so that clients can check the existence and the
+// version of a resource without downloading it. The ETag is the one of the uncompressed json body, so that it can
+// be used in If-Match regardless of the encoding and of the format negotiated with BinaryFormats.
+func (h *HttpStorer) writeJson(w http.ResponseWriter, r *http.Request, body []byte) {
+	header := w.Header()
+	header.Set("Content-Type", "application/json")
+	header.Set("ETag", etag(body))
+	if h.BinaryFormats {
+		header.Add("Vary", "Accept")
+		if encoding := negotiateEncoding(r.Header.Get("Accept")); encoding.binary() {
+			if encoded, err := encoding.encode(body); err == nil {
+				header.Set("Content-Type", encoding.contentType())
+				body = encoded
+			}
+		}
+	}
+	if !h.Compression.Disabled {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	minSize := h.Compression.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+	if encoding := h.Compression.encoding(r.Header.Get("Accept-Encoding")); encoding != "" && len(body) >= minSize {
+		compressed, err := compress(body, encoding)
+		if err == nil {
+			header.Set("Content-Encoding", encoding)
+			body = compressed
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(body)
+}
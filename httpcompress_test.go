@@ -0,0 +1,107 @@
+package jsonstore_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestHandlerCompression(t *testing.T) {
+	large := json.RawMessage(fmt.Sprintf(`{"text":%q}`, strings.Repeat("compressible ", 200)))
+	data := map[string]map[string]json.RawMessage{
+		"test_collection": {"large": large, "small": json.RawMessage(`{"a":1}`)},
+	}
+
+	tcs := []struct {
+		name           string
+		compression    jsonstore.CompressionOptions
+		method         string
+		url            string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{name: "gzip", url: "/large", acceptEncoding: "gzip, deflate", wantEncoding: "gzip"},
+		{name: "gzip list", url: "/", acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{name: "gzip head", method: http.MethodHead, url: "/large", acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{name: "no accept encoding", url: "/large"},
+		{name: "unsupported encoding", url: "/large", acceptEncoding: "br"},
+		{name: "refused gzip", url: "/large", acceptEncoding: "gzip;q=0"},
+		{name: "small response", url: "/small", acceptEncoding: "gzip"},
+		{name: "disabled", compression: jsonstore.CompressionOptions{Disabled: true}, url: "/large", acceptEncoding: "gzip"},
+		{name: "zstd not enabled", url: "/large", acceptEncoding: "zstd, gzip", wantEncoding: "gzip"},
+		{name: "zstd", compression: jsonstore.CompressionOptions{Zstd: true}, url: "/large", acceptEncoding: "gzip, zstd", wantEncoding: "zstd"},
+		{name: "gzip preferred", compression: jsonstore.CompressionOptions{Zstd: true}, url: "/large", acceptEncoding: "gzip, zstd;q=0.5", wantEncoding: "gzip"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: &jsonstoretest.FakeStore{Data: data}, Compression: tc.compression},
+				Collection: "test_collection",
+			}
+			plain := httptest.NewRecorder()
+			handler.ServeHTTP(plain, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			method := http.MethodGet
+			if tc.method != "" {
+				method = tc.method
+			}
+			req := httptest.NewRequest(method, tc.url, nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if diff := cmp.Diff(rec.Header().Get("Content-Encoding"), tc.wantEncoding); diff != "" {
+				t.Errorf("unexpected Content-Encoding (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(rec.Header().Get("ETag"), plain.Header().Get("ETag")); diff != "" {
+				t.Errorf("unexpected ETag (-got +want):\n%s", diff)
+			}
+			if method == http.MethodHead {
+				return
+			}
+			if diff := cmp.Diff(rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len())); diff != "" {
+				t.Errorf("unexpected Content-Length (-got +want):\n%s", diff)
+			}
+
+			var body io.Reader = rec.Body
+			switch tc.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("invalid gzip body: %v", err)
+				}
+				body = zr
+			case "zstd":
+				zr, err := zstd.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("invalid zstd body: %v", err)
+				}
+				defer zr.Close()
+				body = zr
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("unable to decode body: %v", err)
+			}
+			if diff := cmp.Diff(string(got), plain.Body.String()); diff != "" {
+				t.Errorf("unexpected body (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package jsonstore
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handlerMethods are the methods served by the Handler, announced in the Allow header of OPTIONS responses
+var handlerMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	http.MethodOptions,
+}
+
+// CorsOptions configures the Cross-Origin Resource Sharing headers of the Handler, so that browser applications
+// served from another origin can call the store without a proxy
+type CorsOptions struct {
+	// AllowedOrigins are the origins allowed to call the handler, e.g. "https://app.example.com";
+	// "*" allows any origin. Requests from other origins get no CORS headers and are blocked by the browser.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in cross-origin requests, the methods of the Handler if empty
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in cross-origin requests, if empty the headers requested
+	// by the preflight request are allowed
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers readable by the browser application besides the safelisted ones,
+	// ETag and Location if empty
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies or HTTP authentication from the origins listed in
+	// AllowedOrigins; it is ignored for the origins only allowed by "*", which get no credentials
+	AllowCredentials bool
+	// MaxAge is how long the browser can cache the response of a preflight request, not sent if 0
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for origin, empty if it is not allowed,
+// and whether credentials are allowed; they are only allowed for the origins listed explicitly, the wildcard would
+// let any site make credentialed requests
+func (c *CorsOptions) allowOrigin(origin string) (string, bool) {
+	wildcard := false
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			wildcard = true
+			continue
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, c.AllowCredentials
+		}
+	}
+	if wildcard {
+		return "*", false
+	}
+	return "", false
+}
+
+// handle writes the CORS headers of a request, it returns true if the request was a preflight request and the
+// response is complete
+func (c *CorsOptions) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	requestMethod := r.Header.Get("Access-Control-Request-Method")
+	preflight := r.Method == http.MethodOptions && requestMethod != ""
+	if origin == "" {
+		return false
+	}
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	allowOrigin, credentials := c.allowOrigin(origin)
+	if allowOrigin == "" {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return preflight
+	}
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		exposed := c.ExposedHeaders
+		if len(exposed) == 0 {
+			exposed = []string{"ETag", "Location"}
+		}
+		header.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+		return false
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = handlerMethods
+	}
+	if !slices.Contains(methods, requestMethod) {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(c.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,135 @@
+package jsonstore_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerCors(t *testing.T) {
+	newHandler := func(cors *jsonstore.CorsOptions) *jsonstore.Handler {
+		return &jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: &jsonstoretest.FakeStore{
+				Data: map[string]map[string]json.RawMessage{"test_collection": {"key1": json.RawMessage(`{}`)}},
+			}},
+			Collection: "test_collection",
+			Cors:       cors,
+		}
+	}
+
+	tcs := []struct {
+		name        string
+		cors        *jsonstore.CorsOptions
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "preflight",
+			cors:       &jsonstore.CorsOptions{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: time.Hour},
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "Content-Type"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "3600",
+			},
+		},
+		{
+			name:        "preflight with a method not allowed",
+			cors:        &jsonstore.CorsOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:        "preflight from an origin not allowed",
+			cors:        &jsonstore.CorsOptions{AllowedOrigins: []string{"https://app.example.com"}},
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "simple request",
+			cors:       &jsonstore.CorsOptions{AllowedOrigins: []string{"*"}},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "ETag, Location",
+			},
+		},
+		{
+			name:       "simple request with credentials",
+			cors:       &jsonstore.CorsOptions{AllowedOrigins: []string{"*", "https://app.example.com"}, AllowCredentials: true},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:       "wildcard origin without credentials",
+			cors:       &jsonstore.CorsOptions{AllowedOrigins: []string{"*", "https://app.example.com"}, AllowCredentials: true},
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://evil.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:       "preflight of a wildcard origin without credentials",
+			cors:       &jsonstore.CorsOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:     http.MethodOptions,
+			headers:    map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:        "options without cors",
+			method:      http.MethodOptions,
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Allow": "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/key1", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			newHandler(tc.cors).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			for k, want := range tc.wantHeaders {
+				if diff := cmp.Diff(rec.Header().Get(k), want); diff != "" {
+					t.Errorf("unexpected %s header (-got +want):\n%s", k, diff)
+				}
+			}
+		})
+	}
+}
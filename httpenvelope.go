@@ -0,0 +1,131 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ListShaper builds the body of a list response out of the Page of the request, it is marshalled to JSON.
+// The default envelope is the Page itself: {"items":{"<key>":<doc>},"total":..,"page":..,"limit":..}.
+type ListShaper func(r *http.Request, collection string, page Page) (any, error)
+
+// itemOrder returns the keys of the items of a page in the order of the page: the order of the Sort of a query,
+// otherwise the order of the keys
+func itemOrder(page Page) []string {
+	if len(page.Keys) > 0 {
+		return page.Keys
+	}
+	keys := make([]string, 0, len(page.Items))
+	for key := range page.Items {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// withID adds the key to a document as the field idField, replacing a field with the same name;
+// a document that is not an object is put in the field "value"
+func withID(idField, key string, doc json.RawMessage) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if trimmed := bytes.TrimSpace(doc); len(trimmed) == 0 || trimmed[0] != '{' {
+		fields["value"] = doc
+	} else if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode document %s: %v", key, err)
+	}
+	id, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	fields[idField] = id
+	return json.Marshal(fields)
+}
+
+// arrayPage is the list response of ArrayEnvelope
+type arrayPage struct {
+	Items      []json.RawMessage `json:"items"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+	HasNext    bool              `json:"hasNext"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	PrevCursor string            `json:"prevCursor,omitempty"`
+}
+
+// ArrayEnvelope returns a ListShaper with the items as an array of documents, in the order of the page, holding their
+// key in the field idField ("id" if empty); the pagination metadata is the one of the default envelope:
+//
+//	{"items":[{"id":"<key>",...<doc>}],"total":..,"page":..,"limit":..,"hasNext":..}
+func ArrayEnvelope(idField string) ListShaper {
+	if idField == "" {
+		idField = "id"
+	}
+	return func(r *http.Request, collection string, page Page) (any, error) {
+		out := arrayPage{
+			Items:      make([]json.RawMessage, 0, len(page.Items)),
+			Total:      page.Total,
+			Page:       page.Page,
+			Limit:      page.Limit,
+			HasNext:    page.HasNext,
+			NextCursor: page.NextCursor,
+			PrevCursor: page.PrevCursor,
+		}
+		for _, key := range itemOrder(page) {
+			item, err := withID(idField, key, page.Items[key])
+			if err != nil {
+				return nil, err
+			}
+			out.Items = append(out.Items, item)
+		}
+		return out, nil
+	}
+}
+
+// jsonApiResource is a resource object of a JSON:API document
+type jsonApiResource struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id"`
+	Attributes json.RawMessage `json:"attributes"`
+}
+
+// jsonApiPage is the list response of JsonApiEnvelope
+type jsonApiPage struct {
+	Data  []jsonApiResource `json:"data"`
+	Meta  jsonApiMeta       `json:"meta"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+type jsonApiMeta struct {
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+}
+
+// JsonApiEnvelope is a ListShaper following JSON:API (https://jsonapi.org): the documents are the attributes of
+// resources of the type of the collection, the pagination is in meta and the links to the next and previous pages
+// in links
+//
+//	{"data":[{"type":"<collection>","id":"<key>","attributes":<doc>}],"meta":{"total":..,"page":..,"limit":..},
+//	 "links":{"next":"..."}}
+func JsonApiEnvelope(r *http.Request, collection string, page Page) (any, error) {
+	out := jsonApiPage{
+		Data: make([]jsonApiResource, 0, len(page.Items)),
+		Meta: jsonApiMeta{Total: page.Total, Page: page.Page, Limit: page.Limit},
+	}
+	for _, key := range itemOrder(page) {
+		out.Data = append(out.Data, jsonApiResource{Type: collection, ID: key, Attributes: page.Items[key]})
+	}
+	if page.NextCursor != "" || page.PrevCursor != "" {
+		out.Links = map[string]string{}
+	}
+	if page.NextCursor != "" {
+		out.Links["next"] = cursorURL(r, page.NextCursor)
+	}
+	if page.PrevCursor != "" {
+		out.Links["prev"] = cursorURL(r, page.PrevCursor)
+		out.Links["first"] = cursorURL(r, encodeCursor(1, page.Limit))
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerListEnvelope(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := []struct {
+		name       string
+		envelope   jsonstore.ListShaper
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "array of documents",
+			envelope:   jsonstore.ArrayEnvelope(""),
+			url:        "/?limit=2",
+			wantStatus: http.StatusOK,
+			wantBody: `{"items":[{"id":"a","n":1},{"id":"b","value":"text"}],"total":3,"page":1,"limit":2,` +
+				`"hasNext":true,"nextCursor":"eyJwIjoyLCJsIjoyfQ"}` + "\n",
+		},
+		{
+			name:       "array with a custom id field in the sort order",
+			envelope:   jsonstore.ArrayEnvelope("_key"),
+			url:        "/?sort=-n",
+			wantStatus: http.StatusOK,
+			wantBody: `{"items":[{"_key":"c","id":"x","n":3},{"_key":"a","n":1},{"_key":"b","value":"text"}],` +
+				`"total":3,"page":1,"limit":10,"hasNext":false}` + "\n",
+		},
+		{
+			name:       "json api",
+			envelope:   jsonstore.JsonApiEnvelope,
+			url:        "/?limit=1&page=2",
+			wantStatus: http.StatusOK,
+			wantBody: `{"data":[{"type":"col1","id":"b","attributes":"text"}],"meta":{"total":3,"page":2,"limit":1},` +
+				`"links":{"first":"/?cursor=eyJwIjoxLCJsIjoxfQ","next":"/?cursor=eyJwIjozLCJsIjoxfQ",` +
+				`"prev":"/?cursor=eyJwIjoxLCJsIjoxfQ"}}` + "\n",
+		},
+		{
+			name: "failing envelope",
+			envelope: func(r *http.Request, collection string, page jsonstore.Page) (any, error) {
+				return nil, errors.New("envelope failed")
+			},
+			url:        "/",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			docs := map[string]string{"a": `{"n":1}`, "b": `"text"`, "c": `{"n":3,"id":"x"}`}
+			for key, doc := range docs {
+				if err := store.Set(ctx, "col1", key, json.RawMessage(doc)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: store, ListEnvelope: tc.envelope},
+				Collection: "col1",
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantBody != "" {
+				if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+			}
+		})
+	}
+}
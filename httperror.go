@@ -0,0 +1,132 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// missingKeyErr is returned to requests that need a key but were sent to the collection root
+var missingKeyErr = errors.New("missing key")
+
+// bodyTooLargeErr is returned to requests with a body larger than the MaxBodySize of the HttpStorer
+var bodyTooLargeErr = errors.New("request body too large")
+
+// unsupportedMediaTypeErr is returned to write requests with a Content-Type not accepted by the HttpStorer
+var unsupportedMediaTypeErr = errors.New("unsupported content type")
+
+// invalidCollectionErr is returned by the MultiHandler to requests on a collection name it does not accept
+var invalidCollectionErr = errors.New("invalid collection name")
+
+// unsupportedOperationErr is returned to live sync messages with an operation that cannot be sent over the socket
+var unsupportedOperationErr = errors.New("unsupported operation")
+
+// ProblemTypePrefix is the prefix of the type of the problems caused by the errors of this package,
+// e.g. "urn:jsonstore:item-not-found"
+const ProblemTypePrefix = "urn:jsonstore:"
+
+// Problem is the body of the error responses of the HttpStorer, a problem details object as described by
+// RFC 7807, sent with the application/problem+json content type
+type Problem struct {
+	// Type identifies the kind of problem, "about:blank" if it is described by the status alone
+	Type string `json:"type"`
+	// Title is a short summary of the kind of problem
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance identifies this occurrence of the problem, e.g. the path of the request
+	Instance string `json:"instance,omitempty"`
+	// Violations are the reasons of a SchemaViolationErr, an extension member of the problem
+	Violations []SchemaViolation `json:"violations,omitempty"`
+}
+
+// ErrorMapper converts the error of a request into the problem sent to the client, status is the one chosen by the
+// HttpStorer; the status of the returned problem is the status of the response
+type ErrorMapper func(r *http.Request, status int, err error) Problem
+
+// problemTypes are the types of the problems caused by the known errors, checked in order with errors.Is
+var problemTypes = []struct {
+	err  error
+	name string
+}{
+	{ItemNotFoundErr, "item-not-found"},
+	{CollectionNotFoundErr, "collection-not-found"},
+	{InvalidJsonErr, "invalid-json"},
+	{InvalidCursorErr, "invalid-cursor"},
+	{InvalidQueryErr, "invalid-query"},
+	{InvalidPatchErr, "invalid-patch"},
+	{PatchConflictErr, "patch-conflict"},
+	{ImportConflictErr, "import-conflict"},
+	{VersionConflictErr, "version-conflict"},
+	{ReadOnlyErr, "read-only"},
+	{SchemaViolationErr, "schema-violation"},
+	{UnauthorizedErr, "unauthorized"},
+	{ForbiddenErr, "forbidden"},
+	{missingKeyErr, "missing-key"},
+	{bodyTooLargeErr, "body-too-large"},
+	{unsupportedMediaTypeErr, "unsupported-media-type"},
+	{invalidCollectionErr, "invalid-collection"},
+	{unsupportedOperationErr, "unsupported-operation"},
+	{idempotencyKeyReusedErr, "idempotency-key-reused"},
+	{idempotencyInProgressErr, "idempotency-in-progress"},
+	{invalidIdempotencyKeyErr, "invalid-idempotency-key"},
+}
+
+// DefaultErrorMapper is the ErrorMapper used if the HttpStorer has none: the type identifies the errors of this
+// package with ProblemTypePrefix, the title is the status text and the detail the error message
+func DefaultErrorMapper(r *http.Request, status int, err error) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.EscapedPath(),
+	}
+	if err == nil {
+		return p
+	}
+	p.Detail = err.Error()
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		p.Violations = schemaErr.Violations
+	}
+	for _, t := range problemTypes {
+		if errors.Is(err, t.err) {
+			p.Type = ProblemTypePrefix + t.name
+			break
+		}
+	}
+	return p
+}
+
+// problem converts err into a Problem with the ErrorMapper of the HttpStorer
+func (h *HttpStorer) problem(r *http.Request, status int, err error) Problem {
+	mapper := h.ErrorMapper
+	if mapper == nil {
+		mapper = DefaultErrorMapper
+	}
+	p := mapper(r, status, err)
+	if p.Status == 0 {
+		p.Status = status
+	}
+	return p
+}
+
+// writeError writes the problem of err as the response
+func (h *HttpStorer) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	p := h.problem(r, status, err)
+	body, marshalErr := json.Marshal(p)
+	if marshalErr != nil {
+		http.Error(w, http.StatusText(p.Status), p.Status)
+		return
+	}
+	header := w.Header()
+	// headers set for a successful response do not apply to the error
+	header.Del("ETag")
+	header.Set("Content-Type", "application/problem+json")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,107 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerProblems(t *testing.T) {
+	newHandler := func(mapper jsonstore.ErrorMapper) *jsonstore.Handler {
+		return &jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{
+				Storer:       &jsonstoretest.FakeStore{Data: map[string]map[string]json.RawMessage{"test_collection": {"key1": json.RawMessage(`{}`)}}},
+				ValidateJson: true,
+				ErrorMapper:  mapper,
+			},
+			Collection: "test_collection",
+		}
+	}
+
+	tcs := []struct {
+		name   string
+		mapper jsonstore.ErrorMapper
+		method string
+		url    string
+		header map[string]string
+		body   string
+		want   jsonstore.Problem
+	}{
+		{
+			name:   "invalid json",
+			method: http.MethodPost,
+			url:    "/key1",
+			body:   `{"foo":`,
+			want: jsonstore.Problem{Type: "urn:jsonstore:invalid-json", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "failed to store data: invalid json", Instance: "/key1"},
+		},
+		{
+			name:   "failed precondition",
+			method: http.MethodDelete,
+			url:    "/key1",
+			header: map[string]string{"If-Match": `"stale"`},
+			want: jsonstore.Problem{Type: "urn:jsonstore:version-conflict", Title: "Precondition Failed", Status: http.StatusPreconditionFailed,
+				Detail: "version conflict: the document does not match If-Match", Instance: "/key1"},
+		},
+		{
+			name:   "missing key",
+			method: http.MethodPut,
+			url:    "/",
+			body:   `{}`,
+			want: jsonstore.Problem{Type: "urn:jsonstore:missing-key", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "failed to store data: missing key", Instance: "/"},
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodTrace,
+			url:    "/key1",
+			want: jsonstore.Problem{Type: "about:blank", Title: "Method Not Allowed", Status: http.StatusMethodNotAllowed,
+				Detail: "method TRACE not allowed", Instance: "/key1"},
+		},
+		{
+			name: "custom mapper",
+			mapper: func(r *http.Request, status int, err error) jsonstore.Problem {
+				if errors.Is(err, jsonstore.ItemNotFoundErr) {
+					return jsonstore.Problem{Type: "https://example.com/problems/gone", Title: "Gone", Status: http.StatusGone}
+				}
+				return jsonstore.DefaultErrorMapper(r, status, err)
+			},
+			method: http.MethodGet,
+			url:    "/key2",
+			want:   jsonstore.Problem{Type: "https://example.com/problems/gone", Title: "Gone", Status: http.StatusGone},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewReader([]byte(tc.body)))
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			newHandler(tc.mapper).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want.Status {
+				t.Errorf("expected status %d, got %d", tc.want.Status, rec.Code)
+			}
+			if diff := cmp.Diff(rec.Header().Get("Content-Type"), "application/problem+json"); diff != "" {
+				t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+			}
+			got := jsonstore.Problem{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid problem %q: %v", rec.Body.String(), err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("unexpected problem (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// exportPath and importPath are the keys of the export and import endpoints, reserved when Admin is set
+const (
+	exportPath = "_export"
+	importPath = "_import"
+)
+
+// exportWriter sends the headers of an export response with its first bytes, so that an error before them still
+// gets an error response
+type exportWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		header := e.w.Header()
+		header.Set("Content-Type", e.contentType)
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+		e.w.WriteHeader(http.StatusOK)
+	}
+	return e.w.Write(p)
+}
+
+// ExportCollection downloads all the documents of the collection as an attachment, see Export; the format query
+// parameter selects the format, ndjson (the default) or json.
+// An error after the first bytes aborts the response, so that the client does not mistake the truncated dump for
+// a complete one.
+func (h *HttpStorer) ExportCollection(w http.ResponseWriter, r *http.Request, collection string) {
+	format := ExportFormat(r.URL.Query().Get("format"))
+	ew := &exportWriter{w: w}
+	switch format {
+	case ExportNdjson, "":
+		format = ExportNdjson
+		ew.contentType = NdjsonContentType
+	case ExportJson:
+		ew.contentType = "application/json"
+	default:
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: unknown format %q", InvalidQueryErr, format))
+		return
+	}
+	ew.filename = collection + "." + string(format)
+
+	err := Export(r.Context(), h.Storer, collection, ew, format)
+	if err != nil && !isNotFound(err) {
+		if !ew.started {
+			h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to export collection: %w", err))
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if !ew.started {
+		// an empty collection
+		_, _ = ew.Write(nil)
+	}
+}
+
+// ImportCollection stores the documents uploaded in the request body, see Import, and responds with the
+// ImportResult. The body is NDJSON if its Content-Type is NdjsonContentType or with format=ndjson, json otherwise;
+// the conflict query parameter is the ConflictPolicy. The upload is streamed and not limited by MaxBodySize.
+// With ConflictFail an existing document stops the import with a 409 Conflict, and a document violating the schema
+// of the collection, see HttpStorer.Schemas, with a 422 Unprocessable Entity.
+func (h *HttpStorer) ImportCollection(w http.ResponseWriter, r *http.Request, collection string) {
+	defer r.Body.Close()
+	query := r.URL.Query()
+	format := ExportFormat(query.Get("format"))
+	if format == "" {
+		format = ExportJson
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == NdjsonContentType {
+			format = ExportNdjson
+		}
+	}
+	if format != ExportJson && format != ExportNdjson {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: unknown format %q", InvalidQueryErr, format))
+		return
+	}
+	policy := ConflictPolicy(query.Get("conflict"))
+	switch policy {
+	case "", ConflictOverwrite, ConflictSkip, ConflictFail:
+	default:
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: unknown conflict policy %q", InvalidQueryErr, policy))
+		return
+	}
+
+	result, err := importDocuments(r.Context(), h.Storer, collection, r.Body, format, policy, h.schemaCheck(collection))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, SchemaViolationErr):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, InvalidJsonErr):
+			status = http.StatusBadRequest
+		case errors.Is(err, ImportConflictErr):
+			status = http.StatusConflict
+		case errors.Is(err, ReadOnlyErr):
+			status = http.StatusMethodNotAllowed
+		}
+		h.writeError(w, r, status, fmt.Errorf("failed to import collection after %d created, %d updated and %d skipped documents: %w",
+			result.Created, result.Updated, result.Skipped, err))
+		return
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+	h.writeJson(w, r, body)
+}
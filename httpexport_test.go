@@ -0,0 +1,85 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := []struct {
+		name            string
+		method          string
+		url             string
+		contentType     string
+		body            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "export ndjson", method: http.MethodGet, url: "/_export", wantStatus: http.StatusOK, wantContentType: jsonstore.NdjsonContentType,
+			wantBody: "{\"key\":\"a\",\"value\":{\"n\":1}}\n"},
+		{name: "export json", method: http.MethodGet, url: "/_export?format=json", wantStatus: http.StatusOK, wantContentType: "application/json",
+			wantBody: "{\"a\":{\"n\":1}}\n"},
+		{name: "export unknown format", method: http.MethodGet, url: "/_export?format=xml", wantStatus: http.StatusBadRequest},
+		{name: "import json", method: http.MethodPost, url: "/_import", contentType: "application/json", body: `{"a":{"n":2},"b":{"n":3}}`,
+			wantStatus: http.StatusOK, wantBody: `{"created":1,"updated":1,"skipped":0}`},
+		{name: "import ndjson", method: http.MethodPost, url: "/_import?conflict=skip", contentType: jsonstore.NdjsonContentType,
+			body: "{\"key\":\"a\",\"value\":{\"n\":2}}\n", wantStatus: http.StatusOK, wantBody: `{"created":0,"updated":0,"skipped":1}`},
+		{name: "import conflict", method: http.MethodPost, url: "/_import?conflict=fail", body: `{"a":{"n":2}}`, wantStatus: http.StatusConflict},
+		{name: "import invalid", method: http.MethodPost, url: "/_import", body: `{"a":`, wantStatus: http.StatusBadRequest},
+		{name: "import unknown policy", method: http.MethodPost, url: "/_import?conflict=merge", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			if err := store.Set(ctx, "col1", "a", json.RawMessage(`{"n":1}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1", Admin: true}
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantContentType != "" {
+				if diff := cmp.Diff(rec.Header().Get("Content-Type"), tc.wantContentType); diff != "" {
+					t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+				}
+				if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+					t.Errorf("expected an attachment, got %q", rec.Header().Get("Content-Disposition"))
+				}
+			}
+			if tc.wantBody != "" {
+				if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+			}
+		})
+	}
+
+	t.Run("export storage error", func(t *testing.T) {
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: &jsonstoretest.FakeStore{Err: context.DeadlineExceeded}}, Collection: "col1", Admin: true}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_export", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
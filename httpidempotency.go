@@ -0,0 +1,230 @@
+package jsonstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header identifying the retries of a write, see IdempotencyOptions
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyCollection is the reserved collection of the idempotency records if none is configured
+const DefaultIdempotencyCollection = "_idempotency"
+
+// DefaultIdempotencyTTL is how long the idempotency records are kept if no TTL is configured
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLen is the maximum length of an Idempotency-Key header
+const maxIdempotencyKeyLen = 255
+
+var (
+	// idempotencyKeyReusedErr is returned for a request reusing the key of a different request
+	idempotencyKeyReusedErr = errors.New("idempotency key reused for a different request")
+	// idempotencyInProgressErr is returned for a retry arriving while the original request is still processed
+	idempotencyInProgressErr = errors.New("a request with the same idempotency key is in progress")
+	// invalidIdempotencyKeyErr is returned for an Idempotency-Key header that is too long
+	invalidIdempotencyKeyErr = errors.New("invalid idempotency key")
+)
+
+// IdempotencyOptions configures the handling of the Idempotency-Key header of the POST and PUT requests: the first
+// successful response to a key is recorded, and the retries with the same key and the same request get that response
+// again, with an Idempotent-Replayed: true header, without writing the document again. It keeps clients retrying
+// after a lost response, e.g. on a flaky mobile connection, from creating duplicate documents.
+//
+// The records are stored in Collection of the same store, keyed by the collection of the request and the
+// Idempotency-Key. A key reused for a different request gets a 422 Unprocessable Entity, and a retry arriving while
+// the original request is still processed by this HttpStorer a 409 Conflict. Failed requests are not recorded and
+// can be retried.
+type IdempotencyOptions struct {
+	// Collection holds the idempotency records, DefaultIdempotencyCollection if empty
+	Collection string
+	// TTL is how long a record is replayed, DefaultIdempotencyTTL if zero; expired records are replaced on the next
+	// use of the key, see Purge to delete them
+	TTL time.Duration
+
+	mutex    sync.Mutex
+	inFlight map[string]bool
+}
+
+// idempotencyRecord is the response recorded for an Idempotency-Key
+type idempotencyRecord struct {
+	// Fingerprint is a hash of the request, to detect a key reused for a different request
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
+}
+
+// recordedHeaders are the response headers replayed with the recorded response
+var recordedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+func (o *IdempotencyOptions) collection() string {
+	if o.Collection == "" {
+		return DefaultIdempotencyCollection
+	}
+	return o.Collection
+}
+
+func (o *IdempotencyOptions) ttl() time.Duration {
+	if o.TTL == 0 {
+		return DefaultIdempotencyTTL
+	}
+	return o.TTL
+}
+
+// acquire marks the record key as being processed, it returns false if it already is
+func (o *IdempotencyOptions) acquire(recordKey string) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.inFlight[recordKey] {
+		return false
+	}
+	if o.inFlight == nil {
+		o.inFlight = map[string]bool{}
+	}
+	o.inFlight[recordKey] = true
+	return true
+}
+
+func (o *IdempotencyOptions) release(recordKey string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	delete(o.inFlight, recordKey)
+}
+
+// Purge deletes the expired idempotency records from the store, it returns the amount of deleted records
+func (o *IdempotencyOptions) Purge(ctx context.Context, store JsonStorer) (int, error) {
+	now := time.Now()
+	var expired []string
+	err := ForEach(ctx, store, o.collection(), func(key string, value json.RawMessage) error {
+		record := idempotencyRecord{}
+		if err := json.Unmarshal(value, &record); err != nil || now.After(record.ExpiresAt) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to list idempotency records: %v", err)
+	}
+	deleted := 0
+	for _, key := range expired {
+		ok, err := store.Delete(ctx, o.collection(), key)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete idempotency record: %v", err)
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+// serveIdempotent serves a POST or PUT request with an Idempotency-Key header, replaying the recorded response of
+// a previous identical request or recording the response of write
+func (h *HttpStorer) serveIdempotent(w http.ResponseWriter, r *http.Request, collection, key string,
+	write func(w http.ResponseWriter, r *http.Request, collection, key string)) {
+	opts := h.Idempotency
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("%w: longer than %d characters", invalidIdempotencyKeyErr, maxIdempotencyKeyLen))
+		return
+	}
+	body, ok := h.readRequestBody(w, r)
+	if !ok {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256([]byte(collection + "\n" + idempotencyKey))
+	recordKey := hex.EncodeToString(sum[:])
+	sum = sha256.Sum256(append([]byte(r.Method+"\n"+key+"\n"), body...))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	if !opts.acquire(recordKey) {
+		h.writeError(w, r, http.StatusConflict, idempotencyInProgressErr)
+		return
+	}
+	defer opts.release(recordKey)
+
+	var raw json.RawMessage
+	err := h.Storer.Get(r.Context(), opts.collection(), recordKey, &raw)
+	if err != nil && !isNotFound(err) {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to read idempotency record: %w", err))
+		return
+	}
+	if err == nil {
+		record := idempotencyRecord{}
+		if err = json.Unmarshal(raw, &record); err == nil && time.Now().Before(record.ExpiresAt) {
+			if record.Fingerprint != fingerprint {
+				h.writeError(w, r, http.StatusUnprocessableEntity, idempotencyKeyReusedErr)
+				return
+			}
+			for name, values := range record.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			_, _ = w.Write(record.Body)
+			return
+		}
+	}
+
+	rw := &recordingWriter{ResponseWriter: w}
+	write(rw, r, collection, key)
+	if rw.status < 200 || rw.status > 299 {
+		return
+	}
+	record := idempotencyRecord{
+		Fingerprint: fingerprint,
+		Status:      rw.status,
+		Header:      http.Header{},
+		Body:        rw.body.Bytes(),
+		ExpiresAt:   time.Now().Add(opts.ttl()),
+	}
+	for _, name := range recordedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			record.Header.Set(name, value)
+		}
+	}
+	raw, err = json.Marshal(record)
+	if err != nil {
+		return
+	}
+	// the response is already sent: if the record can't be stored a retry is processed again
+	_ = h.Storer.Set(context.WithoutCancel(r.Context()), opts.collection(), recordKey, raw)
+}
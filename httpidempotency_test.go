@@ -0,0 +1,161 @@
+package jsonstore_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerIdempotency(t *testing.T) {
+	ctx := context.Background()
+
+	type request struct {
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+		replayed   bool
+	}
+	tcs := []struct {
+		name      string
+		requests  []request
+		wantCount int
+	}{
+		{
+			name: "retried create",
+			requests: []request{
+				{method: http.MethodPost, path: "/", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated},
+				{method: http.MethodPost, path: "/", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated, replayed: true},
+			},
+			wantCount: 1,
+		},
+		{
+			name: "different keys",
+			requests: []request{
+				{method: http.MethodPost, path: "/", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated},
+				{method: http.MethodPost, path: "/", key: "k2", body: `{"a":1}`, wantStatus: http.StatusCreated},
+			},
+			wantCount: 2,
+		},
+		{
+			name: "without key",
+			requests: []request{
+				{method: http.MethodPost, path: "/", body: `{"a":1}`, wantStatus: http.StatusCreated},
+				{method: http.MethodPost, path: "/", body: `{"a":1}`, wantStatus: http.StatusCreated},
+			},
+			wantCount: 2,
+		},
+		{
+			name: "key reused for another document",
+			requests: []request{
+				{method: http.MethodPost, path: "/", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated},
+				{method: http.MethodPost, path: "/", key: "k1", body: `{"a":2}`, wantStatus: http.StatusUnprocessableEntity},
+			},
+			wantCount: 1,
+		},
+		{
+			name: "retried put",
+			requests: []request{
+				{method: http.MethodPut, path: "/item1", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated},
+				{method: http.MethodPut, path: "/item1", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated, replayed: true},
+			},
+			wantCount: 1,
+		},
+		{
+			name: "failed request is not recorded",
+			requests: []request{
+				{method: http.MethodPut, path: "/", key: "k1", body: `{"a":1}`, wantStatus: http.StatusBadRequest},
+				{method: http.MethodPut, path: "/item1", key: "k1", body: `{"a":1}`, wantStatus: http.StatusCreated},
+			},
+			wantCount: 1,
+		},
+		{
+			name: "key too long",
+			requests: []request{
+				{method: http.MethodPost, path: "/", key: strings.Repeat("k", 256), body: `{"a":1}`, wantStatus: http.StatusBadRequest},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			handler := jsonstore.Handler{
+				HttpStorer: jsonstore.HttpStorer{Storer: store, Idempotency: &jsonstore.IdempotencyOptions{}},
+				Collection: "col1",
+			}
+			var first *httptest.ResponseRecorder
+			for i, req := range tc.requests {
+				r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(jsonstore.IdempotencyKeyHeader, req.key)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, r)
+
+				if rec.Code != req.wantStatus {
+					t.Fatalf("request %d: expected status %d, got %d: %s", i, req.wantStatus, rec.Code, rec.Body.String())
+				}
+				if got := rec.Header().Get("Idempotent-Replayed") == "true"; got != req.replayed {
+					t.Errorf("request %d: expected replayed %t, got %t", i, req.replayed, got)
+				}
+				if req.replayed {
+					if diff := cmp.Diff(rec.Body.String(), first.Body.String()); diff != "" {
+						t.Errorf("request %d: unexpected body (-got +want):\n%s", i, diff)
+					}
+					if diff := cmp.Diff(rec.Header().Get("Location"), first.Header().Get("Location")); diff != "" {
+						t.Errorf("request %d: unexpected Location (-got +want):\n%s", i, diff)
+					}
+				}
+				if i == 0 {
+					first = rec
+				}
+			}
+
+			_, count, err := store.List(ctx, "col1", 10, 1)
+			if err != nil && !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+				t.Fatalf("action: List,  returned an error: %v", err)
+			}
+			if int(count) != tc.wantCount {
+				t.Errorf("expected %d documents, got %d", tc.wantCount, count)
+			}
+		})
+	}
+}
+
+func TestIdempotencyPurge(t *testing.T) {
+	ctx := context.Background()
+	store := jsonstore.NewMemStore()
+	opts := &jsonstore.IdempotencyOptions{TTL: time.Millisecond}
+	handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store, Idempotency: opts}, Collection: "col1"}
+
+	for _, key := range []string{"k1", "k2"} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`))
+		r.Header.Set(jsonstore.IdempotencyKeyHeader, key)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	records, _, err := store.List(ctx, jsonstore.DefaultIdempotencyCollection, 10, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	deleted, err := opts.Purge(ctx, store)
+	if err != nil {
+		t.Fatalf("action: Purge,  returned an error: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted records, got %d", deleted)
+	}
+}
@@ -0,0 +1,49 @@
+package jsonstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyGenerator returns a new unique key for a document created without one, e.g. by a POST to the collection root
+type KeyGenerator func() (string, error)
+
+// UUIDKey generates random (version 4) UUIDs, e.g. "0b6e1c4a-3f0e-4b9e-9a51-4f3c5d2e8a17"; it is the default
+// KeyGenerator of the HttpStorer
+func UUIDKey() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// crockford is the base32 alphabet of ULIDs, without I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDKey generates ULIDs, e.g. "01HZX3K8Q4T9V6W2Y5B7C1D3EF": 26 characters holding a millisecond timestamp and
+// 80 random bits, keys created later sort after the earlier ones (at a millisecond granularity), so that List
+// returns the documents in creation order
+func ULIDKey() (string, error) {
+	var id [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+
+	// 128 bits encoded 5 bits at a time from the end, the first character holds the 3 leftmost bits
+	out := make([]byte, 26)
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out), nil
+}
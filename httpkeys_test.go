@@ -0,0 +1,47 @@
+package jsonstore_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+func TestKeyGenerators(t *testing.T) {
+	tcs := []struct {
+		name    string
+		newKey  jsonstore.KeyGenerator
+		pattern string
+	}{
+		{name: "UUID", newKey: jsonstore.UUIDKey, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{name: "ULID", newKey: jsonstore.ULIDKey, pattern: `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			seen := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				key, err := tc.newKey()
+				if err != nil {
+					t.Fatalf("action: newKey,  returned an error: %v", err)
+				}
+				if !regexp.MustCompile(tc.pattern).MatchString(key) {
+					t.Errorf("key %q does not match %s", key, tc.pattern)
+				}
+				if seen[key] {
+					t.Errorf("duplicated key %q", key)
+				}
+				seen[key] = true
+			}
+		})
+	}
+
+	t.Run("ULID order", func(t *testing.T) {
+		first, _ := jsonstore.ULIDKey()
+		time.Sleep(2 * time.Millisecond)
+		second, _ := jsonstore.ULIDKey()
+		if first[:10] >= second[:10] {
+			t.Errorf("expected the timestamp of %q to sort before %q", first, second)
+		}
+	})
+}
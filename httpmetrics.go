@@ -0,0 +1,141 @@
+package jsonstore
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RequestInfo describes a request served by InstrumentHandler
+type RequestInfo struct {
+	Method string
+	Path   string
+	// Status is the status of the response, 200 if the handler wrote no header
+	Status   int
+	Duration time.Duration
+	// RequestSize and ResponseSize are the bytes of the bodies read by the handler and written to the client
+	RequestSize  int64
+	ResponseSize int64
+}
+
+// StatusClass returns the class of the status of the response, e.g. "2xx"
+func (i RequestInfo) StatusClass() string {
+	return strconv.Itoa(i.Status/100) + "xx"
+}
+
+// RequestObserver records the requests served by InstrumentHandler, e.g. as metrics; see the prommetrics package
+// for Prometheus
+type RequestObserver interface {
+	ObserveRequest(r *http.Request, info RequestInfo)
+}
+
+// InstrumentOptions configures InstrumentHandler
+type InstrumentOptions struct {
+	// Observer records every request, if set
+	Observer RequestObserver
+	// AccessLog, if set, logs every request at info level with the method, path, status, duration, sizes and the
+	// request id of the OperationContext
+	AccessLog *slog.Logger
+}
+
+// InstrumentHandler is an http middleware recording the count, latency, status and payload sizes of the requests
+// served by next, e.g. a Handler or a MultiHandler, and optionally writing an access log. Wrap it with
+// OperationContextHandler to have the request ids in the log.
+func InstrumentHandler(next http.Handler, opts InstrumentOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		info := RequestInfo{
+			Method:       r.Method,
+			Path:         r.URL.EscapedPath(),
+			Status:       sw.status,
+			Duration:     time.Since(start),
+			RequestSize:  body.n,
+			ResponseSize: sw.n,
+		}
+		if info.Status == 0 {
+			info.Status = http.StatusOK
+		}
+		if opts.Observer != nil {
+			opts.Observer.ObserveRequest(r, info)
+		}
+		if opts.AccessLog != nil {
+			attrs := []slog.Attr{
+				slog.String("method", info.Method),
+				slog.String("path", info.Path),
+				slog.Int("status", info.Status),
+				slog.Duration("duration", info.Duration),
+				slog.Int64("requestSize", info.RequestSize),
+				slog.Int64("responseSize", info.ResponseSize),
+			}
+			if oc, ok := GetOperationContext(r.Context()); ok && oc.RequestID != "" {
+				attrs = append(attrs, slog.String("requestId", oc.RequestID))
+			}
+			opts.AccessLog.LogAttrs(r.Context(), slog.LevelInfo, "http request", attrs...)
+		}
+	})
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// statusWriter keeps the status and counts the bytes of a response, it passes the Flush of the streams and the
+// Hijack of the live sync through
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.n += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(sw.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter for http.ResponseController
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
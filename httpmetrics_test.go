@@ -0,0 +1,97 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// recordingObserver keeps the observed requests
+type recordingObserver struct {
+	mutex sync.Mutex
+	infos []jsonstore.RequestInfo
+}
+
+func (o *recordingObserver) ObserveRequest(r *http.Request, info jsonstore.RequestInfo) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.infos = append(o.infos, info)
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	observer := &recordingObserver{}
+	var logs bytes.Buffer
+	handler := jsonstore.OperationContextHandler(jsonstore.InstrumentHandler(&jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()},
+		Collection: "col1",
+	}, jsonstore.InstrumentOptions{
+		Observer:  observer,
+		AccessLog: slog.New(slog.NewJSONHandler(&logs, nil)),
+	}), nil)
+
+	requests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{http.MethodPut, "/item1", `{"n":1}`},
+		{http.MethodGet, "/item1", ""},
+		{http.MethodGet, "/item2", ""},
+	}
+	for _, req := range requests {
+		r := httptest.NewRequest(req.method, req.url, strings.NewReader(req.body))
+		r.Header.Set(jsonstore.RequestIdHeader, "req-"+req.url)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []jsonstore.RequestInfo{
+		{Method: http.MethodPut, Path: "/item1", Status: http.StatusCreated, RequestSize: 7},
+		{Method: http.MethodGet, Path: "/item1", Status: http.StatusOK, ResponseSize: 7},
+		{Method: http.MethodGet, Path: "/item2", Status: http.StatusNotFound},
+	}
+	if diff := cmp.Diff(observer.infos, want, cmpopts.IgnoreFields(jsonstore.RequestInfo{}, "Duration", "ResponseSize")); diff != "" {
+		t.Errorf("unexpected requests (-got +want):\n%s", diff)
+	}
+	if observer.infos[1].ResponseSize != 7 || observer.infos[2].ResponseSize == 0 {
+		t.Errorf("unexpected response sizes: %+v", observer.infos)
+	}
+	if got := observer.infos[2].StatusClass(); got != "4xx" {
+		t.Errorf("expected status class 4xx, got %s", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 log lines, got %d: %s", len(lines), logs.String())
+	}
+	entry := map[string]any{}
+	if err := json.Unmarshal([]byte(lines[2]), &entry); err != nil {
+		t.Fatalf("invalid log line: %v", err)
+	}
+	if entry["msg"] != "http request" || entry["status"] != float64(http.StatusNotFound) || entry["requestId"] != "req-/item2" {
+		t.Errorf("unexpected log line: %s", lines[2])
+	}
+
+	t.Run("flush is passed through", func(t *testing.T) {
+		flushed := false
+		handler := jsonstore.InstrumentHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, flushed = w.(http.Flusher)
+			if flushed {
+				w.(http.Flusher).Flush()
+			}
+		}), jsonstore.InstrumentOptions{})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !flushed || !rec.Flushed {
+			t.Errorf("expected the response to be flushed")
+		}
+	})
+}
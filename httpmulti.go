@@ -0,0 +1,132 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MultiHandler serves several collections of a store from a single handler, routing the paths
+// /{collection}/{key} to the document and /{collection}/ to the collection root; the paths are relative to the
+// handler, mount it with http.StripPrefix, e.g.
+//
+//	mux.Handle("/api/", http.StripPrefix("/api", &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}}))
+type MultiHandler struct {
+	HttpStorer
+	// Collections is the allow-list of the served collections, requests on other collections get a 404 Not Found.
+	// If empty any collection accepted by ValidateCollection is served.
+	Collections []string
+	// ValidateCollection checks the collection names when there is no allow-list, ValidCollectionName if nil
+	ValidateCollection func(collection string) error
+	// Cors enables the CORS headers and the preflight responses, see Handler
+	Cors *CorsOptions
+	// Admin enables the collection management endpoints on every served collection, see Handler; a GET on the
+	// root of the handler then lists the collections
+	Admin bool
+	// OpenAPI serves the OpenAPI document of the handler on /openapi.json, see OpenAPI; the collections and the
+	// enabled endpoints are taken from the handler if not set
+	OpenAPI *OpenAPIOptions
+}
+
+// collectionNameRegex matches the collection names accepted by ValidCollectionName
+var collectionNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// ValidCollectionName accepts collection names of up to 128 letters, digits, _, . and -, starting with a letter
+// or a digit; names starting with _ are reserved for endpoints like _collections
+func ValidCollectionName(collection string) error {
+	if !collectionNameRegex.MatchString(collection) {
+		return fmt.Errorf("%w: %q", invalidCollectionErr, collection)
+	}
+	return nil
+}
+
+// ServeHTTP routes the request to the collection named by the first path segment
+func (h *MultiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Cors != nil && h.Cors.handle(w, r) {
+		return
+	}
+	collection, key, ok := splitPath(r.URL.EscapedPath())
+	if !ok {
+		h.writeError(w, r, http.StatusNotFound, fmt.Errorf("invalid path: %w", ItemNotFoundErr))
+		return
+	}
+	key = h.queryKey(r, key)
+	if collection == "" {
+		if h.Admin && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			if h.authorize(w, r, OperationListCollections, "", "") {
+				h.ListCollections(w, r)
+			}
+			return
+		}
+		h.writeError(w, r, http.StatusNotFound, fmt.Errorf("missing collection: %w", CollectionNotFoundErr))
+		return
+	}
+	if h.OpenAPI != nil && collection == openAPIPath && key == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		h.serveOpenAPI(w, r)
+		return
+	}
+	if !h.allowed(w, r, collection) {
+		return
+	}
+	if h.Admin && h.serveAdmin(w, r, collection, key) {
+		return
+	}
+	// the documents can reference any served collection, see newPopulator
+	h.serve(w, withRefScope(r, h.serves), collection, key)
+}
+
+// splitPath splits an escaped request path into the collection and the key, percent-decoded; a / in the key is
+// requested as %2F, a path with more segments is not valid
+func splitPath(escaped string) (collection, key string, ok bool) {
+	collection, key, _ = strings.Cut(strings.TrimPrefix(escaped, "/"), "/")
+	if strings.Contains(key, "/") {
+		return "", "", false
+	}
+	collection, err := url.PathUnescape(collection)
+	if err != nil {
+		return "", "", false
+	}
+	key, err = url.PathUnescape(key)
+	if err != nil {
+		return "", "", false
+	}
+	return collection, key, true
+}
+
+// allowed checks the collection against the allow-list or ValidateCollection, writing the error response if it
+// is not served
+func (h *MultiHandler) allowed(w http.ResponseWriter, r *http.Request, collection string) bool {
+	err := h.serves(collection)
+	switch {
+	case errors.Is(err, CollectionNotFoundErr):
+		h.writeError(w, r, http.StatusNotFound, err)
+		return false
+	case err != nil:
+		h.writeError(w, r, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+// serves returns an error wrapping CollectionNotFoundErr if the collection is not in the allow-list, or the error of
+// ValidateCollection
+func (h *MultiHandler) serves(collection string) error {
+	if len(h.Collections) > 0 {
+		if !slices.Contains(h.Collections, collection) {
+			return fmt.Errorf("collection %s: %w", collection, CollectionNotFoundErr)
+		}
+		return nil
+	}
+	validate := h.ValidateCollection
+	if validate == nil {
+		validate = ValidCollectionName
+	}
+	if err := validate(collection); err != nil {
+		return fmt.Errorf("collection %s: %w", collection, err)
+	}
+	return nil
+}
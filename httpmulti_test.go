@@ -0,0 +1,120 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMultiHandler(t *testing.T) {
+	ctx := context.Background()
+
+	newStore := func(t *testing.T) *jsonstore.MemStore {
+		store := jsonstore.NewMemStore()
+		for _, collection := range []string{"users", "orders"} {
+			if err := store.Set(ctx, collection, "a", json.RawMessage(fmt.Sprintf(`{"col":%q}`, collection))); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		return store
+	}
+
+	tcs := []struct {
+		name        string
+		collections []string
+		validate    func(string) error
+		admin       bool
+		method      string
+		url         string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "get", method: http.MethodGet, url: "/users/a", wantStatus: http.StatusOK, wantBody: `{"col":"users"}`},
+		{name: "get other collection", method: http.MethodGet, url: "/orders/a", wantStatus: http.StatusOK, wantBody: `{"col":"orders"}`},
+		{name: "list", method: http.MethodGet, url: "/orders/", wantStatus: http.StatusOK},
+		{name: "list without trailing slash", method: http.MethodGet, url: "/orders", wantStatus: http.StatusOK},
+		{name: "set new collection", method: http.MethodPost, url: "/items/b", body: `{}`, wantStatus: http.StatusCreated},
+		{name: "delete", method: http.MethodDelete, url: "/users/a", wantStatus: http.StatusOK},
+		{name: "nested path", method: http.MethodGet, url: "/users/a/b", wantStatus: http.StatusNotFound},
+		{name: "no collection", method: http.MethodGet, url: "/", wantStatus: http.StatusNotFound},
+		{name: "invalid name", method: http.MethodGet, url: "/_users/a", wantStatus: http.StatusBadRequest},
+		{name: "allowed", collections: []string{"users"}, method: http.MethodGet, url: "/users/a", wantStatus: http.StatusOK},
+		{name: "not allowed", collections: []string{"users"}, method: http.MethodGet, url: "/orders/a", wantStatus: http.StatusNotFound},
+		{
+			name:       "custom validation",
+			validate:   func(c string) error { return fmt.Errorf("rejected %s", c) },
+			method:     http.MethodGet,
+			url:        "/users/a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{name: "admin list collections", admin: true, method: http.MethodGet, url: "/", wantStatus: http.StatusOK,
+			wantBody: `{"collections":["orders","users"]}`},
+		{name: "admin stats", admin: true, method: http.MethodGet, url: "/orders/_stats", wantStatus: http.StatusOK},
+		{name: "admin drop", admin: true, method: http.MethodDelete, url: "/orders/", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := jsonstore.MultiHandler{
+				HttpStorer:         jsonstore.HttpStorer{Storer: newStore(t)},
+				Collections:        tc.collections,
+				ValidateCollection: tc.validate,
+				Admin:              tc.admin,
+			}
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantBody != "" {
+				if diff := cmp.Diff(rec.Body.String(), tc.wantBody); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+			}
+		})
+	}
+
+	t.Run("strip prefix", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/api/", http.StripPrefix("/api", &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: newStore(t)}}))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/a", nil))
+		if diff := cmp.Diff(rec.Body.String(), `{"col":"users"}`); diff != "" {
+			t.Errorf("unexpected body (-got +want):\n%s", diff)
+		}
+	})
+}
+
+func TestValidCollectionName(t *testing.T) {
+	tcs := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "users"},
+		{name: "Users_2024.v1-a"},
+		{name: "", wantErr: true},
+		{name: "_collections", wantErr: true},
+		{name: "a b", wantErr: true},
+		{name: strings.Repeat("a", 128)},
+		{name: strings.Repeat("a", 129), wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := jsonstore.ValidCollectionName(tc.name)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected error: %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
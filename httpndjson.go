@@ -0,0 +1,88 @@
+package jsonstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// NdjsonContentType is the media type of the streamed list responses, one json object per line
+const NdjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery is the amount of lines written between the flushes of a streamed list response
+const ndjsonFlushEvery = 100
+
+// NdjsonItem is a line of a streamed list response
+type NdjsonItem struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// wantsNdjson reports whether a list request asks for the streamed format, with format=ndjson or an Accept header
+// listing NdjsonContentType
+func wantsNdjson(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "ndjson" {
+		return true
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accepted); err == nil && mediaType == NdjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// streamList writes all the documents of the collection, or the ones starting with the prefix query parameter, as
+// one NdjsonItem per line in key order. Documents are read with ForEach and written as they come, so that
+// collections larger than the memory can be exported; pagination parameters are ignored.
+// An error before the first line gets an error response, afterwards the status is already sent and the response
+// is aborted, so that the client does not mistake the truncated stream for a complete one.
+func (h *HttpStorer) streamList(w http.ResponseWriter, r *http.Request, collection string) {
+	prefix := r.URL.Query().Get("prefix")
+	flusher, _ := w.(http.Flusher)
+	var bw *bufio.Writer
+	lines := 0
+
+	err := ForEach(r.Context(), h.Storer, collection, func(key string, value json.RawMessage) error {
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		line, err := json.Marshal(NdjsonItem{Key: key, Value: value})
+		if err != nil {
+			return fmt.Errorf("unable to encode document %s: %w", key, err)
+		}
+		if bw == nil {
+			w.Header().Set("Content-Type", NdjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			bw = bufio.NewWriter(w)
+		}
+		if _, err = bw.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		lines++
+		if lines%ndjsonFlushEvery == 0 && flusher != nil {
+			if err = bw.Flush(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if bw == nil {
+			h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to fetch items: %w", err))
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if bw == nil {
+		// an empty collection
+		w.Header().Set("Content-Type", NdjsonContentType)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	_ = bw.Flush()
+}
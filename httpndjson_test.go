@@ -0,0 +1,90 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerNdjson(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) jsonstore.JsonStorer
+	}{
+		{name: "MemStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return jsonstore.NewMemStore() }},
+		{name: "DbStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) }},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.newStore(t)
+			for i := 0; i < 150; i++ {
+				if err := store.Set(ctx, "col1", fmt.Sprintf("item%03d", i), json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "col1"}
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/?format=ndjson&limit=10", nil),
+				func() *http.Request {
+					req := httptest.NewRequest(http.MethodGet, "/", nil)
+					req.Header.Set("Accept", "application/x-ndjson, application/json;q=0.5")
+					return req
+				}(),
+			} {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+				}
+				if diff := cmp.Diff(rec.Header().Get("Content-Type"), jsonstore.NdjsonContentType); diff != "" {
+					t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+				}
+				lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+				if len(lines) != 150 {
+					t.Fatalf("expected 150 lines, got %d", len(lines))
+				}
+				for i, line := range lines {
+					item := jsonstore.NdjsonItem{}
+					if err := json.Unmarshal([]byte(line), &item); err != nil {
+						t.Fatalf("invalid line %q: %v", line, err)
+					}
+					want := jsonstore.NdjsonItem{Key: fmt.Sprintf("item%03d", i), Value: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))}
+					if diff := cmp.Diff(item, want); diff != "" {
+						t.Errorf("unexpected line %d (-got +want):\n%s", i, diff)
+					}
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=ndjson&prefix=item14", nil))
+			if got := strings.Count(rec.Body.String(), "\n"); got != 10 {
+				t.Errorf("expected 10 lines with the prefix, got %d", got)
+			}
+		})
+	}
+
+	t.Run("storage error", func(t *testing.T) {
+		handler := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: &jsonstoretest.FakeStore{Err: fmt.Errorf("storage error")}},
+			Collection: "col1",
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=ndjson", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
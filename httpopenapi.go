@@ -0,0 +1,357 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAPIPath is the path of the OpenAPI document served by a MultiHandler with OpenAPI set
+const openAPIPath = "openapi.json"
+
+// OpenAPIOptions describes the endpoints documented by OpenAPI
+type OpenAPIOptions struct {
+	// Title and Version are the info of the document, "jsonstore" and "1.0.0" if empty
+	Title   string
+	Version string
+	// ServerURL is the URL the paths are relative to, e.g. "https://example.com/api"; the document has no servers
+	// if empty
+	ServerURL string
+	// Collections are the documented collections, every one with its own paths; if empty the paths have a
+	// {collection} parameter
+	Collections []string
+	// Schemas are the JSON Schemas of the documents of the collections, documents of collections without schema
+	// can be any json value
+	Schemas map[string]json.RawMessage
+	// Admin, ChangeStream and LiveSync add the endpoints enabled by the fields of the same name of the handler
+	Admin        bool
+	ChangeStream bool
+	LiveSync     bool
+	// ReadOnly leaves out the writes, rejected by a handler with the field of the same name
+	ReadOnly bool
+	// DefaultPageSize and MaxPageSize are the page sizes of the lists, see the fields of the HttpStorer
+	DefaultPageSize int
+	MaxPageSize     int
+	// ListSchema is the JSON Schema of the list responses of a handler with a ListEnvelope, the schema of the Page
+	// if empty
+	ListSchema json.RawMessage
+}
+
+// OpenAPI generates an OpenAPI 3 document describing the endpoints of a Handler or MultiHandler, e.g. to
+// generate clients; the paths are the ones of a MultiHandler, /{collection}/{key}
+func OpenAPI(opts OpenAPIOptions) ([]byte, error) {
+	title := opts.Title
+	if title == "" {
+		title = "jsonstore"
+	}
+	version := opts.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+
+	schemas := map[string]any{
+		"Problem": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"type":     map[string]any{"type": "string"},
+				"title":    map[string]any{"type": "string"},
+				"status":   map[string]any{"type": "integer"},
+				"detail":   map[string]any{"type": "string"},
+				"instance": map[string]any{"type": "string"},
+			},
+		},
+	}
+	paths := map[string]any{}
+	if len(opts.Collections) == 0 {
+		addCollectionPaths(paths, schemas, opts, "{collection}", nil)
+	}
+	for _, collection := range opts.Collections {
+		addCollectionPaths(paths, schemas, opts, collection, opts.Schemas[collection])
+	}
+	if opts.Admin {
+		paths["/"] = map[string]any{
+			"get": operation("listCollections", "List the collections", nil, nil, map[string]any{
+				"200": jsonResponse("The collections", map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"collections": map[string]any{"type": "array", "items": map[string]any{"type": "string"}}},
+				}),
+			}),
+		}
+	}
+
+	doc := map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": title, "version": version},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+	if opts.ServerURL != "" {
+		doc["servers"] = []any{map[string]any{"url": opts.ServerURL}}
+	}
+	return json.Marshal(doc)
+}
+
+// addCollectionPaths adds the paths of a collection, or of the {collection} parameter, and the schemas they use
+func addCollectionPaths(paths, schemas map[string]any, opts OpenAPIOptions, collection string, schema json.RawMessage) {
+	var params []any
+	// the operation ids are unique across the collections, e.g. users.get
+	opID := func(name string) string { return collection + "." + name }
+	docSchema := map[string]any{}
+	if collection == "{collection}" {
+		params = append(params, pathParam("collection", "The collection"))
+		opID = func(name string) string { return name }
+	} else if len(schema) > 0 {
+		name := "Document." + collection
+		schemas[name] = schema
+		docSchema = map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	keyParams := append(append([]any{}, params...), pathParam("key", "The key of the document"))
+	document := func(description string) map[string]any { return jsonResponse(description, docSchema) }
+	docBody := map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": docSchema}}}
+
+	listSchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"items":      map[string]any{"type": "object", "additionalProperties": docSchema},
+			"total":      map[string]any{"type": "integer"},
+			"page":       map[string]any{"type": "integer"},
+			"limit":      map[string]any{"type": "integer"},
+			"hasNext":    map[string]any{"type": "boolean"},
+			"nextCursor": map[string]any{"type": "string"},
+			"prevCursor": map[string]any{"type": "string"},
+			"keys":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+	if len(opts.ListSchema) > 0 {
+		schemas["List"] = opts.ListSchema
+		listSchema = map[string]any{"$ref": "#/components/schemas/List"}
+	}
+
+	root := "/" + collection + "/"
+	rootPath := map[string]any{
+		"get": operation(opID("list"), "List the documents", params, listParams(opts), map[string]any{
+			"200": jsonResponse("A page of documents", listSchema),
+		}),
+		"post": withBody(operation(opID("create"), "Create a document with a generated key", params, nil, map[string]any{
+			"201": jsonResponse("The document was created", map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"key": map[string]any{"type": "string"}},
+			}),
+		}), docBody),
+	}
+	if opts.Admin {
+		rootPath["put"] = operation(opID("createCollection"), "Create the collection", params, nil, map[string]any{
+			"201": map[string]any{"description": "The collection was created"},
+		})
+		rootPath["delete"] = operation(opID("dropCollection"), "Drop or truncate the collection", params, []any{
+			queryParam("truncate", "Delete the documents and keep the collection", map[string]any{"type": "boolean"}),
+		}, map[string]any{
+			"200": jsonResponse("The collection was truncated", map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"deleted": map[string]any{"type": "integer"}},
+			}),
+			"204": map[string]any{"description": "The collection was dropped"},
+		})
+		paths[root+statsPath] = map[string]any{
+			"get": operation(opID("getStats"), "Get the stats of the collection", params, nil, map[string]any{
+				"200": jsonResponse("The stats", map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"count":        map[string]any{"type": "integer"},
+						"bytes":        map[string]any{"type": "integer"},
+						"minUpdatedAt": map[string]any{"type": "string", "format": "date-time"},
+						"maxUpdatedAt": map[string]any{"type": "string", "format": "date-time"},
+					},
+				}),
+			}),
+		}
+	}
+	if opts.Admin {
+		paths[root+exportPath] = map[string]any{
+			"get": operation(opID("export"), "Download the documents", params, []any{
+				queryParam("format", "The format of the dump", map[string]any{"type": "string", "enum": []string{"ndjson", "json"}}),
+			}, map[string]any{
+				"200": map[string]any{"description": "The dump", "content": map[string]any{
+					NdjsonContentType:  map[string]any{"schema": map[string]any{"type": "string"}},
+					"application/json": map[string]any{"schema": map[string]any{"type": "object", "additionalProperties": docSchema}},
+				}},
+			}),
+		}
+		paths[root+importPath] = map[string]any{
+			"post": withBody(operation(opID("import"), "Upload documents", params, []any{
+				queryParam("conflict", "What to do with the existing documents", map[string]any{"type": "string", "enum": []string{"overwrite", "skip", "fail"}}),
+				queryParam("format", "The format of the dump, by default from the Content-Type", map[string]any{"type": "string", "enum": []string{"ndjson", "json"}}),
+			}, map[string]any{
+				"200": jsonResponse("The documents were imported", map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"created": map[string]any{"type": "integer"},
+						"updated": map[string]any{"type": "integer"},
+						"skipped": map[string]any{"type": "integer"},
+					},
+				}),
+			}), map[string]any{"required": true, "content": map[string]any{
+				NdjsonContentType:  map[string]any{"schema": map[string]any{"type": "string"}},
+				"application/json": map[string]any{"schema": map[string]any{"type": "object", "additionalProperties": docSchema}},
+			}}),
+		}
+	}
+	paths[root] = rootPath
+
+	if opts.ChangeStream {
+		paths[root+changesPath] = map[string]any{
+			"get": operation(opID("watch"), "Stream the changes as Server-Sent Events", params, []any{
+				queryParam("prefix", "Only stream the changes of the keys starting with it", map[string]any{"type": "string"}),
+			}, map[string]any{
+				"200": map[string]any{
+					"description": "The stream of changes",
+					"content":     map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}},
+				},
+			}),
+		}
+	}
+	if opts.LiveSync {
+		paths[root+syncPath] = map[string]any{
+			"get": operation(opID("sync"), "Open the live sync WebSocket", params, nil, map[string]any{
+				"101": map[string]any{"description": "Switching to the WebSocket protocol"},
+			}),
+		}
+	}
+
+	paths[root+"{key}"] = map[string]any{
+		"get": operation(opID("get"), "Get a document", keyParams, []any{
+			queryParam("fields", "Comma separated fields to return", map[string]any{"type": "string"}),
+			queryParam("populate", "Comma separated fields holding references to resolve", map[string]any{"type": "string"}),
+		}, map[string]any{"200": document("The document")}),
+		"head": operation(opID("head"), "Check a document", keyParams, nil, map[string]any{
+			"200": map[string]any{"description": "The document exists"},
+		}),
+		"post": withBody(operation(opID("set"), "Create or update a document", keyParams, nil, map[string]any{
+			"201": map[string]any{"description": "The document was stored"},
+		}), docBody),
+		"put": withBody(operation(opID("replace"), "Replace an existing document", keyParams, nil, map[string]any{
+			"204": map[string]any{"description": "The document was replaced"},
+		}), docBody),
+		"patch": withBody(operation(opID("patch"), "Patch a document", keyParams, nil, map[string]any{
+			"200": document("The patched document"),
+		}), map[string]any{"required": true, "content": map[string]any{
+			string(MergePatch): map[string]any{"schema": map[string]any{"type": "object"}},
+			string(JsonPatch):  map[string]any{"schema": map[string]any{"type": "array", "items": map[string]any{"type": "object"}}},
+		}}),
+		"delete": operation(opID("delete"), "Delete a document", keyParams, nil, map[string]any{
+			"200": map[string]any{"description": "The document was deleted"},
+		}),
+	}
+
+	if opts.ReadOnly {
+		for path, item := range paths {
+			if !strings.HasPrefix(path, root) {
+				continue
+			}
+			ops := item.(map[string]any)
+			for _, method := range []string{"post", "put", "patch", "delete"} {
+				delete(ops, method)
+			}
+			if len(ops) == 0 {
+				delete(paths, path)
+			}
+		}
+	}
+}
+
+// operation returns an OpenAPI operation, with the problem response as default response
+func operation(id, summary string, pathParams, queryParams []any, responses map[string]any) map[string]any {
+	responses["default"] = map[string]any{
+		"description": "A problem",
+		"content": map[string]any{
+			"application/problem+json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Problem"}},
+		},
+	}
+	op := map[string]any{"operationId": id, "summary": summary, "responses": responses}
+	params := append(append([]any{}, pathParams...), queryParams...)
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	return op
+}
+
+func withBody(op map[string]any, body map[string]any) map[string]any {
+	op["requestBody"] = body
+	return op
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+func pathParam(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "path", "required": true, "description": description, "schema": map[string]any{"type": "string"}}
+}
+
+func queryParam(name, description string, schema map[string]any) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+// listParams are the query parameters of a list request
+func listParams(opts OpenAPIOptions) []any {
+	maxLimit := opts.MaxPageSize
+	if maxLimit <= 0 {
+		maxLimit = MaxListItems
+	}
+	defaultLimit := opts.DefaultPageSize
+	if defaultLimit <= 0 {
+		defaultLimit = DefaultPageSize
+	}
+	return []any{
+		queryParam("limit", "The amount of documents of a page",
+			map[string]any{"type": "integer", "minimum": 1, "maximum": maxLimit, "default": min(defaultLimit, maxLimit)}),
+		queryParam("page", "The page, starting at 1", map[string]any{"type": "integer", "minimum": 1}),
+		queryParam("cursor", "The cursor of the page, nextCursor or prevCursor of a previous page", map[string]any{"type": "string"}),
+		queryParam("prefix", "Only list the keys starting with it", map[string]any{"type": "string"}),
+		queryParam("total", "Set to false to skip counting the documents", map[string]any{"type": "boolean"}),
+		queryParam("sort", "Comma separated paths to sort by, prefixed by - for a descending order", map[string]any{"type": "string"}),
+		queryParam("fields", "Comma separated fields to return", map[string]any{"type": "string"}),
+		queryParam("populate", "Comma separated fields holding references to resolve", map[string]any{"type": "string"}),
+		queryParam("format", "ndjson to stream the whole collection", map[string]any{"type": "string", "enum": []string{"ndjson"}}),
+		map[string]any{
+			"name":        "filter",
+			"in":          "query",
+			"description": "Filters as filter[path]=value or filter[path][op]=value",
+			"style":       "deepObject",
+			"explode":     true,
+			"schema":      map[string]any{"type": "object", "additionalProperties": true},
+		},
+	}
+}
+
+// serveOpenAPI writes the OpenAPI document of the MultiHandler, completing the options with its collections and
+// enabled endpoints
+func (h *MultiHandler) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	opts := *h.OpenAPI
+	if len(opts.Collections) == 0 {
+		opts.Collections = h.Collections
+	}
+	opts.Admin = opts.Admin || h.Admin
+	opts.ChangeStream = opts.ChangeStream || h.ChangeStream
+	opts.LiveSync = opts.LiveSync || h.LiveSync != nil
+	opts.ReadOnly = opts.ReadOnly || h.ReadOnly
+	if opts.DefaultPageSize == 0 {
+		opts.DefaultPageSize = h.DefaultPageSize
+	}
+	if opts.MaxPageSize == 0 {
+		opts.MaxPageSize = h.MaxPageSize
+	}
+	if len(opts.Schemas) == 0 && h.Schemas != nil {
+		opts.Schemas = h.Schemas.Schemas()
+	}
+	body, err := OpenAPI(opts)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to generate the OpenAPI document: %w", err))
+		return
+	}
+	h.writeJson(w, r, body)
+}
@@ -0,0 +1,176 @@
+package jsonstore_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+// openAPIDoc is the part of an OpenAPI document checked by the tests
+type openAPIDoc struct {
+	OpenAPI string                               `json:"openapi"`
+	Paths   map[string]map[string]map[string]any `json:"paths"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	tcs := []struct {
+		name      string
+		opts      jsonstore.OpenAPIOptions
+		wantPaths []string
+	}{
+		{
+			name:      "collection parameter",
+			opts:      jsonstore.OpenAPIOptions{},
+			wantPaths: []string{"/{collection}/", "/{collection}/{key}"},
+		},
+		{
+			name:      "collections",
+			opts:      jsonstore.OpenAPIOptions{Collections: []string{"users", "orders"}, ServerURL: "https://example.com/api"},
+			wantPaths: []string{"/orders/", "/orders/{key}", "/users/", "/users/{key}"},
+		},
+		{
+			name: "all endpoints",
+			opts: jsonstore.OpenAPIOptions{Collections: []string{"users"}, Admin: true, ChangeStream: true, LiveSync: true},
+			wantPaths: []string{
+				"/", "/users/", "/users/_changes", "/users/_export", "/users/_import", "/users/_stats", "/users/_sync", "/users/{key}",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := jsonstore.OpenAPI(tc.opts)
+			if err != nil {
+				t.Fatalf("action: OpenAPI,  returned an error: %v", err)
+			}
+			doc := openAPIDoc{}
+			if err = json.Unmarshal(body, &doc); err != nil {
+				t.Fatalf("invalid document: %v", err)
+			}
+			if doc.OpenAPI != "3.0.3" {
+				t.Errorf("unexpected openapi version %q", doc.OpenAPI)
+			}
+			var paths []string
+			operationIds := map[string]bool{}
+			for path, ops := range doc.Paths {
+				paths = append(paths, path)
+				for method, op := range ops {
+					id, _ := op["operationId"].(string)
+					if id == "" || operationIds[id] {
+						t.Errorf("%s %s: missing or duplicated operationId %q", method, path, id)
+					}
+					operationIds[id] = true
+				}
+			}
+			sort.Strings(paths)
+			if diff := cmp.Diff(paths, tc.wantPaths); diff != "" {
+				t.Errorf("unexpected paths (-got +want):\n%s", diff)
+			}
+			if tc.opts.ServerURL != "" && (len(doc.Servers) != 1 || doc.Servers[0].URL != tc.opts.ServerURL) {
+				t.Errorf("expected the server %s, got %+v", tc.opts.ServerURL, doc.Servers)
+			}
+		})
+	}
+
+	t.Run("read only", func(t *testing.T) {
+		body, err := jsonstore.OpenAPI(jsonstore.OpenAPIOptions{Collections: []string{"users"}, Admin: true, ReadOnly: true})
+		if err != nil {
+			t.Fatalf("action: OpenAPI,  returned an error: %v", err)
+		}
+		doc := openAPIDoc{}
+		if err = json.Unmarshal(body, &doc); err != nil {
+			t.Fatalf("invalid document: %v", err)
+		}
+		for path, ops := range doc.Paths {
+			for method := range ops {
+				if method != "get" && method != "head" {
+					t.Errorf("unexpected write %s %s", method, path)
+				}
+			}
+		}
+		if _, ok := doc.Paths["/users/_import"]; ok {
+			t.Errorf("expected no import path")
+		}
+		if _, ok := doc.Paths["/users/_export"]; !ok {
+			t.Errorf("expected the export path")
+		}
+	})
+
+	t.Run("schemas", func(t *testing.T) {
+		schema := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`)
+		body, err := jsonstore.OpenAPI(jsonstore.OpenAPIOptions{
+			Collections: []string{"users", "notes"},
+			Schemas:     map[string]json.RawMessage{"users": schema},
+		})
+		if err != nil {
+			t.Fatalf("action: OpenAPI,  returned an error: %v", err)
+		}
+		doc := openAPIDoc{}
+		if err = json.Unmarshal(body, &doc); err != nil {
+			t.Fatalf("invalid document: %v", err)
+		}
+		if diff := cmp.Diff(string(doc.Components.Schemas["Document.users"]), string(schema)); diff != "" {
+			t.Errorf("unexpected schema (-got +want):\n%s", diff)
+		}
+		if _, ok := doc.Components.Schemas["Document.notes"]; ok {
+			t.Errorf("expected no schema for the notes")
+		}
+		got, _ := json.Marshal(doc.Paths["/users/{key}"]["get"]["responses"])
+		want := `{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Document.users"}}},"description":"The document"},` +
+			`"default":{"content":{"application/problem+json":{"schema":{"$ref":"#/components/schemas/Problem"}}},"description":"A problem"}}`
+		if diff := cmp.Diff(string(got), want); diff != "" {
+			t.Errorf("unexpected responses (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("list schema", func(t *testing.T) {
+		schema := json.RawMessage(`{"type":"object","properties":{"data":{"type":"array"}}}`)
+		body, err := jsonstore.OpenAPI(jsonstore.OpenAPIOptions{Collections: []string{"users"}, ListSchema: schema})
+		if err != nil {
+			t.Fatalf("action: OpenAPI,  returned an error: %v", err)
+		}
+		doc := openAPIDoc{}
+		if err = json.Unmarshal(body, &doc); err != nil {
+			t.Fatalf("invalid document: %v", err)
+		}
+		if diff := cmp.Diff(string(doc.Components.Schemas["List"]), string(schema)); diff != "" {
+			t.Errorf("unexpected schema (-got +want):\n%s", diff)
+		}
+		got, _ := json.Marshal(doc.Paths["/users/"]["get"]["responses"].(map[string]any)["200"])
+		want := `{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/List"}}},"description":"A page of documents"}`
+		if diff := cmp.Diff(string(got), want); diff != "" {
+			t.Errorf("unexpected response (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("served by the MultiHandler", func(t *testing.T) {
+		handler := jsonstore.MultiHandler{
+			HttpStorer:  jsonstore.HttpStorer{Storer: jsonstore.NewMemStore(), ChangeStream: true},
+			Collections: []string{"users"},
+			OpenAPI:     &jsonstore.OpenAPIOptions{Title: "users api"},
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		doc := openAPIDoc{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid document: %v", err)
+		}
+		if _, ok := doc.Paths["/users/_changes"]; !ok {
+			t.Errorf("expected the change stream of the handler to be documented, got %v", doc.Paths)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// parseFilters reads the filters of a list request from the query parameters filter[<path>]=<value> (equality)
+// and filter[<path>][<op>]=<value>, e.g. filter[status]=active&filter[age][gt]=30. Values that are valid json
+// are compared as json, so that 30 is a number and true a boolean; other values, like active, are strings.
+// To compare a string holding a number use its json form, e.g. filter[zip]="8000".
+func parseFilters(query url.Values) ([]Filter, error) {
+	var filters []Filter
+	for param, values := range query {
+		rest, ok := strings.CutPrefix(param, "filter[")
+		if !ok {
+			continue
+		}
+		path, rest, ok := strings.Cut(rest, "]")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("%w: malformed filter parameter %q", InvalidQueryErr, param)
+		}
+		op := OpEq
+		if rest != "" {
+			opName, ok := strings.CutPrefix(rest, "[")
+			if !ok || !strings.HasSuffix(opName, "]") {
+				return nil, fmt.Errorf("%w: malformed filter parameter %q", InvalidQueryErr, param)
+			}
+			op = FilterOp(strings.TrimSuffix(opName, "]"))
+		}
+		for _, value := range values {
+			filters = append(filters, Filter{Path: path, Op: op, Value: filterValue(value)})
+		}
+	}
+	// map iteration is random, keep the filters in a stable order for the stores translating them to queries
+	sort.SliceStable(filters, func(i, j int) bool {
+		if filters[i].Path != filters[j].Path {
+			return filters[i].Path < filters[j].Path
+		}
+		return filters[i].Op < filters[j].Op
+	})
+	return filters, nil
+}
+
+// filterValue converts the value of a filter parameter into the json value it is compared to
+func filterValue(value string) any {
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err == nil {
+		return v
+	}
+	return value
+}
+
+// parseSort reads the order of a list request from the sort query parameter, a comma separated list of paths,
+// each prefixed by - for a descending order, e.g. sort=-updated_at,name
+func parseSort(query url.Values) []SortField {
+	var fields []SortField
+	for _, param := range query["sort"] {
+		if param == "" {
+			continue
+		}
+		for _, path := range strings.Split(param, ",") {
+			path = strings.TrimSpace(path)
+			desc := strings.HasPrefix(path, "-")
+			// + is decoded as a space when not escaped, it is trimmed above
+			path = strings.TrimPrefix(strings.TrimPrefix(path, "-"), "+")
+			fields = append(fields, SortField{Path: path, Desc: desc})
+		}
+	}
+	return fields
+}
+
+// parsePopulate reads the references to resolve from the populate query parameter, a comma separated list of the
+// paths holding references, e.g. populate=author,tags; see Populate
+func parsePopulate(query url.Values) []string {
+	var paths []string
+	for _, param := range query["populate"] {
+		if param == "" {
+			continue
+		}
+		for _, path := range strings.Split(param, ",") {
+			paths = append(paths, strings.TrimSpace(path))
+		}
+	}
+	return paths
+}
+
+// parseFields reads the projection of a request from the fields query parameter, a comma separated list of the
+// fields to return, e.g. fields=name,email; nested fields are dot separated paths, e.g. address.city
+func parseFields(query url.Values) []string {
+	var fields []string
+	for _, param := range query["fields"] {
+		if param == "" {
+			continue
+		}
+		for _, field := range strings.Split(param, ",") {
+			fields = append(fields, strings.TrimSpace(field))
+		}
+	}
+	return fields
+}
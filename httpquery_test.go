@@ -0,0 +1,195 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newQueryHandler returns the handlers of a MemStore and a DbStore holding the same users collection
+func newQueryHandler(t *testing.T) map[string]*jsonstore.Handler {
+	t.Helper()
+	users := map[string]string{
+		"alice": `{"name":"alice","status":"active","age":31,"zip":"8000"}`,
+		"bob":   `{"name":"bob","status":"active","age":25,"zip":"3000"}`,
+		"carol": `{"name":"carol","status":"inactive","age":42,"zip":"8000"}`,
+		"dave":  `{"name":"dave","status":"active","age":38}`,
+	}
+	handlers := map[string]*jsonstore.Handler{}
+	for name, store := range map[string]jsonstore.JsonStorer{"MemStore": jsonstore.NewMemStore(), "DbStore": newDbStore(t)} {
+		for key, value := range users {
+			if err := store.Set(context.Background(), "users", key, json.RawMessage(value)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		handlers[name] = &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "users"}
+	}
+	return handlers
+}
+
+func TestHandlerListFilters(t *testing.T) {
+	tcs := []struct {
+		name       string
+		query      string
+		want       []string
+		wantStatus int
+	}{
+		{name: "equal", query: "filter[status]=active", want: []string{"alice", "bob", "dave"}},
+		{name: "operation", query: "filter[age][gt]=30", want: []string{"alice", "carol", "dave"}},
+		{name: "combined", query: "filter[status]=active&filter[age][gte]=31", want: []string{"alice", "dave"}},
+		{name: "json string", query: `filter[zip]="8000"`, want: []string{"alice", "carol"}},
+		{name: "number does not match a string", query: "filter[zip]=8000", want: []string{}},
+		{name: "not equal", query: "filter[zip][ne]=\"8000\"", want: []string{"bob", "dave"}},
+		{name: "unknown operation", query: "filter[age][like]=3", wantStatus: http.StatusBadRequest},
+		{name: "malformed", query: "filter[age=3", wantStatus: http.StatusBadRequest},
+	}
+
+	for name, handler := range newQueryHandler(t) {
+		for _, tc := range tcs {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/users/?"+tc.query, nil)
+				rec := httptest.NewRecorder()
+
+				handler.ServeHTTP(rec, req)
+
+				wantStatus := tc.wantStatus
+				if wantStatus == 0 {
+					wantStatus = http.StatusOK
+				}
+				if rec.Code != wantStatus {
+					t.Fatalf("expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+				}
+				if tc.wantStatus != 0 {
+					return
+				}
+				page := jsonstore.Page{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				got := make([]string, 0, len(page.Items))
+				for key := range page.Items {
+					got = append(got, key)
+				}
+				sort.Strings(got)
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("unexpected keys (-got +want):\n%s", diff)
+				}
+				if page.Total != int64(len(tc.want)) {
+					t.Errorf("expected total %d, got %d", len(tc.want), page.Total)
+				}
+			})
+		}
+	}
+}
+
+func TestHandlerListSort(t *testing.T) {
+	tcs := []struct {
+		name       string
+		query      string
+		want       []string
+		wantStatus int
+	}{
+		{name: "ascending", query: "sort=age", want: []string{"bob", "alice", "dave", "carol"}},
+		{name: "descending", query: "sort=-age", want: []string{"carol", "dave", "alice", "bob"}},
+		{name: "missing field first", query: "sort=zip,name", want: []string{"dave", "bob", "alice", "carol"}},
+		{name: "several fields", query: "sort=-status,-name", want: []string{"carol", "dave", "bob", "alice"}},
+		{name: "filtered and paged", query: "filter[status]=active&sort=-age&limit=2", want: []string{"dave", "alice"}},
+		{name: "empty path", query: "sort=name,", wantStatus: http.StatusBadRequest},
+	}
+
+	for name, handler := range newQueryHandler(t) {
+		for _, tc := range tcs {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/users/?"+tc.query, nil)
+				rec := httptest.NewRecorder()
+
+				handler.ServeHTTP(rec, req)
+
+				wantStatus := tc.wantStatus
+				if wantStatus == 0 {
+					wantStatus = http.StatusOK
+				}
+				if rec.Code != wantStatus {
+					t.Fatalf("expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+				}
+				if tc.wantStatus != 0 {
+					return
+				}
+				if diff := cmp.Diff(responseKeys(t, rec.Body.Bytes()), tc.want); diff != "" {
+					t.Errorf("unexpected keys (-got +want):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+// responseKeys returns the keys of a list response in the order of the sort
+func responseKeys(t *testing.T, body []byte) []string {
+	t.Helper()
+	page := jsonstore.Page{}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Keys) != len(page.Items) {
+		t.Fatalf("expected the keys of the %d items, got %v", len(page.Items), page.Keys)
+	}
+	return page.Keys
+}
+
+func TestHandlerFields(t *testing.T) {
+	tcs := []struct {
+		name       string
+		url        string
+		want       string
+		wantStatus int
+	}{
+		{name: "get", url: "/users/alice?fields=name,age", want: `{"age":31,"name":"alice"}`},
+		{name: "get missing field", url: "/users/dave?fields=name,zip", want: `{"name":"dave"}`},
+		{name: "get without fields", url: "/users/bob", want: `{"name":"bob","status":"active","age":25,"zip":"3000"}`},
+		{name: "list", url: "/users/?fields=name&filter[zip]=\"8000\"", want: `{"alice":{"name":"alice"},"carol":{"name":"carol"}}`},
+		{name: "empty field", url: "/users/alice?fields=name,,age", wantStatus: http.StatusBadRequest},
+	}
+
+	for name, handler := range newQueryHandler(t) {
+		for _, tc := range tcs {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+				rec := httptest.NewRecorder()
+
+				handler.ServeHTTP(rec, req)
+
+				wantStatus := tc.wantStatus
+				if wantStatus == 0 {
+					wantStatus = http.StatusOK
+				}
+				if rec.Code != wantStatus {
+					t.Fatalf("expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+				}
+				if tc.wantStatus != 0 {
+					return
+				}
+				got := rec.Body.Bytes()
+				if strings.HasSuffix(tc.url, "/") || strings.Contains(tc.url, "/?") {
+					page := jsonstore.Page{}
+					if err := json.Unmarshal(got, &page); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					got, _ = json.Marshal(page.Items)
+				}
+				var gotValue, wantValue any
+				_ = json.Unmarshal(got, &gotValue)
+				_ = json.Unmarshal([]byte(tc.want), &wantValue)
+				if diff := cmp.Diff(gotValue, wantValue); diff != "" {
+					t.Errorf("unexpected body (-got +want):\n%s", diff)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package jsonstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaViolationErr is returned for a document not matching the JSON Schema of its collection, the returned
+// *SchemaError lists the violations
+var SchemaViolationErr = errors.New("document does not match the schema")
+
+// SchemaViolation is a single reason for a document not to match its schema
+type SchemaViolation struct {
+	// Path is the JSON Pointer of the invalid value in the document, e.g. "/address/zip", empty for the document
+	Path string `json:"path"`
+	// Keyword is the JSON Pointer of the failing keyword in the schema, e.g. "/properties/address/required"
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// SchemaError is returned by SchemaValidator.Validate for an invalid document, it wraps SchemaViolationErr
+type SchemaError struct {
+	Collection string
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Path+": "+v.Message)
+	}
+	return fmt.Sprintf("%v of collection %s: %s", SchemaViolationErr, e.Collection, strings.Join(msgs, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return SchemaViolationErr
+}
+
+// SchemaValidator validates the documents of the collections against their JSON Schema, set it on the HttpStorer to
+// reject the POST, PUT and PATCH requests writing an invalid document with a 422 Unprocessable Entity listing the
+// violations, without calling the store. Documents of collections without schema are not validated.
+type SchemaValidator struct {
+	raw     map[string]json.RawMessage
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewSchemaValidator compiles the JSON Schemas of the collections, keyed by collection; drafts 4 to 2020-12 are
+// supported, 2020-12 if the schema has no $schema. Schemas can't $ref external documents.
+func NewSchemaValidator(schemas map[string]json.RawMessage) (*SchemaValidator, error) {
+	v := &SchemaValidator{raw: map[string]json.RawMessage{}, schemas: map[string]*jsonschema.Schema{}}
+	for collection, schema := range schemas {
+		url := "urn:jsonstore:schema:" + collection
+		compiler := jsonschema.NewCompiler()
+		compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+			return nil, fmt.Errorf("external schema %s not supported", s)
+		}
+		if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+			return nil, fmt.Errorf("invalid schema of collection %s: %v", collection, err)
+		}
+		compiled, err := compiler.Compile(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema of collection %s: %v", collection, err)
+		}
+		v.raw[collection] = schema
+		v.schemas[collection] = compiled
+	}
+	return v, nil
+}
+
+// Schemas returns the JSON Schemas of the collections, e.g. for OpenAPIOptions.Schemas
+func (v *SchemaValidator) Schemas() map[string]json.RawMessage {
+	return v.raw
+}
+
+// Validate validates a document against the schema of its collection, returning a *SchemaError if it does not
+// match, or InvalidJsonErr if it is not json
+func (v *SchemaValidator) Validate(collection string, doc json.RawMessage) error {
+	schema, ok := v.schemas[collection]
+	if !ok {
+		return nil
+	}
+	value, err := decodeJsonNumbers(doc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidJsonErr, err)
+	}
+	err = schema.Validate(value)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	schemaErr := &SchemaError{Collection: collection}
+	var collect func(ve *jsonschema.ValidationError)
+	collect = func(ve *jsonschema.ValidationError) {
+		// only the leaves are the violations, their parents sum them up
+		if len(ve.Causes) == 0 {
+			schemaErr.Violations = append(schemaErr.Violations, SchemaViolation{
+				Path:    ve.InstanceLocation,
+				Keyword: ve.KeywordLocation,
+				Message: ve.Message,
+			})
+		}
+		for _, cause := range ve.Causes {
+			collect(cause)
+		}
+	}
+	collect(ve)
+	return schemaErr
+}
+
+// validate validates a document with the SchemaValidator of the HttpStorer, writing the error response if the
+// document is invalid
+func (h *HttpStorer) validate(w http.ResponseWriter, r *http.Request, collection string, doc json.RawMessage) bool {
+	if h.Schemas == nil {
+		return true
+	}
+	err := h.Schemas.Validate(collection, doc)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, SchemaViolationErr):
+		h.writeError(w, r, http.StatusUnprocessableEntity, fmt.Errorf("failed to store data: %w", err))
+	case errors.Is(err, InvalidJsonErr):
+		h.writeError(w, r, http.StatusBadRequest, fmt.Errorf("failed to store data: %w", err))
+	default:
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to store data: %w", err))
+	}
+	return false
+}
+
+// schemaCheck returns the function validating the documents of the collection with the SchemaValidator of the
+// HttpStorer, nil if the collection has no schema
+func (h *HttpStorer) schemaCheck(collection string) func(doc json.RawMessage) error {
+	if h.Schemas == nil || h.Schemas.schemas[collection] == nil {
+		return nil
+	}
+	return func(doc json.RawMessage) error { return h.Schemas.Validate(collection, doc) }
+}
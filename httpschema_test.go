@@ -0,0 +1,213 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/websocket"
+)
+
+// userSchema is the schema of the users collection of the schema tests
+const userSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func TestSchemaValidator(t *testing.T) {
+	validator, err := jsonstore.NewSchemaValidator(map[string]json.RawMessage{"users": json.RawMessage(userSchema)})
+	if err != nil {
+		t.Fatalf("action: NewSchemaValidator,  returned an error: %v", err)
+	}
+
+	tcs := []struct {
+		name           string
+		collection     string
+		doc            string
+		wantErr        error
+		wantViolations []jsonstore.SchemaViolation
+	}{
+		{name: "valid", collection: "users", doc: `{"name":"alice","age":30}`},
+		{name: "collection without schema", collection: "notes", doc: `[1,2]`},
+		{name: "invalid json", collection: "users", doc: `{"name":`, wantErr: jsonstore.InvalidJsonErr},
+		{
+			name:       "violations",
+			collection: "users",
+			doc:        `{"age":-1}`,
+			wantErr:    jsonstore.SchemaViolationErr,
+			wantViolations: []jsonstore.SchemaViolation{
+				{Path: "", Keyword: "/required", Message: "missing properties: 'name'"},
+				{Path: "/age", Keyword: "/properties/age/minimum", Message: "must be >= 0 but found -1"},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validator.Validate(tc.collection, json.RawMessage(tc.doc))
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.wantErr, err)
+			}
+			var schemaErr *jsonstore.SchemaError
+			if errors.As(err, &schemaErr) {
+				if diff := cmp.Diff(schemaErr.Violations, tc.wantViolations); diff != "" {
+					t.Errorf("unexpected violations (-got +want):\n%s", diff)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid schema", func(t *testing.T) {
+		_, err := jsonstore.NewSchemaValidator(map[string]json.RawMessage{"users": json.RawMessage(`{"type":"unknown"}`)})
+		if err == nil {
+			t.Errorf("expected an error for an invalid schema")
+		}
+	})
+
+	t.Run("external reference", func(t *testing.T) {
+		_, err := jsonstore.NewSchemaValidator(map[string]json.RawMessage{"users": json.RawMessage(`{"$ref":"https://example.com/s.json"}`)})
+		if err == nil {
+			t.Errorf("expected an error for an external reference")
+		}
+	})
+}
+
+func TestHandlerSchemas(t *testing.T) {
+	ctx := context.Background()
+	validator, err := jsonstore.NewSchemaValidator(map[string]json.RawMessage{"users": json.RawMessage(userSchema)})
+	if err != nil {
+		t.Fatalf("action: NewSchemaValidator,  returned an error: %v", err)
+	}
+
+	tcs := []struct {
+		name           string
+		method         string
+		url            string
+		contentType    string
+		body           string
+		wantStatus     int
+		wantViolations int
+	}{
+		{name: "valid post", method: http.MethodPost, url: "/", body: `{"name":"bob"}`, wantStatus: http.StatusCreated},
+		{name: "invalid post", method: http.MethodPost, url: "/", body: `{"age":"old"}`, wantStatus: http.StatusUnprocessableEntity, wantViolations: 2},
+		{name: "valid put", method: http.MethodPut, url: "/bob", body: `{"name":"bob"}`, wantStatus: http.StatusCreated},
+		{name: "invalid put", method: http.MethodPut, url: "/alice", body: `{"name":""}`, wantStatus: http.StatusUnprocessableEntity, wantViolations: 1},
+		{name: "valid patch", method: http.MethodPatch, url: "/alice", contentType: string(jsonstore.MergePatch),
+			body: `{"age":31}`, wantStatus: http.StatusOK},
+		{name: "patch producing an invalid document", method: http.MethodPatch, url: "/alice", contentType: string(jsonstore.MergePatch),
+			body: `{"name":null}`, wantStatus: http.StatusUnprocessableEntity, wantViolations: 1},
+		{name: "invalid json", method: http.MethodPut, url: "/alice", body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "valid import", method: http.MethodPost, url: "/_import", body: `{"bob":{"name":"bob"}}`, wantStatus: http.StatusOK},
+		{name: "invalid import", method: http.MethodPost, url: "/_import", body: `{"alice":{"name":""}}`,
+			wantStatus: http.StatusUnprocessableEntity, wantViolations: 1},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := jsonstore.NewMemStore()
+			if err := store.Set(ctx, "users", "alice", json.RawMessage(`{"name":"alice","age":30}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store, Schemas: validator}, Collection: "users", Admin: true}
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusUnprocessableEntity {
+				return
+			}
+			var problem jsonstore.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if problem.Type != jsonstore.ProblemTypePrefix+"schema-violation" || len(problem.Violations) != tc.wantViolations {
+				t.Errorf("unexpected problem: %+v", problem)
+			}
+			var doc json.RawMessage
+			if err := store.Get(ctx, "users", "alice", &doc); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(doc), `{"name":"alice","age":30}`); diff != "" {
+				t.Errorf("expected the document to be unchanged (-got +want):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("live sync", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		if err := store.Set(ctx, "users", "alice", json.RawMessage(`{"name":"alice","age":30}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		handler := &jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: store, Schemas: validator, LiveSync: &jsonstore.LiveSyncOptions{}},
+			Collection: "users",
+		}
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/_sync", "", server.URL)
+		if err != nil {
+			t.Fatalf("action: Dial,  returned an error: %v", err)
+		}
+		defer ws.Close()
+
+		synctcs := []struct {
+			name       string
+			req        jsonstore.SyncRequest
+			wantStatus int
+		}{
+			{name: "invalid set", req: jsonstore.SyncRequest{ID: "1", Op: jsonstore.OperationSet, Key: "alice", Value: json.RawMessage(`{"name":""}`)},
+				wantStatus: http.StatusUnprocessableEntity},
+			{name: "patch producing an invalid document", req: jsonstore.SyncRequest{ID: "2", Op: jsonstore.OperationPatch, Key: "alice",
+				Value: json.RawMessage(`{"name":null}`)}, wantStatus: http.StatusUnprocessableEntity},
+			{name: "valid set", req: jsonstore.SyncRequest{ID: "3", Op: jsonstore.OperationSet, Key: "bob", Value: json.RawMessage(`{"name":"bob"}`)}},
+		}
+		for _, tc := range synctcs {
+			if err = websocket.JSON.Send(ws, tc.req); err != nil {
+				t.Fatalf("action: Send,  returned an error: %v", err)
+			}
+			msg := jsonstore.SyncMessage{}
+			// skip the changes of the valid writes
+			for msg.ID == "" {
+				_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+				msg = jsonstore.SyncMessage{}
+				if err = websocket.JSON.Receive(ws, &msg); err != nil {
+					t.Fatalf("action: Receive,  returned an error: %v", err)
+				}
+			}
+			if msg.ID != tc.req.ID {
+				t.Fatalf("%s: expected the response to %s, got %+v", tc.name, tc.req.ID, msg)
+			}
+			status := 0
+			if msg.Error != nil {
+				status = msg.Error.Status
+			}
+			if status != tc.wantStatus {
+				t.Errorf("%s: expected status %d, got %+v", tc.name, tc.wantStatus, msg.Error)
+			}
+		}
+		var doc json.RawMessage
+		if err = store.Get(ctx, "users", "alice", &doc); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(string(doc), `{"name":"alice","age":30}`); diff != "" {
+			t.Errorf("expected the document to be unchanged (-got +want):\n%s", diff)
+		}
+	})
+}
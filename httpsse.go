@@ -0,0 +1,79 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// changesPath is the key of the change stream of a collection, reserved when ChangeStream is set
+const changesPath = "_changes"
+
+// sseKeepAlive is the interval of the comments sent on an idle change stream, so that proxies don't close it
+const sseKeepAlive = 30 * time.Second
+
+// StreamChanges streams the changes of a collection as Server-Sent Events until the client disconnects, it requires
+// the store to implement Watcher. Every change is an event named after its EventType, with the Event as json data:
+//
+//	event: set
+//	data: {"type":"set","collection":"users","key":"alice","value":{"name":"alice"}}
+//
+// The prefix query parameter only streams the changes of the keys starting with it. Only the changes made after
+// the request are sent, clients list the collection first to get the current documents.
+func (h *HttpStorer) StreamChanges(w http.ResponseWriter, r *http.Request, collection string) {
+	watcher, ok := h.Storer.(Watcher)
+	if !ok {
+		h.writeError(w, r, http.StatusNotImplemented, fmt.Errorf("failed to watch changes: not supported by the store"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to watch changes: streaming not supported"))
+		return
+	}
+	events, err := watcher.Watch(r.Context(), collection)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to watch changes: %w", err))
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// disables the response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				// the watch ended, with the request or with the store
+				return
+			}
+			if !strings.HasPrefix(event.Key, prefix) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				// a document stored with invalid json, the change is sent without its value
+				event.Value = nil
+				data, _ = json.Marshal(event)
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,108 @@
+package jsonstore_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandlerChangeStream(t *testing.T) {
+	ctx := context.Background()
+	store, err := jsonstore.NewFileStore(jsonstore.InMemoryDb)
+	if err != nil {
+		t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+	}
+	handler := &jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: store, ChangeStream: true},
+		Collection: "users",
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"/_changes?prefix=a", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("action: GET _changes,  returned an error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if diff := cmp.Diff(resp.Header.Get("Content-Type"), "text/event-stream"); diff != "" {
+		t.Errorf("unexpected Content-Type (-got +want):\n%s", diff)
+	}
+
+	if err = store.Set(ctx, "users", "bob", json.RawMessage(`{"name":"bob"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if err = store.Set(ctx, "users", "alice", json.RawMessage(`{"name":"alice"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if _, err = store.Delete(ctx, "users", "alice"); err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, jsonstore.Event) {
+		t.Helper()
+		name, event := "", jsonstore.Event{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("unable to read the stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return name, event
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+					t.Fatalf("invalid event data %q: %v", line, err)
+				}
+			}
+		}
+	}
+
+	want := []jsonstore.Event{
+		{Type: jsonstore.EventSet, Collection: "users", Key: "alice", Value: json.RawMessage(`{"name":"alice"}`)},
+		{Type: jsonstore.EventDelete, Collection: "users", Key: "alice"},
+	}
+	for _, wantEvent := range want {
+		name, event := readEvent()
+		if diff := cmp.Diff(name, string(wantEvent.Type)); diff != "" {
+			t.Errorf("unexpected event name (-got +want):\n%s", diff)
+		}
+		if diff := cmp.Diff(event, wantEvent); diff != "" {
+			t.Errorf("unexpected event (-got +want):\n%s", diff)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "users"}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_changes", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("store without watch", func(t *testing.T) {
+		handler := jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore(), ChangeStream: true}, Collection: "users"}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_changes", nil))
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("expected status %d, got %d", http.StatusNotImplemented, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// syncPath is the key of the live sync WebSocket of a collection, reserved when LiveSync is set
+const syncPath = "_sync"
+
+// LiveSyncOptions configures the live sync WebSocket endpoint, see ServeLiveSync
+type LiveSyncOptions struct {
+	// AllowedOrigins are the origins of the browser pages allowed to connect, e.g. "https://app.example.com",
+	// "*" allows any origin. If empty only the pages served from the host of the request can connect.
+	// Clients that are not browsers, sending no Origin header, are always accepted.
+	AllowedOrigins []string
+}
+
+// checkOrigin rejects the WebSocket handshakes of pages from origins not allowed, protecting the cookies of the
+// users from cross-site WebSocket hijacking
+func (o *LiveSyncOptions) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(o.AllowedOrigins, "*") || slices.Contains(o.AllowedOrigins, origin) {
+		return nil
+	}
+	if len(o.AllowedOrigins) == 0 {
+		if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+// SyncRequest is a message sent by a live sync client to read or write a document of the collection.
+// Op is one of OperationGet, OperationSet, OperationPatch or OperationDelete; Value is the document to set or the
+// patch, of PatchType, MergePatch if empty.
+type SyncRequest struct {
+	// ID is returned in the response, so that clients can match the responses to their requests
+	ID        string          `json:"id,omitempty"`
+	Op        Operation       `json:"op"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	PatchType PatchType       `json:"patchType,omitempty"`
+}
+
+// SyncMessage is a message sent by the server to a live sync client: either the response to a SyncRequest,
+// holding its ID and the document read or patched, or the problem if the request failed; or a change of the
+// collection in Event.
+type SyncMessage struct {
+	ID    string          `json:"id,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Error *Problem        `json:"error,omitempty"`
+	Event *Event          `json:"event,omitempty"`
+}
+
+// ServeLiveSync upgrades the request to a WebSocket on which the client sends SyncRequest messages to read and
+// write the documents of the collection, and receives the SyncMessage responses as well as, for stores
+// implementing Watcher, the changes of the collection made by any client, including its own writes.
+// Every request is checked with Authorize, connecting requires OperationWatch.
+func (h *HttpStorer) ServeLiveSync(w http.ResponseWriter, r *http.Request, collection string) {
+	opts := h.LiveSync
+	if opts == nil {
+		opts = &LiveSyncOptions{}
+	}
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, req *http.Request) error {
+			return opts.checkOrigin(req)
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.liveSync(ws, r, collection)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// liveSync serves the messages of a live sync connection until the client closes it
+func (h *HttpStorer) liveSync(ws *websocket.Conn, r *http.Request, collection string) {
+	defer ws.Close()
+	limit := h.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+	if limit > 0 {
+		ws.MaxPayloadBytes = int(limit)
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// the responses and the changes are sent from two goroutines
+	var mutex sync.Mutex
+	send := func(msg SyncMessage) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return websocket.JSON.Send(ws, msg)
+	}
+
+	if watcher, ok := h.Storer.(Watcher); ok {
+		events, err := watcher.Watch(ctx, collection)
+		if err != nil {
+			p := h.problem(r, http.StatusInternalServerError, fmt.Errorf("failed to watch changes: %w", err))
+			_ = send(SyncMessage{Error: &p})
+			return
+		}
+		go func() {
+			for event := range events {
+				if err := send(SyncMessage{Event: &event}); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		req := SyncRequest{}
+		err := websocket.JSON.Receive(ws, &req)
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+				err = fmt.Errorf("%w: %v", InvalidJsonErr, err)
+			case errors.Is(err, websocket.ErrFrameTooLarge):
+				err = fmt.Errorf("%w: the limit is %d bytes", bodyTooLargeErr, ws.MaxPayloadBytes)
+			default:
+				// io.EOF once the client closes the connection
+				return
+			}
+			p := h.problem(r, http.StatusBadRequest, fmt.Errorf("invalid message: %w", err))
+			if send(SyncMessage{Error: &p}) != nil {
+				return
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err = send(h.syncRequest(ctx, r, collection, req)); err != nil {
+			return
+		}
+	}
+}
+
+// syncRequest runs the operation of a SyncRequest and returns its response
+func (h *HttpStorer) syncRequest(ctx context.Context, r *http.Request, collection string, req SyncRequest) SyncMessage {
+	resp := SyncMessage{ID: req.ID}
+	fail := func(status int, err error) SyncMessage {
+		p := h.problem(r, status, fmt.Errorf("failed to %s %s: %w", req.Op, req.Key, err))
+		resp.Error = &p
+		return resp
+	}
+	if req.Key == "" {
+		return fail(http.StatusBadRequest, missingKeyErr)
+	}
+	if h.ReadOnly && req.Op.IsWrite() {
+		return fail(http.StatusMethodNotAllowed, ReadOnlyErr)
+	}
+	if h.Authorize != nil {
+		if err := h.Authorize(r, req.Op, collection, req.Key); err != nil {
+			if errors.Is(err, UnauthorizedErr) {
+				return fail(http.StatusUnauthorized, err)
+			}
+			return fail(http.StatusForbidden, err)
+		}
+	}
+
+	var err error
+	switch req.Op {
+	case OperationGet:
+		var value json.RawMessage
+		err = h.Storer.Get(ctx, collection, req.Key, &value)
+		resp.Value = value
+	case OperationSet:
+		if len(req.Value) == 0 {
+			return fail(http.StatusBadRequest, fmt.Errorf("%w: missing value", InvalidJsonErr))
+		}
+		if check := h.schemaCheck(collection); check != nil {
+			err = check(req.Value)
+		}
+		if err == nil {
+			err = h.Storer.Set(ctx, collection, req.Key, req.Value)
+		}
+	case OperationPatch:
+		patchType := req.PatchType
+		if patchType == "" {
+			patchType = MergePatch
+		}
+		resp.Value, err = patchDocument(ctx, h.Storer, collection, req.Key, req.Value, patchType, h.schemaCheck(collection))
+	case OperationDelete:
+		var deleted bool
+		deleted, err = h.Storer.Delete(ctx, collection, req.Key)
+		if err == nil && !deleted {
+			err = ItemNotFoundErr
+		}
+	default:
+		return fail(http.StatusBadRequest, fmt.Errorf("%w %q", unsupportedOperationErr, req.Op))
+	}
+	if err != nil {
+		return fail(syncErrStatus(err), err)
+	}
+	return resp
+}
+
+// syncErrStatus returns the status of the problem of a failed SyncRequest, the one of the equivalent HTTP request
+func syncErrStatus(err error) int {
+	switch {
+	case isNotFound(err):
+		return http.StatusNotFound
+	case errors.Is(err, SchemaViolationErr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, InvalidJsonErr), errors.Is(err, InvalidPatchErr):
+		return http.StatusBadRequest
+	case errors.Is(err, PatchConflictErr):
+		return http.StatusConflict
+	case errors.Is(err, VersionConflictErr):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ReadOnlyErr):
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusInternalServerError
+}
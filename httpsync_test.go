@@ -0,0 +1,143 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/websocket"
+)
+
+func TestHandlerLiveSync(t *testing.T) {
+	ctx := context.Background()
+	store, err := jsonstore.NewFileStore(jsonstore.InMemoryDb)
+	if err != nil {
+		t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+	}
+	if err = store.Set(ctx, "notes", "a", json.RawMessage(`{"text":"hello"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	handler := &jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: store, LiveSync: &jsonstore.LiveSyncOptions{}},
+		Collection: "notes",
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/_sync"
+
+	dial := func(t *testing.T) *websocket.Conn {
+		t.Helper()
+		ws, err := websocket.Dial(wsURL, "", server.URL)
+		if err != nil {
+			t.Fatalf("action: Dial,  returned an error: %v", err)
+		}
+		t.Cleanup(func() { _ = ws.Close() })
+		return ws
+	}
+	receive := func(t *testing.T, ws *websocket.Conn) jsonstore.SyncMessage {
+		t.Helper()
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		msg := jsonstore.SyncMessage{}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatalf("action: Receive,  returned an error: %v", err)
+		}
+		return msg
+	}
+
+	t.Run("requests", func(t *testing.T) {
+		ws := dial(t)
+		tcs := []struct {
+			name       string
+			req        jsonstore.SyncRequest
+			wantValue  string
+			wantStatus int
+		}{
+			{name: "get", req: jsonstore.SyncRequest{ID: "1", Op: jsonstore.OperationGet, Key: "a"}, wantValue: `{"text":"hello"}`},
+			{name: "get missing", req: jsonstore.SyncRequest{ID: "2", Op: jsonstore.OperationGet, Key: "missing"}, wantStatus: http.StatusNotFound},
+			{name: "patch", req: jsonstore.SyncRequest{ID: "3", Op: jsonstore.OperationPatch, Key: "a", Value: json.RawMessage(`{"done":true}`)},
+				wantValue: `{"done":true,"text":"hello"}`},
+			{name: "unknown operation", req: jsonstore.SyncRequest{ID: "4", Op: "drop", Key: "a"}, wantStatus: http.StatusBadRequest},
+			{name: "missing key", req: jsonstore.SyncRequest{ID: "5", Op: jsonstore.OperationGet}, wantStatus: http.StatusBadRequest},
+		}
+		for _, tc := range tcs {
+			if err := websocket.JSON.Send(ws, tc.req); err != nil {
+				t.Fatalf("action: Send,  returned an error: %v", err)
+			}
+			msg := receive(t, ws)
+			for msg.Event != nil {
+				// changes of the patch
+				msg = receive(t, ws)
+			}
+			if msg.ID != tc.req.ID {
+				t.Fatalf("%s: expected the response to %s, got %+v", tc.name, tc.req.ID, msg)
+			}
+			if tc.wantStatus != 0 {
+				if msg.Error == nil || msg.Error.Status != tc.wantStatus {
+					t.Errorf("%s: expected an error with status %d, got %+v", tc.name, tc.wantStatus, msg.Error)
+				}
+				continue
+			}
+			if msg.Error != nil {
+				t.Fatalf("%s: unexpected error: %+v", tc.name, msg.Error)
+			}
+			if diff := cmp.Diff(string(msg.Value), tc.wantValue); diff != "" {
+				t.Errorf("%s: unexpected value (-got +want):\n%s", tc.name, diff)
+			}
+		}
+	})
+
+	t.Run("changes of other clients", func(t *testing.T) {
+		reader := dial(t)
+		writer := dial(t)
+		// a request round trip makes sure the watch of the reader is registered
+		if err := websocket.JSON.Send(reader, jsonstore.SyncRequest{ID: "ping", Op: jsonstore.OperationGet, Key: "a"}); err != nil {
+			t.Fatalf("action: Send,  returned an error: %v", err)
+		}
+		receive(t, reader)
+
+		if err := websocket.JSON.Send(writer, jsonstore.SyncRequest{ID: "1", Op: jsonstore.OperationSet, Key: "b", Value: json.RawMessage(`{"text":"new"}`)}); err != nil {
+			t.Fatalf("action: Send,  returned an error: %v", err)
+		}
+		msg := receive(t, reader)
+		want := &jsonstore.Event{Type: jsonstore.EventSet, Collection: "notes", Key: "b", Value: json.RawMessage(`{"text":"new"}`)}
+		if diff := cmp.Diff(msg.Event, want); diff != "" {
+			t.Errorf("unexpected event (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		ws := dial(t)
+		if err := websocket.Message.Send(ws, "not json"); err != nil {
+			t.Fatalf("action: Send,  returned an error: %v", err)
+		}
+		msg := receive(t, ws)
+		if msg.Error == nil || msg.Error.Type != jsonstore.ProblemTypePrefix+"invalid-json" {
+			t.Errorf("expected an invalid json problem, got %+v", msg.Error)
+		}
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		handler.ReadOnly = true
+		defer func() { handler.ReadOnly = false }()
+		ws := dial(t)
+		if err := websocket.JSON.Send(ws, jsonstore.SyncRequest{ID: "1", Op: jsonstore.OperationDelete, Key: "a"}); err != nil {
+			t.Fatalf("action: Send,  returned an error: %v", err)
+		}
+		msg := receive(t, ws)
+		if msg.Error == nil || msg.Error.Status != http.StatusMethodNotAllowed {
+			t.Errorf("expected an error with status %d, got %+v", http.StatusMethodNotAllowed, msg.Error)
+		}
+	})
+
+	t.Run("origin not allowed", func(t *testing.T) {
+		if _, err := websocket.Dial(wsURL, "", "https://evil.example.com"); err == nil {
+			t.Errorf("expected the handshake to fail")
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package jsonstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Purger is implemented by stores keeping documents that need to be removed permanently in the background,
+// e.g. the soft deleted documents of a DbStore or expired documents
+type Purger interface {
+	// PurgeBatch permanently removes up to limit of the documents of the collection deleted or expired before the
+	// given time, all of them if limit is not positive; it returns the amount of removed documents
+	PurgeBatch(ctx context.Context, collection string, before time.Time, limit int) (int64, error)
+}
+
+// PurgeLister is implemented by Purger stores able to list the collections holding documents to purge, including
+// the collections whose documents are all deleted, which are not listed by CollectionManager.Collections
+type PurgeLister interface {
+	PurgeCollections(ctx context.Context) ([]string, error)
+}
+
+// DefaultJanitorInterval is the time between the runs of a Janitor if no Interval is configured
+const DefaultJanitorInterval = time.Hour
+
+// DefaultJanitorBatchSize is the amount of documents purged at once by a Janitor if no BatchSize is configured
+const DefaultJanitorBatchSize = 1000
+
+// JanitorOptions configures a Janitor
+type JanitorOptions struct {
+	// Interval is the time between the runs, DefaultJanitorInterval if zero
+	Interval time.Duration
+	// BatchSize is the amount of documents purged at once, DefaultJanitorBatchSize if zero; a run purges batches
+	// until a collection has no documents left to purge, keeping every statement short
+	BatchSize int
+	// Retention is how long deleted documents are kept before being purged, e.g. to be recovered with
+	// DbStore.Restore; zero purges them on the next run
+	Retention time.Duration
+	// Collections are the collections to purge, if empty the collections of a PurgeLister store, or all the
+	// collections of a CollectionManager store
+	Collections []string
+	// Idempotency, if set, purges the expired idempotency records of the store as well, see IdempotencyOptions.Purge
+	Idempotency *IdempotencyOptions
+	// OnRun, if set, is called after every run with its result, e.g. to export metrics
+	OnRun func(run JanitorRun)
+}
+
+// JanitorRun is the result of a run of a Janitor
+type JanitorRun struct {
+	Start    time.Time
+	Duration time.Duration
+	// Purged is the amount of documents removed by collection
+	Purged map[string]int64
+	// Err is the first error of the run, the other collections are purged anyway
+	Err error
+}
+
+// JanitorStats are the counters of a Janitor since it was created
+type JanitorStats struct {
+	Runs    int64
+	Errors  int64
+	Purged  int64
+	LastRun JanitorRun
+}
+
+// Janitor periodically purges the deleted and expired documents of a store implementing Purger, and optionally the
+// expired idempotency records, shared by all the backends that keep such documents.
+type Janitor struct {
+	store JsonStorer
+	opts  JanitorOptions
+	mutex sync.Mutex
+	stats JanitorStats
+}
+
+// NewJanitor returns a janitor for the store, call Run to start it
+func NewJanitor(store JsonStorer, opts JanitorOptions) *Janitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultJanitorInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultJanitorBatchSize
+	}
+	return &Janitor{store: store, opts: opts}
+}
+
+// Run purges the store every Interval until the context is canceled, errors of the runs are passed to onErr
+// (if not nil) and don't stop the loop. It is intended to be run as a goroutine:
+//
+//	go janitor.Run(ctx, func(err error) { log.Print(err) })
+func (j *Janitor) Run(ctx context.Context, onErr func(error)) error {
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			run := j.RunOnce(ctx)
+			if run.Err != nil && onErr != nil {
+				onErr(run.Err)
+			}
+		}
+	}
+}
+
+// RunOnce purges the store once and returns the result of the run
+func (j *Janitor) RunOnce(ctx context.Context) JanitorRun {
+	run := JanitorRun{Start: time.Now(), Purged: map[string]int64{}}
+	setErr := func(err error) {
+		if run.Err == nil {
+			run.Err = err
+		}
+	}
+
+	if purger, ok := j.store.(Purger); ok {
+		collections, err := j.collections(ctx)
+		if err != nil {
+			setErr(err)
+		}
+		before := run.Start.Add(-j.opts.Retention)
+		for _, collection := range collections {
+			n, err := j.purge(ctx, purger, collection, before)
+			if n > 0 {
+				run.Purged[collection] += n
+			}
+			if err != nil {
+				setErr(err)
+			}
+		}
+	}
+	if j.opts.Idempotency != nil {
+		n, err := j.opts.Idempotency.Purge(ctx, j.store)
+		if n > 0 {
+			run.Purged[j.opts.Idempotency.collection()] += int64(n)
+		}
+		if err != nil {
+			setErr(fmt.Errorf("failed to purge idempotency records: %v", err))
+		}
+	}
+	run.Duration = time.Since(run.Start)
+
+	j.mutex.Lock()
+	j.stats.Runs++
+	if run.Err != nil {
+		j.stats.Errors++
+	}
+	for _, n := range run.Purged {
+		j.stats.Purged += n
+	}
+	j.stats.LastRun = run
+	j.mutex.Unlock()
+
+	if j.opts.OnRun != nil {
+		j.opts.OnRun(run)
+	}
+	return run
+}
+
+// Stats returns the counters of the janitor
+func (j *Janitor) Stats() JanitorStats {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.stats
+}
+
+// collections returns the collections to purge
+func (j *Janitor) collections(ctx context.Context) ([]string, error) {
+	if len(j.opts.Collections) > 0 {
+		return j.opts.Collections, nil
+	}
+	if pl, ok := j.store.(PurgeLister); ok {
+		collections, err := pl.PurgeCollections(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %v", err)
+		}
+		return collections, nil
+	}
+	cm, ok := j.store.(CollectionManager)
+	if !ok {
+		return nil, fmt.Errorf("the janitor needs the collections of a store that is not a CollectionManager")
+	}
+	collections, err := cm.Collections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	return collections, nil
+}
+
+// purge removes the documents of a collection in batches, until a batch is not full or ctx is done
+func (j *Janitor) purge(ctx context.Context, purger Purger, collection string, before time.Time) (int64, error) {
+	var total int64
+	for {
+		n, err := purger.PurgeBatch(ctx, collection, before, j.opts.BatchSize)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("failed to purge collection %s: %v", collection, err)
+		}
+		if n < int64(j.opts.BatchSize) {
+			return total, nil
+		}
+		if err = ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJanitor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("purge soft deleted documents", func(t *testing.T) {
+		store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), jsonstore.DbStoreOptions{SoftDelete: true})
+		if err != nil {
+			t.Fatalf("action: NewDbStoreWithOptions,  returned an error: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprintf("item%d", i)
+			if err = store.Set(ctx, "col1", key, json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if _, err = store.Delete(ctx, "col1", key); err != nil {
+				t.Fatalf("action: Delete,  returned an error: %v", err)
+			}
+		}
+		if err = store.Set(ctx, "col1", "kept", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		retained := jsonstore.NewJanitor(store, jsonstore.JanitorOptions{Retention: time.Hour})
+		if run := retained.RunOnce(ctx); run.Err != nil || len(run.Purged) != 0 {
+			t.Errorf("expected the documents to be retained, got %+v", run)
+		}
+
+		janitor := jsonstore.NewJanitor(store, jsonstore.JanitorOptions{BatchSize: 2})
+		run := janitor.RunOnce(ctx)
+		if run.Err != nil {
+			t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+		}
+		if diff := cmp.Diff(run.Purged, map[string]int64{"col1": 5}); diff != "" {
+			t.Errorf("unexpected purged documents (-got +want):\n%s", diff)
+		}
+		if restored, _ := store.Restore(ctx, "col1", "item0"); restored {
+			t.Errorf("expected the purged document not to be restorable")
+		}
+		var got json.RawMessage
+		if err = store.Get(ctx, "col1", "kept", &got); err != nil {
+			t.Errorf("action: Get,  returned an error: %v", err)
+		}
+		stats := janitor.Stats()
+		if stats.Runs != 1 || stats.Purged != 5 || stats.Errors != 0 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("purge fully deleted collections", func(t *testing.T) {
+		tcs := []struct {
+			name string
+			opts jsonstore.DbStoreOptions
+		}{
+			{name: "shared table", opts: jsonstore.DbStoreOptions{SoftDelete: true}},
+			{name: "table per collection", opts: jsonstore.DbStoreOptions{SoftDelete: true, TablePerCollection: true}},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), tc.opts)
+				if err != nil {
+					t.Fatalf("action: NewDbStoreWithOptions,  returned an error: %v", err)
+				}
+				for _, collection := range []string{"col1", "col2"} {
+					if err = store.Set(ctx, collection, "item", json.RawMessage(`{}`)); err != nil {
+						t.Fatalf("action: Set,  returned an error: %v", err)
+					}
+				}
+				if _, err = store.Delete(ctx, "col2", "item"); err != nil {
+					t.Fatalf("action: Delete,  returned an error: %v", err)
+				}
+				collections, err := store.Collections(ctx)
+				if err != nil {
+					t.Fatalf("action: Collections,  returned an error: %v", err)
+				}
+				if diff := cmp.Diff(collections, []string{"col1"}); diff != "" {
+					t.Fatalf("unexpected collections (-got +want):\n%s", diff)
+				}
+
+				run := jsonstore.NewJanitor(store, jsonstore.JanitorOptions{}).RunOnce(ctx)
+				if run.Err != nil {
+					t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+				}
+				if diff := cmp.Diff(run.Purged, map[string]int64{"col2": 1}); diff != "" {
+					t.Errorf("unexpected purged documents (-got +want):\n%s", diff)
+				}
+				if restored, _ := store.Restore(ctx, "col2", "item"); restored {
+					t.Errorf("expected the purged document not to be restorable")
+				}
+			})
+		}
+	})
+
+	t.Run("purge expired idempotency records", func(t *testing.T) {
+		store := jsonstore.NewMemStore()
+		err := store.Set(ctx, jsonstore.DefaultIdempotencyCollection, "expired", json.RawMessage(`{"expiresAt":"2000-01-01T00:00:00Z"}`))
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		janitor := jsonstore.NewJanitor(store, jsonstore.JanitorOptions{Idempotency: &jsonstore.IdempotencyOptions{}})
+		run := janitor.RunOnce(ctx)
+		if diff := cmp.Diff(run.Purged, map[string]int64{jsonstore.DefaultIdempotencyCollection: 1}); diff != "" {
+			t.Errorf("unexpected purged documents (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		store := &purgerStore{FakeStore: &jsonstoretest.FakeStore{}, err: errors.New("database is down")}
+		janitor := jsonstore.NewJanitor(store, jsonstore.JanitorOptions{})
+		if run := janitor.RunOnce(ctx); run.Err == nil {
+			t.Errorf("expected an error without collections")
+		}
+		janitor = jsonstore.NewJanitor(store, jsonstore.JanitorOptions{Collections: []string{"col1"}})
+		if run := janitor.RunOnce(ctx); run.Err == nil || !strings.Contains(run.Err.Error(), store.err.Error()) {
+			t.Errorf("expected the error of the store, got %v", run.Err)
+		}
+		if stats := janitor.Stats(); stats.Errors != 1 {
+			t.Errorf("expected 1 error, got %+v", stats)
+		}
+	})
+
+	t.Run("run periodically", func(t *testing.T) {
+		runs := make(chan jsonstore.JanitorRun, 10)
+		janitor := jsonstore.NewJanitor(jsonstore.NewMemStore(), jsonstore.JanitorOptions{
+			Interval: 5 * time.Millisecond,
+			OnRun: func(run jsonstore.JanitorRun) {
+				select {
+				case runs <- run:
+				default:
+				}
+			},
+		})
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- janitor.Run(ctx, nil) }()
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the janitor did not run")
+		}
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+// purgerStore is a store failing to purge
+type purgerStore struct {
+	*jsonstoretest.FakeStore
+	err error
+}
+
+func (s *purgerStore) PurgeBatch(ctx context.Context, collection string, before time.Time, limit int) (int64, error) {
+	return 0, s.err
+}
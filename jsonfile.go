@@ -8,54 +8,362 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"time"
 )
 
 type FileStore struct {
 	file    string
+	fs      fileSystem
 	mutex   sync.RWMutex
 	content map[string]map[string]json.RawMessage
 
 	// flags
 	inMemory      bool
+	readOnly      bool
+	fileMode      os.FileMode
+	dirMode       os.FileMode
+	lock          *os.File
 	ManualFlush   bool
 	humanReadable bool
+	format        FileFormat
+	gzip          bool
+	perCollection bool
+	lazy          bool
+	recoverable   bool
+	reloadOnRead  bool
+	recovery      *RecoveryReport
+	dirtyCols     map[string]struct{} // collections changed since the last flush with FilePerCollection
+	encryption    *fileEncryption
+
+	// Durability defines when Set and Delete write the content to the file, the default is DurabilitySync
+	Durability Durability
+	// FlushInterval is the maximum delay of a write with DurabilityInterval, if zero DefaultFlushInterval is used
+	FlushInterval time.Duration
+	// FlushAfterWrites flushes the changes of DurabilityInterval as soon as this amount of writes is pending,
+	// without waiting for the interval; zero disables it
+	FlushAfterWrites int
+	// OnFlushError is called with the error of a failed background flush of DurabilityInterval,
+	// the content stays pending and is written again by the next flush
+	OnFlushError func(error)
+	flushTimer   *time.Timer
+	pending      int // writes not yet flushed
+
+	// CompactAfter is the amount of records of the AppendJournal after which they are compacted into the file,
+	// if zero DefaultCompactAfter is used
+	CompactAfter int
+	// MaxLoadedCollections is the amount of collections kept in memory with LazyLoad, the least recently used
+	// collections without pending changes are evicted; zero keeps all of them
+	MaxLoadedCollections int
+	// MaxListItems is the maximum page size of List and ListPage, requests for larger pages get this amount of
+	// documents; if zero the MaxLimit of the request applies, MaxListItems by default
+	MaxListItems int
+	lastAccess   map[string]uint64
+	accessClock  uint64
+
+	journal        writableFile
+	journalRecords int
+
+	persister Persister
+
+	watchers watchHub
 }
 
+// Durability is the trade-off between write latency and safety of the FileStore writes
+type Durability int
+
+const (
+	// DurabilitySync writes the file on every Set and Delete and syncs it to disk before returning:
+	// a successful write survives both a process and an os crash.
+	DurabilitySync Durability = iota
+	// DurabilityNoSync writes the file on every Set and Delete without syncing it: a successful write survives
+	// a process crash but can be lost on an os crash or power loss.
+	DurabilityNoSync
+	// DurabilityInterval writes and syncs the file in the background at most FlushInterval after a change, or
+	// once FlushAfterWrites changes are pending, coalescing the writes of bulk loads into a single flush:
+	// the pending writes are lost on a crash, call Close or Flush to persist them e.g. before exiting.
+	DurabilityInterval
+)
+
+// DefaultFlushInterval is the FlushInterval used by DurabilityInterval if none is set
+const DefaultFlushInterval = time.Second
+
 // make sure the jsonfile store fulfills the JsonStore interface
 var _ JsonStorer = &FileStore{}
+var _ PageLister = &FileStore{}
+var _ Watcher = &FileStore{}
+var _ Snapshotter = &FileStore{}
+var _ Closer = &FileStore{}
+var _ Patcher = &FileStore{}
 
 type FileStoreFlag int
 
 const (
-	MinimizedJson FileStoreFlag = iota
-	ManualFlush                 // force manual flush instead of automatically write/read
+	MinimizedJson     FileStoreFlag = iota
+	ManualFlush                     // force manual flush instead of automatically write/read
+	AppendJournal                   // append the changes to a journal instead of rewriting the file on every write
+	GzipCompressed                  // write the file gzip compressed, compressed files are detected on open regardless
+	FilePerCollection               // the file is a directory with a file per collection, flushes only write the changed ones
+	LazyLoad                        // with FilePerCollection, load the collections on their first access
+	Recoverable                     // write a checksum header and keep a backup to recover a corrupted file on open
+	ReloadOnRead                    // re-read the file on every Get to see the writes of other processes
+	YamlFormat                      // write the file as yaml instead of json
+	TomlFormat                      // write the file as toml instead of json, documents holding null values cannot be stored
 )
 const InMemoryDb = "memory"
 
+// FileStoreOptions configures a FileStore created with NewFileStoreWithOptions, unlike the flags it can carry
+// parameters like intervals, permissions or keys. The zero value is the default store of NewFileStore.
+type FileStoreOptions struct {
+	// ManualFlush only writes the content on Flush and Close
+	ManualFlush bool
+	// Durability, FlushInterval, FlushAfterWrites and OnFlushError define when the writes are flushed,
+	// see the fields of FileStore with the same name
+	Durability       Durability
+	FlushInterval    time.Duration
+	FlushAfterWrites int
+	OnFlushError     func(error)
+	// AppendJournal appends the changes to a journal compacted every CompactAfter records, see the flag
+	AppendJournal bool
+	CompactAfter  int
+
+	// Minimized writes minimized json instead of human-readable json
+	Minimized bool
+	// Format is the format of the file, json by default
+	Format FileFormat
+	// Gzip writes the file gzip compressed
+	Gzip bool
+	// Encryption encrypts the file with AES-GCM, see NewEncryptedFileStore
+	Encryption *FileEncryption
+
+	// FilePerCollection, LazyLoad and MaxLoadedCollections select the layout of the files, see the flags
+	FilePerCollection    bool
+	LazyLoad             bool
+	MaxLoadedCollections int
+	// MaxListItems is the maximum page size of the lists, see the field of FileStore with the same name
+	MaxListItems int
+	// Recoverable writes a checksum header and keeps a backup to recover a corrupted file on open
+	Recoverable bool
+	// ReloadOnRead re-reads the file on every Get to see the writes of other processes
+	ReloadOnRead bool
+	// ReadOnly never writes to the file, see NewFileStoreReadOnly
+	ReadOnly bool
+
+	// FileMode and DirMode are the permissions of the files and directories created by the store,
+	// if zero 0644 and 0755 are used
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	// Lock takes an exclusive lock on the file until Close, a second store opening it fails with FileLockedErr;
+	// it is supported on unix systems only
+	Lock bool
+}
+
+// flagOptions converts the flags of the constructors into options
+func flagOptions(flags []FileStoreFlag) (FileStoreOptions, error) {
+	opts := FileStoreOptions{
+		ManualFlush:       isFlagSet(flags, ManualFlush),
+		AppendJournal:     isFlagSet(flags, AppendJournal),
+		Minimized:         isFlagSet(flags, MinimizedJson),
+		Gzip:              isFlagSet(flags, GzipCompressed),
+		FilePerCollection: isFlagSet(flags, FilePerCollection),
+		LazyLoad:          isFlagSet(flags, LazyLoad),
+		Recoverable:       isFlagSet(flags, Recoverable),
+		ReloadOnRead:      isFlagSet(flags, ReloadOnRead),
+	}
+	switch {
+	case isFlagSet(flags, YamlFormat) && isFlagSet(flags, TomlFormat):
+		return opts, fmt.Errorf("only one of the YamlFormat and TomlFormat flags can be set")
+	case isFlagSet(flags, YamlFormat):
+		opts.Format = FormatYaml
+	case isFlagSet(flags, TomlFormat):
+		opts.Format = FormatToml
+	}
+	return opts, nil
+}
+
+// NewFileStore returns a FileStore persisting its documents to file, or only in memory if file is InMemoryDb.
+// The file is created if it does not exist; if it exists its content is loaded, so that a store can be reopened
+// e.g. after a restart, and NewFileStore fails if the content can not be read, unless the Recoverable flag is set
+// and the backup can be loaded instead.
 func NewFileStore(file string, flags ...FileStoreFlag) (*FileStore, error) {
+	opts, err := flagOptions(flags)
+	if err != nil {
+		return nil, err
+	}
+	return newFileStore(file, opts, nil)
+}
 
-	db := FileStore{
-		file:          file,
-		content:       map[string]map[string]json.RawMessage{},
-		inMemory:      true,
-		ManualFlush:   isFlagSet(flags, ManualFlush),
-		humanReadable: !isFlagSet(flags, MinimizedJson),
+// NewFileStoreWithOptions returns a FileStore configured with opts, file can be InMemoryDb to not write to a file
+func NewFileStoreWithOptions(file string, opts FileStoreOptions) (*FileStore, error) {
+	return newFileStore(file, opts, nil)
+}
+
+// NewEncryptedFileStore returns a FileStore encrypting its file with AES-GCM, see FileEncryption
+func NewEncryptedFileStore(file string, enc FileEncryption, flags ...FileStoreFlag) (*FileStore, error) {
+	opts, err := flagOptions(flags)
+	if err != nil {
+		return nil, err
 	}
+	opts.Encryption = &enc
+	return newFileStore(file, opts, nil)
+}
 
-	// create a file
-	if file != "" && file != InMemoryDb {
-		// If the file doesn't exist, create it, or append to the file
-		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// NewFileStoreReadOnly opens an existing file, or directory with FilePerCollection, without ever writing to it,
+// e.g. to serve reference data baked into a read-only container image. Set, Delete and RestoreSnapshot fail
+// with ReadOnlyErr. Leftovers of interrupted writes are ignored, a corrupted Recoverable file is loaded from its
+// backup in memory only, and the AppendJournal flag is not supported.
+func NewFileStoreReadOnly(file string, flags ...FileStoreFlag) (*FileStore, error) {
+	opts, err := flagOptions(flags)
+	if err != nil {
+		return nil, err
+	}
+	opts.ReadOnly = true
+	return newFileStore(file, opts, nil)
+}
+
+// validate checks the combination of options
+func (opts FileStoreOptions) validate() error {
+	if opts.Format < FormatJson || opts.Format > FormatToml {
+		return fmt.Errorf("invalid file format %d", opts.Format)
+	}
+	if opts.ReadOnly && opts.AppendJournal {
+		return fmt.Errorf("the append journal is not supported in read only mode")
+	}
+	if opts.ReadOnly && opts.Lock {
+		return fmt.Errorf("locking is not supported in read only mode")
+	}
+	if opts.LazyLoad && !opts.FilePerCollection {
+		return fmt.Errorf("lazy loading requires the FilePerCollection layout")
+	}
+	if opts.ReloadOnRead && opts.LazyLoad {
+		return fmt.Errorf("the ReloadOnRead flag is not supported with lazy loading")
+	}
+	if opts.Recoverable && opts.FilePerCollection {
+		return fmt.Errorf("the Recoverable flag is not supported with the FilePerCollection layout")
+	}
+	// the journal records are written in clear text
+	if opts.Encryption != nil && opts.AppendJournal {
+		return fmt.Errorf("the append journal is not supported with encryption")
+	}
+	return nil
+}
+
+func newFileStore(file string, opts FileStoreOptions, persister Persister) (*FileStore, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	db := FileStore{
+		file:                 file,
+		fs:                   osFs{},
+		content:              map[string]map[string]json.RawMessage{},
+		inMemory:             true,
+		ManualFlush:          opts.ManualFlush,
+		humanReadable:        !opts.Minimized,
+		format:               opts.Format,
+		gzip:                 opts.Gzip,
+		perCollection:        opts.FilePerCollection,
+		lazy:                 opts.LazyLoad,
+		recoverable:          opts.Recoverable,
+		reloadOnRead:         opts.ReloadOnRead,
+		readOnly:             opts.ReadOnly,
+		fileMode:             opts.FileMode,
+		dirMode:              opts.DirMode,
+		Durability:           opts.Durability,
+		FlushInterval:        opts.FlushInterval,
+		FlushAfterWrites:     opts.FlushAfterWrites,
+		OnFlushError:         opts.OnFlushError,
+		CompactAfter:         opts.CompactAfter,
+		MaxLoadedCollections: opts.MaxLoadedCollections,
+		MaxListItems:         opts.MaxListItems,
+	}
+	if db.fileMode == 0 {
+		db.fileMode = 0644
+	}
+	if db.dirMode == 0 {
+		db.dirMode = 0755
+	}
+	if opts.ReadOnly && persister == nil && (file == "" || file == InMemoryDb) {
+		return nil, fmt.Errorf("read only mode requires a file")
+	}
+	if opts.Encryption != nil {
+		err := db.setEncryption(*opts.Encryption)
 		if err != nil {
 			return nil, err
 		}
-		f.Close()
-		db.inMemory = false
 	}
 
+	err := db.open(opts, persister)
+	if err != nil {
+		_ = db.releaseLock()
+		return nil, err
+	}
 	return &db, nil
 }
 
+// open locks and loads the file of the store, or the content of the persister
+func (f *FileStore) open(opts FileStoreOptions, persister Persister) error {
+	file := f.file
+	if persister != nil {
+		return f.openPersister(persister, opts)
+	}
+	if file == "" || file == InMemoryDb {
+		return nil
+	}
+	f.inMemory = false
+	if opts.Lock {
+		err := f.acquireLock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.perCollection {
+		err := f.openCollectionsDir()
+		if err != nil {
+			return err
+		}
+	} else {
+		if !f.readOnly {
+			// If the file doesn't exist, create it, or append to the file
+			fh, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, f.fileMode)
+			if err != nil {
+				return err
+			}
+			fh.Close()
+
+			err = discardInterruptedWrite(f.fs, file)
+			if err != nil {
+				return err
+			}
+		}
+
+		// load the content of an already existing file
+		stat, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if stat.Size() > 0 {
+			err = f.readFile()
+			if err != nil && f.recoverable {
+				err = f.recoverFromBackup(err)
+			}
+			if err != nil {
+				return err
+			}
+		} else if bak, bakErr := os.Stat(file + backupSuffix); f.recoverable && bakErr == nil && bak.Size() > 0 {
+			// a crash between moving the file to the backup and renaming the new content over it
+			err = f.recoverFromBackup(fmt.Errorf("file is empty"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.AppendJournal {
+		return f.openJournal()
+	}
+	return nil
+}
+
 func isFlagSet(in []FileStoreFlag, search FileStoreFlag) bool {
 	for i := 0; i < len(in); i++ {
 		if in[i] == search {
@@ -72,136 +380,388 @@ func (f *FileStore) colExists(name string) bool {
 	return true
 }
 
+// Json returns the content of the store as json.
+//
+// Deprecated: Json panics if the content cannot be marshaled, e.g. a document stored with invalid json,
+// use Bytes instead.
 func (f *FileStore) Json() []byte {
+	bytes, err := f.Bytes()
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// Bytes returns the content of the store as json, formatted like the file of the store; it is a copy taken under
+// the read lock of the store, see Snapshot.
+func (f *FileStore) Bytes() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	content, err := f.snapshotContent()
+	if err != nil {
+		return nil, err
+	}
+	return f.marshal(content)
+}
+
+// MarshalJSON implements json.Marshaler, it returns the content of the store as compact json
+func (f *FileStore) MarshalJSON() ([]byte, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	content, err := f.snapshotContent()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal content: %v", err)
+	}
+	return bytes, nil
+}
+
+// marshal encodes v as json, indented unless the store uses MinimizedJson. It fails if a document is not
+// valid json, Set does not validate the values.
+func (f *FileStore) marshal(v any) ([]byte, error) {
 	var bytes []byte
 	var err error
-	// json.Marshal function can return two types of errors: UnsupportedTypeError or UnsupportedValueError
-	// both cases are handled when adding data with Set, hence omitting error handling here
 	if f.humanReadable {
-		bytes, err = json.MarshalIndent(f.content, "", "    ")
+		bytes, err = json.MarshalIndent(v, "", "    ")
+	} else {
+		bytes, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal content: %v", err)
+	}
+	return bytes, nil
+}
+
+func (f *FileStore) flushToFile() error {
+
+	if f.persister != nil {
+		bytes, err := f.marshal(f.content)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		bytes, err = f.encodeFile(bytes)
+		if err != nil {
+			return err
+		}
+		err = f.persister.Save(bytes)
+		if err != nil {
+			return fmt.Errorf("unable to save content: %v", err)
+		}
+	} else if f.perCollection {
+		err := f.flushCollections()
+		if err != nil {
+			return err
 		}
 	} else {
-		bytes, err = json.Marshal(f.content)
+		bytes, err := f.marshal(f.content)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		bytes, err = f.encodeFile(bytes)
+		if err != nil {
+			return err
+		}
+		backup := ""
+		if f.recoverable {
+			backup = f.file + backupSuffix
+		}
+		err = writeFileBackup(f.fs, f.file, backup, bytes, f.fileMode, f.Durability != DurabilityNoSync)
+		if err != nil {
+			return err
 		}
 	}
-	return bytes
+	f.pending = 0
+	if f.journal != nil {
+		return f.truncateJournal()
+	}
+	return nil
 }
 
-func (f *FileStore) flushToFile() error {
+// Flush writes the content to the file, including the changes not yet written with
+// ManualFlush or DurabilityInterval, and compacts the journal
+func (f *FileStore) Flush() error {
+	if f.inMemory || f.readOnly {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stopFlushTimer()
+	return f.flushToFile()
+}
 
-	bytes := f.Json()
-	err := os.WriteFile(f.file, bytes, 0644)
-	if err != nil {
-		return err
+// Close flushes the pending writes of ManualFlush and DurabilityInterval, compacts the journal, releases its
+// file handle and the lock of the file, and stops the background flush
+func (f *FileStore) Close(ctx context.Context) error {
+	if f.inMemory {
+		return nil
 	}
-	return nil
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stopFlushTimer()
+	if f.pending > 0 || f.journalRecords > 0 {
+		if err := f.flushToFile(); err != nil {
+			return err
+		}
+	}
+	if f.journal != nil {
+		err := f.journal.Close()
+		f.journal = nil
+		if err != nil {
+			return fmt.Errorf("unable to close journal: %v", err)
+		}
+	}
+	return f.releaseLock()
 }
 
-func (f *FileStore) Flush() error {
-	if !f.inMemory && !f.ManualFlush {
-		f.mutex.Lock()
-		defer f.mutex.Unlock()
+func (f *FileStore) stopFlushTimer() {
+	if f.flushTimer != nil {
+		f.flushTimer.Stop()
+		f.flushTimer = nil
+	}
+}
+
+// persist writes the change to the journal or the content to the file according to the durability of the store,
+// it needs to be called holding the write lock
+func (f *FileStore) persist(change Event) error {
+	if f.inMemory {
+		return nil
+	}
+	f.markDirty(change.Collection)
+	if f.journal != nil && !f.ManualFlush {
+		return f.appendJournal(change)
+	}
+	f.pending++
+	if f.ManualFlush {
+		return nil
+	}
+	if f.Durability != DurabilityInterval {
+		return f.flushToFile()
+	}
+	if f.FlushAfterWrites > 0 && f.pending >= f.FlushAfterWrites {
+		f.stopFlushTimer()
 		return f.flushToFile()
 	}
+	if f.flushTimer == nil {
+		interval := f.FlushInterval
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+		f.flushTimer = time.AfterFunc(interval, f.intervalFlush)
+	}
 	return nil
 }
 
+// intervalFlush is the background flush of DurabilityInterval
+func (f *FileStore) intervalFlush() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flushTimer = nil
+	if f.pending == 0 {
+		return
+	}
+	if err := f.flushToFile(); err != nil && f.OnFlushError != nil {
+		f.OnFlushError(fmt.Errorf("background flush failed: %v", err))
+	}
+}
+
 func (f *FileStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if f.readOnly {
+		return ReadOnlyErr
+	}
 
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
+	if err := f.loadCollection(collection); err != nil {
+		return err
+	}
 	if !f.colExists(collection) {
 		f.content[collection] = map[string]json.RawMessage{}
 	}
 	f.content[collection][key] = value
-	if !f.inMemory && !f.ManualFlush {
-		return f.flushToFile()
+	change := Event{Type: EventSet, Collection: collection, Key: key, Value: value}
+	f.watchers.publish(change)
+	return f.persist(change)
+}
+
+// Patch applies a patch to a document under the write lock and returns the patched document
+func (f *FileStore) Patch(ctx context.Context, collection, key string, patch json.RawMessage, patchType PatchType) (json.RawMessage, error) {
+	if f.readOnly {
+		return nil, ReadOnlyErr
 	}
 
-	return nil
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.loadCollection(collection); err != nil {
+		return nil, err
+	}
+	doc, ok := f.content[collection][key]
+	if !ok {
+		return nil, ItemNotFoundErr
+	}
+	patched, err := ApplyPatch(doc, patch, patchType)
+	if err != nil {
+		return nil, err
+	}
+	f.content[collection][key] = patched
+	change := Event{Type: EventSet, Collection: collection, Key: key, Value: patched}
+	f.watchers.publish(change)
+	return patched, f.persist(change)
 }
 
+// Get reads a document, it returns ItemNotFoundErr if the key does not exist in the collection.
+// With ReloadOnRead the content of the file is loaded first.
 func (f *FileStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
 
+	unlock, err := f.getLock(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if !f.colExists(collection) {
 		return CollectionNotFoundErr
 	}
-	f.mutex.RLock()
-	defer f.mutex.RUnlock()
 
-	if !f.inMemory {
-
-		err := f.readFile()
-		if err != nil {
-			return err
-		}
+	d, ok := f.content[collection][key]
+	if !ok {
+		return ItemNotFoundErr
 	}
-
-	d := f.content[collection][key]
 	*value = d
 
 	return nil
 
 }
 
+// getLock takes the lock for reading a collection with Get, with ReloadOnRead it takes the write lock and
+// loads the content of the file
+func (f *FileStore) getLock(collection string) (func(), error) {
+	if !f.reloadOnRead || f.inMemory {
+		return f.readLock(collection)
+	}
+	f.mutex.Lock()
+	// pending changes not yet flushed, or only written to the journal, would be overwritten by the content of the file
+	if f.pending == 0 && f.journal == nil {
+		if err := f.readFile(); err != nil {
+			f.mutex.Unlock()
+			return nil, err
+		}
+	}
+	return f.mutex.Unlock, nil
+}
+
+// readFile replaces the content in memory with the content of the file, dropping the documents and collections
+// deleted by other processes
 func (f *FileStore) readFile() error {
-	fHandle, err := os.Open(f.file)
+	data, err := f.readContent()
 	if err != nil {
-		return fmt.Errorf("unable to open file: %v", err)
+		return err
 	}
-	defer fHandle.Close()
+	f.content = data
+	return nil
+}
 
-	bytes, err := io.ReadAll(fHandle)
-	if err != nil {
-		return fmt.Errorf("unable to read file: %v", err)
+// readContent reads and parses the content of the file
+func (f *FileStore) readContent() (map[string]map[string]json.RawMessage, error) {
+	if f.perCollection {
+		return f.readCollections()
 	}
+	if f.persister != nil {
+		return f.loadPersisted()
+	}
+	return f.readContentFrom(f.file)
+}
 
-	if len(bytes) == 0 {
-		return fmt.Errorf("file is empty")
+// readContentFrom reads and parses the content of a file holding all the collections
+func (f *FileStore) readContentFrom(file string) (map[string]map[string]json.RawMessage, error) {
+	bytes, err := f.readFileData(file)
+	if err != nil {
+		return nil, err
 	}
+	return parseContent(bytes)
+}
 
+// parseContent parses the json content of a store
+func parseContent(bytes []byte) (map[string]map[string]json.RawMessage, error) {
 	var data map[string]map[string]any
-	err = json.Unmarshal(bytes, &data)
+	err := json.Unmarshal(bytes, &data)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal file: %v", err)
+		return nil, fmt.Errorf("unable to unmarshal file: %v", err)
 	}
 
-	for collection, content := range data {
-		for k, v := range content {
-			raw, err := json.Marshal(v)
-			if err != nil {
-				return fmt.Errorf("failed to marshal key %q: %v", k, err)
-			}
-			f.content[collection][k] = raw
+	content := make(map[string]map[string]json.RawMessage, len(data))
+	for collection, items := range data {
+		content[collection], err = documents(items)
+		if err != nil {
+			return nil, err
 		}
 	}
+	return content, nil
+}
 
-	return nil
+// readFileData reads a file of the store and decodes it into json
+func (f *FileStore) readFileData(file string) ([]byte, error) {
+	fHandle, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %v", err)
+	}
+	defer fHandle.Close()
+
+	bytes, err := io.ReadAll(fHandle)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %v", err)
+	}
+
+	if len(bytes) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	return f.decodeFile(bytes)
+}
+
+// documents converts the parsed documents of a collection back into json values
+func documents(items map[string]any) (map[string]json.RawMessage, error) {
+	docs := make(map[string]json.RawMessage, len(items))
+	for k, v := range items {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal key %q: %v", k, err)
+		}
+		docs[k] = raw
+	}
+	return docs, nil
 }
 
+// List returns the documents of a collection and the total amount of documents in it
 func (f *FileStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := f.ListPage(ctx, collection, ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage returns a page of documents of the collection together with the pagination metadata
+func (f *FileStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
 
-	f.mutex.RLock()
-	defer f.mutex.RUnlock()
 	if collection == "" {
 		collection = DefaultCollection
 	}
+	unlock, err := f.readLock(collection)
+	if err != nil {
+		return Page{}, err
+	}
+	defer unlock()
 	if !f.colExists(collection) {
-		return nil, 0, CollectionNotFoundErr
+		return Page{}, CollectionNotFoundErr
 	}
 	collen := len(f.content[collection])
 
-	if limit == 0 || limit > MaxListItems {
-		limit = MaxListItems
-	}
-	if page < 1 {
-		page = 1
+	opts, err = opts.capLimit(f.MaxListItems).Normalize()
+	if err != nil {
+		return Page{}, err
 	}
-	offset := (page - 1) * limit
+	offset := (opts.Page - 1) * opts.Limit
 
 	// Extract and sort the keys alphabetically
 	keys := make([]string, 0, collen)
@@ -209,36 +769,132 @@ func (f *FileStore) List(ctx context.Context, collection string, limit, page int
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
+	keys = filterPrefix(keys, opts.Prefix)
 
-	end := offset + limit
+	end := offset + opts.Limit
 	if end > len(keys) {
 		end = len(keys)
 	}
+	if offset > end {
+		offset = end
+	}
 
 	// Set the resulting map with paginated keys
 	result := make(map[string]json.RawMessage, end-offset)
 	for _, key := range keys[offset:end] {
 		result[key] = f.content[collection][key]
 	}
-	return result, int64(collen), nil
+	return NewPage(result, int64(len(keys)), opts.Page, opts.Limit), nil
 
 }
 
 func (f *FileStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if f.readOnly {
+		return false, ReadOnlyErr
+	}
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
+	if err := f.loadCollection(collection); err != nil {
+		return false, err
+	}
 	if !f.colExists(collection) {
 		return false, CollectionNotFoundErr
 	}
 
 	entryDeleted := false
+	change := Event{Type: EventDelete, Collection: collection, Key: key}
 
 	if _, ok := f.content[collection][key]; ok {
 		delete(f.content[collection], key)
 		entryDeleted = true
+		f.watchers.publish(change)
+	}
+	return entryDeleted, f.persist(change)
+}
+
+// Collections returns the names of the collections of the store, sorted; with LazyLoad this includes the
+// collections not loaded yet
+func (f *FileStore) Collections(ctx context.Context) ([]string, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	names := map[string]struct{}{}
+	for collection := range f.content {
+		names[collection] = struct{}{}
+	}
+	if f.lazy {
+		files, err := f.diskCollections()
+		if err != nil {
+			return nil, err
+		}
+		for collection := range files {
+			if _, dropped := f.dirtyCols[collection]; dropped && !f.colExists(collection) {
+				// dropped, the file is removed by the next flush
+				continue
+			}
+			names[collection] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for collection := range names {
+		sorted = append(sorted, collection)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// CreateCollection adds an empty collection, creating an existing collection is a no-op
+func (f *FileStore) CreateCollection(ctx context.Context, collection string) error {
+	if f.readOnly {
+		return ReadOnlyErr
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.loadCollection(collection); err != nil {
+		return err
+	}
+	if f.colExists(collection) {
+		return nil
+	}
+	f.content[collection] = map[string]json.RawMessage{}
+	return f.persistCollection(collection)
+}
+
+// DropCollection removes a collection and all its documents, watchers get a delete event for every document
+func (f *FileStore) DropCollection(ctx context.Context, collection string) error {
+	if f.readOnly {
+		return ReadOnlyErr
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.loadCollection(collection); err != nil {
+		return err
+	}
+	if !f.colExists(collection) {
+		return nil
+	}
+	keys := make([]string, 0, len(f.content[collection]))
+	for key := range f.content[collection] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	delete(f.content, collection)
+	for _, key := range keys {
+		f.watchers.publish(Event{Type: EventDelete, Collection: collection, Key: key})
+	}
+	return f.persistCollection(collection)
+}
+
+// persistCollection writes the creation or removal of a whole collection, which the journal cannot record,
+// it needs to be called holding the write lock
+func (f *FileStore) persistCollection(collection string) error {
+	if f.inMemory {
+		return nil
 	}
-	if !f.inMemory && !f.ManualFlush {
-		return entryDeleted, f.flushToFile()
+	f.markDirty(collection)
+	f.pending++
+	if f.ManualFlush {
+		return nil
 	}
-	return entryDeleted, nil
+	f.stopFlushTimer()
+	return f.flushToFile()
 }
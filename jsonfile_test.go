@@ -1,14 +1,19 @@
 package jsonstore_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-bumbu/jsonstore"
 	"github.com/google/go-cmp/cmp"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestJsonfileSet(t *testing.T) {
@@ -212,7 +217,107 @@ func TestJsonfileGet(t *testing.T) {
 		}
 		//readJsonFile(t, file)
 	})
-	// TODO add test for getting data from file, e.g. store and use a new handler to get
+
+	t.Run("get missing key", func(t *testing.T) {
+		var got json.RawMessage
+		err := store.Get(context.Background(), "collection1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("reload on read", func(t *testing.T) {
+		ctx := context.Background()
+		file := filepath.Join(t.TempDir(), "store.json")
+		writer, err := jsonstore.NewFileStore(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Set(ctx, "col1", "item1", json.RawMessage(`{"item":"my value"}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		reloading, err := jsonstore.NewFileStore(file, jsonstore.ReloadOnRead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached, err := jsonstore.NewFileStore(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// written by another store after the readers were opened
+		want := json.RawMessage(`{"item":"updated value"}`)
+		if err = writer.Set(ctx, "col1", "item1", want); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		if err = reloading.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		if err = cached.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, json.RawMessage(`{"item":"my value"}`)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("reload on read sees deletes", func(t *testing.T) {
+		tcs := []struct {
+			name  string
+			flags []jsonstore.FileStoreFlag
+		}{
+			{name: "single file"},
+			{name: "file per collection", flags: []jsonstore.FileStoreFlag{jsonstore.FilePerCollection}},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				ctx := context.Background()
+				file := filepath.Join(t.TempDir(), "store.json")
+				writer, err := jsonstore.NewFileStore(file, tc.flags...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, collection := range []string{"col1", "col2"} {
+					for _, key := range []string{"item1", "item2"} {
+						if err = writer.Set(ctx, collection, key, json.RawMessage(`{"a":1}`)); err != nil {
+							t.Fatalf("action: Set,  returned an error: %v", err)
+						}
+					}
+				}
+				reloading, err := jsonstore.NewFileStore(file, append(tc.flags, jsonstore.ReloadOnRead)...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got json.RawMessage
+				if err = reloading.Get(ctx, "col1", "item1", &got); err != nil {
+					t.Fatalf("action: Get,  returned an error: %v", err)
+				}
+
+				// deleted by another store after the reader loaded them
+				if _, err = writer.Delete(ctx, "col1", "item1"); err != nil {
+					t.Fatalf("action: Delete,  returned an error: %v", err)
+				}
+				if err = writer.DropCollection(ctx, "col2"); err != nil {
+					t.Fatalf("action: DropCollection,  returned an error: %v", err)
+				}
+
+				if err = reloading.Get(ctx, "col1", "item1", &got); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+					t.Errorf("expected ItemNotFoundErr, got: %v", err)
+				}
+				if err = reloading.Get(ctx, "col1", "item2", &got); err != nil {
+					t.Errorf("action: Get,  returned an error: %v", err)
+				}
+				if err = reloading.Get(ctx, "col2", "item1", &got); !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+					t.Errorf("expected CollectionNotFoundErr, got: %v", err)
+				}
+			})
+		}
+	})
 }
 
 func TestJsonfileDelete(t *testing.T) {
@@ -357,6 +462,815 @@ func TestJsonfileList(t *testing.T) {
 	})
 }
 
+func TestJsonfileLoad(t *testing.T) {
+	store, jsonFile := getjsonFileStore(t)
+	value := json.RawMessage(`{"item":"my value"}`)
+	err := store.Set(context.Background(), "col1", "item1", value)
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	// open a new store on the same file
+	reopened, err := jsonstore.NewFileStore(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got json.RawMessage
+	err = reopened.Get(context.Background(), "col1", "item1", &got)
+	if err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(got, value); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
+
+func TestJsonfileOpenExistingFile(t *testing.T) {
+	ctx := context.Background()
+	tcs := []struct {
+		name string
+		// content is written to the file before opening the store, unless the file is missing
+		missing bool
+		content string
+		want    map[string]map[string]json.RawMessage
+		err     bool
+	}{
+		{name: "missing file", missing: true, want: map[string]map[string]json.RawMessage{}},
+		{name: "empty file", want: map[string]map[string]json.RawMessage{}},
+		{
+			name:    "existing content",
+			content: `{"col1":{"item1":{"item":"my value"}},"empty":{}}`,
+			want: map[string]map[string]json.RawMessage{
+				"col1":  {"item1": json.RawMessage(`{"item":"my value"}`)},
+				"empty": {},
+			},
+		},
+		{name: "invalid content", content: `{"col1":`, err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "store.json")
+			if !tc.missing {
+				if err := os.WriteFile(file, []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store, err := jsonstore.NewFileStore(file)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error opening the file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFileStore returned an error: %v", err)
+			}
+			if _, err = os.Stat(file); err != nil {
+				t.Errorf("expected the file to exist: %v", err)
+			}
+			got := map[string]map[string]json.RawMessage{}
+			collections, err := store.Collections(ctx)
+			if err != nil {
+				t.Fatalf("action: Collections,  returned an error: %v", err)
+			}
+			for _, collection := range collections {
+				items, _, err := store.List(ctx, collection, 0, 1)
+				if err != nil {
+					t.Fatalf("action: List,  returned an error: %v", err)
+				}
+				got[collection] = items
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("unexpected content (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+// syncCountingFs counts the syncs of the written files
+type syncCountingFs struct {
+	syncs int
+}
+
+type syncCountingFile struct {
+	*os.File
+	fs *syncCountingFs
+}
+
+func (c *syncCountingFile) Sync() error {
+	c.fs.syncs++
+	return c.File.Sync()
+}
+
+func (c *syncCountingFs) OpenFile(name string, flag int, perm os.FileMode) (jsonstore.WritableFile, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &syncCountingFile{File: f, fs: c}, nil
+}
+func (c *syncCountingFs) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+func (c *syncCountingFs) Remove(name string) error             { return os.Remove(name) }
+
+func TestJsonfileDurability(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	storedValue := func(t *testing.T, file string) any {
+		t.Helper()
+		data := readJsonFile(t, file).(map[string]interface{})
+		col, ok := data["col1"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return col["item1"]
+	}
+
+	tcs := []struct {
+		name      string
+		mode      jsonstore.Durability
+		wantSyncs int
+	}{
+		{name: "sync every write", mode: jsonstore.DurabilitySync, wantSyncs: 1},
+		{name: "no sync", mode: jsonstore.DurabilityNoSync, wantSyncs: 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store, file := getjsonFileStore(t)
+			fsys := &syncCountingFs{}
+			jsonstore.SetFileSystem(store, fsys)
+			store.Durability = tc.mode
+
+			if err := store.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if storedValue(t, file) == nil {
+				t.Errorf("expected the value to be written to the file")
+			}
+			if fsys.syncs != tc.wantSyncs {
+				t.Errorf("expected %d syncs, got %d", tc.wantSyncs, fsys.syncs)
+			}
+		})
+	}
+
+	t.Run("flush on interval", func(t *testing.T) {
+		store, file := getjsonFileStore(t)
+		store.Durability = jsonstore.DurabilityInterval
+		store.FlushInterval = 50 * time.Millisecond
+
+		if err := store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if stat, err := os.Stat(file); err != nil || stat.Size() != 0 {
+			t.Fatalf("expected the file to be empty until the interval elapses")
+		}
+		// the pending value is returned before being flushed
+		var got json.RawMessage
+		if err := store.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if stat, err := os.Stat(file); err == nil && stat.Size() > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("the value was not flushed in the background")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if storedValue(t, file) == nil {
+			t.Errorf("expected the value to be written to the file")
+		}
+	})
+
+	t.Run("flush after writes and on close", func(t *testing.T) {
+		store, file := getjsonFileStore(t)
+		fsys := &syncCountingFs{}
+		jsonstore.SetFileSystem(store, fsys)
+		store.Durability = jsonstore.DurabilityInterval
+		store.FlushInterval = time.Hour
+		store.FlushAfterWrites = 3
+
+		for i := 0; i < 5; i++ {
+			if err := store.Set(ctx, "col1", fmt.Sprintf("item%d", i), value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		if fsys.syncs != 1 {
+			t.Errorf("expected 1 flush after 5 writes, got %d", fsys.syncs)
+		}
+		if err := store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		if fsys.syncs != 2 {
+			t.Errorf("expected the pending writes to be flushed on close, got %d flushes", fsys.syncs)
+		}
+		if got := len(readJsonFile(t, file).(map[string]interface{})["col1"].(map[string]interface{})); got != 5 {
+			t.Errorf("expected 5 items in the file, got %d", got)
+		}
+		// nothing is pending anymore
+		if err := store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		if fsys.syncs != 2 {
+			t.Errorf("expected no flush without pending writes, got %d flushes", fsys.syncs)
+		}
+	})
+
+	t.Run("explicit flush with interval", func(t *testing.T) {
+		store, file := getjsonFileStore(t)
+		store.Durability = jsonstore.DurabilityInterval
+		store.FlushInterval = time.Hour
+
+		if err := store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if err := store.Flush(); err != nil {
+			t.Fatalf("action: Flush,  returned an error: %v", err)
+		}
+		if storedValue(t, file) == nil {
+			t.Errorf("expected the value to be written to the file")
+		}
+	})
+}
+
+func TestJsonfileGzip(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "test.json.gz")
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	store, err := jsonstore.NewFileStore(file, jsonstore.GzipCompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set(ctx, "col1", "item1", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatalf("expected a gzip compressed file")
+	}
+
+	// the compression is detected on open, also without the flag
+	for _, flags := range [][]jsonstore.FileStoreFlag{{jsonstore.GzipCompressed}, nil} {
+		reopened, err := jsonstore.NewFileStore(file, flags...)
+		if err != nil {
+			t.Fatalf("unable to reopen the store: %v", err)
+		}
+		var got json.RawMessage
+		if err = reopened.Get(ctx, "col1", "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	}
+}
+
+func TestJsonfileFormats(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"count":12,"enabled":"true","ratio":1.5,"tags":["a","b"],"text":"line1\nline2"}`)
+
+	tcs := []struct {
+		name     string
+		flags    []jsonstore.FileStoreFlag
+		wantFile string
+	}{
+		{
+			name:     "yaml",
+			flags:    []jsonstore.FileStoreFlag{jsonstore.YamlFormat},
+			wantFile: "col1:\n  item1:\n    count: 12\n    enabled: \"true\"\n    ratio: 1.5\n",
+		},
+		{
+			name:     "toml",
+			flags:    []jsonstore.FileStoreFlag{jsonstore.TomlFormat},
+			wantFile: "[col1]\n  [col1.item1]\n    count = 12\n    enabled = \"true\"\n    ratio = 1.5\n",
+		},
+		{
+			name:  "yaml file per collection",
+			flags: []jsonstore.FileStoreFlag{jsonstore.YamlFormat, jsonstore.FilePerCollection},
+		},
+		{
+			name:  "toml file per collection",
+			flags: []jsonstore.FileStoreFlag{jsonstore.TomlFormat, jsonstore.FilePerCollection},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "store")
+			store, err := jsonstore.NewFileStore(file, tc.flags...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = store.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if err = store.Set(ctx, "col2", "item1", json.RawMessage(`"text"`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if _, err = store.Delete(ctx, "col2", "item1"); err != nil {
+				t.Fatalf("action: Delete,  returned an error: %v", err)
+			}
+			if tc.wantFile != "" {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(data), tc.wantFile) {
+					t.Errorf("unexpected file content:\n%s", data)
+				}
+			}
+
+			reopened, err := jsonstore.NewFileStore(file, tc.flags...)
+			if err != nil {
+				t.Fatalf("unable to reopen the store: %v", err)
+			}
+			want := map[string]map[string]any{"col1": {"item1": map[string]any{
+				"count": 12.0, "enabled": "true", "ratio": 1.5, "tags": []any{"a", "b"}, "text": "line1\nline2",
+			}}, "col2": {}}
+			if diff := cmp.Diff(takeSnapshot(t, reopened), want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("hand edited yaml", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store.yaml")
+		data := "# reference data\ncountries:\n  ch:\n    name: Switzerland\n    languages: [de, fr, it, rm]\n"
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		store, err := jsonstore.NewFileStore(file, jsonstore.YamlFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got json.RawMessage
+		if err = store.Get(ctx, "countries", "ch", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(string(got), `{"languages":["de","fr","it","rm"],"name":"Switzerland"}`); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("json file is converted", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store")
+		store, err := jsonstore.NewFileStore(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		converted, err := jsonstore.NewFileStore(file, jsonstore.TomlFormat)
+		if err != nil {
+			t.Fatalf("unable to open the json file as toml: %v", err)
+		}
+		if err = converted.Flush(); err != nil {
+			t.Fatalf("action: Flush,  returned an error: %v", err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "[col1]") {
+			t.Errorf("expected the file to be converted to toml:\n%s", data)
+		}
+	})
+
+	t.Run("toml null value", func(t *testing.T) {
+		store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "store"), jsonstore.TomlFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{"a":null}`)); err == nil {
+			t.Errorf("expected an error storing a null value as toml")
+		}
+	})
+
+	if _, err := jsonstore.NewFileStore(jsonstore.InMemoryDb, jsonstore.YamlFormat, jsonstore.TomlFormat); err == nil {
+		t.Errorf("expected an error setting both formats")
+	}
+}
+
+func TestJsonfileOptions(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	t.Run("format and permissions", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store.yaml")
+		store, err := jsonstore.NewFileStoreWithOptions(file, jsonstore.FileStoreOptions{
+			Format:   jsonstore.FormatYaml,
+			FileMode: 0600,
+		})
+		if err != nil {
+			t.Fatalf("NewFileStoreWithOptions returned an error: %v", err)
+		}
+		if err = store.Set(ctx, "col1", "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		stat, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(stat.Mode().Perm(), os.FileMode(0600)); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(data), "col1:\n  item1:\n    item: my value\n"); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("flush policy", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store.json")
+		store, err := jsonstore.NewFileStoreWithOptions(file, jsonstore.FileStoreOptions{
+			Durability:       jsonstore.DurabilityInterval,
+			FlushInterval:    time.Hour,
+			FlushAfterWrites: 2,
+		})
+		if err != nil {
+			t.Fatalf("NewFileStoreWithOptions returned an error: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err = store.Set(ctx, "col1", fmt.Sprintf("item%d", i), value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		if got := len(readJsonFile(t, file).(map[string]interface{})["col1"].(map[string]interface{})); got != 2 {
+			t.Errorf("expected 2 items flushed after 2 writes, got %d", got)
+		}
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("file locking is only supported on unix")
+		}
+		file := filepath.Join(t.TempDir(), "store.json")
+		opts := jsonstore.FileStoreOptions{Lock: true}
+		store, err := jsonstore.NewFileStoreWithOptions(file, opts)
+		if err != nil {
+			t.Fatalf("NewFileStoreWithOptions returned an error: %v", err)
+		}
+		if _, err = jsonstore.NewFileStoreWithOptions(file, opts); !errors.Is(err, jsonstore.FileLockedErr) {
+			t.Errorf("expected FileLockedErr opening a locked file, got: %v", err)
+		}
+		if err = store.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+		reopened, err := jsonstore.NewFileStoreWithOptions(file, opts)
+		if err != nil {
+			t.Fatalf("unable to reopen the store after Close: %v", err)
+		}
+		_ = reopened.Close(ctx)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		tcs := map[string]jsonstore.FileStoreOptions{
+			"lazy load without file per collection": {LazyLoad: true},
+			"read only journal":                     {ReadOnly: true, AppendJournal: true},
+			"unknown format":                        {Format: jsonstore.FileFormat(42)},
+		}
+		for name, opts := range tcs {
+			if _, err := jsonstore.NewFileStoreWithOptions(filepath.Join(t.TempDir(), "store"), opts); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestJsonfileBytes(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "store.json")
+	store, err := jsonstore.NewFileStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{"item":"my value"}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	got, err := store.Bytes()
+	if err != nil {
+		t.Fatalf("action: Bytes,  returned an error: %v", err)
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(got), string(want)); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	got, err = json.Marshal(store)
+	if err != nil {
+		t.Fatalf("action: MarshalJSON,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(string(got), `{"col1":{"item1":{"item":"my value"}}}`); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	// a value that is not valid json is reported instead of panicking
+	memStore, err := jsonstore.NewFileStore(jsonstore.InMemoryDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = memStore.Set(ctx, "col1", "item1", json.RawMessage(`{"item":`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if _, err = memStore.Bytes(); err == nil {
+		t.Errorf("expected an error marshaling invalid json")
+	}
+	if err = store.Set(ctx, "col1", "item2", json.RawMessage(`{"item":`)); err == nil {
+		t.Errorf("expected an error flushing invalid json")
+	}
+}
+
+func TestJsonfileEncryption(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"secret value"}`)
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	tcs := []struct {
+		name  string
+		enc   jsonstore.FileEncryption
+		wrong jsonstore.FileEncryption
+		flags []jsonstore.FileStoreFlag
+	}{
+		{
+			name:  "key",
+			enc:   jsonstore.FileEncryption{Key: key},
+			wrong: jsonstore.FileEncryption{Key: []byte("fedcba9876543210fedcba9876543210")},
+		},
+		{
+			name:  "passphrase",
+			enc:   jsonstore.FileEncryption{Passphrase: "correct horse"},
+			wrong: jsonstore.FileEncryption{Passphrase: "battery staple"},
+		},
+		{
+			name:  "passphrase and compression",
+			enc:   jsonstore.FileEncryption{Passphrase: "correct horse"},
+			wrong: jsonstore.FileEncryption{Key: key},
+			flags: []jsonstore.FileStoreFlag{jsonstore.GzipCompressed},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "test.json")
+			store, err := jsonstore.NewEncryptedFileStore(file, tc.enc, tc.flags...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = store.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("secret value")) {
+				t.Errorf("expected the file to be encrypted")
+			}
+
+			reopened, err := jsonstore.NewEncryptedFileStore(file, tc.enc, tc.flags...)
+			if err != nil {
+				t.Fatalf("unable to reopen the store: %v", err)
+			}
+			var got json.RawMessage
+			if err = reopened.Get(ctx, "col1", "item1", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, value); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			if _, err = jsonstore.NewEncryptedFileStore(file, tc.wrong); err == nil {
+				t.Errorf("expected an error opening the store with the wrong key")
+			}
+			if _, err = jsonstore.NewFileStore(file); err == nil {
+				t.Errorf("expected an error opening the store without encryption")
+			}
+		})
+	}
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "test.json")
+		for _, enc := range []jsonstore.FileEncryption{{}, {Key: []byte("short")}, {Key: key, Passphrase: "both"}} {
+			if _, err := jsonstore.NewEncryptedFileStore(file, enc); err == nil {
+				t.Errorf("expected an error with encryption %+v", enc)
+			}
+		}
+		if _, err := jsonstore.NewEncryptedFileStore(file, jsonstore.FileEncryption{Key: key}, jsonstore.AppendJournal); err == nil {
+			t.Errorf("expected an error combining encryption and the append journal")
+		}
+	})
+}
+
+func TestJsonfilePerCollection(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "store")
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	store, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"col1", "a/b"} {
+		if err = store.Set(ctx, col, "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+	data := readJsonFile(t, filepath.Join(dir, "a%2Fb.json")).(map[string]interface{})
+	if _, ok := data["item1"]; !ok {
+		t.Errorf("expected item1 in the collection file, got %v", data)
+	}
+
+	// a write only rewrites its own collection
+	fsys := &syncCountingFs{}
+	jsonstore.SetFileSystem(store, fsys)
+	if err = store.Set(ctx, "col1", "item2", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	if _, err = store.Delete(ctx, "col1", "item1"); err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	if fsys.syncs != 2 {
+		t.Errorf("expected 2 file writes, got %d", fsys.syncs)
+	}
+
+	reopened, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+	if err != nil {
+		t.Fatalf("unable to reopen the store: %v", err)
+	}
+	want := map[string]map[string]json.RawMessage{
+		"col1": {"item2": value},
+		"a/b":  {"item1": value},
+	}
+	for col, items := range want {
+		got, _, err := reopened.List(ctx, col, 0, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, items); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	}
+}
+
+func TestJsonfileLazyLoad(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "store")
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	store, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"col1", "col2", "col3"} {
+		if err = store.Set(ctx, col, "item1", value); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+
+	lazy, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection, jsonstore.LazyLoad)
+	if err != nil {
+		t.Fatalf("unable to reopen the store: %v", err)
+	}
+	lazy.MaxLoadedCollections = 2
+	if diff := cmp.Diff(jsonstore.LoadedCollections(lazy), []string{}); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	steps := []struct {
+		collection string
+		want       []string
+	}{
+		{collection: "col1", want: []string{"col1"}},
+		{collection: "col2", want: []string{"col1", "col2"}},
+		{collection: "col1", want: []string{"col1", "col2"}},
+		// col2 is the least recently used
+		{collection: "col3", want: []string{"col1", "col3"}},
+		{collection: "col2", want: []string{"col2", "col3"}},
+	}
+	for _, step := range steps {
+		var got json.RawMessage
+		if err = lazy.Get(ctx, step.collection, "item1", &got); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		if diff := cmp.Diff(jsonstore.LoadedCollections(lazy), step.want); diff != "" {
+			t.Errorf("unexpected loaded collections after reading %s (-got +want)\n%s", step.collection, diff)
+		}
+	}
+
+	// a write loads the collection before changing it
+	if err = lazy.Set(ctx, "col1", "item2", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	got, _, err := lazy.List(ctx, "col1", 0, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(got, map[string]json.RawMessage{"item1": value, "item2": value}); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+
+	if _, err = jsonstore.NewFileStore(dir, jsonstore.LazyLoad); err == nil {
+		t.Errorf("expected an error using lazy loading without FilePerCollection")
+	}
+}
+
+func TestJsonfileReadOnly(t *testing.T) {
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"my value"}`)
+
+	tcs := []struct {
+		name  string
+		flags []jsonstore.FileStoreFlag
+	}{
+		{name: "single file"},
+		{name: "file per collection", flags: []jsonstore.FileStoreFlag{jsonstore.FilePerCollection}},
+		{name: "lazy load", flags: []jsonstore.FileStoreFlag{jsonstore.FilePerCollection, jsonstore.LazyLoad}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "store")
+			writer, err := jsonstore.NewFileStore(file, tc.flags...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = writer.Set(ctx, "col1", "item1", value); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			before := takeSnapshot(t, writer)
+
+			store, err := jsonstore.NewFileStoreReadOnly(file, tc.flags...)
+			if err != nil {
+				t.Fatalf("NewFileStoreReadOnly returned an error: %v", err)
+			}
+			var got json.RawMessage
+			if err = store.Get(ctx, "col1", "item1", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(got, value); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			if err = store.Set(ctx, "col1", "item2", value); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr from Set, got: %v", err)
+			}
+			if _, err = store.Delete(ctx, "col1", "item1"); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr from Delete, got: %v", err)
+			}
+			if err = store.RestoreSnapshot(ctx, bytes.NewReader([]byte(`{}`))); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr from RestoreSnapshot, got: %v", err)
+			}
+			if err = store.Flush(); err != nil {
+				t.Errorf("action: Flush,  returned an error: %v", err)
+			}
+			if err = store.Close(ctx); err != nil {
+				t.Errorf("action: Close,  returned an error: %v", err)
+			}
+			reopened, err := jsonstore.NewFileStore(file, tc.flags...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(takeSnapshot(t, reopened), before); diff != "" {
+				t.Errorf("the read only store changed the file (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("missing file is not created", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "store.json")
+		if _, err := jsonstore.NewFileStoreReadOnly(file); err == nil {
+			t.Errorf("expected an error opening a missing file")
+		}
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("the read only store created the file: %v", err)
+		}
+	})
+
+	t.Run("in memory store", func(t *testing.T) {
+		if _, err := jsonstore.NewFileStoreReadOnly(jsonstore.InMemoryDb); err == nil {
+			t.Errorf("expected an error opening an in memory store read only")
+		}
+	})
+}
+
 func TestJsonfileConcurrency(t *testing.T) {
 }
 
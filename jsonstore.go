@@ -2,8 +2,14 @@ package jsonstore
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
 )
 
 // JsonStorer interface implements the needed actions to Store and retrieve json Values identified by a key
@@ -18,3 +24,328 @@ type JsonStorer interface {
 
 var CollectionNotFoundErr = errors.New("collection not found")
 var ItemNotFoundErr = errors.New("item not found")
+var InvalidCursorErr = errors.New("invalid cursor")
+var VersionConflictErr = errors.New("version conflict")
+var InvalidJsonErr = errors.New("invalid json")
+var ReadOnlyErr = errors.New("store is read only")
+var FileLockedErr = errors.New("file is locked by another store")
+
+// VersionedStorer is implemented by stores that support optimistic concurrency: every document carries
+// an opaque version that changes on each write, and writes can be made conditional on the current version
+// so that concurrent editors detect mid-air collisions instead of silently overwriting each other.
+type VersionedStorer interface {
+	// GetWithVersion reads a document together with its current version
+	GetWithVersion(ctx context.Context, collection, key string, value *json.RawMessage) (string, error)
+	// SetIfVersion stores the document only if its current version matches version, an empty version
+	// requires the document to not exist yet. It returns the new version or VersionConflictErr.
+	SetIfVersion(ctx context.Context, collection, key string, value json.RawMessage, version string) (string, error)
+	// DeleteIfVersion deletes the document only if its current version matches version,
+	// it returns VersionConflictErr on mismatch and ItemNotFoundErr if the document does not exist.
+	DeleteIfVersion(ctx context.Context, collection, key, version string) error
+}
+
+// ModTimeGetter is implemented by stores keeping the time of the last write of the documents, the HTTP handler
+// uses it to answer conditional requests with If-Modified-Since
+type ModTimeGetter interface {
+	// GetWithModTime reads a document together with the time it was last written, the time is zero if it is not
+	// known; it returns ItemNotFoundErr if the document does not exist
+	GetWithModTime(ctx context.Context, collection, key string, value *json.RawMessage) (time.Time, error)
+}
+
+// EventType is the kind of change notified by a Watcher
+type EventType string
+
+const (
+	EventSet    EventType = "set"
+	EventDelete EventType = "delete"
+)
+
+// Event is a change to a document, Value is empty for deletions
+type Event struct {
+	Type       EventType       `json:"type"`
+	Collection string          `json:"collection"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value,omitempty"`
+}
+
+// Snapshotter is implemented by stores able to dump their whole content at a point in time and to restore it,
+// the snapshot format is the one of the FileStore file: {"collection":{"key":value}}
+type Snapshotter interface {
+	// Snapshot writes a consistent point-in-time dump of all the collections to w
+	Snapshot(ctx context.Context, w io.Writer) error
+	// RestoreSnapshot replaces the whole content of the store with the snapshot read from r
+	RestoreSnapshot(ctx context.Context, r io.Reader) error
+}
+
+// Closer is implemented by stores holding resources that need to be released, e.g. pending writes or open files;
+// the store must not be used after Close
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Watcher is implemented by stores able to notify the changes to the documents of a collection
+type Watcher interface {
+	// Watch returns a channel receiving the changes of the collection until ctx is canceled,
+	// the channel is closed once the watch ends.
+	Watch(ctx context.Context, collection string) (<-chan Event, error)
+}
+
+// ListOptions holds the parameters of a paginated list request
+type ListOptions struct {
+	Limit int
+	Page  int
+	// Cursor is the NextCursor or PrevCursor of a previous Page, if set it takes precedence over Page
+	Cursor string
+	// Prefix restricts the listing to the documents whose key starts with it
+	Prefix string
+	// SkipTotal allows the store to skip counting the documents of the collection, the Page then has
+	// UnknownTotal as Total. Stores that can count cheaply may still return the total.
+	SkipTotal bool
+	// MaxLimit is the maximum page size, larger limits are lowered to it; MaxListItems if zero.
+	// Stores configured with a lower maximum page size apply theirs.
+	MaxLimit int
+}
+
+// UnknownTotal is the Total of a Page listed with ListOptions.SkipTotal
+const UnknownTotal int64 = -1
+
+// Page is a single page of a list request together with the pagination metadata,
+// it is shared by the Go API and the HTTP list envelope so the navigation logic lives in one place.
+type Page struct {
+	Items      map[string]json.RawMessage `json:"items"`
+	Total      int64                      `json:"total"`
+	Page       int                        `json:"page"`
+	Limit      int                        `json:"limit"`
+	HasNext    bool                       `json:"hasNext"`
+	NextCursor string                     `json:"nextCursor,omitempty"`
+	// PrevCursor points to the previous page, it is empty on the first page
+	PrevCursor string `json:"prevCursor,omitempty"`
+	// Keys are the keys of Items in the order of the Sort of a query, Items being a map it has no order;
+	// empty for unsorted pages, whose order is the one of the keys
+	Keys []string `json:"keys,omitempty"`
+}
+
+// PageLister is implemented by stores that natively return list results as a Page
+type PageLister interface {
+	ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error)
+}
+
+// ListPage lists the items of a collection returning a Page, if the store does not implement
+// PageLister the page is built out of the plain List method.
+func ListPage(ctx context.Context, store JsonStorer, collection string, opts ListOptions) (Page, error) {
+	if pl, ok := store.(PageLister); ok {
+		return pl.ListPage(ctx, collection, opts)
+	}
+	opts, err := opts.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	if opts.Limit > MaxListItems {
+		// the plain List of the stores is capped at MaxListItems
+		opts.Limit = MaxListItems
+	}
+	if opts.Prefix != "" {
+		return listPrefixClientSide(ctx, store, collection, opts)
+	}
+	items, total, err := store.List(ctx, collection, opts.Limit, opts.Page)
+	if err != nil {
+		return Page{}, err
+	}
+	return NewPage(items, total, opts.Page, opts.Limit), nil
+}
+
+// ForEacher is implemented by stores able to stream all the documents of a collection without loading them in memory
+type ForEacher interface {
+	// ForEach calls fn for every document of the collection in key order, it stops at the first error returned by fn
+	ForEach(ctx context.Context, collection string, fn func(key string, value json.RawMessage) error) error
+}
+
+// ForEach calls fn for every document of the collection in key order, stopping at the first error returned by fn.
+// If the store does not implement ForEacher the documents are read page by page.
+func ForEach(ctx context.Context, store JsonStorer, collection string, fn func(key string, value json.RawMessage) error) error {
+	if fe, ok := store.(ForEacher); ok {
+		return fe.ForEach(ctx, collection, fn)
+	}
+	opts := ListOptions{Limit: MaxListItems, Page: 1}
+	for {
+		page, err := ListPage(ctx, store, collection, opts)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(page.Items))
+		for key := range page.Items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err = fn(key, page.Items[key]); err != nil {
+				return err
+			}
+		}
+		if !page.HasNext {
+			return nil
+		}
+		opts.Page++
+	}
+}
+
+// listPrefixClientSide lists all the documents of the collection to return the page of the ones matching the prefix
+func listPrefixClientSide(ctx context.Context, store JsonStorer, collection string, opts ListOptions) (Page, error) {
+	matches := map[string]json.RawMessage{}
+	for page := 1; ; page++ {
+		items, total, err := store.List(ctx, collection, MaxListItems, page)
+		if err != nil {
+			return Page{}, err
+		}
+		for key, value := range items {
+			if strings.HasPrefix(key, opts.Prefix) {
+				matches[key] = value
+			}
+		}
+		if int64(page*MaxListItems) >= total || len(items) == 0 {
+			break
+		}
+	}
+	keys := make([]string, 0, len(matches))
+	for key := range matches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keys = pageKeys(keys, opts)
+	result := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		result[key] = matches[key]
+	}
+	return NewPage(result, int64(len(matches)), opts.Page, opts.Limit), nil
+}
+
+// filterPrefix returns the keys starting with prefix, preserving their order
+func filterPrefix(keys []string, prefix string) []string {
+	if prefix == "" {
+		return keys
+	}
+	filtered := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
+
+// pageKeys returns the keys of the page out of all the sorted keys, the options need to be normalized
+func pageKeys(keys []string, opts ListOptions) []string {
+	offset := (opts.Page - 1) * opts.Limit
+	end := offset + opts.Limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+	if offset > end {
+		offset = end
+	}
+	return keys[offset:end]
+}
+
+// NewPage creates a Page and computes the navigation metadata
+func NewPage(items map[string]json.RawMessage, total int64, page, limit int) Page {
+	p := Page{
+		Items: items,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}
+	if p.Items == nil {
+		p.Items = map[string]json.RawMessage{}
+	}
+	if int64(page)*int64(limit) < total {
+		p.HasNext = true
+		p.NextCursor = encodeCursor(page+1, limit)
+	}
+	if page > 1 {
+		p.PrevCursor = encodeCursor(page-1, limit)
+	}
+	return p
+}
+
+// NewPageWithoutTotal creates a Page whose total is unknown, hasNext needs to be computed by the store,
+// e.g. by fetching one item more than the limit.
+func NewPageWithoutTotal(items map[string]json.RawMessage, page, limit int, hasNext bool) Page {
+	p := Page{
+		Items:   items,
+		Total:   UnknownTotal,
+		Page:    page,
+		Limit:   limit,
+		HasNext: hasNext,
+	}
+	if p.Items == nil {
+		p.Items = map[string]json.RawMessage{}
+	}
+	if hasNext {
+		p.NextCursor = encodeCursor(page+1, limit)
+	}
+	if page > 1 {
+		p.PrevCursor = encodeCursor(page-1, limit)
+	}
+	return p
+}
+
+// Normalize resolves the cursor and applies the default and maximum values to the list options,
+// it is meant for PageLister implementations outside this package.
+func (o ListOptions) Normalize() (ListOptions, error) {
+	if o.Cursor != "" {
+		page, limit, err := decodeCursor(o.Cursor)
+		if err != nil {
+			return o, err
+		}
+		o.Page = page
+		if o.Limit == 0 {
+			o.Limit = limit
+		}
+	}
+	maxLimit := o.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = MaxListItems
+	}
+	if o.Limit <= 0 || o.Limit > maxLimit {
+		o.Limit = maxLimit
+	}
+	if o.Page < 1 {
+		o.Page = 1
+	}
+	return o, nil
+}
+
+// capLimit lowers the MaxLimit of the options to the maximum page size of a store, zero keeps it
+func (o ListOptions) capLimit(max int) ListOptions {
+	if max > 0 && (o.MaxLimit <= 0 || o.MaxLimit > max) {
+		o.MaxLimit = max
+	}
+	return o
+}
+
+type cursor struct {
+	Page  int `json:"p"`
+	Limit int `json:"l"`
+}
+
+// encodeCursor returns an opaque string pointing to a page, callers should not rely on its content
+func encodeCursor(page, limit int) string {
+	// marshal of a struct with int fields cannot fail
+	b, _ := json.Marshal(cursor{Page: page, Limit: limit})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(in string) (int, int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(in)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", InvalidCursorErr, err)
+	}
+	c := cursor{}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", InvalidCursorErr, err)
+	}
+	if c.Page < 1 {
+		return 0, 0, InvalidCursorErr
+	}
+	return c.Page, c.Limit, nil
+}
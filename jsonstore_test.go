@@ -3,12 +3,16 @@ package jsonstore_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"path/filepath"
+	"sort"
 	"testing"
 )
 
@@ -50,8 +54,10 @@ func TestJsonStorerImplementations(t *testing.T) {
 		storer jsonstore.JsonStorer
 	}{
 
-		{"mock", &MockStorer{}},
+		{"mock", &jsonstoretest.FakeStore{}},
 		{"jsonfile", newJsonFile(t)},
+		{"memstore", jsonstore.NewMemStore()},
+		{"dirstore", newDirStore(t)},
 		{"db", newDbStore(t)},
 	}
 
@@ -114,3 +120,204 @@ func TestJsonStorerImplementations(t *testing.T) {
 		})
 	}
 }
+
+func TestListPage(t *testing.T) {
+	implementations := []struct {
+		name   string
+		storer jsonstore.JsonStorer
+	}{
+		{"mock", &jsonstoretest.FakeStore{}},
+		{"jsonfile", newJsonFile(t)},
+		{"memstore", jsonstore.NewMemStore()},
+		{"dirstore", newDirStore(t)},
+		{"db", newDbStore(t)},
+	}
+
+	collection := "test-collection"
+	value := json.RawMessage(`{"name":"test-item"}`)
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 5; i++ {
+				if err := impl.storer.Set(ctx, collection, fmt.Sprintf("key-%d", i), value); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+
+			page, err := jsonstore.ListPage(ctx, impl.storer, collection, jsonstore.ListOptions{Limit: 2, Page: 1})
+			if err != nil {
+				t.Fatalf("ListPage failed: %v", err)
+			}
+			if page.Total != 5 || page.Page != 1 || page.Limit != 2 || len(page.Items) != 2 {
+				t.Errorf("unexpected page metadata: %+v", page)
+			}
+			if !page.HasNext || page.NextCursor == "" {
+				t.Fatalf("expected page to have a next cursor")
+			}
+
+			// follow the cursors up to the last page
+			pages := 1
+			for page.HasNext {
+				page, err = jsonstore.ListPage(ctx, impl.storer, collection, jsonstore.ListOptions{Cursor: page.NextCursor})
+				if err != nil {
+					t.Fatalf("ListPage failed: %v", err)
+				}
+				pages++
+			}
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+			if len(page.Items) != 1 || page.NextCursor != "" {
+				t.Errorf("unexpected last page: %+v", page)
+			}
+
+			_, err = jsonstore.ListPage(ctx, impl.storer, collection, jsonstore.ListOptions{Cursor: "not a cursor"})
+			if !errors.Is(err, jsonstore.InvalidCursorErr) {
+				t.Errorf("expected InvalidCursorErr, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestListPageMaxLimit(t *testing.T) {
+	cappedFile, err := jsonstore.NewFileStoreWithOptions(jsonstore.InMemoryDb, jsonstore.FileStoreOptions{MaxListItems: 3})
+	if err != nil {
+		t.Fatalf("action: NewFileStoreWithOptions,  returned an error: %v", err)
+	}
+	cappedDb, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), jsonstore.DbStoreOptions{MaxListItems: 3})
+	if err != nil {
+		t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+	}
+
+	tcs := []struct {
+		name      string
+		storer    jsonstore.JsonStorer
+		opts      jsonstore.ListOptions
+		wantLimit int
+	}{
+		{name: "default maximum", storer: jsonstore.NewMemStore(), opts: jsonstore.ListOptions{Limit: 50}, wantLimit: jsonstore.MaxListItems},
+		{name: "higher maximum", storer: jsonstore.NewMemStore(), opts: jsonstore.ListOptions{Limit: 50, MaxLimit: 25}, wantLimit: 25},
+		{name: "lower maximum", storer: newDbStore(t), opts: jsonstore.ListOptions{Limit: 50, MaxLimit: 4}, wantLimit: 4},
+		{name: "no limit", storer: jsonstore.NewMemStore(), opts: jsonstore.ListOptions{MaxLimit: 25}, wantLimit: 25},
+		{name: "fallback capped at MaxListItems", storer: &jsonstoretest.FakeStore{}, opts: jsonstore.ListOptions{Limit: 50, MaxLimit: 25}, wantLimit: jsonstore.MaxListItems},
+		{name: "jsonfile store maximum", storer: cappedFile, opts: jsonstore.ListOptions{Limit: 50, MaxLimit: 25}, wantLimit: 3},
+		{name: "db store maximum", storer: cappedDb, opts: jsonstore.ListOptions{Limit: 50, MaxLimit: 25}, wantLimit: 3},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 30; i++ {
+				if err := tc.storer.Set(ctx, "col1", fmt.Sprintf("key-%02d", i), json.RawMessage(`{"n":1}`)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			page, err := jsonstore.ListPage(ctx, tc.storer, "col1", tc.opts)
+			if err != nil {
+				t.Fatalf("action: ListPage,  returned an error: %v", err)
+			}
+			if page.Limit != tc.wantLimit || len(page.Items) != tc.wantLimit {
+				t.Errorf("expected limit and items %d, got limit %d and %d items", tc.wantLimit, page.Limit, len(page.Items))
+			}
+		})
+	}
+}
+
+func TestListPagePrefix(t *testing.T) {
+	implementations := []struct {
+		name   string
+		storer jsonstore.JsonStorer
+	}{
+		{"mock", &jsonstoretest.FakeStore{}},
+		{"jsonfile", newJsonFile(t)},
+		{"memstore", jsonstore.NewMemStore()},
+		{"dirstore", newDirStore(t)},
+		{"db", newDbStore(t)},
+	}
+
+	collection := "test-collection"
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			ctx := context.Background()
+			// wildcards and a different case must not match the prefix
+			for _, key := range []string{"user_1", "user_2", "user_3", "userX1", "User_4", "group_1"} {
+				if err := impl.storer.Set(ctx, collection, key, json.RawMessage(`{}`)); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+
+			got := []string{}
+			opts := jsonstore.ListOptions{Limit: 2, Prefix: "user_"}
+			for {
+				page, err := jsonstore.ListPage(ctx, impl.storer, collection, opts)
+				if err != nil {
+					t.Fatalf("ListPage failed: %v", err)
+				}
+				if page.Total != 3 {
+					t.Errorf("expected a total of 3, got %d", page.Total)
+				}
+				for key := range page.Items {
+					got = append(got, key)
+				}
+				if !page.HasNext {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			sort.Strings(got)
+			want := []string{"user_1", "user_2", "user_3"}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestForEach(t *testing.T) {
+	implementations := []struct {
+		name   string
+		storer jsonstore.JsonStorer
+	}{
+		{"memstore", jsonstore.NewMemStore()},
+		{"db", newDbStore(t)},
+	}
+
+	collection := "test-collection"
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			ctx := context.Background()
+			want := []string{}
+			// more than a page to verify the paginated fallback
+			for i := 0; i < jsonstore.MaxListItems+5; i++ {
+				key := fmt.Sprintf("key-%02d", i)
+				if err := impl.storer.Set(ctx, collection, key, json.RawMessage(`{}`)); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+				want = append(want, key)
+			}
+
+			got := []string{}
+			err := jsonstore.ForEach(ctx, impl.storer, collection, func(key string, value json.RawMessage) error {
+				got = append(got, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ForEach failed: %v", err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			stop := errors.New("stop")
+			calls := 0
+			err = jsonstore.ForEach(ctx, impl.storer, collection, func(key string, value json.RawMessage) error {
+				calls++
+				return stop
+			})
+			if !errors.Is(err, stop) || calls != 1 {
+				t.Errorf("expected ForEach to stop at the first error, got %v after %d calls", err, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package jsonstoretest provides a fake jsonstore.JsonStorer to unit test the code using the interface,
+// with error injection, latency injection and call recording.
+package jsonstoretest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// Method is a method of the JsonStorer interface
+type Method string
+
+const (
+	MethodSet    Method = "Set"
+	MethodGet    Method = "Get"
+	MethodDelete Method = "Delete"
+	MethodList   Method = "List"
+)
+
+// Call is a call to a FakeStore, Value is the document of Set calls
+type Call struct {
+	Method     Method
+	Collection string
+	Key        string
+	Value      json.RawMessage
+}
+
+// FakeStore is an in memory JsonStorer for tests, the zero value is an empty store ready to use.
+// Every call is recorded, see Calls; errors and latency can be injected to test the error paths and timeouts of
+// the code under test. Missing documents return jsonstore.ItemNotFoundErr, and missing collections
+// jsonstore.CollectionNotFoundErr. The lists are sorted by key.
+type FakeStore struct {
+	mutex sync.Mutex
+	// Data holds the documents by collection and key, it can be filled before the test
+	Data map[string]map[string]json.RawMessage
+	// Err, if set, is returned by every call
+	Err error
+	// ErrFunc, if set, returns the error of a call, nil to run it; it is called after Err is checked
+	ErrFunc func(call Call) error
+	// Latency delays every call, calls whose context is done first return the error of the context
+	Latency time.Duration
+	calls   []Call
+}
+
+// make sure the fake store fulfills the JsonStore interface
+var _ jsonstore.JsonStorer = &FakeStore{}
+
+// NewFakeStore returns a FakeStore holding the documents of data, keyed by collection and key
+func NewFakeStore(data map[string]map[string]json.RawMessage) *FakeStore {
+	return &FakeStore{Data: data}
+}
+
+// Calls returns the calls made to the store, in order
+func (f *FakeStore) Calls() []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount returns how many times method was called
+func (f *FakeStore) CallCount(method Method) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	count := 0
+	for _, call := range f.calls {
+		if call.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset clears the recorded calls
+func (f *FakeStore) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = nil
+}
+
+// call records a call and returns the injected error, after waiting the Latency
+func (f *FakeStore) call(ctx context.Context, call Call) error {
+	f.mutex.Lock()
+	f.calls = append(f.calls, call)
+	latency, err, errFunc := f.Latency, f.Err, f.ErrFunc
+	f.mutex.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if errFunc != nil {
+		return errFunc(call)
+	}
+	return nil
+}
+
+func (f *FakeStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if err := f.call(ctx, Call{Method: MethodSet, Collection: collection, Key: key, Value: value}); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.Data == nil {
+		f.Data = make(map[string]map[string]json.RawMessage)
+	}
+	if f.Data[collection] == nil {
+		f.Data[collection] = make(map[string]json.RawMessage)
+	}
+	f.Data[collection][key] = append(json.RawMessage(nil), value...)
+	return nil
+}
+
+func (f *FakeStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	if err := f.call(ctx, Call{Method: MethodGet, Collection: collection, Key: key}); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	col, ok := f.Data[collection]
+	if !ok {
+		return jsonstore.CollectionNotFoundErr
+	}
+	val, ok := col[key]
+	if !ok {
+		return jsonstore.ItemNotFoundErr
+	}
+	*value = append(json.RawMessage(nil), val...)
+	return nil
+}
+
+func (f *FakeStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if err := f.call(ctx, Call{Method: MethodDelete, Collection: collection, Key: key}); err != nil {
+		return false, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if col, ok := f.Data[collection]; ok {
+		if _, ok := col[key]; ok {
+			delete(col, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *FakeStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if err := f.call(ctx, Call{Method: MethodList, Collection: collection}); err != nil {
+		return map[string]json.RawMessage{}, 0, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	col, exists := f.Data[collection]
+	if !exists {
+		return nil, 0, nil
+	}
+
+	keys := make([]string, 0, len(col))
+	for k := range col {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	start := (page - 1) * limit
+	end := start + limit
+	items := make(map[string]json.RawMessage)
+	for i, k := range keys {
+		if i >= start && i < end {
+			items[k] = append(json.RawMessage(nil), col[k]...)
+		}
+	}
+	return items, int64(len(col)), nil
+}
@@ -0,0 +1,95 @@
+package jsonstoretest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/go-bumbu/jsonstore/storetest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFakeStoreConformance(t *testing.T) {
+	storetest.TestStorer(t, func() jsonstore.JsonStorer { return &jsonstoretest.FakeStore{} })
+}
+
+func TestFakeStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("record calls", func(t *testing.T) {
+		store := jsonstoretest.NewFakeStore(map[string]map[string]json.RawMessage{"col1": {"item1": json.RawMessage(`{}`)}})
+		var got json.RawMessage
+		_ = store.Get(ctx, "col1", "item1", &got)
+		_ = store.Set(ctx, "col1", "item2", json.RawMessage(`{"n":1}`))
+		_, _ = store.Delete(ctx, "col1", "item1")
+		_, _, _ = store.List(ctx, "col1", 10, 1)
+
+		want := []jsonstoretest.Call{
+			{Method: jsonstoretest.MethodGet, Collection: "col1", Key: "item1"},
+			{Method: jsonstoretest.MethodSet, Collection: "col1", Key: "item2", Value: json.RawMessage(`{"n":1}`)},
+			{Method: jsonstoretest.MethodDelete, Collection: "col1", Key: "item1"},
+			{Method: jsonstoretest.MethodList, Collection: "col1"},
+		}
+		if diff := cmp.Diff(store.Calls(), want); diff != "" {
+			t.Errorf("unexpected calls (-got +want):\n%s", diff)
+		}
+		if got := store.CallCount(jsonstoretest.MethodSet); got != 1 {
+			t.Errorf("expected 1 Set call, got %d", got)
+		}
+		store.Reset()
+		if got := len(store.Calls()); got != 0 {
+			t.Errorf("expected no calls after Reset, got %d", got)
+		}
+	})
+
+	t.Run("inject errors", func(t *testing.T) {
+		errFull := errors.New("disk full")
+		store := &jsonstoretest.FakeStore{ErrFunc: func(call jsonstoretest.Call) error {
+			if call.Method == jsonstoretest.MethodSet && call.Collection == "full" {
+				return errFull
+			}
+			return nil
+		}}
+		if err := store.Set(ctx, "full", "item1", json.RawMessage(`{}`)); !errors.Is(err, errFull) {
+			t.Errorf("expected the injected error, got: %v", err)
+		}
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{}`)); err != nil {
+			t.Errorf("action: Set,  returned an error: %v", err)
+		}
+		if _, ok := store.Data["full"]; ok {
+			t.Errorf("expected the failed Set not to store the document")
+		}
+
+		store.Err = errors.New("unavailable")
+		if _, err := store.Delete(ctx, "col1", "item1"); !errors.Is(err, store.Err) {
+			t.Errorf("expected the injected error, got: %v", err)
+		}
+	})
+
+	t.Run("inject latency", func(t *testing.T) {
+		store := &jsonstoretest.FakeStore{Latency: time.Second}
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		var got json.RawMessage
+		if err := store.Get(ctx, "col1", "item1", &got); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected DeadlineExceeded, got: %v", err)
+		}
+		if time.Since(start) >= time.Second {
+			t.Errorf("expected the call to return once the context is done")
+		}
+
+		store.Latency = 10 * time.Millisecond
+		start = time.Now()
+		if _, _, err := store.List(context.Background(), "col1", 10, 1); err != nil {
+			t.Errorf("action: List,  returned an error: %v", err)
+		}
+		if time.Since(start) < store.Latency {
+			t.Errorf("expected the call to be delayed")
+		}
+	})
+}
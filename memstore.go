@@ -0,0 +1,158 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// MemStore is a map based store that keeps all the documents in memory, it is meant for tests
+// and ephemeral caches where persistence is not needed.
+// Values are copied on Set and Get, so callers can safely reuse their buffers.
+type MemStore struct {
+	mutex   sync.RWMutex
+	content map[string]map[string]json.RawMessage
+}
+
+// make sure the memory store fulfills the JsonStore interface
+var _ JsonStorer = &MemStore{}
+var _ PageLister = &MemStore{}
+var _ Patcher = &MemStore{}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		content: map[string]map[string]json.RawMessage{},
+	}
+}
+
+func (m *MemStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.content[collection]; !ok {
+		m.content[collection] = map[string]json.RawMessage{}
+	}
+	m.content[collection][key] = copyRaw(value)
+	return nil
+}
+
+func (m *MemStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	v, ok := m.content[collection][key]
+	if !ok {
+		return ItemNotFoundErr
+	}
+	*value = copyRaw(v)
+	return nil
+}
+
+// Patch applies a patch to a document under the write lock and returns the patched document
+func (m *MemStore) Patch(ctx context.Context, collection, key string, patch json.RawMessage, patchType PatchType) (json.RawMessage, error) {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	v, ok := m.content[collection][key]
+	if !ok {
+		return nil, ItemNotFoundErr
+	}
+	patched, err := ApplyPatch(v, patch, patchType)
+	if err != nil {
+		return nil, err
+	}
+	m.content[collection][key] = patched
+	return copyRaw(patched), nil
+}
+
+// List returns the documents of a collection and the total amount of documents in it
+func (m *MemStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := m.ListPage(ctx, collection, ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage returns a page of documents of the collection sorted by key, a missing collection is an empty one
+func (m *MemStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	opts, err := opts.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	col := m.content[collection]
+	keys := make([]string, 0, len(col))
+	for key := range col {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keys = filterPrefix(keys, opts.Prefix)
+
+	pageKeys := pageKeys(keys, opts)
+	result := make(map[string]json.RawMessage, len(pageKeys))
+	for _, key := range pageKeys {
+		result[key] = copyRaw(col[key])
+	}
+	return NewPage(result, int64(len(keys)), opts.Page, opts.Limit), nil
+}
+
+func (m *MemStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.content[collection][key]; !ok {
+		return false, nil
+	}
+	delete(m.content[collection], key)
+	if len(m.content[collection]) == 0 {
+		delete(m.content, collection)
+	}
+	return true, nil
+}
+
+// Collections returns the names of the collections holding documents, sorted
+func (m *MemStore) Collections(ctx context.Context) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	names := make([]string, 0, len(m.content))
+	for collection := range m.content {
+		names = append(names, collection)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DropCollection removes a collection and all its documents
+func (m *MemStore) DropCollection(ctx context.Context, collection string) error {
+	if collection == "" {
+		collection = DefaultCollection
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.content, collection)
+	return nil
+}
+
+func copyRaw(in json.RawMessage) json.RawMessage {
+	if in == nil {
+		return nil
+	}
+	out := make(json.RawMessage, len(in))
+	copy(out, in)
+	return out
+}
@@ -0,0 +1,161 @@
+package mongostore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-bumbu/jsonstore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const fieldId = "_id"
+const fieldValue = "value"
+
+// MongoStore stores json documents in a MongoDB database, every jsonstore collection maps
+// to a mongo collection and the key is used as the document _id.
+// The value is stored as a native mongo document in the "value" field, hence it can be queried
+// with the usual mongo tooling; note that the returned json is re-encoded by mongo, e.g. whitespace is not preserved.
+type MongoStore struct {
+	db *mongo.Database
+}
+
+// make sure the mongo store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &MongoStore{}
+
+// NewMongoStore returns a store using the provided database,
+// the caller is responsible to disconnect the client once done.
+func NewMongoStore(db *mongo.Database) (*MongoStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("mongo database cannot be nil")
+	}
+	store := MongoStore{
+		db: db,
+	}
+	return &store, nil
+}
+
+func (store *MongoStore) col(collection string) *mongo.Collection {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	return store.db.Collection(collection)
+}
+
+func (store *MongoStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if key == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	val, err := toBson(value)
+	if err != nil {
+		return err
+	}
+	doc := bson.D{{Key: fieldId, Value: key}, {Key: fieldValue, Value: val}}
+	_, err = store.col(collection).ReplaceOne(ctx, bson.D{{Key: fieldId, Value: key}}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *MongoStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	doc := mongoDocument{}
+	err := store.col(collection).FindOne(ctx, bson.D{{Key: fieldId, Value: key}}).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value, err = toJson(doc.Value)
+	return err
+}
+
+func (store *MongoStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	col := store.col(collection)
+
+	count, err := col.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: fieldId, Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := col.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	docs := []mongoDocument{}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+
+	result := map[string]json.RawMessage{}
+	for _, doc := range docs {
+		result[doc.ID], err = toJson(doc.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return result, count, nil
+}
+
+func (store *MongoStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	res, err := store.col(collection).DeleteOne(ctx, bson.D{{Key: fieldId, Value: key}})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return res.DeletedCount == 1, nil
+}
+
+// mongoDocument is the representation of a stored document
+type mongoDocument struct {
+	ID    string        `bson:"_id"`
+	Value bson.RawValue `bson:"value"`
+}
+
+// toBson converts a json value into its bson representation, the value is wrapped in a document
+// since extended json only allows documents at the top level.
+func toBson(value json.RawMessage) (interface{}, error) {
+	if !json.Valid(value) {
+		return nil, fmt.Errorf("value is not valid json")
+	}
+	wrapped := make([]byte, 0, len(value)+6)
+	wrapped = append(wrapped, `{"v":`...)
+	wrapped = append(wrapped, value...)
+	wrapped = append(wrapped, '}')
+
+	doc := bson.D{}
+	err := bson.UnmarshalExtJSON(wrapped, false, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert value to bson: %v", err)
+	}
+	return doc[0].Value, nil
+}
+
+// toJson converts a stored bson value back into relaxed extended json
+func toJson(value bson.RawValue) (json.RawMessage, error) {
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert value to json: %v", err)
+	}
+	wrapper := struct {
+		V json.RawMessage `json:"v"`
+	}{}
+	if err = json.Unmarshal(b, &wrapper); err != nil {
+		return nil, fmt.Errorf("failed to convert value to json: %v", err)
+	}
+	return wrapper.V, nil
+}
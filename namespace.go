@@ -0,0 +1,141 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// NamespaceSeparator joins the namespace of a NamespacedStore and the collection names, e.g. tenant42.users
+const NamespaceSeparator = "."
+
+// NamespacedStore exposes the collections of a namespace of the inner store, prefixing all the collection names
+// with the namespace, so that one physical store can host many tenants without them seeing each other's data.
+//
+// The namespace must not be empty nor contain the NamespaceSeparator, otherwise a namespace could reach the
+// collections of another one (e.g. namespace a and collection b.users of namespace a.b); the operations of a store
+// with an invalid namespace return an error.
+type NamespacedStore struct {
+	inner  JsonStorer
+	prefix string
+	err    error
+}
+
+// make sure the namespaced store fulfills the optional interfaces
+var _ PageLister = &NamespacedStore{}
+var _ ForEacher = &NamespacedStore{}
+var _ CollectionManager = &NamespacedStore{}
+var _ Aggregator = &NamespacedStore{}
+
+// NewNamespacedStore returns a store whose collections are the collections of inner prefixed with
+// namespace + NamespaceSeparator, e.g. a namespace per tenant.
+func NewNamespacedStore(inner JsonStorer, namespace string) *NamespacedStore {
+	s := &NamespacedStore{inner: inner, prefix: namespace + NamespaceSeparator}
+	if namespace == "" || strings.Contains(namespace, NamespaceSeparator) {
+		s.err = fmt.Errorf("invalid namespace %q: it must not be empty nor contain %q", namespace, NamespaceSeparator)
+	}
+	return s
+}
+
+// Namespace returns the namespace of the store
+func (s *NamespacedStore) Namespace() string {
+	return strings.TrimSuffix(s.prefix, NamespaceSeparator)
+}
+
+// collection returns the name of the collection in the inner store
+func (s *NamespacedStore) collection(collection string) string {
+	return s.prefix + collection
+}
+
+func (s *NamespacedStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.inner.Set(ctx, s.collection(collection), key, value)
+}
+
+func (s *NamespacedStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.inner.Get(ctx, s.collection(collection), key, value)
+}
+
+func (s *NamespacedStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return s.inner.Delete(ctx, s.collection(collection), key)
+}
+
+func (s *NamespacedStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	return s.inner.List(ctx, s.collection(collection), limit, page)
+}
+
+// ListPage lists a page of the collection, with the PageLister of the inner store if available
+func (s *NamespacedStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	if s.err != nil {
+		return Page{}, s.err
+	}
+	return ListPage(ctx, s.inner, s.collection(collection), opts)
+}
+
+// ForEach streams the documents of the collection, with the ForEacher of the inner store if available
+func (s *NamespacedStore) ForEach(ctx context.Context, collection string, fn func(key string, value json.RawMessage) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	return ForEach(ctx, s.inner, s.collection(collection), fn)
+}
+
+// Aggregate aggregates the documents of the collection, with the Aggregator of the inner store if available
+func (s *NamespacedStore) Aggregate(ctx context.Context, collection string, spec AggregateSpec) ([]AggregateGroup, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return Aggregate(ctx, s.inner, s.collection(collection), spec)
+}
+
+// Collections returns the collections of the namespace, without the namespace prefix;
+// the inner store needs to be a CollectionManager
+func (s *NamespacedStore) Collections(ctx context.Context) ([]string, error) {
+	cm, err := s.collectionManager()
+	if err != nil {
+		return nil, err
+	}
+	all, err := cm.Collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collections := []string{}
+	for _, collection := range all {
+		if name, ok := strings.CutPrefix(collection, s.prefix); ok && name != "" {
+			collections = append(collections, name)
+		}
+	}
+	return collections, nil
+}
+
+// DropCollection drops a collection of the namespace, the inner store needs to be a CollectionManager
+func (s *NamespacedStore) DropCollection(ctx context.Context, collection string) error {
+	cm, err := s.collectionManager()
+	if err != nil {
+		return err
+	}
+	return cm.DropCollection(ctx, s.collection(collection))
+}
+
+func (s *NamespacedStore) collectionManager() (CollectionManager, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	cm, ok := s.inner.(CollectionManager)
+	if !ok {
+		return nil, fmt.Errorf("the inner store of the namespace is not a CollectionManager")
+	}
+	return cm, nil
+}
@@ -0,0 +1,93 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNamespacedStore(t *testing.T) {
+	ctx := context.Background()
+	inner := jsonstore.NewMemStore()
+	tenant1 := jsonstore.NewNamespacedStore(inner, "tenant1")
+	tenant2 := jsonstore.NewNamespacedStore(inner, "tenant2")
+
+	for _, tc := range []struct {
+		store      *jsonstore.NamespacedStore
+		collection string
+		key        string
+	}{
+		{tenant1, "users", "alice"},
+		{tenant1, "orders", "o1"},
+		{tenant2, "users", "bob"},
+	} {
+		if err := tc.store.Set(ctx, tc.collection, tc.key, json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+
+	t.Run("collections are prefixed", func(t *testing.T) {
+		var got json.RawMessage
+		if err := inner.Get(ctx, "tenant1.users", "alice", &got); err != nil {
+			t.Errorf("action: Get,  returned an error: %v", err)
+		}
+		if err := tenant2.Get(ctx, "users", "alice", &got); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		items, total, err := tenant2.List(ctx, "users", 10, 1)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(items, map[string]json.RawMessage{"bob": json.RawMessage(`{}`)}); diff != "" || total != 1 {
+			t.Errorf("unexpected items, total %d (-got +want):\n%s", total, diff)
+		}
+		page, err := jsonstore.ListPage(ctx, tenant1, "users", jsonstore.ListOptions{Limit: 10})
+		if err != nil {
+			t.Fatalf("action: ListPage,  returned an error: %v", err)
+		}
+		if _, ok := page.Items["alice"]; !ok || page.Total != 1 {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("collections", func(t *testing.T) {
+		if err := inner.Set(ctx, "global", "settings", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		got, err := tenant1.Collections(ctx)
+		if err != nil {
+			t.Fatalf("action: Collections,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, []string{"orders", "users"}); diff != "" {
+			t.Errorf("unexpected collections (-got +want):\n%s", diff)
+		}
+
+		if err = tenant1.DropCollection(ctx, "users"); err != nil {
+			t.Fatalf("action: DropCollection,  returned an error: %v", err)
+		}
+		got, _ = inner.Collections(ctx)
+		if diff := cmp.Diff(got, []string{"global", "tenant1.orders", "tenant2.users"}); diff != "" {
+			t.Errorf("unexpected inner collections (-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("invalid namespace", func(t *testing.T) {
+		for _, namespace := range []string{"", "tenant1.users"} {
+			store := jsonstore.NewNamespacedStore(inner, namespace)
+			var got json.RawMessage
+			if err := store.Get(ctx, "orders", "o1", &got); err == nil {
+				t.Errorf("expected an error for namespace %q", namespace)
+			}
+			if _, err := store.Collections(ctx); err == nil {
+				t.Errorf("expected an error for namespace %q", namespace)
+			}
+		}
+	})
+}
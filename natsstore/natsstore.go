@@ -0,0 +1,211 @@
+package natsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NatsStore stores json documents in NATS JetStream Key-Value buckets, one bucket per collection.
+// Buckets are created on the first write, collection names and keys need to be valid bucket names and keys.
+type NatsStore struct {
+	js jetstream.JetStream
+	// BucketConfig, if set, is used to create new buckets, the bucket name is filled in by the store
+	BucketConfig func(collection string) jetstream.KeyValueConfig
+}
+
+// make sure the nats store fulfills the JsonStorer and Watcher interfaces
+var _ jsonstore.JsonStorer = &NatsStore{}
+var _ jsonstore.Watcher = &NatsStore{}
+
+// NewNatsStore returns a store using the provided JetStream context, the caller is responsible to close the connection.
+func NewNatsStore(js jetstream.JetStream) (*NatsStore, error) {
+	if js == nil {
+		return nil, fmt.Errorf("jetstream cannot be nil")
+	}
+	store := NatsStore{
+		js: js,
+	}
+	return &store, nil
+}
+
+// bucket returns the bucket of the collection, if create is false a missing bucket returns jetstream.ErrBucketNotFound
+func (store *NatsStore) bucket(ctx context.Context, collection string, create bool) (jetstream.KeyValue, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	kv, err := store.js.KeyValue(ctx, collection)
+	if err == nil || !create || !errors.Is(err, jetstream.ErrBucketNotFound) {
+		return kv, err
+	}
+	cfg := jetstream.KeyValueConfig{}
+	if store.BucketConfig != nil {
+		cfg = store.BucketConfig(collection)
+	}
+	cfg.Bucket = collection
+	return store.js.CreateKeyValue(ctx, cfg)
+}
+
+func (store *NatsStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if key == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	kv, err := store.bucket(ctx, collection, true)
+	if err != nil {
+		return fmt.Errorf("failed to open bucket: %v", err)
+	}
+	_, err = kv.Put(ctx, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *NatsStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	kv, err := store.bucket(ctx, collection, false)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to open bucket: %v", err)
+	}
+	entry, err := kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value = entry.Value()
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, the keys of the bucket are loaded to
+// sort them and the values of the requested page are read one by one.
+func (store *NatsStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	result := map[string]json.RawMessage{}
+	kv, err := store.bucket(ctx, collection, false)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			return result, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open bucket: %v", err)
+	}
+	keys, err := kv.Keys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return result, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+	sort.Strings(keys)
+
+	end := offset + limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+	if offset > end {
+		offset = end
+	}
+	for _, key := range keys[offset:end] {
+		entry, err := kv.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				// deleted in the meantime
+				continue
+			}
+			return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		result[key] = entry.Value()
+	}
+	return result, int64(len(keys)), nil
+}
+
+func (store *NatsStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	kv, err := store.bucket(ctx, collection, false)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open bucket: %v", err)
+	}
+	// deleting a key adds a delete marker, check beforehand whether there is something to delete
+	entry, err := kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	err = kv.Delete(ctx, key, jetstream.LastRevision(entry.Revision()))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return true, nil
+}
+
+// Watch notifies the changes of the collection using a KV watcher on the bucket, the bucket is created if needed
+func (store *NatsStore) Watch(ctx context.Context, collection string) (<-chan jsonstore.Event, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	kv, err := store.bucket(ctx, collection, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bucket: %v", err)
+	}
+	watcher, err := kv.WatchAll(ctx, jetstream.UpdatesOnly())
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch bucket: %v", err)
+	}
+
+	events := make(chan jsonstore.Event)
+	go func() {
+		defer close(events)
+		defer watcher.Stop()
+		for {
+			var entry jetstream.KeyValueEntry
+			var ok bool
+			select {
+			case entry, ok = <-watcher.Updates():
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			if entry == nil {
+				continue
+			}
+			event := jsonstore.Event{
+				Collection: collection,
+				Key:        entry.Key(),
+			}
+			switch entry.Operation() {
+			case jetstream.KeyValuePut:
+				event.Type = jsonstore.EventSet
+				event.Value = entry.Value()
+			case jetstream.KeyValueDelete, jetstream.KeyValuePurge:
+				event.Type = jsonstore.EventDelete
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
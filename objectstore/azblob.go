@@ -0,0 +1,176 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// azureApiVersion is the version of the blob service rest api the requests are sent with
+const azureApiVersion = "2021-08-06"
+
+func init() {
+	RegisterDriver("azblob", openAzblob)
+}
+
+// openAzblob opens azblob://<container>?account=<account>, the requests go to https://<account>.blob.core.windows.net
+// unless the "endpoint" query parameter is set, e.g. to use Azurite. A shared access signature can be passed
+// with the "sas" query parameter, otherwise the http client needs to authenticate the requests.
+func openAzblob(_ context.Context, u *url.URL, client *http.Client) (Bucket, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("container name cannot be empty")
+	}
+	q := u.Query()
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		account := q.Get("account")
+		if account == "" {
+			return nil, fmt.Errorf("either the account or the endpoint parameter is required")
+		}
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(q.Get("sas"), "?"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sas token: %v", err)
+	}
+	return &AzureBucket{client: client, endpoint: strings.TrimSuffix(endpoint, "/"), container: u.Host, sas: sas}, nil
+}
+
+// AzureBucket is a Bucket on an Azure Blob Storage container using the blob service rest api
+type AzureBucket struct {
+	client    *http.Client
+	endpoint  string
+	container string
+	sas       url.Values
+}
+
+func (b *AzureBucket) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	for k, v := range b.sas {
+		query[k] = v
+	}
+	u := b.endpoint + "/" + url.PathEscape(b.container) + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureApiVersion)
+	if body != nil {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return b.client.Do(req)
+}
+
+// blobPath escapes the blob name keeping the "/" separators
+func blobPath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+func (b *AzureBucket) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, blobPath(name), nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseErr(resp)
+	}
+	return nil
+}
+
+func (b *AzureBucket) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, blobPath(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ObjectNotFoundErr
+	}
+	return nil, responseErr(resp)
+}
+
+func (b *AzureBucket) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, blobPath(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil
+	case http.StatusNotFound:
+		return ObjectNotFoundErr
+	}
+	return responseErr(resp)
+}
+
+type azureList struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *AzureBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	names := []string{}
+	marker := ""
+	for {
+		q := url.Values{}
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		q.Set("prefix", prefix)
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		resp, err := b.do(ctx, http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = responseErr(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		list := azureList{}
+		err = xml.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode response: %v", err)
+		}
+		for _, blob := range list.Blobs.Blob {
+			names = append(names, blob.Name)
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+	sort.Strings(names)
+	return names, nil
+}
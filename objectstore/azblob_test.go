@@ -0,0 +1,88 @@
+package objectstore_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore/objectstore"
+)
+
+type azureBlob struct {
+	Name string `xml:"Name"`
+}
+
+type azureEnumeration struct {
+	XMLName xml.Name    `xml:"EnumerationResults"`
+	Blobs   []azureBlob `xml:"Blobs>Blob"`
+}
+
+// azureServer fakes the subset of the blob service rest api used by the driver
+func azureServer(objs *objects) http.Handler {
+	const containerPath = "/container"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objs.mutex.Lock()
+		defer objs.mutex.Unlock()
+		if r.Header.Get("x-ms-version") == "" || r.URL.Query().Get("sig") != "secret" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == containerPath && r.URL.Query().Get("comp") == "list" {
+			list := azureEnumeration{}
+			for name := range objs.data {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					list.Blobs = append(list.Blobs, azureBlob{Name: name})
+				}
+			}
+			_ = xml.NewEncoder(w).Encode(list)
+			return
+		}
+		name, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), containerPath+"/"))
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				http.Error(w, "missing blob type", http.StatusBadRequest)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objs.data[name] = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, ok := objs.data[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			if _, ok := objs.data[name]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			delete(objs.data, name)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+}
+
+func TestAzureBucket(t *testing.T) {
+	objs := newObjects()
+	srv := httptest.NewServer(azureServer(objs))
+	defer srv.Close()
+
+	q := url.Values{}
+	q.Set("endpoint", srv.URL)
+	q.Set("sas", "sv=2021-08-06&sig=secret")
+	store, err := objectstore.Open(context.Background(), "azblob://container/data?"+q.Encode(), srv.Client())
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	testObjectStore(t, store, objs)
+}
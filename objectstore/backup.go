@@ -0,0 +1,57 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// BackupTarget is a jsonstore.BackupDestination storing the backups as objects <Prefix><name> in a bucket,
+// it deletes the old backups according to the retention of the jsonstore.BackupScheduler
+type BackupTarget struct {
+	Bucket Bucket
+	// Prefix is prepended to the backup names, e.g. "backups/"
+	Prefix string
+}
+
+// make sure the backup target fulfills the BackupDestination and BackupPruner interfaces
+var _ jsonstore.BackupDestination = BackupTarget{}
+var _ jsonstore.BackupPruner = BackupTarget{}
+
+// Put uploads a backup, the bucket drivers upload whole objects so the backup is read in memory first
+func (b BackupTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	return b.Bucket.Put(ctx, b.Prefix+name, data)
+}
+
+// List returns the names of the backups, without the prefix
+func (b BackupTarget) List(ctx context.Context) ([]string, error) {
+	objects, err := b.Bucket.List(ctx, b.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		name := strings.TrimPrefix(object, b.Prefix)
+		// objects in sub folders of the prefix are not backups
+		if !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (b BackupTarget) Delete(ctx context.Context, name string) error {
+	err := b.Bucket.Delete(ctx, b.Prefix+name)
+	if errors.Is(err, ObjectNotFoundErr) {
+		return jsonstore.SnapshotNotFoundErr
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package objectstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/objectstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBackupTarget(t *testing.T) {
+	ctx := context.Background()
+	objs := newObjects()
+	srv := httptest.NewServer(gcsServer(objs))
+	defer srv.Close()
+
+	bucket, err := objectstore.OpenBucket(ctx, "gs://bucket?endpoint="+url.QueryEscape(srv.URL), srv.Client())
+	if err != nil {
+		t.Fatalf("action: OpenBucket,  returned an error: %v", err)
+	}
+	// objects in sub folders are not backups
+	if err = bucket.Put(ctx, "backups/other/file.json", []byte(`{}`)); err != nil {
+		t.Fatalf("action: Put,  returned an error: %v", err)
+	}
+
+	store := jsonstore.NewMemStore()
+	if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{"n":1}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	target := objectstore.BackupTarget{Bucket: bucket, Prefix: "backups/"}
+	scheduler := jsonstore.NewBackupScheduler(store, target, jsonstore.BackupOptions{
+		Retention: jsonstore.BackupRetention{KeepLast: 2},
+	})
+	var names []string
+	for i := 0; i < 3; i++ {
+		run := scheduler.RunOnce(ctx)
+		if run.Err != nil {
+			t.Fatalf("action: RunOnce,  returned an error: %v", run.Err)
+		}
+		names = append(names, run.Name)
+	}
+
+	got, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff(got, names[1:]); diff != "" {
+		t.Errorf("unexpected backups (-got +want):\n%s", diff)
+	}
+
+	data, err := bucket.Get(ctx, "backups/"+names[2])
+	if err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(string(data), `{"col1":{"item1":{"n":1}}}`); diff != "" {
+		t.Errorf("unexpected backup (-got +want):\n%s", diff)
+	}
+
+	if err = target.Delete(ctx, names[0]); !errors.Is(err, jsonstore.SnapshotNotFoundErr) {
+		t.Errorf("expected SnapshotNotFoundErr, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultGcsEndpoint is the Google Cloud Storage JSON api endpoint
+const DefaultGcsEndpoint = "https://storage.googleapis.com"
+
+func init() {
+	RegisterDriver("gs", openGcs)
+}
+
+// openGcs opens gs://<bucket>, the endpoint can be changed with the "endpoint" query parameter,
+// e.g. to use an emulator. The http client needs to add the credentials, e.g. golang.org/x/oauth2/google.
+func openGcs(_ context.Context, u *url.URL, client *http.Client) (Bucket, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	endpoint := u.Query().Get("endpoint")
+	if endpoint == "" {
+		endpoint = DefaultGcsEndpoint
+	}
+	return &GcsBucket{client: client, endpoint: strings.TrimSuffix(endpoint, "/"), bucket: u.Host}, nil
+}
+
+// GcsBucket is a Bucket on Google Cloud Storage using the JSON api
+type GcsBucket struct {
+	client   *http.Client
+	endpoint string
+	bucket   string
+}
+
+func (b *GcsBucket) objectUrl(name string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", b.endpoint, url.PathEscape(b.bucket), url.PathEscape(name))
+}
+
+func (b *GcsBucket) do(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return b.client.Do(req)
+}
+
+func (b *GcsBucket) Put(ctx context.Context, name string, data []byte) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		b.endpoint, url.PathEscape(b.bucket), url.QueryEscape(name))
+	resp, err := b.do(ctx, http.MethodPost, u, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseErr(resp)
+	}
+	return nil
+}
+
+func (b *GcsBucket) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.objectUrl(name)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ObjectNotFoundErr
+	}
+	return nil, responseErr(resp)
+}
+
+func (b *GcsBucket) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, b.objectUrl(name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ObjectNotFoundErr
+	}
+	return responseErr(resp)
+}
+
+type gcsList struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (b *GcsBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	names := []string{}
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("prefix", prefix)
+		q.Set("fields", "items(name),nextPageToken")
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", b.endpoint, url.PathEscape(b.bucket), q.Encode())
+		resp, err := b.do(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = responseErr(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		list := gcsList{}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode response: %v", err)
+		}
+		for _, item := range list.Items {
+			names = append(names, item.Name)
+		}
+		if list.NextPageToken == "" {
+			break
+		}
+		token = list.NextPageToken
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// responseErr returns an error out of an unexpected http response
+func responseErr(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
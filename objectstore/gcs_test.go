@@ -0,0 +1,64 @@
+package objectstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore/objectstore"
+)
+
+// gcsServer fakes the subset of the Cloud Storage JSON api used by the driver
+func gcsServer(objs *objects) http.Handler {
+	const objPrefix = "/storage/v1/b/bucket/o/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objs.mutex.Lock()
+		defer objs.mutex.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			body, _ := io.ReadAll(r.Body)
+			objs.data[r.URL.Query().Get("name")] = body
+			_ = json.NewEncoder(w).Encode(map[string]string{"name": r.URL.Query().Get("name")})
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+			items := []map[string]string{}
+			for name := range objs.data {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{"name": name})
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"items": items})
+		case strings.HasPrefix(r.URL.EscapedPath(), objPrefix):
+			name, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), objPrefix))
+			data, ok := objs.data[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(objs.data, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+}
+
+func TestGcsBucket(t *testing.T) {
+	objs := newObjects()
+	srv := httptest.NewServer(gcsServer(objs))
+	defer srv.Close()
+
+	store, err := objectstore.Open(context.Background(), "gs://bucket/data?endpoint="+url.QueryEscape(srv.URL), srv.Client())
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	testObjectStore(t, store, objs)
+}
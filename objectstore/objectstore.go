@@ -0,0 +1,220 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// ObjectNotFoundErr is returned by the Bucket drivers when an object does not exist
+var ObjectNotFoundErr = errors.New("object not found")
+
+// Bucket is the driver interface implemented by every object storage provider
+type Bucket interface {
+	Put(ctx context.Context, name string, data []byte) error
+	// Get returns ObjectNotFoundErr if the object does not exist
+	Get(ctx context.Context, name string) ([]byte, error)
+	// Delete returns ObjectNotFoundErr if the object does not exist
+	Delete(ctx context.Context, name string) error
+	// List returns the names of the objects starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Opener creates a Bucket out of an url, the http client is responsible to authenticate the requests
+type Opener func(ctx context.Context, u *url.URL, client *http.Client) (Bucket, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Opener{}
+)
+
+// RegisterDriver makes a driver available for the url scheme, it panics if the scheme is already registered
+func RegisterDriver(scheme string, open Opener) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := drivers[scheme]; dup {
+		panic("objectstore: driver already registered for scheme " + scheme)
+	}
+	drivers[scheme] = open
+}
+
+// OpenBucket opens a bucket using the driver registered for the url scheme,
+// e.g. gs://my-bucket, s3://my-bucket?region=eu-west-1 or azblob://my-container?account=my-account
+func OpenBucket(ctx context.Context, rawUrl string, client *http.Client) (Bucket, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket url: %v", err)
+	}
+	driversMu.RLock()
+	open, ok := drivers[u.Scheme]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no driver registered for scheme %q", u.Scheme)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return open(ctx, u, client)
+}
+
+// ObjectStore stores every json document as an object <prefix><collection>/<key>.json, keys are path escaped.
+type ObjectStore struct {
+	bucket Bucket
+	prefix string
+}
+
+// make sure the object store fulfills the JsonStorer interface
+var _ jsonstore.JsonStorer = &ObjectStore{}
+
+const objectExtension = ".json"
+
+// NewObjectStore returns a store writing into the bucket, object names are prefixed with prefix
+func NewObjectStore(bucket Bucket, prefix string) (*ObjectStore, error) {
+	if bucket == nil {
+		return nil, fmt.Errorf("bucket cannot be nil")
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &ObjectStore{bucket: bucket, prefix: prefix}, nil
+}
+
+// Open returns a store on the bucket of the url, the path of the url is used as prefix,
+// e.g. gs://my-bucket/data stores the documents under data/<collection>/<key>.json
+func Open(ctx context.Context, rawUrl string, client *http.Client) (*ObjectStore, error) {
+	bucket, err := OpenBucket(ctx, rawUrl, client)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(rawUrl)
+	return NewObjectStore(bucket, strings.TrimPrefix(u.Path, "/"))
+}
+
+func (store *ObjectStore) colPrefix(collection string) (string, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if strings.Contains(collection, "/") {
+		return "", fmt.Errorf("collection name cannot contain \"/\"")
+	}
+	return store.prefix + collection + "/", nil
+}
+
+func (store *ObjectStore) objectName(collection, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("id cannot be empty")
+	}
+	prefix, err := store.colPrefix(collection)
+	if err != nil {
+		return "", err
+	}
+	return prefix + url.PathEscape(key) + objectExtension, nil
+}
+
+func (store *ObjectStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	name, err := store.objectName(collection, key)
+	if err != nil {
+		return err
+	}
+	if err = store.bucket.Put(ctx, name, value); err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *ObjectStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	name, err := store.objectName(collection, key)
+	if err != nil {
+		return err
+	}
+	data, err := store.bucket.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, ObjectNotFoundErr) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value = data
+	return nil
+}
+
+// List returns the documents of a collection sorted by key, the object names are listed and only
+// the objects of the requested page are read.
+func (store *ObjectStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	prefix, err := store.colPrefix(collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 || limit > jsonstore.MaxListItems {
+		limit = jsonstore.MaxListItems
+	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	names, err := store.bucket.List(ctx, prefix)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+	keys := make([]string, 0, len(names))
+	objects := map[string]string{}
+	for _, name := range names {
+		escaped := strings.TrimPrefix(name, prefix)
+		if strings.Contains(escaped, "/") || !strings.HasSuffix(escaped, objectExtension) {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSuffix(escaped, objectExtension))
+		if err != nil {
+			// not written by the store
+			continue
+		}
+		keys = append(keys, key)
+		objects[key] = name
+	}
+	sort.Strings(keys)
+
+	end := offset + limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+	if offset > end {
+		offset = end
+	}
+	result := map[string]json.RawMessage{}
+	for _, key := range keys[offset:end] {
+		data, err := store.bucket.Get(ctx, objects[key])
+		if err != nil {
+			if errors.Is(err, ObjectNotFoundErr) {
+				// deleted in the meantime
+				continue
+			}
+			return nil, 0, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		result[key] = data
+	}
+	return result, int64(len(keys)), nil
+}
+
+func (store *ObjectStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	name, err := store.objectName(collection, key)
+	if err != nil {
+		return false, err
+	}
+	err = store.bucket.Delete(ctx, name)
+	if err != nil {
+		if errors.Is(err, ObjectNotFoundErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	return true, nil
+}
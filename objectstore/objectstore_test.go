@@ -0,0 +1,136 @@
+package objectstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/objectstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+// objects is the in memory content of the fake object storage servers
+type objects struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func newObjects() *objects {
+	return &objects{data: map[string][]byte{}}
+}
+
+func (o *objects) names() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	names := []string{}
+	for name := range o.data {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestOpenBucket(t *testing.T) {
+	tcs := []struct {
+		name string
+		url  string
+		err  string
+	}{
+		{name: "unknown scheme", url: "ftp://bucket", err: `no driver registered for scheme "ftp"`},
+		{name: "gcs without bucket", url: "gs:///path", err: "bucket name cannot be empty"},
+		{name: "s3 without bucket", url: "s3:///path", err: "bucket name cannot be empty"},
+		{name: "azure without account", url: "azblob://container", err: "either the account or the endpoint parameter is required"},
+		{name: "gcs", url: "gs://bucket/prefix"},
+		{name: "azure", url: "azblob://container?account=myaccount"},
+		{name: "s3", url: "s3://bucket/prefix?region=eu-west-1"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := objectstore.OpenBucket(context.Background(), tc.url, nil)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q, got nil", tc.err)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("expected error %q, got %q", tc.err, err.Error())
+			}
+		})
+	}
+}
+
+// testObjectStore runs the JsonStorer operations on a store backed by a fake server and checks the object names
+func testObjectStore(t *testing.T, store *objectstore.ObjectStore, objs *objects) {
+	ctx := context.Background()
+
+	t.Run("set and get", func(t *testing.T) {
+		value := json.RawMessage(`{"item": "my value"}`)
+		err := store.Set(ctx, "col1", "item/1", value)
+		if err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+
+		var got json.RawMessage
+		err = store.Get(ctx, "col1", "item/1", &got)
+		if err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, value); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+
+		err = store.Get(ctx, "col1", "missing", &got)
+		if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got: %v", err)
+		}
+		if diff := cmp.Diff(objs.names(), []string{"data/col1/item%2F1.json"}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+			if err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		items, total, err := store.List(ctx, "col2", 2, 2)
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 3 {
+			t.Errorf("expected total 3, got %d", total)
+		}
+		want := map[string]json.RawMessage{
+			"item3": json.RawMessage(`{"item":"item3"}`),
+		}
+		if diff := cmp.Diff(items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleted, err := store.Delete(ctx, "col1", "item/1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if !deleted {
+			t.Errorf("expect Delete to affect one entry, but got false")
+		}
+		deleted, err = store.Delete(ctx, "col1", "item/1")
+		if err != nil {
+			t.Fatalf("action: Delete,  returned an error: %v", err)
+		}
+		if deleted {
+			t.Errorf("expect Delete to NOT affect any entry, but got true")
+		}
+	})
+}
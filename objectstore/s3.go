@@ -0,0 +1,192 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// DefaultS3Region is the region of the s3 buckets opened without the "region" query parameter
+const DefaultS3Region = "us-east-1"
+
+func init() {
+	RegisterDriver("s3", openS3)
+}
+
+// openS3 opens s3://<bucket>?region=<region>, the requests go to https://<bucket>.s3.<region>.amazonaws.com unless
+// the "endpoint" query parameter is set, e.g. to use MinIO, in which case the bucket is addressed in the path.
+// The requests are signed with the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables if set, otherwise the http client needs to authenticate the requests.
+func openS3(_ context.Context, u *url.URL, client *http.Client) (Bucket, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("bucket name cannot be empty")
+	}
+	q := u.Query()
+	region := q.Get("region")
+	if region == "" {
+		region = DefaultS3Region
+	}
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.Host, region)
+	} else {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(u.Host)
+	}
+	b := &S3Bucket{client: client, endpoint: endpoint, region: region}
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		b.credentials = &aws.Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+	}
+	return b, nil
+}
+
+// S3Bucket is a Bucket on Amazon S3, or a compatible service, using the s3 rest api
+type S3Bucket struct {
+	client *http.Client
+	// endpoint is the url of the bucket, the object names are appended to it
+	endpoint    string
+	region      string
+	credentials *aws.Credentials
+}
+
+func (b *S3Bucket) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := b.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.credentials != nil {
+		sum := sha256.Sum256(body)
+		payloadHash := hex.EncodeToString(sum[:])
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		err = v4.NewSigner().SignHTTP(ctx, *b.credentials, req, payloadHash, "s3", b.region, time.Now(),
+			func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign request: %v", err)
+		}
+	}
+	return b.client.Do(req)
+}
+
+func (b *S3Bucket) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, blobPath(name), nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseErr(resp)
+	}
+	return nil
+}
+
+func (b *S3Bucket) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, blobPath(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ObjectNotFoundErr
+	}
+	return nil, responseErr(resp)
+}
+
+// Delete checks that the object exists first, s3 reports the deletion of missing objects as successful
+func (b *S3Bucket) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodHead, blobPath(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ObjectNotFoundErr
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	resp, err = b.do(ctx, http.MethodDelete, blobPath(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ObjectNotFoundErr
+	}
+	return responseErr(resp)
+}
+
+type s3List struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b *S3Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	names := []string{}
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := b.do(ctx, http.MethodGet, "/", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = responseErr(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		list := s3List{}
+		err = xml.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode response: %v", err)
+		}
+		for _, item := range list.Contents {
+			names = append(names, item.Key)
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			break
+		}
+		token = list.NextContinuationToken
+	}
+	sort.Strings(names)
+	return names, nil
+}
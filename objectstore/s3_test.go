@@ -0,0 +1,130 @@
+package objectstore_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore/objectstore"
+)
+
+type s3Object struct {
+	Key string `xml:"Key"`
+}
+
+type s3ListResult struct {
+	XMLName               xml.Name   `xml:"ListBucketResult"`
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken,omitempty"`
+}
+
+// s3PageSize is the page size of the fake server, small to exercise the continuation tokens
+const s3PageSize = 2
+
+// s3Server fakes the subset of the s3 rest api used by the driver, with path style addressing
+func s3Server(objs *objects) http.Handler {
+	const bucketPath = "/bucket/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objs.mutex.Lock()
+		defer objs.mutex.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+			r.Header.Get("X-Amz-Content-Sha256") == "" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == bucketPath && r.URL.Query().Get("list-type") == "2" {
+			names := []string{}
+			for name := range objs.data {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("continuation-token") {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			list := s3ListResult{}
+			if len(names) > s3PageSize {
+				names = names[:s3PageSize]
+				list.IsTruncated = true
+				list.NextContinuationToken = names[len(names)-1]
+			}
+			for _, name := range names {
+				list.Contents = append(list.Contents, s3Object{Key: name})
+			}
+			_ = xml.NewEncoder(w).Encode(list)
+			return
+		}
+		name, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), bucketPath))
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objs.data[name] = body
+		case http.MethodGet, http.MethodHead:
+			data, ok := objs.data[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(data)
+			}
+		case http.MethodDelete:
+			// s3 does not report missing objects on delete
+			delete(objs.data, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+}
+
+func TestS3Bucket(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	objs := newObjects()
+	srv := httptest.NewServer(s3Server(objs))
+	defer srv.Close()
+
+	store, err := objectstore.Open(context.Background(), "s3://bucket/data?region=eu-west-1&endpoint="+url.QueryEscape(srv.URL), srv.Client())
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	testObjectStore(t, store, objs)
+
+	t.Run("list with continuation", func(t *testing.T) {
+		ctx := context.Background()
+		bucket, err := objectstore.OpenBucket(ctx, "s3://bucket?endpoint="+url.QueryEscape(srv.URL), srv.Client())
+		if err != nil {
+			t.Fatalf("OpenBucket returned an error: %v", err)
+		}
+		want := []string{"pages/1", "pages/2", "pages/3", "pages/4", "pages/5"}
+		for _, name := range want {
+			if err = bucket.Put(ctx, name, []byte(`{}`)); err != nil {
+				t.Fatalf("action: Put,  returned an error: %v", err)
+			}
+		}
+		got, err := bucket.List(ctx, "pages/")
+		if err != nil {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("delete missing object", func(t *testing.T) {
+		bucket, err := objectstore.OpenBucket(context.Background(), "s3://bucket?endpoint="+url.QueryEscape(srv.URL), srv.Client())
+		if err != nil {
+			t.Fatalf("OpenBucket returned an error: %v", err)
+		}
+		err = bucket.Delete(context.Background(), "missing")
+		if err != objectstore.ObjectNotFoundErr {
+			t.Errorf("expected ObjectNotFoundErr, got %v", err)
+		}
+	})
+}
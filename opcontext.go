@@ -0,0 +1,69 @@
+package jsonstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// OperationContext carries the metadata of who and what triggered a store operation,
+// it travels in the context.Context passed to the store so that cross-cutting features
+// (audit, webhooks, metrics, ownership) all read the metadata from the same place.
+type OperationContext struct {
+	// Actor identifies the user or service performing the operation
+	Actor string
+	// RequestID correlates all the operations triggered by the same request
+	RequestID string
+	// Origin is the entry point of the operation, e.g. OriginHttp
+	Origin string
+	// Tenant the operation belongs to, if any
+	Tenant string
+}
+
+const OriginHttp = "http"
+
+// RequestIdHeader is read to populate the OperationContext RequestID and set on the response
+const RequestIdHeader = "X-Request-Id"
+
+type opCtxKey struct{}
+
+// WithOperationContext returns a copy of ctx carrying the operation context
+func WithOperationContext(ctx context.Context, oc OperationContext) context.Context {
+	return context.WithValue(ctx, opCtxKey{}, oc)
+}
+
+// GetOperationContext returns the operation context carried by ctx, the second value reports
+// whether ctx carried one at all.
+func GetOperationContext(ctx context.Context) (OperationContext, bool) {
+	oc, ok := ctx.Value(opCtxKey{}).(OperationContext)
+	return oc, ok
+}
+
+// OperationContextHandler is an http middleware that populates the OperationContext of every request
+// before calling next. The RequestID is taken from the X-Request-Id header or generated, and the Origin
+// is set to OriginHttp; resolve, if not nil, is called to complete the rest, e.g. the Actor out of
+// the authenticated user or the Tenant out of the host name.
+func OperationContextHandler(next http.Handler, resolve func(r *http.Request, oc *OperationContext)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oc := OperationContext{
+			RequestID: r.Header.Get(RequestIdHeader),
+			Origin:    OriginHttp,
+		}
+		if oc.RequestID == "" {
+			oc.RequestID = newRequestId()
+		}
+		if resolve != nil {
+			resolve(r, &oc)
+		}
+		w.Header().Set(RequestIdHeader, oc.RequestID)
+		next.ServeHTTP(w, r.WithContext(WithOperationContext(r.Context(), oc)))
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	// rand.Read only fails if the os random source is unavailable, an empty id is preferable to failing the request
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
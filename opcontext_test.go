@@ -0,0 +1,72 @@
+package jsonstore_test
+
+import (
+	"context"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOperationContext(t *testing.T) {
+	t.Run("empty context", func(t *testing.T) {
+		_, ok := jsonstore.GetOperationContext(context.Background())
+		if ok {
+			t.Errorf("expected no operation context")
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		want := jsonstore.OperationContext{Actor: "alice", RequestID: "1", Origin: "cli", Tenant: "acme"}
+		got, ok := jsonstore.GetOperationContext(jsonstore.WithOperationContext(context.Background(), want))
+		if !ok {
+			t.Fatalf("expected an operation context")
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
+
+func TestOperationContextHandler(t *testing.T) {
+	var got jsonstore.OperationContext
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = jsonstore.GetOperationContext(r.Context())
+	})
+	handler := jsonstore.OperationContextHandler(next, func(r *http.Request, oc *jsonstore.OperationContext) {
+		oc.Actor = r.Header.Get("X-User")
+		oc.Tenant = "acme"
+	})
+
+	t.Run("use request id header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+		req.Header.Set(jsonstore.RequestIdHeader, "req-1")
+		req.Header.Set("X-User", "alice")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		want := jsonstore.OperationContext{Actor: "alice", RequestID: "req-1", Origin: jsonstore.OriginHttp, Tenant: "acme"}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+		if rec.Header().Get(jsonstore.RequestIdHeader) != "req-1" {
+			t.Errorf("expected the request id to be set on the response")
+		}
+	})
+
+	t.Run("generate request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/key1", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if got.RequestID == "" {
+			t.Errorf("expected a generated request id")
+		}
+		if rec.Header().Get(jsonstore.RequestIdHeader) != got.RequestID {
+			t.Errorf("expected the generated request id to be set on the response")
+		}
+	})
+}
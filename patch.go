@@ -0,0 +1,465 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchType is the format of a patch, its value is the media type of the format
+type PatchType string
+
+const (
+	// MergePatch is a JSON Merge Patch (RFC 7386): the object fields of the patch replace the ones of the
+	// document, recursively, and null fields are removed
+	MergePatch PatchType = "application/merge-patch+json"
+	// JsonPatch is a JSON Patch (RFC 6902): a list of add, remove, replace, move, copy and test operations
+	JsonPatch PatchType = "application/json-patch+json"
+)
+
+// InvalidPatchErr is returned for patches that are malformed or of an unknown type
+var InvalidPatchErr = errors.New("invalid patch")
+
+// PatchConflictErr is returned when a valid patch cannot be applied to the document, e.g. a JSON Patch operation
+// on a path that does not exist or a failed test operation
+var PatchConflictErr = errors.New("patch cannot be applied")
+
+// maxPatchRetries is the amount of times PatchDocument retries on a version conflict with a VersionedStorer
+const maxPatchRetries = 10
+
+// Patcher is implemented by stores able to apply a patch to a document atomically
+type Patcher interface {
+	// Patch applies the patch to the document and returns the patched document,
+	// it returns ItemNotFoundErr if the document does not exist
+	Patch(ctx context.Context, collection, key string, patch json.RawMessage, patchType PatchType) (json.RawMessage, error)
+}
+
+// PatchDocument applies a patch to a document and returns the patched document. Stores implementing Patcher
+// apply it atomically, on a VersionedStorer the document is written only if it did not change since it was read
+// (retrying on conflicts), on the other stores a concurrent write between the read and the write of the
+// document is lost.
+func PatchDocument(ctx context.Context, store JsonStorer, collection, key string, patch json.RawMessage, patchType PatchType) (json.RawMessage, error) {
+	return patchDocument(ctx, store, collection, key, patch, patchType, nil)
+}
+
+// patchDocument is PatchDocument calling check, if not nil, with the patched document before writing it; the
+// document is not written if check returns an error. A Patcher can't be checked, it is used as a plain store then.
+func patchDocument(ctx context.Context, store JsonStorer, collection, key string, patch json.RawMessage, patchType PatchType,
+	check func(doc json.RawMessage) error) (json.RawMessage, error) {
+	if p, ok := store.(Patcher); ok && check == nil {
+		return p.Patch(ctx, collection, key, patch, patchType)
+	}
+	if vs, ok := store.(VersionedStorer); ok {
+		for i := 0; ; i++ {
+			var doc json.RawMessage
+			version, err := vs.GetWithVersion(ctx, collection, key, &doc)
+			if err != nil {
+				return nil, err
+			}
+			patched, err := ApplyPatch(doc, patch, patchType)
+			if err != nil {
+				return nil, err
+			}
+			if check != nil {
+				if err = check(patched); err != nil {
+					return nil, err
+				}
+			}
+			_, err = vs.SetIfVersion(ctx, collection, key, patched, version)
+			if errors.Is(err, VersionConflictErr) && i < maxPatchRetries {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return patched, nil
+		}
+	}
+
+	var doc json.RawMessage
+	if err := store.Get(ctx, collection, key, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		// stores not returning ItemNotFoundErr for missing keys
+		return nil, ItemNotFoundErr
+	}
+	patched, err := ApplyPatch(doc, patch, patchType)
+	if err != nil {
+		return nil, err
+	}
+	if check != nil {
+		if err = check(patched); err != nil {
+			return nil, err
+		}
+	}
+	if err = store.Set(ctx, collection, key, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// ApplyPatch applies a patch to a json document and returns the patched document
+func ApplyPatch(doc, patch json.RawMessage, patchType PatchType) (json.RawMessage, error) {
+	target, err := decodeJsonNumbers(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode document: %v", err)
+	}
+	p, err := decodeJsonNumbers(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidPatchErr, err)
+	}
+	switch patchType {
+	case MergePatch:
+		target = mergePatch(target, p)
+	case JsonPatch:
+		target, err = applyJsonPatch(target, p)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported patch type %q", InvalidPatchErr, patchType)
+	}
+	return json.Marshal(target)
+}
+
+// mergePatch applies a JSON Merge Patch, see RFC 7386
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// jsonPatchOp is an operation of a JSON Patch
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from"`
+	Value any    `json:"value"`
+	// hasValue distinguishes a null value from a missing one
+	hasValue bool
+}
+
+// applyJsonPatch applies a JSON Patch, see RFC 6902
+func applyJsonPatch(doc, patch any) (any, error) {
+	rawOps, ok := patch.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: a json patch is an array of operations", InvalidPatchErr)
+	}
+	for i, rawOp := range rawOps {
+		op, err := parseJsonPatchOp(rawOp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", InvalidPatchErr, i, err)
+		}
+		doc, err = op.apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d (%s %s): %v", PatchConflictErr, i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func parseJsonPatchOp(raw any) (jsonPatchOp, error) {
+	fields, ok := raw.(map[string]any)
+	if !ok {
+		return jsonPatchOp{}, fmt.Errorf("an operation is an object")
+	}
+	op := jsonPatchOp{}
+	var err error
+	if op.Op, err = stringField(fields, "op"); err != nil {
+		return op, err
+	}
+	if op.Path, err = stringField(fields, "path"); err != nil {
+		return op, err
+	}
+	op.Value, op.hasValue = fields["value"]
+	switch op.Op {
+	case "add", "replace", "test":
+		if !op.hasValue {
+			return op, fmt.Errorf("missing value")
+		}
+	case "move", "copy":
+		if op.From, err = stringField(fields, "from"); err != nil {
+			return op, err
+		}
+	case "remove":
+	default:
+		return op, fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return op, nil
+}
+
+func stringField(fields map[string]any, name string) (string, error) {
+	v, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("missing %s", name)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", name)
+	}
+	return s, nil
+}
+
+func (op jsonPatchOp) apply(doc any) (any, error) {
+	switch op.Op {
+	case "add":
+		return pointerAdd(doc, op.Path, op.Value)
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+	case "replace":
+		doc, _, err := pointerRemove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, op.Value)
+	case "move":
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move a value into one of its children")
+		}
+		doc, value, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, value)
+	case "copy":
+		value, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		// the copy must not share maps or slices with the source
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		value, err = decodeJsonNumbers(data)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, value)
+	default: // test
+		value, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonValuesEqual(value, op.Value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+}
+
+// jsonValuesEqual compares two decoded json values, numbers are compared by value
+func jsonValuesEqual(a, b any) bool {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for k, v := range va {
+			w, ok := vb[k]
+			if !ok || !jsonValuesEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		vb, ok := b.([]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !jsonValuesEqual(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		vb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := va.Float64()
+		fb, errB := vb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	default:
+		return a == b
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the index of an array element, "-" is the end of the array and only allowed if end is true
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := length - 1
+	if end {
+		limit = length
+	}
+	if i > limit {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func pointerGet(doc any, pointer string) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path %s does not exist", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at pointer and returns the document, which is replaced if pointer is the root
+func pointerAdd(doc any, pointer string, value any) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(doc, pointer[:len(pointer)-len(escapeToken(tokens[len(tokens)-1]))-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return setParent(doc, tokens[:len(tokens)-1], node)
+	default:
+		return nil, fmt.Errorf("path %s does not exist", pointer)
+	}
+}
+
+// pointerRemove removes the value at pointer and returns the document and the removed value
+func pointerRemove(doc any, pointer string) (any, any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := pointerGet(doc, pointer[:len(pointer)-len(escapeToken(tokens[len(tokens)-1]))-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path %s does not exist", pointer)
+		}
+		delete(node, last)
+		return doc, value, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = setParent(doc, tokens[:len(tokens)-1], node)
+		return doc, value, err
+	default:
+		return nil, nil, fmt.Errorf("path %s does not exist", pointer)
+	}
+}
+
+// setParent replaces the array at the path of tokens, slices cannot be modified in place when their length changes
+func setParent(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	pointer := ""
+	for _, token := range tokens[:len(tokens)-1] {
+		pointer += "/" + escapeToken(token)
+	}
+	grandParent, err := pointerGet(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := grandParent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+	return doc, nil
+}
+
+func escapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,168 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyPatch(t *testing.T) {
+	doc := json.RawMessage(`{"name":"bob","age":30,"address":{"city":"Bern","zip":"3000"},"tags":["a","b"]}`)
+
+	tcs := []struct {
+		name      string
+		patchType jsonstore.PatchType
+		patch     string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "merge patch",
+			patchType: jsonstore.MergePatch,
+			patch:     `{"age":31,"address":{"zip":null},"tags":["c"]}`,
+			want:      `{"address":{"city":"Bern"},"age":31,"name":"bob","tags":["c"]}`,
+		},
+		{
+			name:      "merge patch replacing the document",
+			patchType: jsonstore.MergePatch,
+			patch:     `["x"]`,
+			want:      `["x"]`,
+		},
+		{
+			name:      "json patch",
+			patchType: jsonstore.JsonPatch,
+			patch: `[
+				{"op":"test","path":"/name","value":"bob"},
+				{"op":"replace","path":"/age","value":31.0},
+				{"op":"add","path":"/tags/1","value":"x"},
+				{"op":"remove","path":"/tags/0"},
+				{"op":"copy","from":"/address/city","path":"/city"},
+				{"op":"move","from":"/address/zip","path":"/zip"},
+				{"op":"add","path":"/tags/-","value":"z"}
+			]`,
+			want: `{"address":{"city":"Bern"},"age":31.0,"city":"Bern","name":"bob","tags":["x","b","z"],"zip":"3000"}`,
+		},
+		{
+			name:      "json patch escaped pointer",
+			patchType: jsonstore.JsonPatch,
+			patch:     `[{"op":"add","path":"/a~1b~0c","value":null}]`,
+			want:      `{"a/b~c":null,"address":{"city":"Bern","zip":"3000"},"age":30,"name":"bob","tags":["a","b"]}`,
+		},
+		{
+			name:      "json patch failed test",
+			patchType: jsonstore.JsonPatch,
+			patch:     `[{"op":"test","path":"/name","value":"alice"}]`,
+			wantErr:   jsonstore.PatchConflictErr,
+		},
+		{
+			name:      "json patch missing path",
+			patchType: jsonstore.JsonPatch,
+			patch:     `[{"op":"remove","path":"/missing"}]`,
+			wantErr:   jsonstore.PatchConflictErr,
+		},
+		{
+			name:      "json patch index out of range",
+			patchType: jsonstore.JsonPatch,
+			patch:     `[{"op":"add","path":"/tags/5","value":"x"}]`,
+			wantErr:   jsonstore.PatchConflictErr,
+		},
+		{
+			name:      "json patch unknown operation",
+			patchType: jsonstore.JsonPatch,
+			patch:     `[{"op":"rename","path":"/name"}]`,
+			wantErr:   jsonstore.InvalidPatchErr,
+		},
+		{
+			name:      "json patch not an array",
+			patchType: jsonstore.JsonPatch,
+			patch:     `{"op":"remove","path":"/name"}`,
+			wantErr:   jsonstore.InvalidPatchErr,
+		},
+		{
+			name:      "malformed patch",
+			patchType: jsonstore.MergePatch,
+			patch:     `{"age":`,
+			wantErr:   jsonstore.InvalidPatchErr,
+		},
+		{
+			name:      "unknown patch type",
+			patchType: "application/xml",
+			patch:     `{}`,
+			wantErr:   jsonstore.InvalidPatchErr,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := jsonstore.ApplyPatch(doc, json.RawMessage(tc.patch), tc.patchType)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("expected error %v, got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("action: ApplyPatch,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), tc.want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPatchDocument(t *testing.T) {
+	ctx := context.Background()
+
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) jsonstore.JsonStorer
+	}{
+		{name: "FileStore", newStore: func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "store.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		}},
+		{name: "MemStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return jsonstore.NewMemStore() }},
+		{name: "DbStore", newStore: func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) }},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.newStore(t)
+			if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"a":1,"b":2}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			got, err := jsonstore.PatchDocument(ctx, store, "col1", "item1", json.RawMessage(`{"b":null,"c":3}`), jsonstore.MergePatch)
+			if err != nil {
+				t.Fatalf("action: PatchDocument,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), `{"a":1,"c":3}`); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			var stored json.RawMessage
+			if err = store.Get(ctx, "col1", "item1", &stored); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			var gotStored, want any
+			_ = json.Unmarshal(stored, &gotStored)
+			_ = json.Unmarshal(got, &want)
+			if diff := cmp.Diff(gotStored, want); diff != "" {
+				t.Errorf("unexpected stored value (-got +want)\n%s", diff)
+			}
+
+			_, err = jsonstore.PatchDocument(ctx, store, "col1", "missing", json.RawMessage(`{}`), jsonstore.MergePatch)
+			if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+				t.Errorf("expected ItemNotFoundErr, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// make sure the postgres store fulfills the Aggregator interface
+var _ jsonstore.Aggregator = &PgStore{}
+
+// aggregation returns the column computing an aggregation, ignoring the values of types not aggregated by the
+// operation; min and max are returned as jsonb
+func (w *whereBuilder) aggregation(a jsonstore.Aggregation) string {
+	if a.Path == "" {
+		return "COUNT(*)"
+	}
+	field := fmt.Sprintf(`("value" #> %s::text[])`, w.arg(pathArg(a.Path)))
+	typeOf := fmt.Sprintf("jsonb_typeof(%s)", field)
+	number := fmt.Sprintf("CASE WHEN %s = 'number' THEN (%s)::numeric END", typeOf, field)
+	str := fmt.Sprintf(`CASE WHEN %s = 'string' THEN %s #>> '{}' END COLLATE "C"`, typeOf, field)
+	switch a.Op {
+	case jsonstore.AggCount:
+		return fmt.Sprintf("COUNT(CASE WHEN %s <> 'null' THEN 1 END)", typeOf)
+	case jsonstore.AggSum:
+		return fmt.Sprintf("COALESCE(SUM(%s), 0)::float8", number)
+	case jsonstore.AggMin:
+		// numbers are lower than strings, as in jsonstore.Query
+		return fmt.Sprintf("COALESCE(to_jsonb(MIN(%s)), to_jsonb(MIN(%s)))", number, str)
+	}
+	return fmt.Sprintf("COALESCE(to_jsonb(MAX(%s)), to_jsonb(MAX(%s)))", str, number)
+}
+
+// Aggregate computes the aggregations in Postgres; the groups are the jsonb values of the GroupBy paths, so equal
+// numbers are grouped whatever their json text, e.g. 1 and 1.0
+func (store *PgStore) Aggregate(ctx context.Context, collection string, spec jsonstore.AggregateSpec) ([]jsonstore.AggregateGroup, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := whereBuilder{}
+	w.conds = append(w.conds, fmt.Sprintf(`"collection" = %s`, w.arg(collection)))
+	for _, f := range spec.Filters {
+		if err := w.filter(f); err != nil {
+			return nil, err
+		}
+	}
+	where := strings.Join(w.conds, " AND ")
+
+	var columns, groupBy []string
+	for i, path := range spec.GroupBy {
+		// missing fields are grouped with the null ones; the groups refer to the position of the columns, the
+		// placeholders of the same path would differ between the select and the group by
+		columns = append(columns, fmt.Sprintf(`COALESCE("value" #> %s::text[], 'null'::jsonb)`, w.arg(pathArg(path))))
+		groupBy = append(groupBy, strconv.Itoa(i+1))
+	}
+	for _, a := range spec.Aggregations {
+		columns = append(columns, w.aggregation(a))
+	}
+	stmt := fmt.Sprintf(`SELECT %s FROM "%s" WHERE %s`, strings.Join(columns, ", "), store.table, where)
+	if len(groupBy) > 0 {
+		stmt += " GROUP BY " + strings.Join(groupBy, ", ")
+	}
+	rows, err := store.db.QueryContext(ctx, stmt, w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+	}
+	defer rows.Close()
+
+	groups := []jsonstore.AggregateGroup{}
+	for rows.Next() {
+		keys := make([]string, len(spec.GroupBy))
+		counts := make([]int64, len(spec.Aggregations))
+		sums := make([]float64, len(spec.Aggregations))
+		values := make([]sql.NullString, len(spec.Aggregations))
+		var dest []any
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		for i, a := range spec.Aggregations {
+			switch a.Op {
+			case jsonstore.AggCount:
+				dest = append(dest, &counts[i])
+			case jsonstore.AggSum:
+				dest = append(dest, &sums[i])
+			default:
+				dest = append(dest, &values[i])
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+		}
+
+		group := jsonstore.AggregateGroup{Values: map[string]any{}}
+		if len(spec.GroupBy) > 0 {
+			group.Key = map[string]any{}
+			for i, path := range spec.GroupBy {
+				var v any
+				if err = json.Unmarshal([]byte(keys[i]), &v); err != nil {
+					return nil, fmt.Errorf("unable to decode group key: %v", err)
+				}
+				group.Key[path] = v
+			}
+		}
+		for i, a := range spec.Aggregations {
+			name := a.ValueName()
+			switch a.Op {
+			case jsonstore.AggCount:
+				group.Values[name] = counts[i]
+			case jsonstore.AggSum:
+				group.Values[name] = sums[i]
+			default:
+				var v any
+				if values[i].Valid {
+					if err = json.Unmarshal([]byte(values[i].String), &v); err != nil {
+						return nil, fmt.Errorf("unable to decode aggregated value: %v", err)
+					}
+				}
+				group.Values[name] = v
+			}
+		}
+		groups = append(groups, group)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate collection %s: %v", collection, err)
+	}
+	jsonstore.SortGroups(groups, spec.GroupBy)
+	return groups, nil
+}
@@ -0,0 +1,252 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/sqlstore"
+)
+
+// DefaultTable is the table used if none is provided, it differs from the one of the DbStore and SqlStore
+// since those declare the value column as json instead of jsonb.
+const DefaultTable = "jsonb_documents"
+
+// PgStore is a Postgres specialized store: the value column is declared as jsonb and indexed with a GIN index,
+// queries are pushed down to SQL instead of being evaluated client-side.
+// The plain JsonStorer operations are the ones of the sqlstore.SqlStore.
+type PgStore struct {
+	*sqlstore.SqlStore
+	db    *sql.DB
+	table string
+}
+
+// make sure the postgres store fulfills the JsonStorer and Querier interfaces
+var _ jsonstore.JsonStorer = &PgStore{}
+var _ jsonstore.Querier = &PgStore{}
+
+var tableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// NewPgStore returns a store using the table, if empty DefaultTable is used; the table and its indexes are
+// created if they do not exist. The db needs to be opened with a postgres driver, e.g. github.com/jackc/pgx/v5/stdlib.
+func NewPgStore(db *sql.DB, table string) (*PgStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db cannot be nil")
+	}
+	if table == "" {
+		table = DefaultTable
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" ("id" text NOT NULL, "collection" text NOT NULL, "value" jsonb NOT NULL, PRIMARY KEY ("collection", "id"))`, table),
+		// jsonb_path_ops indexes support the containment operator used for equality filters
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_value_idx" ON "%s" USING GIN ("value" jsonb_path_ops)`, table, table),
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create table: %v", err)
+		}
+	}
+
+	s, err := sqlstore.NewSqlStore(db, sqlstore.Postgres, table)
+	if err != nil {
+		return nil, err
+	}
+	store := PgStore{
+		SqlStore: s,
+		db:       db,
+		table:    table,
+	}
+	return &store, nil
+}
+
+// whereBuilder accumulates the conditions and arguments of a WHERE clause
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// arg adds an argument returning its placeholder
+func (w *whereBuilder) arg(v any) string {
+	w.args = append(w.args, v)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// pathArg returns a text[] literal out of a dot separated path, to be used with the #> operator
+func pathArg(path string) string {
+	keys := jsonstore.SplitPath(path)
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		k = strings.ReplaceAll(k, `\`, `\\`)
+		k = strings.ReplaceAll(k, `"`, `\"`)
+		quoted[i] = `"` + k + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+var sqlOps = map[jsonstore.FilterOp]string{
+	jsonstore.OpGt:  ">",
+	jsonstore.OpGte: ">=",
+	jsonstore.OpLt:  "<",
+	jsonstore.OpLte: "<=",
+}
+
+func (w *whereBuilder) filter(f jsonstore.Filter) error {
+	value, err := json.Marshal(f.Value)
+	if err != nil {
+		return fmt.Errorf("%w: filter value: %v", jsonstore.InvalidQueryErr, err)
+	}
+	field := fmt.Sprintf(`"value" #> %s::text[]`, w.arg(pathArg(f.Path)))
+	v := w.arg(string(value)) + "::jsonb"
+
+	switch f.Op {
+	case jsonstore.OpEq:
+		cond := fmt.Sprintf("%s = %s", field, v)
+		var decoded any
+		_ = json.Unmarshal(value, &decoded)
+		if _, isObj := decoded.(map[string]any); !isObj {
+			if _, isArr := decoded.([]any); !isArr {
+				// for scalars containment is exact and can use the GIN index, the equality stays as a guard
+				contained := map[string]any{}
+				jsonstore.SetPath(contained, jsonstore.SplitPath(f.Path), decoded)
+				doc, _ := json.Marshal(contained)
+				cond = fmt.Sprintf(`"value" @> %s::jsonb AND %s`, w.arg(string(doc)), cond)
+			}
+		}
+		w.conds = append(w.conds, cond)
+	case jsonstore.OpNe:
+		w.conds = append(w.conds, fmt.Sprintf("(%s IS NULL OR %s <> %s)", field, field, v))
+	case jsonstore.OpGt, jsonstore.OpGte, jsonstore.OpLt, jsonstore.OpLte:
+		// jsonb orders values of different types, restrict the comparison to scalars of the same type
+		w.conds = append(w.conds, fmt.Sprintf("jsonb_typeof(%s) = jsonb_typeof(%s) AND jsonb_typeof(%s) NOT IN ('object', 'array') AND %s %s %s",
+			field, v, field, field, sqlOps[f.Op], v))
+	default:
+		return fmt.Errorf("%w: unknown filter operation %q", jsonstore.InvalidQueryErr, f.Op)
+	}
+	return nil
+}
+
+// sortExprs returns the ORDER BY expressions of a sort field, following the order documented in jsonstore.Query:
+// missing and null values first, then booleans and numbers, then strings and the json text of arrays and objects.
+func (w *whereBuilder) sortExprs(f jsonstore.SortField) []string {
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+	field := fmt.Sprintf(`("value" #> %s::text[])`, w.arg(pathArg(f.Path)))
+	typeOf := fmt.Sprintf("jsonb_typeof(%s)", field)
+	return []string{
+		fmt.Sprintf("CASE WHEN %s IS NULL OR %s = 'null' THEN 0 WHEN %s IN ('boolean', 'number') THEN 1 ELSE 2 END %s",
+			field, typeOf, typeOf, dir),
+		fmt.Sprintf("CASE %s WHEN 'number' THEN (%s)::numeric WHEN 'boolean' THEN CASE WHEN %s = 'true'::jsonb THEN 1 ELSE 0 END END %s",
+			typeOf, field, field, dir),
+		fmt.Sprintf(`CASE %s WHEN 'string' THEN %s #>> '{}' WHEN 'array' THEN %s::text WHEN 'object' THEN %s::text END COLLATE "C" %s`,
+			typeOf, field, field, field, dir),
+	}
+}
+
+// Query runs the filters, search, sorting and projection of the query in Postgres
+func (store *PgStore) Query(ctx context.Context, collection string, q jsonstore.Query) (jsonstore.Page, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if err := q.Validate(); err != nil {
+		return jsonstore.Page{}, err
+	}
+	opts, err := q.ListOptions.Normalize()
+	if err != nil {
+		return jsonstore.Page{}, err
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	w := whereBuilder{}
+	w.conds = append(w.conds, fmt.Sprintf(`"collection" = %s`, w.arg(collection)))
+	if opts.Prefix != "" {
+		w.conds = append(w.conds, fmt.Sprintf(`"id" LIKE %s`, w.arg(likeEscaper.Replace(opts.Prefix)+"%")))
+	}
+	for _, f := range q.Filters {
+		if err = w.filter(f); err != nil {
+			return jsonstore.Page{}, err
+		}
+	}
+	if q.Search != "" {
+		w.conds = append(w.conds, fmt.Sprintf(`"value"::text ILIKE %s`, w.arg("%"+likeEscaper.Replace(q.Search)+"%")))
+	}
+	where := strings.Join(w.conds, " AND ")
+
+	var count int64
+	err = store.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE %s`, store.table, where), w.args...).Scan(&count)
+	if err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+
+	// only the projected fields are transferred, the documents are assembled afterward
+	columns := []string{`"value"`}
+	if len(q.Fields) > 0 {
+		columns = columns[:0]
+		for _, field := range q.Fields {
+			columns = append(columns, fmt.Sprintf(`"value" #> %s::text[]`, w.arg(pathArg(field))))
+		}
+	}
+	order := []string{}
+	for _, field := range q.Sort {
+		order = append(order, w.sortExprs(field)...)
+	}
+	order = append(order, `"id" ASC`)
+	stmt := fmt.Sprintf(`SELECT "id", %s FROM "%s" WHERE %s ORDER BY %s LIMIT %s OFFSET %s`,
+		strings.Join(columns, ", "), store.table, where, strings.Join(order, ", "), w.arg(opts.Limit), w.arg(offset))
+	rows, err := store.db.QueryContext(ctx, stmt, w.args...)
+	if err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	defer rows.Close()
+
+	result := map[string]json.RawMessage{}
+	var keys []string
+	for rows.Next() {
+		var key string
+		values := make([]sql.NullString, len(columns))
+		dest := []any{&key}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		keys = append(keys, key)
+		if len(q.Fields) == 0 {
+			result[key] = json.RawMessage(values[0].String)
+			continue
+		}
+		doc := map[string]any{}
+		for i, field := range q.Fields {
+			if !values[i].Valid {
+				continue
+			}
+			jsonstore.SetPath(doc, jsonstore.SplitPath(field), json.RawMessage(values[i].String))
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return jsonstore.Page{}, fmt.Errorf("failed to project document: %v", err)
+		}
+		result[key] = b
+	}
+	if err = rows.Err(); err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	p := jsonstore.NewPage(result, count, opts.Page, opts.Limit)
+	if len(q.Sort) > 0 {
+		p.Keys = keys
+	}
+	return p, nil
+}
@@ -0,0 +1,236 @@
+package pgstore_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/pgstore"
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func newPgStore(t *testing.T) *pgstore.PgStore {
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		t.Skip("skipping postgres tests, they depend on testcontainers")
+	}
+	ctx := context.Background()
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:13",
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "testuser",
+			"POSTGRES_PASSWORD": "password",
+			"POSTGRES_DB":       "testdb",
+		},
+		WaitingFor: wait.ForListeningPort("5432/tcp").WithStartupTimeout(60 * time.Second),
+	}
+	postgresContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start PostgreSQL container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := postgresContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate PostgreSQL container: %v", err)
+		}
+	})
+
+	host, err := postgresContainer.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get PostgreSQL container host: %v", err)
+	}
+	port, err := postgresContainer.MappedPort(ctx, "5432")
+	if err != nil {
+		t.Fatalf("failed to get PostgreSQL container port: %v", err)
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%s user=testuser dbname=testdb password=password sslmode=disable", host, port.Port())
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := pgstore.NewPgStore(db, "")
+	if err != nil {
+		t.Fatalf("NewPgStore returned an error: %v", err)
+	}
+	return store
+}
+
+func TestPgStoreQuery(t *testing.T) {
+	store := newPgStore(t)
+	ctx := context.Background()
+
+	docs := map[string]string{
+		"alice": `{"name":"Alice","age":31,"address":{"city":"Zurich"},"tags":["admin"]}`,
+		"bob":   `{"name":"Bob","age":25,"address":{"city":"Berlin"}}`,
+		"carol": `{"name":"Carol","age":"unknown","address":{"city":"Zurich"}}`,
+	}
+	for key, value := range docs {
+		if err := store.Set(ctx, "users", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	tcs := []struct {
+		name  string
+		query jsonstore.Query
+		want  []string
+	}{
+		{
+			name:  "filter nested field",
+			query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "address.city", Op: jsonstore.OpEq, Value: "Zurich"}}},
+			want:  []string{"alice", "carol"},
+		},
+		{
+			name:  "compare numbers, ignoring other types",
+			query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "age", Op: jsonstore.OpGte, Value: 30}}},
+			want:  []string{"alice"},
+		},
+		{
+			name:  "not equal matches missing fields",
+			query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "tags", Op: jsonstore.OpNe, Value: []string{"admin"}}}},
+			want:  []string{"bob", "carol"},
+		},
+		{
+			name:  "search",
+			query: jsonstore.Query{Search: "berlin"},
+			want:  []string{"bob"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			// the results must match the client-side evaluation of the query
+			page, err := jsonstore.RunQuery(ctx, store, "users", tc.query)
+			if err != nil {
+				t.Fatalf("RunQuery failed: %v", err)
+			}
+			got := []string{}
+			for key := range page.Items {
+				got = append(got, key)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+			if page.Total != int64(len(tc.want)) {
+				t.Errorf("expected total %d, got %d", len(tc.want), page.Total)
+			}
+		})
+	}
+
+	t.Run("projection", func(t *testing.T) {
+		q := jsonstore.Query{
+			Filters: []jsonstore.Filter{{Path: "name", Op: jsonstore.OpEq, Value: "Alice"}},
+			Fields:  []string{"name", "address.city", "missing"},
+		}
+		page, err := store.Query(ctx, "users", q)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		want := map[string]json.RawMessage{"alice": json.RawMessage(`{"address":{"city":"Zurich"},"name":"Alice"}`)}
+		if diff := cmp.Diff(page.Items, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+}
+
+func TestPgStoreAggregate(t *testing.T) {
+	store := newPgStore(t)
+	ctx := context.Background()
+
+	docs := map[string]string{
+		"o1": `{"status":"paid","amount":10,"customer":"alice","items":1}`,
+		"o2": `{"status":"paid","amount":2.5,"customer":"bob","items":1.0}`,
+		"o3": `{"status":"open","amount":7,"customer":"alice","items":2}`,
+		"o4": `{"status":"open","amount":"n/a","customer":null,"items":false}`,
+		"o5": `{"amount":1,"customer":"carol"}`,
+	}
+	// the client-side aggregation of the documents is the expected result
+	mem := jsonstore.NewMemStore()
+	for key, value := range docs {
+		if err := store.Set(ctx, "orders", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if err := mem.Set(ctx, "orders", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	tcs := []struct {
+		name string
+		spec jsonstore.AggregateSpec
+	}{
+		{
+			name: "totals",
+			spec: jsonstore.AggregateSpec{Aggregations: []jsonstore.Aggregation{
+				{Op: jsonstore.AggCount},
+				{Op: jsonstore.AggCount, Path: "customer"},
+				{Op: jsonstore.AggSum, Path: "amount"},
+				{Op: jsonstore.AggMin, Path: "amount"},
+				{Op: jsonstore.AggMax, Path: "amount"},
+			}},
+		},
+		{
+			name: "group by",
+			spec: jsonstore.AggregateSpec{
+				GroupBy:      []string{"status"},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}, {Op: jsonstore.AggSum, Path: "amount"}},
+			},
+		},
+		{
+			name: "group equal numbers",
+			spec: jsonstore.AggregateSpec{
+				GroupBy:      []string{"items"},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}},
+			},
+		},
+		{
+			name: "filter and group by many fields",
+			spec: jsonstore.AggregateSpec{
+				Filters:      []jsonstore.Filter{{Path: "status", Op: jsonstore.OpNe, Value: "open"}},
+				GroupBy:      []string{"customer", "status"},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}},
+			},
+		},
+		{
+			name: "no match",
+			spec: jsonstore.AggregateSpec{
+				Filters:      []jsonstore.Filter{{Path: "amount", Op: jsonstore.OpGt, Value: 100}},
+				Aggregations: []jsonstore.Aggregation{{Op: jsonstore.AggCount}, {Op: jsonstore.AggMax, Path: "amount"}},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := store.Aggregate(ctx, "orders", tc.spec)
+			if err != nil {
+				t.Fatalf("Aggregate failed: %v", err)
+			}
+			want, err := jsonstore.Aggregate(ctx, mem, "orders", tc.spec)
+			if err != nil {
+				t.Fatalf("Aggregate failed: %v", err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package prommetrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the namespace of the metrics if none is given
+const DefaultNamespace = "jsonstore"
+
+// Observer records the requests served by jsonstore.InstrumentHandler as Prometheus metrics, labelled by the method
+// and the status class of the response, e.g. "2xx":
+//
+//	jsonstore_http_requests_total{method,code}
+//	jsonstore_http_request_duration_seconds{method,code}
+//	jsonstore_http_request_size_bytes{method,code}
+//	jsonstore_http_response_size_bytes{method,code}
+type Observer struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+// make sure the Observer fulfills the RequestObserver interface
+var _ jsonstore.RequestObserver = &Observer{}
+
+// New creates an Observer registering its metrics in reg, prometheus.DefaultRegisterer if nil; the metric names start
+// with namespace, DefaultNamespace if empty
+func New(reg prometheus.Registerer, namespace string) (*Observer, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	labels := []string{"method", "code"}
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 8) // 64B to 1MiB
+	o := &Observer{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "Count of the http requests.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help: "Latency of the http requests.", Buckets: prometheus.DefBuckets,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_size_bytes",
+			Help: "Size of the bodies of the http requests.", Buckets: sizeBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "response_size_bytes",
+			Help: "Size of the bodies of the http responses.", Buckets: sizeBuckets,
+		}, labels),
+	}
+	for _, c := range []prometheus.Collector{o.requests, o.duration, o.requestSize, o.responseSize} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %v", err)
+		}
+	}
+	return o, nil
+}
+
+// ObserveRequest records a request
+func (o *Observer) ObserveRequest(r *http.Request, info jsonstore.RequestInfo) {
+	code := info.StatusClass()
+	o.requests.WithLabelValues(info.Method, code).Inc()
+	o.duration.WithLabelValues(info.Method, code).Observe(info.Duration.Seconds())
+	o.requestSize.WithLabelValues(info.Method, code).Observe(float64(info.RequestSize))
+	o.responseSize.WithLabelValues(info.Method, code).Observe(float64(info.ResponseSize))
+}
@@ -0,0 +1,59 @@
+package prommetrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserver(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	observer, err := prommetrics.New(reg, "")
+	if err != nil {
+		t.Fatalf("action: New,  returned an error: %v", err)
+	}
+	handler := jsonstore.InstrumentHandler(&jsonstore.Handler{
+		HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()},
+		Collection: "col1",
+	}, jsonstore.InstrumentOptions{Observer: observer})
+
+	requests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{http.MethodPut, "/item1", `{"n":1}`},
+		{http.MethodGet, "/item1", ""},
+		{http.MethodGet, "/item2", ""},
+		{http.MethodGet, "/", ""},
+	}
+	for _, req := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.url, strings.NewReader(req.body)))
+	}
+
+	want := `
+# HELP jsonstore_http_requests_total Count of the http requests.
+# TYPE jsonstore_http_requests_total counter
+jsonstore_http_requests_total{code="2xx",method="GET"} 2
+jsonstore_http_requests_total{code="2xx",method="PUT"} 1
+jsonstore_http_requests_total{code="4xx",method="GET"} 1
+`
+	if err = testutil.GatherAndCompare(reg, strings.NewReader(want), "jsonstore_http_requests_total"); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(reg, "jsonstore_http_request_duration_seconds"); got != 3 {
+		t.Errorf("expected 3 latency series, got %d", got)
+	}
+
+	t.Run("registered twice", func(t *testing.T) {
+		if _, err := prommetrics.New(reg, ""); err == nil {
+			t.Errorf("expected an error registering the metrics twice")
+		}
+	})
+}
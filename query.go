@@ -0,0 +1,421 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var InvalidQueryErr = errors.New("invalid query")
+
+// FilterOp is the comparison applied by a Filter
+type FilterOp string
+
+const (
+	OpEq  FilterOp = "eq"
+	OpNe  FilterOp = "ne"
+	OpGt  FilterOp = "gt"
+	OpGte FilterOp = "gte"
+	OpLt  FilterOp = "lt"
+	OpLte FilterOp = "lte"
+)
+
+// Filter matches the documents whose field at Path compares to Value using Op.
+// Path is a dot separated list of object keys, e.g. "address.city"; Value is any json encodable value.
+// Values of different json types never match, except for OpNe that also matches documents without the field.
+type Filter struct {
+	Path  string
+	Op    FilterOp
+	Value any
+}
+
+// SortField orders the results of a query by the value at Path, see Query.Sort
+type SortField struct {
+	Path string
+	Desc bool
+}
+
+// Query selects, searches and projects the documents of a collection.
+type Query struct {
+	ListOptions
+	// Filters that all need to match
+	Filters []Filter
+	// Search matches the documents whose json contains the term, case-insensitive
+	Search string
+	// Fields, if set, projects the documents to only the given paths
+	Fields []string
+	// Sort orders the results by the given fields, then by key, Page.Keys lists the keys in this order. In ascending
+	// order documents missing the field come first, followed by booleans and numbers, then strings; arrays and
+	// objects are ordered as their json text.
+	Sort []SortField
+}
+
+// Querier is implemented by stores able to run queries natively, e.g. pushing them down to the database
+type Querier interface {
+	Query(ctx context.Context, collection string, q Query) (Page, error)
+}
+
+// RunQuery runs the query on the store, if the store does not implement Querier all the documents
+// of the collection are loaded and the query is evaluated client-side.
+func RunQuery(ctx context.Context, store JsonStorer, collection string, q Query) (Page, error) {
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	if qr, ok := store.(Querier); ok {
+		return qr.Query(ctx, collection, q)
+	}
+	return queryClientSide(ctx, store, collection, q)
+}
+
+// queryClientSide loads all the documents of the collection and evaluates the query on them
+func queryClientSide(ctx context.Context, store JsonStorer, collection string, q Query) (Page, error) {
+	opts, err := q.ListOptions.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	matches := map[string]json.RawMessage{}
+	listOpts := ListOptions{Limit: MaxListItems, Page: 1, Prefix: opts.Prefix}
+	for {
+		page, err := ListPage(ctx, store, collection, listOpts)
+		if err != nil {
+			return Page{}, err
+		}
+		for key, value := range page.Items {
+			ok, err := q.Match(value)
+			if err != nil {
+				return Page{}, err
+			}
+			if ok {
+				matches[key] = value
+			}
+		}
+		if !page.HasNext {
+			break
+		}
+		listOpts.Page++
+	}
+
+	keys := make([]string, 0, len(matches))
+	for key := range matches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(q.Sort) > 0 {
+		err = sortKeys(keys, matches, q.Sort)
+		if err != nil {
+			return Page{}, err
+		}
+	}
+
+	offset := (opts.Page - 1) * opts.Limit
+	end := offset + opts.Limit
+	if end > len(keys) {
+		end = len(keys)
+	}
+	if offset > end {
+		offset = end
+	}
+	result := make(map[string]json.RawMessage, end-offset)
+	for _, key := range keys[offset:end] {
+		value, err := Project(matches[key], q.Fields)
+		if err != nil {
+			return Page{}, err
+		}
+		result[key] = value
+	}
+	p := NewPage(result, int64(len(keys)), opts.Page, opts.Limit)
+	if len(q.Sort) > 0 {
+		p.Keys = keys[offset:end]
+	}
+	return p, nil
+}
+
+// Validate checks the filters and fields of the query, errors wrap InvalidQueryErr
+func (q Query) Validate() error {
+	for _, f := range q.Filters {
+		if f.Path == "" {
+			return fmt.Errorf("%w: filter path cannot be empty", InvalidQueryErr)
+		}
+		switch f.Op {
+		case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		default:
+			return fmt.Errorf("%w: unknown filter operation %q", InvalidQueryErr, f.Op)
+		}
+		if _, err := json.Marshal(f.Value); err != nil {
+			return fmt.Errorf("%w: filter value: %v", InvalidQueryErr, err)
+		}
+	}
+	for _, field := range q.Fields {
+		if field == "" {
+			return fmt.Errorf("%w: field path cannot be empty", InvalidQueryErr)
+		}
+	}
+	for _, field := range q.Sort {
+		if field.Path == "" {
+			return fmt.Errorf("%w: sort path cannot be empty", InvalidQueryErr)
+		}
+	}
+	return nil
+}
+
+// sortKeys sorts the keys, already sorted alphabetically, by the sort fields of their documents
+func sortKeys(keys []string, docs map[string]json.RawMessage, fields []SortField) error {
+	decoded := make(map[string]any, len(keys))
+	for _, key := range keys {
+		var doc any
+		if err := json.Unmarshal(docs[key], &doc); err != nil {
+			return fmt.Errorf("unable to decode document: %v", err)
+		}
+		decoded[key] = doc
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		for _, field := range fields {
+			path := SplitPath(field.Path)
+			// missing fields are nil, sorted as null
+			a, _ := lookup(decoded[keys[i]], path)
+			b, _ := lookup(decoded[keys[j]], path)
+			c := compareSort(a, b)
+			if field.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// sortRank groups the json types in the order used for sorting
+func sortRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool, float64:
+		return 1
+	}
+	return 2
+}
+
+// compareSort compares two values for sorting, see Query.Sort
+func compareSort(a, b any) int {
+	ra, rb := sortRank(a), sortRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch ra {
+	case 0:
+		return 0
+	case 1:
+		fa, fb := sortNumber(a), sortNumber(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(sortString(a), sortString(b))
+}
+
+// sortNumber returns booleans as 0 and 1
+func sortNumber(v any) float64 {
+	switch n := v.(type) {
+	case bool:
+		if n {
+			return 1
+		}
+		return 0
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func sortString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// SplitPath splits a dot separated path into its keys
+func SplitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// Match reports whether the document matches the filters and search term of the query
+func (q Query) Match(value json.RawMessage) (bool, error) {
+	if q.Search != "" && !strings.Contains(strings.ToLower(string(value)), strings.ToLower(q.Search)) {
+		return false, nil
+	}
+	if len(q.Filters) == 0 {
+		return true, nil
+	}
+	var doc any
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return false, fmt.Errorf("unable to decode document: %v", err)
+	}
+	for _, f := range q.Filters {
+		ok, err := f.match(doc)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f Filter) match(doc any) (bool, error) {
+	got, found := lookup(doc, SplitPath(f.Path))
+	// normalize the filter value to the types produced by json.Unmarshal
+	b, err := json.Marshal(f.Value)
+	if err != nil {
+		return false, fmt.Errorf("%w: filter value: %v", InvalidQueryErr, err)
+	}
+	var want any
+	_ = json.Unmarshal(b, &want)
+
+	if !found {
+		return f.Op == OpNe, nil
+	}
+	cmp, comparable := compareJson(got, want)
+	switch f.Op {
+	case OpEq:
+		return comparable && cmp == 0, nil
+	case OpNe:
+		return !comparable || cmp != 0, nil
+	}
+	// arrays and objects only support equality
+	if !comparable || !isScalar(got) {
+		return false, nil
+	}
+	switch f.Op {
+	case OpGt:
+		return cmp > 0, nil
+	case OpGte:
+		return cmp >= 0, nil
+	case OpLt:
+		return cmp < 0, nil
+	case OpLte:
+		return cmp <= 0, nil
+	}
+	return false, fmt.Errorf("%w: unknown filter operation %q", InvalidQueryErr, f.Op)
+}
+
+// lookup returns the value at the path of a decoded json document
+func lookup(doc any, path []string) (any, bool) {
+	cur := doc
+	for _, key := range path {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// compareJson compares two decoded json values of the same type, the second return value is false
+// if the values cannot be compared; for arrays and objects only the equality is meaningful.
+func compareJson(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case nil:
+		return 0, b == nil
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av < bv:
+			return -1, true
+		case av > bv:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	case bool:
+		bv, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if av == bv {
+			return 0, true
+		}
+		if !av {
+			return -1, true
+		}
+		return 1, true
+	default:
+		ab, _ := json.Marshal(a)
+		bb, err := json.Marshal(b)
+		if err != nil || fmt.Sprintf("%T", a) != fmt.Sprintf("%T", b) {
+			return 0, false
+		}
+		if string(ab) == string(bb) {
+			return 0, true
+		}
+		return 1, true
+	}
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	return true
+}
+
+// Project returns a document containing only the given paths, missing paths are omitted.
+// An empty list of fields returns the document unchanged.
+func Project(value json.RawMessage, fields []string) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return value, nil
+	}
+	var doc any
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return nil, fmt.Errorf("unable to decode document: %v", err)
+	}
+	out := map[string]any{}
+	for _, field := range fields {
+		path := SplitPath(field)
+		v, found := lookup(doc, path)
+		if !found {
+			continue
+		}
+		SetPath(out, path, v)
+	}
+	return json.Marshal(out)
+}
+
+// SetPath sets a value at the path of a json object, creating the intermediate objects
+func SetPath(obj map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+}
@@ -0,0 +1,117 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestRunQuery(t *testing.T) {
+	ctx := context.Background()
+	store := jsonstore.NewMemStore()
+	docs := map[string]string{
+		"alice": `{"name":"Alice","age":31,"address":{"city":"Zurich"},"tags":["admin"]}`,
+		"bob":   `{"name":"Bob","age":25,"address":{"city":"Berlin"}}`,
+		"carol": `{"name":"Carol","age":"unknown","address":{"city":"Zurich"}}`,
+	}
+	for key, value := range docs {
+		if err := store.Set(ctx, "users", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	tcs := []struct {
+		name  string
+		query jsonstore.Query
+		want  map[string]json.RawMessage
+	}{
+		{
+			name:  "filter nested field",
+			query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "address.city", Op: jsonstore.OpEq, Value: "Zurich"}}},
+			want: map[string]json.RawMessage{
+				"alice": json.RawMessage(docs["alice"]),
+				"carol": json.RawMessage(docs["carol"]),
+			},
+		},
+		{
+			name: "compare numbers, ignoring other types",
+			query: jsonstore.Query{
+				Filters: []jsonstore.Filter{{Path: "age", Op: jsonstore.OpGte, Value: 30}},
+				Fields:  []string{"name", "address.city"},
+			},
+			want: map[string]json.RawMessage{
+				"alice": json.RawMessage(`{"address":{"city":"Zurich"},"name":"Alice"}`),
+			},
+		},
+		{
+			name:  "not equal matches missing fields",
+			query: jsonstore.Query{Filters: []jsonstore.Filter{{Path: "tags", Op: jsonstore.OpNe, Value: []string{"admin"}}}},
+			want: map[string]json.RawMessage{
+				"bob":   json.RawMessage(docs["bob"]),
+				"carol": json.RawMessage(docs["carol"]),
+			},
+		},
+		{
+			name:  "search",
+			query: jsonstore.Query{Search: "berlin", Fields: []string{"name"}},
+			want: map[string]json.RawMessage{
+				"bob": json.RawMessage(`{"name":"Bob"}`),
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := jsonstore.RunQuery(ctx, store, "users", tc.query)
+			if err != nil {
+				t.Fatalf("RunQuery failed: %v", err)
+			}
+			if diff := cmp.Diff(page.Items, tc.want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+			if page.Total != int64(len(tc.want)) {
+				t.Errorf("expected total %d, got %d", len(tc.want), page.Total)
+			}
+		})
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		q := jsonstore.Query{ListOptions: jsonstore.ListOptions{Limit: 1}, Fields: []string{"name"}}
+		page, err := jsonstore.RunQuery(ctx, store, "users", q)
+		if err != nil {
+			t.Fatalf("RunQuery failed: %v", err)
+		}
+		if page.Total != 3 || !page.HasNext {
+			t.Fatalf("unexpected page metadata: %+v", page)
+		}
+		q.Cursor = page.NextCursor
+		page, err = jsonstore.RunQuery(ctx, store, "users", q)
+		if err != nil {
+			t.Fatalf("RunQuery failed: %v", err)
+		}
+		if diff := cmp.Diff(page.Items, map[string]json.RawMessage{"bob": json.RawMessage(`{"name":"Bob"}`)}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("sorted keys", func(t *testing.T) {
+		q := jsonstore.Query{ListOptions: jsonstore.ListOptions{Limit: 2}, Sort: []jsonstore.SortField{{Path: "name", Desc: true}}}
+		page, err := jsonstore.RunQuery(ctx, store, "users", q)
+		if err != nil {
+			t.Fatalf("RunQuery failed: %v", err)
+		}
+		if diff := cmp.Diff(page.Keys, []string{"carol", "bob"}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		q := jsonstore.Query{Filters: []jsonstore.Filter{{Path: "age", Op: "like", Value: 1}}}
+		_, err := jsonstore.RunQuery(ctx, store, "users", q)
+		if !errors.Is(err, jsonstore.InvalidQueryErr) {
+			t.Errorf("expected InvalidQueryErr, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RefKey is the key of the objects referencing another document of the store, e.g. {"$ref": "users/123"}
+const RefKey = "$ref"
+
+// Reference points to the document Key of the Collection, it is encoded as {"$ref": "<collection>/<key>"} so it
+// can be used as field of the documents to declare references that are resolved by Populate
+type Reference struct {
+	Collection string
+	Key        string
+}
+
+func (ref Reference) String() string {
+	return ref.Collection + "/" + ref.Key
+}
+
+func (ref Reference) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{RefKey: ref.String()})
+}
+
+func (ref *Reference) UnmarshalJSON(data []byte) error {
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	r, ok := parseReference(obj)
+	if !ok {
+		return fmt.Errorf("invalid reference: %s", data)
+	}
+	*ref = r
+	return nil
+}
+
+// parseReference returns the reference of a decoded json value, it is false if the value is not an object with only
+// a $ref string of the form <collection>/<key>; collection names have no slashes, the key may have them
+func parseReference(value any) (Reference, bool) {
+	obj, ok := value.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return Reference{}, false
+	}
+	s, ok := obj[RefKey].(string)
+	if !ok {
+		return Reference{}, false
+	}
+	collection, key, ok := strings.Cut(s, "/")
+	if !ok || collection == "" || key == "" {
+		return Reference{}, false
+	}
+	return Reference{Collection: collection, Key: key}, true
+}
+
+// refGetter reads a referenced document, it returns an error wrapping ItemNotFoundErr if it does not exist
+type refGetter func(ctx context.Context, ref Reference) (json.RawMessage, error)
+
+// Populate replaces the references at the given paths of the document, see Reference, with the referenced
+// documents read from the store; a path can also hold an array of references. Values that are not references
+// and references to missing documents are left unchanged. Paths are dot separated, e.g. "post.author".
+func Populate(ctx context.Context, store JsonStorer, value json.RawMessage, paths []string) (json.RawMessage, error) {
+	return newPopulator(storeRefGetter(store)).populate(ctx, value, paths)
+}
+
+// GetPopulated reads a document and populates the references at the given paths, see Populate
+func GetPopulated(ctx context.Context, store JsonStorer, collection, key string, value *json.RawMessage, paths ...string) error {
+	var doc json.RawMessage
+	if err := store.Get(ctx, collection, key, &doc); err != nil {
+		return err
+	}
+	doc, err := Populate(ctx, store, doc, paths)
+	if err != nil {
+		return err
+	}
+	*value = doc
+	return nil
+}
+
+// populator resolves references caching the documents, so documents referenced many times, e.g. by all the
+// documents of a page, are read once
+type populator struct {
+	get   refGetter
+	cache map[Reference]json.RawMessage
+}
+
+func newPopulator(get refGetter) *populator {
+	return &populator{get: get, cache: map[Reference]json.RawMessage{}}
+}
+
+// storeRefGetter reads the referenced documents from the store
+func storeRefGetter(store JsonStorer) refGetter {
+	return func(ctx context.Context, ref Reference) (json.RawMessage, error) {
+		var value json.RawMessage
+		err := store.Get(ctx, ref.Collection, ref.Key, &value)
+		return value, err
+	}
+}
+
+func (p *populator) populate(ctx context.Context, value json.RawMessage, paths []string) (json.RawMessage, error) {
+	if len(paths) == 0 {
+		return value, nil
+	}
+	var doc any
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return nil, fmt.Errorf("unable to decode document: %v", err)
+	}
+	changed := false
+	for _, field := range paths {
+		path := SplitPath(field)
+		parent, ok := lookup(doc, path[:len(path)-1])
+		if !ok {
+			continue
+		}
+		obj, ok := parent.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := path[len(path)-1]
+		switch v := obj[name].(type) {
+		case map[string]any:
+			resolved, found, err := p.resolve(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				obj[name] = resolved
+				changed = true
+			}
+		case []any:
+			for i, item := range v {
+				resolved, found, err := p.resolve(ctx, item)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					v[i] = resolved
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return value, nil
+	}
+	return json.Marshal(doc)
+}
+
+// resolve returns the document referenced by the value, false if the value is not a reference or the document
+// does not exist
+func (p *populator) resolve(ctx context.Context, value any) (json.RawMessage, bool, error) {
+	ref, ok := parseReference(value)
+	if !ok {
+		return nil, false, nil
+	}
+	if doc, ok := p.cache[ref]; ok {
+		return doc, doc != nil, nil
+	}
+	doc, err := p.get(ctx, ref)
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, false, fmt.Errorf("failed to populate %s: %w", ref, err)
+		}
+		doc = nil
+	}
+	p.cache[ref] = doc
+	return doc, doc != nil, nil
+}
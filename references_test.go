@@ -0,0 +1,322 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newReferencesStore returns a store with posts referencing users and tags
+func newReferencesStore(t *testing.T) *jsonstoretest.FakeStore {
+	t.Helper()
+	return jsonstoretest.NewFakeStore(map[string]map[string]json.RawMessage{
+		"users": {
+			"alice": json.RawMessage(`{"name":"alice"}`),
+			"bob":   json.RawMessage(`{"name":"bob"}`),
+		},
+		"tags": {
+			"go": json.RawMessage(`{"label":"Go"}`),
+		},
+		"posts": {
+			"p1": json.RawMessage(`{"title":"first","author":{"$ref":"users/alice"},"tags":[{"$ref":"tags/go"},{"$ref":"tags/missing"}]}`),
+			"p2": json.RawMessage(`{"title":"second","author":{"$ref":"users/alice"},"meta":{"editor":{"$ref":"users/bob"}}}`),
+			"p3": json.RawMessage(`{"title":"third","author":"not a reference"}`),
+		},
+	})
+}
+
+func jsonDiff(t *testing.T, got []byte, want string) string {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("failed to decode %s: %v", got, err)
+	}
+	_ = json.Unmarshal([]byte(want), &wantValue)
+	return cmp.Diff(gotValue, wantValue)
+}
+
+func TestReference(t *testing.T) {
+	type post struct {
+		Author jsonstore.Reference `json:"author"`
+	}
+	data, err := json.Marshal(post{Author: jsonstore.Reference{Collection: "users", Key: "a/b"}})
+	if err != nil {
+		t.Fatalf("action: Marshal,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(string(data), `{"author":{"$ref":"users/a/b"}}`); diff != "" {
+		t.Errorf("unexpected json (-got +want):\n%s", diff)
+	}
+	var got post
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("action: Unmarshal,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(got.Author, jsonstore.Reference{Collection: "users", Key: "a/b"}); diff != "" {
+		t.Errorf("unexpected reference (-got +want):\n%s", diff)
+	}
+	for _, invalid := range []string{`{"$ref":"users"}`, `{"$ref":1}`, `{"$ref":"users/a","other":1}`, `"users/a"`} {
+		if err = json.Unmarshal([]byte(invalid), &got.Author); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestGetPopulated(t *testing.T) {
+	ctx := context.Background()
+	tcs := []struct {
+		name   string
+		key    string
+		fields []string
+		want   string
+	}{
+		{
+			name:   "reference and array of references",
+			key:    "p1",
+			fields: []string{"author", "tags"},
+			want:   `{"title":"first","author":{"name":"alice"},"tags":[{"label":"Go"},{"$ref":"tags/missing"}]}`,
+		},
+		{
+			name:   "nested path",
+			key:    "p2",
+			fields: []string{"meta.editor", "missing.path"},
+			want:   `{"title":"second","author":{"$ref":"users/alice"},"meta":{"editor":{"name":"bob"}}}`,
+		},
+		{
+			name:   "not a reference",
+			key:    "p3",
+			fields: []string{"author"},
+			want:   `{"title":"third","author":"not a reference"}`,
+		},
+		{
+			name: "no fields",
+			key:  "p2",
+			want: `{"title":"second","author":{"$ref":"users/alice"},"meta":{"editor":{"$ref":"users/bob"}}}`,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var got json.RawMessage
+			if err := jsonstore.GetPopulated(ctx, newReferencesStore(t), "posts", tc.key, &got, tc.fields...); err != nil {
+				t.Fatalf("action: GetPopulated,  returned an error: %v", err)
+			}
+			if diff := jsonDiff(t, got, tc.want); diff != "" {
+				t.Errorf("unexpected document (-got +want):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		store := newReferencesStore(t)
+		var got json.RawMessage
+		if err := jsonstore.GetPopulated(ctx, store, "posts", "missing", &got, "author"); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+			t.Errorf("expected ItemNotFoundErr, got %v", err)
+		}
+		store.ErrFunc = func(call jsonstoretest.Call) error {
+			if call.Collection == "users" {
+				return errors.New("database is down")
+			}
+			return nil
+		}
+		if err := jsonstore.GetPopulated(ctx, store, "posts", "p1", &got, "author"); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("references are read once", func(t *testing.T) {
+		store := newReferencesStore(t)
+		doc := json.RawMessage(`{"a":{"$ref":"users/alice"},"b":[{"$ref":"users/alice"},{"$ref":"users/alice"}]}`)
+		got, err := jsonstore.Populate(ctx, store, doc, []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("action: Populate,  returned an error: %v", err)
+		}
+		if diff := jsonDiff(t, got, `{"a":{"name":"alice"},"b":[{"name":"alice"},{"name":"alice"}]}`); diff != "" {
+			t.Errorf("unexpected document (-got +want):\n%s", diff)
+		}
+		if n := store.CallCount(jsonstoretest.MethodGet); n != 1 {
+			t.Errorf("expected 1 get, got %d", n)
+		}
+	})
+}
+
+func TestHandlerPopulate(t *testing.T) {
+	tcs := []struct {
+		name       string
+		url        string
+		want       string
+		wantStatus int
+	}{
+		{name: "get", url: "/posts/p2?populate=author", want: `{"title":"second","author":{"name":"alice"},"meta":{"editor":{"$ref":"users/bob"}}}`},
+		{name: "get with fields", url: "/posts/p2?populate=author,meta.editor&fields=author.name,meta", want: `{"author":{"name":"alice"},"meta":{"editor":{"name":"bob"}}}`},
+		{name: "list", url: "/posts/?populate=author&fields=author", want: `{"p1":{"author":{"name":"alice"}},"p2":{"author":{"name":"alice"}},"p3":{"author":"not a reference"}}`},
+		{name: "forbidden reference", url: "/posts/p1?populate=tags", wantStatus: http.StatusForbidden},
+		{name: "forbidden reference in list", url: "/posts/?populate=tags", wantStatus: http.StatusForbidden},
+		{name: "empty path", url: "/posts/p1?populate=author,", wantStatus: http.StatusBadRequest},
+	}
+	handler := &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{
+		Storer: newReferencesStore(t),
+		Authorize: func(r *http.Request, op jsonstore.Operation, collection, key string) error {
+			if collection == "tags" {
+				return jsonstore.ForbiddenErr
+			}
+			return nil
+		},
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			wantStatus := tc.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if rec.Code != wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantStatus != 0 {
+				return
+			}
+			got := rec.Body.Bytes()
+			if tc.name == "list" {
+				page := jsonstore.Page{}
+				if err := json.Unmarshal(got, &page); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				got, _ = json.Marshal(page.Items)
+			}
+			if diff := jsonDiff(t, got, tc.want); diff != "" {
+				t.Errorf("unexpected body (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerPopulateScope(t *testing.T) {
+	store := jsonstoretest.NewFakeStore(map[string]map[string]json.RawMessage{
+		"public": {
+			"a":    json.RawMessage(`{"r":{"$ref":"secret/x"}}`),
+			"b":    json.RawMessage(`{"r":{"$ref":"public/c"}}`),
+			"c":    json.RawMessage(`{"n":1}`),
+			"idem": json.RawMessage(`{"r":{"$ref":"_idempotency/k"}}`),
+			"own":  json.RawMessage(`{"r":{"$ref":"records/k"}}`),
+		},
+		"secret":       {"x": json.RawMessage(`{"secret":true}`)},
+		"_idempotency": {"k": json.RawMessage(`{"status":200}`)},
+		"records":      {"k": json.RawMessage(`{"status":200}`)},
+		"alice":        {"a": json.RawMessage(`{"r":{"$ref":"bob/b"}}`), "self": json.RawMessage(`{"r":{"$ref":"alice/a"}}`)},
+		"bob":          {"b": json.RawMessage(`{"private":true}`)},
+	})
+
+	tcs := []struct {
+		name       string
+		handler    http.Handler
+		url        string
+		wantStatus int
+	}{
+		{
+			name:       "collection outside the allow-list",
+			handler:    &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collections: []string{"public"}},
+			url:        "/public/a?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "collection outside the allow-list is not found",
+			handler:    &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collections: []string{"public"}},
+			url:        "/secret/x",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "collection in the allow-list",
+			handler:    &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collections: []string{"public"}},
+			url:        "/public/b?populate=r",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list with collection outside the allow-list",
+			handler:    &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collections: []string{"public"}},
+			url:        "/public/?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "collection rejected by ValidateCollection",
+			handler:    &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}},
+			url:        "/public/idem?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "idempotency collection",
+			handler: &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{
+				Storer:      store,
+				Idempotency: &jsonstore.IdempotencyOptions{Collection: "records"},
+			}},
+			url:        "/public/own?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "other collection of a single collection handler",
+			handler:    &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "public"},
+			url:        "/public/a?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "same collection of a single collection handler",
+			handler:    &jsonstore.Handler{HttpStorer: jsonstore.HttpStorer{Storer: store}, Collection: "public"},
+			url:        "/public/b?populate=r",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "collection of another user",
+			handler: &jsonstore.Handler{
+				HttpStorer:     jsonstore.HttpStorer{Storer: store},
+				CollectionFunc: func(r *http.Request) (string, error) { return "alice", nil },
+			},
+			url:        "/a?populate=r",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "collection of the user",
+			handler: &jsonstore.Handler{
+				HttpStorer:     jsonstore.HttpStorer{Storer: store},
+				CollectionFunc: func(r *http.Request) (string, error) { return "alice", nil },
+			},
+			url:        "/self?populate=r",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "unauthorized reference",
+			handler: &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{
+				Storer: store,
+				Authorize: func(r *http.Request, op jsonstore.Operation, collection, key string) error {
+					if key == "c" {
+						return jsonstore.UnauthorizedErr
+					}
+					return nil
+				},
+			}},
+			url:        "/public/b?populate=r",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			rec := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if strings.Contains(rec.Body.String(), `"secret":true`) && tc.wantStatus != http.StatusNotFound ||
+				strings.Contains(rec.Body.String(), `"private":true`) {
+				t.Errorf("unexpected referenced document in the response: %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// snapshotWriter streams a snapshot, the documents need to be written grouped by collection
+type snapshotWriter struct {
+	w          io.Writer
+	collection string
+	started    bool
+	docs       int
+	err        error
+}
+
+func (s *snapshotWriter) write(p []byte) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+}
+
+func (s *snapshotWriter) writeString(v string) {
+	b, _ := json.Marshal(v)
+	s.write(b)
+}
+
+// document writes a document of the snapshot
+func (s *snapshotWriter) document(collection, key string, value json.RawMessage) error {
+	if !s.started || collection != s.collection {
+		if !s.started {
+			s.write([]byte("{"))
+		} else {
+			s.write([]byte("},"))
+		}
+		s.writeString(collection)
+		s.write([]byte(":{"))
+		s.started = true
+		s.collection = collection
+		s.docs = 0
+	}
+	if s.docs > 0 {
+		s.write([]byte(","))
+	}
+	s.writeString(key)
+	s.write([]byte(":"))
+	s.write(value)
+	s.docs++
+	return s.err
+}
+
+// close terminates the snapshot
+func (s *snapshotWriter) close() error {
+	if !s.started {
+		s.write([]byte("{}"))
+	} else {
+		s.write([]byte("}}"))
+	}
+	return s.err
+}
+
+// readSnapshot parses a snapshot
+func readSnapshot(r io.Reader) (map[string]map[string]json.RawMessage, error) {
+	content := map[string]map[string]json.RawMessage{}
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&content); err != nil {
+		return nil, fmt.Errorf("unable to read snapshot: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unable to read snapshot: unexpected data after the snapshot")
+	}
+	for collection, docs := range content {
+		if docs == nil {
+			content[collection] = map[string]json.RawMessage{}
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,144 @@
+package jsonstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+type snapshotStore interface {
+	jsonstore.JsonStorer
+	jsonstore.Snapshotter
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	newFileStore := func(t *testing.T, flags ...jsonstore.FileStoreFlag) snapshotStore {
+		store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "store"), flags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+	newDbStoreWithOptions := func(t *testing.T, opts jsonstore.DbStoreOptions) snapshotStore {
+		store, err := jsonstore.NewDbStoreWithOptions(newSqliteDbFile(t), opts)
+		if err != nil {
+			t.Fatalf("NewDbStoreWithOptions returned an error: %v", err)
+		}
+		return store
+	}
+
+	tcs := []struct {
+		name     string
+		newStore func(t *testing.T) snapshotStore
+	}{
+		{name: "FileStore", newStore: func(t *testing.T) snapshotStore { return newFileStore(t) }},
+		{name: "FileStore lazy collections", newStore: func(t *testing.T) snapshotStore {
+			return newFileStore(t, jsonstore.FilePerCollection, jsonstore.LazyLoad)
+		}},
+		{name: "DbStore", newStore: func(t *testing.T) snapshotStore { return newDbStore(t) }},
+		{name: "DbStore table per collection", newStore: func(t *testing.T) snapshotStore {
+			return newDbStoreWithOptions(t, jsonstore.DbStoreOptions{TablePerCollection: true})
+		}},
+		{name: "DbStore soft delete", newStore: func(t *testing.T) snapshotStore {
+			return newDbStoreWithOptions(t, jsonstore.DbStoreOptions{SoftDelete: true})
+		}},
+	}
+
+	want := map[string]map[string]any{
+		"col1": {"item1": map[string]any{"a": 1.0}, "item2": "two"},
+		"col2": {"item1": []any{1.0, 2.0}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.newStore(t)
+			for collection, items := range want {
+				for key, value := range items {
+					raw, _ := json.Marshal(value)
+					if err := store.Set(ctx, collection, key, raw); err != nil {
+						t.Fatalf("action: Set,  returned an error: %v", err)
+					}
+				}
+			}
+			if err := store.Set(ctx, "col1", "deleted", json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if _, err := store.Delete(ctx, "col1", "deleted"); err != nil {
+				t.Fatalf("action: Delete,  returned an error: %v", err)
+			}
+
+			snapshot := takeSnapshot(t, store)
+			if diff := cmp.Diff(snapshot, want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+
+			// changes after the snapshot are discarded by the restore
+			if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"a":2}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			if err := store.Set(ctx, "extra", "item1", json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+			data, _ := json.Marshal(snapshot)
+			if err := store.RestoreSnapshot(ctx, bytes.NewReader(data)); err != nil {
+				t.Fatalf("action: RestoreSnapshot,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(takeSnapshot(t, store), want); diff != "" {
+				t.Errorf("unexpected value after restore (-got +want)\n%s", diff)
+			}
+			var got json.RawMessage
+			if err := store.Get(ctx, "col1", "item1", &got); err != nil {
+				t.Fatalf("action: Get,  returned an error: %v", err)
+			}
+			if diff := cmp.Diff(string(got), `{"a":1}`); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("restore into another store", func(t *testing.T) {
+		source := newFileStore(t)
+		if err := source.Set(ctx, "col1", "item1", json.RawMessage(`{"a":1}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		buf := bytes.Buffer{}
+		if err := source.Snapshot(ctx, &buf); err != nil {
+			t.Fatalf("action: Snapshot,  returned an error: %v", err)
+		}
+		target := newDbStore(t)
+		if err := target.RestoreSnapshot(ctx, &buf); err != nil {
+			t.Fatalf("action: RestoreSnapshot,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(takeSnapshot(t, target), map[string]map[string]any{"col1": {"item1": map[string]any{"a": 1.0}}}); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("invalid snapshot", func(t *testing.T) {
+		for _, store := range []snapshotStore{newFileStore(t), newDbStore(t)} {
+			if err := store.RestoreSnapshot(ctx, bytes.NewReader([]byte(`{"col1":[]}`))); err == nil {
+				t.Errorf("expected an error restoring an invalid snapshot")
+			}
+		}
+	})
+}
+
+func takeSnapshot(t *testing.T, store jsonstore.Snapshotter) map[string]map[string]any {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := store.Snapshot(context.Background(), &buf); err != nil {
+		t.Fatalf("action: Snapshot,  returned an error: %v", err)
+	}
+	got := map[string]map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid snapshot %q: %v", buf.String(), err)
+	}
+	return got
+}
@@ -0,0 +1,76 @@
+package sqlitestore
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultBusyTimeout is the time a connection waits for a lock held by another connection
+// before failing with SQLITE_BUSY "database is locked"
+const DefaultBusyTimeout = 5 * time.Second
+
+// Options are the pragmas applied to every connection of the sqlite database
+type Options struct {
+	// BusyTimeout is the time a connection waits for a lock, if zero DefaultBusyTimeout is used
+	BusyTimeout time.Duration
+	// Synchronous is the synchronous pragma, if empty NORMAL is used: in WAL mode it is durable against
+	// application crashes and only the last transactions can be lost on power loss.
+	Synchronous string
+	// DisableWAL keeps the rollback journal instead of switching the database to WAL mode,
+	// e.g. for databases on network filesystems where WAL is not supported.
+	DisableWAL bool
+}
+
+// DSN returns the data source name of the sqlite file for github.com/mattn/go-sqlite3, the driver used by
+// gorm.io/driver/sqlite, setting the pragmas on every new connection: WAL journal mode, so that readers don't
+// block the writer, a busy timeout and the synchronous level. Transactions take the write lock immediately,
+// a deferred transaction upgrading its lock fails with SQLITE_BUSY without waiting for the busy timeout.
+func DSN(file string, opts Options) string {
+	if opts.BusyTimeout == 0 {
+		opts.BusyTimeout = DefaultBusyTimeout
+	}
+	if opts.Synchronous == "" {
+		opts.Synchronous = "NORMAL"
+	}
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprintf("%d", opts.BusyTimeout.Milliseconds()))
+	params.Set("_synchronous", opts.Synchronous)
+	params.Set("_txlock", "immediate")
+	if !opts.DisableWAL {
+		params.Set("_journal_mode", "WAL")
+	}
+	sep := "?"
+	if strings.Contains(file, "?") {
+		sep = "&"
+	}
+	return file + sep + params.Encode()
+}
+
+// Open opens the sqlite file with gorm using the DSN configured with opts, if config is nil
+// the gorm logger is discarded.
+func Open(file string, opts Options, config *gorm.Config) (*gorm.DB, error) {
+	if config == nil {
+		config = &gorm.Config{Logger: logger.Discard}
+	}
+	db, err := gorm.Open(sqlite.Open(DSN(file, opts)), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database %s: %v", file, err)
+	}
+	return db, nil
+}
+
+// NewDbStore opens the sqlite file with the pragmas of opts and creates a jsonstore.DbStore on it
+func NewDbStore(file string, opts Options, storeOpts jsonstore.DbStoreOptions) (*jsonstore.DbStore, error) {
+	db, err := Open(file, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return jsonstore.NewDbStoreWithOptions(db, storeOpts)
+}
@@ -0,0 +1,98 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/sqlitestore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDSN(t *testing.T) {
+	tcs := []struct {
+		name string
+		file string
+		opts sqlitestore.Options
+		want string
+	}{
+		{
+			name: "defaults",
+			file: "data.sqlite",
+			want: "data.sqlite?_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate",
+		},
+		{
+			name: "custom pragmas",
+			file: "data.sqlite",
+			opts: sqlitestore.Options{BusyTimeout: time.Second, Synchronous: "FULL", DisableWAL: true},
+			want: "data.sqlite?_busy_timeout=1000&_synchronous=FULL&_txlock=immediate",
+		},
+		{
+			name: "existing parameters",
+			file: "file:data.sqlite?cache=shared",
+			want: "file:data.sqlite?cache=shared&_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := sqlitestore.DSN(tc.file, tc.opts)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	db, err := sqlitestore.Open(filepath.Join(t.TempDir(), "testdb.sqlite"), sqlitestore.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get underlying DB: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	// pragmas are per connection, run them on several connections of the pool
+	sqlDB.SetMaxIdleConns(0)
+	for i := 0; i < 3; i++ {
+		got := map[string]any{}
+		for _, pragma := range []string{"journal_mode", "busy_timeout", "synchronous"} {
+			var v any
+			if err = sqlDB.QueryRow("PRAGMA " + pragma).Scan(&v); err != nil {
+				t.Fatalf("failed to read pragma %s: %v", pragma, err)
+			}
+			got[pragma] = v
+		}
+		want := map[string]any{"journal_mode": "wal", "busy_timeout": int64(5000), "synchronous": int64(1)}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("unexpected value (-got +want)\n%s", diff)
+		}
+	}
+}
+
+func TestNewDbStore(t *testing.T) {
+	store, err := sqlitestore.NewDbStore(filepath.Join(t.TempDir(), "testdb.sqlite"), sqlitestore.Options{},
+		jsonstore.DbStoreOptions{})
+	if err != nil {
+		t.Fatalf("NewDbStore returned an error: %v", err)
+	}
+	ctx := context.Background()
+	value := json.RawMessage(`{"item":"value"}`)
+	if err = store.Set(ctx, "col1", "item1", value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	var got json.RawMessage
+	if err = store.Get(ctx, "col1", "item1", &got); err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(got, value); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
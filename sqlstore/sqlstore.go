@@ -0,0 +1,255 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-bumbu/jsonstore"
+)
+
+// Dialect selects the SQL flavour used by the SqlStore
+type Dialect string
+
+const (
+	Sqlite   Dialect = "sqlite"
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+)
+
+// DefaultTable is the table used by the gorm based jsonstore.DbStore, using it allows to switch between both stores
+const DefaultTable = "db_documents"
+
+// SqlStore stores json documents using database/sql directly, for users who don't want to depend on gorm.
+// The statements are hand written per dialect, the table layout matches the one of jsonstore.DbStore.
+type SqlStore struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+// make sure the sql store fulfills the JsonStorer, PageLister and HealthChecker interfaces
+var _ jsonstore.JsonStorer = &SqlStore{}
+var _ jsonstore.PageLister = &SqlStore{}
+var _ jsonstore.HealthChecker = &SqlStore{}
+
+var tableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// NewSqlStore returns a store using the table, if empty DefaultTable is used; the table is created if it does not exist.
+// The db needs to be opened with a driver matching the dialect, e.g. github.com/mattn/go-sqlite3,
+// github.com/go-sql-driver/mysql or github.com/jackc/pgx/v5/stdlib.
+func NewSqlStore(db *sql.DB, dialect Dialect, table string) (*SqlStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db cannot be nil")
+	}
+	switch dialect {
+	case Sqlite, MySQL, Postgres:
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	if table == "" {
+		table = DefaultTable
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	store := SqlStore{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+	}
+	_, err := db.Exec(store.createTableStmt())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table: %v", err)
+	}
+	return &store, nil
+}
+
+// quote quotes an identifier
+func (store *SqlStore) quote(name string) string {
+	if store.dialect == MySQL {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// bind rewrites the ? placeholders of a statement into the ones of the dialect
+func (store *SqlStore) bind(stmt string) string {
+	if store.dialect != Postgres {
+		return stmt
+	}
+	b := strings.Builder{}
+	n := 0
+	for _, c := range stmt {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (store *SqlStore) createTableStmt() string {
+	keyType := "text"
+	if store.dialect == MySQL {
+		// mysql can't index text columns without a length
+		keyType = "varchar(191)"
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s NOT NULL, %s %s NOT NULL, %s json, PRIMARY KEY (%s, %s))",
+		store.quote(store.table),
+		store.quote("id"), keyType,
+		store.quote("collection"), keyType,
+		store.quote("value"),
+		store.quote("id"), store.quote("collection"),
+	)
+}
+
+func (store *SqlStore) upsertStmt() string {
+	insert := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)",
+		store.quote(store.table), store.quote("id"), store.quote("collection"), store.quote("value"))
+	if store.dialect == MySQL {
+		return insert + fmt.Sprintf(" ON DUPLICATE KEY UPDATE %s = VALUES(%s)", store.quote("value"), store.quote("value"))
+	}
+	return store.bind(insert + fmt.Sprintf(" ON CONFLICT (%s, %s) DO UPDATE SET %s = excluded.%s",
+		store.quote("id"), store.quote("collection"), store.quote("value"), store.quote("value")))
+}
+
+func (store *SqlStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	if key == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	_, err := store.db.ExecContext(ctx, store.upsertStmt(), key, collection, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to save document: %v", err)
+	}
+	return nil
+}
+
+func (store *SqlStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	stmt := store.bind(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? AND %s = ?",
+		store.quote("value"), store.quote(store.table), store.quote("id"), store.quote("collection")))
+
+	var data []byte
+	err := store.db.QueryRowContext(ctx, stmt, key, collection).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return jsonstore.ItemNotFoundErr
+		}
+		return fmt.Errorf("failed to retrieve document: %v", err)
+	}
+	*value = data
+	return nil
+}
+
+// List returns the documents of a collection and the total amount of documents in it
+func (store *SqlStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	p, err := store.ListPage(ctx, collection, jsonstore.ListOptions{Limit: limit, Page: page})
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Items, p.Total, nil
+}
+
+// ListPage returns a page of documents of the collection together with the pagination metadata
+func (store *SqlStore) ListPage(ctx context.Context, collection string, opts jsonstore.ListOptions) (jsonstore.Page, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	opts, err := opts.Normalize()
+	if err != nil {
+		return jsonstore.Page{}, err
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	where := fmt.Sprintf("%s = ?", store.quote("collection"))
+	args := []any{collection}
+	if opts.Prefix != "" {
+		where += " AND " + store.prefixCond()
+		args = append(args, likeEscaper.Replace(opts.Prefix)+"%")
+	}
+
+	var count int64
+	stmt := store.bind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", store.quote(store.table), where))
+	err = store.db.QueryRowContext(ctx, stmt, args...).Scan(&count)
+	if err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to count items in collection %s: %v", collection, err)
+	}
+
+	stmt = store.bind(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s ORDER BY %s ASC LIMIT ? OFFSET ?",
+		store.quote("id"), store.quote("value"), store.quote(store.table), where, store.quote("id")))
+	rows, err := store.db.QueryContext(ctx, stmt, append(args, opts.Limit, offset)...)
+	if err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	defer rows.Close()
+
+	result := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err = rows.Scan(&key, &data); err != nil {
+			return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+		}
+		result[key] = data
+	}
+	if err = rows.Err(); err != nil {
+		return jsonstore.Page{}, fmt.Errorf("failed to retrieve documents: %v", err)
+	}
+	return jsonstore.NewPage(result, count, opts.Page, opts.Limit), nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern using \ as escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// prefixCond returns the condition matching the ids starting with the pattern argument
+func (store *SqlStore) prefixCond() string {
+	if store.dialect == MySQL {
+		// \ is already the default escape character, and mysql would read '\' as an unterminated string
+		return fmt.Sprintf("%s LIKE ?", store.quote("id"))
+	}
+	return fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, store.quote("id"))
+}
+
+func (store *SqlStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if collection == "" {
+		collection = jsonstore.DefaultCollection
+	}
+	stmt := store.bind(fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND %s = ?",
+		store.quote(store.table), store.quote("id"), store.quote("collection")))
+	result, err := store.db.ExecContext(ctx, stmt, key, collection)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document with ID %s: %v", key, err)
+	}
+	switch affected {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return true, fmt.Errorf("unexpected amount of deleted rows, expected 1 or 0, got: %d", affected)
+	}
+}
+
+// Health pings the database
+func (store *SqlStore) Health(ctx context.Context) error {
+	if err := store.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+	return nil
+}
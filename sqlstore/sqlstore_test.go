@@ -0,0 +1,255 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/sqlstore"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type target struct {
+	db      *sql.DB
+	dialect sqlstore.Dialect
+}
+
+func getTargetDBs(t *testing.T) map[string]target {
+	databases := map[string]target{
+		"sqlite": {db: openDb(t, "sqlite3", filepath.Join(t.TempDir(), "testdb.sqlite")), dialect: sqlstore.Sqlite},
+	}
+
+	_, skipTestCont := os.LookupEnv("SKIP_TESTCONTAINERS")
+	if testing.Short() || skipTestCont {
+		return databases
+	}
+
+	// discard testcontainer messages
+	testcontainers.Logger = testcontainers.TestLogger(t)
+
+	_, skipMysql := os.LookupEnv("SKIP_MYSQL")
+	if !skipMysql {
+		host, port := startContainer(t, testcontainers.ContainerRequest{
+			Image:        "mysql:8.0",
+			ExposedPorts: []string{"3306/tcp"},
+			Env: map[string]string{
+				"MYSQL_ROOT_PASSWORD": "password",
+				"MYSQL_DATABASE":      "testdb",
+				"MYSQL_USER":          "testuser",
+				"MYSQL_PASSWORD":      "password",
+			},
+			WaitingFor: wait.ForListeningPort("3306/tcp").WithStartupTimeout(60 * time.Second),
+		}, "3306")
+		dsn := fmt.Sprintf("testuser:password@tcp(%s:%s)/testdb", host, port)
+		databases["mysql"] = target{db: openDb(t, "mysql", dsn), dialect: sqlstore.MySQL}
+	}
+
+	_, skipPostgres := os.LookupEnv("SKIP_POSTGRES")
+	if !skipPostgres {
+		host, port := startContainer(t, testcontainers.ContainerRequest{
+			Image:        "postgres:13",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "testuser",
+				"POSTGRES_PASSWORD": "password",
+				"POSTGRES_DB":       "testdb",
+			},
+			WaitingFor: wait.ForListeningPort("5432/tcp").WithStartupTimeout(60 * time.Second),
+		}, "5432")
+		dsn := fmt.Sprintf("host=%s port=%s user=testuser dbname=testdb password=password sslmode=disable", host, port)
+		databases["postgres"] = target{db: openDb(t, "pgx", dsn), dialect: sqlstore.Postgres}
+	}
+	return databases
+}
+
+func openDb(t *testing.T, driver, dsn string) *sql.DB {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close() // Ensure all connections are closed after the test
+	})
+	return db
+}
+
+func startContainer(t *testing.T, req testcontainers.ContainerRequest, port string) (string, string) {
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("failed to start %s container: %v", req.Image, err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate %s container: %v", req.Image, err)
+		}
+	})
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get %s container host: %v", req.Image, err)
+	}
+	mapped, err := container.MappedPort(ctx, nat.Port(port))
+	if err != nil {
+		t.Fatalf("failed to get %s container port: %v", req.Image, err)
+	}
+	return host, mapped.Port()
+}
+
+func TestSqlStore(t *testing.T) {
+	for name, target := range getTargetDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			store, err := sqlstore.NewSqlStore(target.db, target.dialect, "")
+			if err != nil {
+				t.Fatalf("NewSqlStore returned an error: %v", err)
+			}
+			ctx := context.Background()
+
+			t.Run("health", func(t *testing.T) {
+				if err := store.Health(ctx); err != nil {
+					t.Errorf("action: Health,  returned an error: %v", err)
+				}
+			})
+
+			t.Run("set and get", func(t *testing.T) {
+				err := store.Set(ctx, "col1", "item1", json.RawMessage(`{"item":"value"}`))
+				if err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+				// the second set updates the existing row
+				value := json.RawMessage(`{"item":"value changed"}`)
+				err = store.Set(ctx, "col1", "item1", value)
+				if err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+
+				var got json.RawMessage
+				err = store.Get(ctx, "col1", "item1", &got)
+				if err != nil {
+					t.Fatalf("action: Get,  returned an error: %v", err)
+				}
+				if diff := cmp.Diff(compact(t, got), string(value)); diff != "" {
+					t.Errorf("unexpected value (-got +want)\n%s", diff)
+				}
+
+				err = store.Get(ctx, "col1", "missing", &got)
+				if !errors.Is(err, jsonstore.ItemNotFoundErr) {
+					t.Errorf("expected ItemNotFoundErr, got: %v", err)
+				}
+			})
+
+			t.Run("list", func(t *testing.T) {
+				for i := 1; i <= 3; i++ {
+					err := store.Set(ctx, "col2", fmt.Sprintf("item%d", i), json.RawMessage(fmt.Sprintf(`{"item":"item%d"}`, i)))
+					if err != nil {
+						t.Fatalf("action: Set,  returned an error: %v", err)
+					}
+				}
+				items, total, err := store.List(ctx, "col2", 2, 2)
+				if err != nil {
+					t.Fatalf("action: List,  returned an error: %v", err)
+				}
+				if total != 3 {
+					t.Errorf("expected total 3, got %d", total)
+				}
+				if len(items) != 1 || compact(t, items["item3"]) != `{"item":"item3"}` {
+					t.Errorf("unexpected items: %v", items)
+				}
+			})
+
+			t.Run("list prefix", func(t *testing.T) {
+				for _, key := range []string{"a_1", "a_2", "aX3", "b_1"} {
+					err := store.Set(ctx, "col3", key, json.RawMessage(`{}`))
+					if err != nil {
+						t.Fatalf("action: Set,  returned an error: %v", err)
+					}
+				}
+				page, err := store.ListPage(ctx, "col3", jsonstore.ListOptions{Prefix: "a_"})
+				if err != nil {
+					t.Fatalf("action: ListPage,  returned an error: %v", err)
+				}
+				if page.Total != 2 || len(page.Items) != 2 || page.Items["a_1"] == nil || page.Items["a_2"] == nil {
+					t.Errorf("unexpected page: %+v", page)
+				}
+			})
+
+			t.Run("delete", func(t *testing.T) {
+				deleted, err := store.Delete(ctx, "col1", "item1")
+				if err != nil {
+					t.Fatalf("action: Delete,  returned an error: %v", err)
+				}
+				if !deleted {
+					t.Errorf("expect Delete to affect one entry, but got false")
+				}
+				deleted, err = store.Delete(ctx, "col1", "item1")
+				if err != nil {
+					t.Fatalf("action: Delete,  returned an error: %v", err)
+				}
+				if deleted {
+					t.Errorf("expect Delete to NOT affect any entry, but got true")
+				}
+			})
+		})
+	}
+}
+
+// TestSqlStoreDbStoreTable verifies that the SqlStore can read the data written by the gorm based DbStore
+func TestSqlStoreDbStoreTable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "testdb.sqlite")
+	gormDb, err := gorm.Open(sqlite.Open(file), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	dbStore, err := jsonstore.NewDbStore(gormDb)
+	if err != nil {
+		t.Fatalf("NewDbStore returned an error: %v", err)
+	}
+	ctx := context.Background()
+	err = dbStore.Set(ctx, "col1", "item1", json.RawMessage(`{"item":"value"}`))
+	if err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+	sqlDb, _ := gormDb.DB()
+	sqlDb.Close()
+
+	store, err := sqlstore.NewSqlStore(openDb(t, "sqlite3", file), sqlstore.Sqlite, sqlstore.DefaultTable)
+	if err != nil {
+		t.Fatalf("NewSqlStore returned an error: %v", err)
+	}
+	var got json.RawMessage
+	err = store.Get(ctx, "col1", "item1", &got)
+	if err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	if diff := cmp.Diff(string(got), `{"item":"value"}`); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
+
+// compact removes the whitespace added by databases normalizing json, e.g. mysql
+func compact(t *testing.T, in json.RawMessage) string {
+	t.Helper()
+	out, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("invalid json %s: %v", in, err)
+	}
+	return string(out)
+}
@@ -0,0 +1,365 @@
+package jsonstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotTarget is a remote location where FileStore snapshots are shipped to,
+// it allows to keep a warm standby copy of the data for disaster recovery.
+type SnapshotTarget interface {
+	// Put stores a snapshot under the given name
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Latest returns the most recent snapshot
+	Latest(ctx context.Context) (io.ReadCloser, error)
+}
+
+var SnapshotNotFoundErr = errors.New("snapshot not found")
+
+const snapshotPrefix = "snapshot-"
+const snapshotExt = ".json"
+
+// snapshotName returns a name that sorts alphabetically in the order the snapshots were taken
+func snapshotName(t time.Time) string {
+	return snapshotPrefix + t.UTC().Format("20060102T150405.000000000") + snapshotExt
+}
+
+// Snapshot writes a point-in-time copy of the store content to w, taken under the lock of the store.
+// With LazyLoad the collections not loaded are read from their files.
+func (f *FileStore) Snapshot(ctx context.Context, w io.Writer) error {
+	f.mutex.RLock()
+	content, err := f.snapshotContent()
+	if err != nil {
+		f.mutex.RUnlock()
+		return err
+	}
+	data, err := json.Marshal(content)
+	f.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("unable to marshal snapshot: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("unable to write snapshot: %v", err)
+	}
+	return nil
+}
+
+// snapshotContent returns the whole content of the store, with LazyLoad the collections not loaded are read from
+// their files; it needs to be called holding the lock
+func (f *FileStore) snapshotContent() (map[string]map[string]json.RawMessage, error) {
+	if !f.lazy {
+		return f.content, nil
+	}
+	files, err := f.diskCollections()
+	if err != nil {
+		return nil, err
+	}
+	content := make(map[string]map[string]json.RawMessage, len(files))
+	for collection, file := range files {
+		if content[collection], err = f.readCollection(file); err != nil {
+			return nil, fmt.Errorf("collection %s: %v", collection, err)
+		}
+	}
+	for collection, docs := range f.content {
+		content[collection] = docs
+	}
+	return content, nil
+}
+
+// RestoreSnapshot replaces the whole content of the store with a snapshot, the collections not in the snapshot
+// are emptied. The content is flushed to the file unless the store uses ManualFlush.
+func (f *FileStore) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	if f.readOnly {
+		return ReadOnlyErr
+	}
+	content, err := readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.inMemory {
+		f.replaceContent(content)
+		return nil
+	}
+	if f.perCollection {
+		// with LazyLoad the collections not loaded need to be emptied as well
+		files, err := f.diskCollections()
+		if err != nil {
+			return err
+		}
+		for collection := range files {
+			f.markDirty(collection)
+		}
+		for collection := range f.content {
+			f.markDirty(collection)
+		}
+		for collection := range content {
+			f.markDirty(collection)
+		}
+	}
+	f.replaceContent(content)
+	if f.ManualFlush {
+		f.pending++
+		return nil
+	}
+	f.stopFlushTimer()
+	return f.flushToFile()
+}
+
+// ShipSnapshot sends a point-in-time copy of the store content to the target
+func (f *FileStore) ShipSnapshot(ctx context.Context, target SnapshotTarget) error {
+	buf := bytes.Buffer{}
+	if err := f.Snapshot(ctx, &buf); err != nil {
+		return fmt.Errorf("failed to ship snapshot: %v", err)
+	}
+
+	err := target.Put(ctx, snapshotName(time.Now()), &buf)
+	if err != nil {
+		return fmt.Errorf("failed to ship snapshot: %v", err)
+	}
+	return nil
+}
+
+// ShipSnapshots periodically ships a snapshot to the target until the context is canceled,
+// errors on individual shipments are passed to onErr (if not nil) and don't stop the loop.
+// It is intended to be run as a goroutine:
+//
+//	go store.ShipSnapshots(ctx, target, 5*time.Minute, func(err error) { log.Print(err) })
+func (f *FileStore) ShipSnapshots(ctx context.Context, target SnapshotTarget, interval time.Duration, onErr func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			err := f.ShipSnapshot(ctx, target)
+			if err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+// RestoreFromRemote bootstraps a FileStore from the latest snapshot found in the target, the store is opened with
+// the flags and its current content is replaced with the snapshot, see RestoreSnapshot. The snapshot is parsed before
+// touching the files and written with the atomic writes of the store, an invalid snapshot or a crash during the
+// restore leaves the previous content in place.
+func RestoreFromRemote(ctx context.Context, target SnapshotTarget, file string, flags ...FileStoreFlag) (*FileStore, error) {
+	if file == "" || file == InMemoryDb {
+		return nil, fmt.Errorf("restoring from remote requires a file")
+	}
+	r, err := target.Latest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest snapshot: %w", err)
+	}
+	defer r.Close()
+
+	store, err := NewFileStore(file, flags...)
+	if err != nil {
+		return nil, err
+	}
+	if err = store.RestoreSnapshot(ctx, r); err != nil {
+		store.Close(ctx)
+		return nil, fmt.Errorf("failed to restore snapshot: %v", err)
+	}
+	return store, nil
+}
+
+// DirSnapshotTarget stores snapshots as files in a directory, e.g. a mounted network share
+type DirSnapshotTarget struct {
+	Dir string
+	// Keep is the amount of snapshots to retain, 0 keeps all of them
+	Keep int
+}
+
+func (d DirSnapshotTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	if name != filepath.Base(name) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	err := os.MkdirAll(d.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that Latest never returns a partial snapshot
+	tmp, err := os.CreateTemp(d.Dir, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), filepath.Join(d.Dir, name)); err != nil {
+		return err
+	}
+	return d.prune()
+}
+
+func (d DirSnapshotTarget) Latest(ctx context.Context) (io.ReadCloser, error) {
+	names, err := d.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, SnapshotNotFoundErr
+	}
+	return os.Open(filepath.Join(d.Dir, names[len(names)-1]))
+}
+
+// List returns the names of the snapshots in the directory sorted from oldest to newest
+func (d DirSnapshotTarget) List(ctx context.Context) ([]string, error) {
+	return d.snapshots()
+}
+
+// Delete removes a snapshot from the directory
+func (d DirSnapshotTarget) Delete(ctx context.Context, name string) error {
+	if name != filepath.Base(name) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	err := os.Remove(filepath.Join(d.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return SnapshotNotFoundErr
+	}
+	return err
+}
+
+// snapshots returns the snapshot file names sorted from oldest to newest
+func (d DirSnapshotTarget) snapshots() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), snapshotPrefix) && strings.HasSuffix(e.Name(), snapshotExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d DirSnapshotTarget) prune() error {
+	if d.Keep <= 0 {
+		return nil
+	}
+	names, err := d.snapshots()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(names)-d.Keep; i++ {
+		if err = os.Remove(filepath.Join(d.Dir, names[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HttpSnapshotTarget ships snapshots to another host, the remote side is expected
+// to serve a SnapshotTargetHandler on URL.
+type HttpSnapshotTarget struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h HttpSnapshotTarget) client() *http.Client {
+	if h.Client == nil {
+		return http.DefaultClient
+	}
+	return h.Client
+}
+
+func (h HttpSnapshotTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	u, err := url.JoinPath(h.URL, url.PathEscape(name))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, r)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h HttpSnapshotTarget) Latest(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, SnapshotNotFoundErr
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+// SnapshotTargetHandler exposes a SnapshotTarget over http to receive snapshots from a HttpSnapshotTarget:
+// PUT /<name> stores a snapshot and GET / returns the latest one.
+func SnapshotTargetHandler(target SnapshotTarget) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			name := path.Base(r.URL.Path)
+			defer r.Body.Close()
+			if err := target.Put(r.Context(), name, r.Body); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to store snapshot: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			rc, err := target.Latest(r.Context())
+			if err != nil {
+				if errors.Is(err, SnapshotNotFoundErr) {
+					http.Error(w, err.Error(), http.StatusNotFound)
+					return
+				}
+				http.Error(w, fmt.Sprintf("Failed to read snapshot: %v", err), http.StatusInternalServerError)
+				return
+			}
+			defer rc.Close()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.Copy(w, rc)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
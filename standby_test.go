@@ -0,0 +1,158 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShipAndRestoreSnapshot(t *testing.T) {
+	dirTarget := jsonstore.DirSnapshotTarget{Dir: t.TempDir(), Keep: 2}
+	srv := httptest.NewServer(jsonstore.SnapshotTargetHandler(jsonstore.DirSnapshotTarget{Dir: t.TempDir()}))
+	defer srv.Close()
+
+	targets := map[string]jsonstore.SnapshotTarget{
+		"dir":  dirTarget,
+		"http": jsonstore.HttpSnapshotTarget{URL: srv.URL},
+	}
+
+	for name, target := range targets {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, _ := getjsonFileStore(t)
+			value := json.RawMessage(`{"item":"my value"}`)
+
+			for _, key := range []string{"item1", "item2", "item3"} {
+				err := store.Set(ctx, "col1", key, value)
+				if err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+				// ship one snapshot after every write
+				err = store.ShipSnapshot(ctx, target)
+				if err != nil {
+					t.Fatalf("ShipSnapshot returned an error: %v", err)
+				}
+			}
+
+			restored, err := jsonstore.RestoreFromRemote(ctx, target, filepath.Join(t.TempDir(), "restored.json"))
+			if err != nil {
+				t.Fatalf("RestoreFromRemote returned an error: %v", err)
+			}
+			items, total, err := restored.List(ctx, "col1", 0, 1)
+			if err != nil {
+				t.Fatalf("action: List,  returned an error: %v", err)
+			}
+			if total != 3 {
+				t.Errorf("expected 3 restored items, got %d", total)
+			}
+			if diff := cmp.Diff(items["item3"], value); diff != "" {
+				t.Errorf("unexpected value (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("retain snapshots", func(t *testing.T) {
+		entries, err := os.ReadDir(dirTarget.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("expected 2 retained snapshots, got %d", len(entries))
+		}
+	})
+
+	t.Run("file per collection", func(t *testing.T) {
+		ctx := context.Background()
+		store, _ := getjsonFileStore(t)
+		for _, collection := range []string{"col1", "col2"} {
+			if err := store.Set(ctx, collection, "item1", json.RawMessage(`{"item":"my value"}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		target := jsonstore.DirSnapshotTarget{Dir: t.TempDir()}
+		if err := store.ShipSnapshot(ctx, target); err != nil {
+			t.Fatalf("ShipSnapshot returned an error: %v", err)
+		}
+
+		dir := filepath.Join(t.TempDir(), "restored")
+		existing, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// collections missing from the snapshot are removed
+		if err = existing.Set(ctx, "stale", "item1", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		if err = existing.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+
+		restored, err := jsonstore.RestoreFromRemote(ctx, target, dir, jsonstore.FilePerCollection)
+		if err != nil {
+			t.Fatalf("RestoreFromRemote returned an error: %v", err)
+		}
+		got, err := restored.Collections(ctx)
+		if err != nil {
+			t.Fatalf("action: Collections,  returned an error: %v", err)
+		}
+		if diff := cmp.Diff(got, []string{"col1", "col2"}); diff != "" {
+			t.Errorf("unexpected collections (-got +want)\n%s", diff)
+		}
+		if err = restored.Close(ctx); err != nil {
+			t.Fatalf("action: Close,  returned an error: %v", err)
+		}
+
+		// the restored content is persisted in the collection files
+		reopened, err := jsonstore.NewFileStore(dir, jsonstore.FilePerCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value json.RawMessage
+		if err = reopened.Get(ctx, "col2", "item1", &value); err != nil {
+			t.Fatalf("action: Get,  returned an error: %v", err)
+		}
+		if err = reopened.Get(ctx, "stale", "item1", &value); !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+			t.Errorf("expected CollectionNotFoundErr, got: %v", err)
+		}
+	})
+
+	t.Run("invalid snapshot keeps the file", func(t *testing.T) {
+		ctx := context.Background()
+		target := jsonstore.DirSnapshotTarget{Dir: t.TempDir()}
+		if err := target.Put(ctx, "snapshot-20240101T000000.000000000.json", strings.NewReader(`{"col1":`)); err != nil {
+			t.Fatalf("action: Put,  returned an error: %v", err)
+		}
+		file := filepath.Join(t.TempDir(), "restored.json")
+		want := []byte(`{"col1":{"item1":{"item":"my value"}}}`)
+		if err := os.WriteFile(file, want, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := jsonstore.RestoreFromRemote(ctx, target, file)
+		if err == nil || errors.Is(err, jsonstore.SnapshotNotFoundErr) {
+			t.Fatalf("expected an error restoring the snapshot, got: %v", err)
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(got), string(want)); diff != "" {
+			t.Errorf("unexpected file content (-got +want)\n%s", diff)
+		}
+	})
+
+	t.Run("no snapshot available", func(t *testing.T) {
+		target := jsonstore.DirSnapshotTarget{Dir: t.TempDir()}
+		_, err := jsonstore.RestoreFromRemote(context.Background(), target, filepath.Join(t.TempDir(), "restored.json"))
+		if !errors.Is(err, jsonstore.SnapshotNotFoundErr) {
+			t.Errorf("expected SnapshotNotFoundErr, got: %v", err)
+		}
+	})
+}
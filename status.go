@@ -0,0 +1,513 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorLog keeps the most recent errors in a fixed size ring buffer, to be displayed by the StatusHandler
+type ErrorLog struct {
+	mutex   sync.Mutex
+	entries []ErrorEntry
+	next    int
+	full    bool
+}
+
+// ErrorEntry is a single error recorded by the ErrorLog
+type ErrorEntry struct {
+	Time  time.Time
+	Error string
+}
+
+// NewErrorLog returns an ErrorLog retaining the last size errors
+func NewErrorLog(size int) *ErrorLog {
+	if size < 1 {
+		size = 1
+	}
+	return &ErrorLog{entries: make([]ErrorEntry, size)}
+}
+
+// Add records an error, nil errors are ignored
+func (l *ErrorLog) Add(err error) {
+	if err == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries[l.next] = ErrorEntry{Time: time.Now(), Error: err.Error()}
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns the recorded errors, newest first
+func (l *ErrorLog) Recent() []ErrorEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	out := make([]ErrorEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return out
+}
+
+// errorRecordingStore records the errors returned by the wrapped store into an ErrorLog
+type errorRecordingStore struct {
+	JsonStorer
+	log *ErrorLog
+}
+
+// make sure the error recording store forwards the optional interfaces
+var _ PageLister = &errorRecordingStore{}
+var _ ForEacher = &errorRecordingStore{}
+var _ Querier = &errorRecordingStore{}
+var _ Aggregator = &errorRecordingStore{}
+var _ Patcher = &errorRecordingStore{}
+var _ StatsReporter = &errorRecordingStore{}
+var _ HealthChecker = &errorRecordingStore{}
+
+// NewErrorRecordingStore wraps a store recording all its errors, except ItemNotFoundErr and
+// CollectionNotFoundErr that are part of the normal operation, into the ErrorLog.
+//
+// The optional interfaces with a fallback for plain stores, e.g. PageLister or Querier, are forwarded to the
+// wrapped store when it implements them; the returned store implements Watcher, VersionedStorer, ModTimeGetter
+// and CollectionManager only if the wrapped store does. Other interfaces, e.g. Purger or Snapshotter, are not forwarded.
+func NewErrorRecordingStore(store JsonStorer, log *ErrorLog) JsonStorer {
+	s := &errorRecordingStore{JsonStorer: store, log: log}
+	w, isWatcher := store.(Watcher)
+	v, isVersioned := store.(VersionedStorer)
+	m, isModTime := store.(ModTimeGetter)
+	cm, isCollections := store.(CollectionManager)
+	watcher := recordingWatcher{s: s, watcher: w}
+	versioned := recordingVersioned{s: s, versioned: v}
+	modTime := recordingModTime{s: s, modTime: m}
+	collections := recordingCollections{s: s, cm: cm}
+	switch {
+	case isWatcher && isVersioned && isModTime && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingVersioned
+			recordingModTime
+			recordingCollections
+		}{s, watcher, versioned, modTime, collections}
+	case isWatcher && isVersioned && isModTime:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingVersioned
+			recordingModTime
+		}{s, watcher, versioned, modTime}
+	case isWatcher && isVersioned && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingVersioned
+			recordingCollections
+		}{s, watcher, versioned, collections}
+	case isWatcher && isModTime && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingModTime
+			recordingCollections
+		}{s, watcher, modTime, collections}
+	case isVersioned && isModTime && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingVersioned
+			recordingModTime
+			recordingCollections
+		}{s, versioned, modTime, collections}
+	case isWatcher && isVersioned:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingVersioned
+		}{s, watcher, versioned}
+	case isWatcher && isModTime:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingModTime
+		}{s, watcher, modTime}
+	case isWatcher && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+			recordingCollections
+		}{s, watcher, collections}
+	case isVersioned && isModTime:
+		return &struct {
+			*errorRecordingStore
+			recordingVersioned
+			recordingModTime
+		}{s, versioned, modTime}
+	case isVersioned && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingVersioned
+			recordingCollections
+		}{s, versioned, collections}
+	case isModTime && isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingModTime
+			recordingCollections
+		}{s, modTime, collections}
+	case isWatcher:
+		return &struct {
+			*errorRecordingStore
+			recordingWatcher
+		}{s, watcher}
+	case isVersioned:
+		return &struct {
+			*errorRecordingStore
+			recordingVersioned
+		}{s, versioned}
+	case isModTime:
+		return &struct {
+			*errorRecordingStore
+			recordingModTime
+		}{s, modTime}
+	case isCollections:
+		return &struct {
+			*errorRecordingStore
+			recordingCollections
+		}{s, collections}
+	}
+	return s
+}
+
+func (s *errorRecordingStore) record(err error) error {
+	if err != nil && !errors.Is(err, ItemNotFoundErr) && !errors.Is(err, CollectionNotFoundErr) {
+		s.log.Add(err)
+	}
+	return err
+}
+
+func (s *errorRecordingStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	return s.record(s.JsonStorer.Set(ctx, collection, key, value))
+}
+
+func (s *errorRecordingStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	return s.record(s.JsonStorer.Get(ctx, collection, key, value))
+}
+
+func (s *errorRecordingStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	deleted, err := s.JsonStorer.Delete(ctx, collection, key)
+	return deleted, s.record(err)
+}
+
+func (s *errorRecordingStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	items, total, err := s.JsonStorer.List(ctx, collection, limit, page)
+	return items, total, s.record(err)
+}
+
+// ListPage lists a page of the collection, with the PageLister of the wrapped store if available
+func (s *errorRecordingStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	page, err := ListPage(ctx, s.JsonStorer, collection, opts)
+	return page, s.record(err)
+}
+
+// ForEach streams the documents of the collection, with the ForEacher of the wrapped store if available; the
+// errors returned by fn are not recorded
+func (s *errorRecordingStore) ForEach(ctx context.Context, collection string, fn func(key string, value json.RawMessage) error) error {
+	var fnErr error
+	err := ForEach(ctx, s.JsonStorer, collection, func(key string, value json.RawMessage) error {
+		fnErr = fn(key, value)
+		return fnErr
+	})
+	if err != nil && (fnErr == nil || !errors.Is(err, fnErr)) {
+		s.record(err)
+	}
+	return err
+}
+
+// Query runs the query with the Querier of the wrapped store if available
+func (s *errorRecordingStore) Query(ctx context.Context, collection string, q Query) (Page, error) {
+	page, err := RunQuery(ctx, s.JsonStorer, collection, q)
+	return page, s.record(err)
+}
+
+// Aggregate aggregates the documents with the Aggregator of the wrapped store if available
+func (s *errorRecordingStore) Aggregate(ctx context.Context, collection string, spec AggregateSpec) ([]AggregateGroup, error) {
+	groups, err := Aggregate(ctx, s.JsonStorer, collection, spec)
+	return groups, s.record(err)
+}
+
+// Patch patches the document with the Patcher of the wrapped store if available, see PatchDocument
+func (s *errorRecordingStore) Patch(ctx context.Context, collection, key string, patch json.RawMessage, patchType PatchType) (json.RawMessage, error) {
+	doc, err := PatchDocument(ctx, s.JsonStorer, collection, key, patch, patchType)
+	return doc, s.record(err)
+}
+
+// Stats returns the statistics of a collection of the wrapped store, see GetStats
+func (s *errorRecordingStore) Stats(ctx context.Context, collection string) (CollectionStats, error) {
+	stats, err := GetStats(ctx, s.JsonStorer, collection)
+	return stats, s.record(err)
+}
+
+// Health checks the wrapped store, see CheckHealth
+func (s *errorRecordingStore) Health(ctx context.Context) error {
+	return s.record(CheckHealth(ctx, s.JsonStorer))
+}
+
+// Close closes the wrapped store if it implements Closer
+func (s *errorRecordingStore) Close(ctx context.Context) error {
+	closer, ok := s.JsonStorer.(Closer)
+	if !ok {
+		return nil
+	}
+	return s.record(closer.Close(ctx))
+}
+
+// recordingWatcher forwards Watcher, it is only embedded if the wrapped store is a Watcher
+type recordingWatcher struct {
+	s       *errorRecordingStore
+	watcher Watcher
+}
+
+func (w recordingWatcher) Watch(ctx context.Context, collection string) (<-chan Event, error) {
+	events, err := w.watcher.Watch(ctx, collection)
+	return events, w.s.record(err)
+}
+
+// recordingVersioned forwards VersionedStorer, it is only embedded if the wrapped store is a VersionedStorer;
+// version conflicts are part of the normal operation and not recorded
+type recordingVersioned struct {
+	s         *errorRecordingStore
+	versioned VersionedStorer
+}
+
+func (v recordingVersioned) record(err error) error {
+	if errors.Is(err, VersionConflictErr) {
+		return err
+	}
+	return v.s.record(err)
+}
+
+func (v recordingVersioned) GetWithVersion(ctx context.Context, collection, key string, value *json.RawMessage) (string, error) {
+	version, err := v.versioned.GetWithVersion(ctx, collection, key, value)
+	return version, v.record(err)
+}
+
+func (v recordingVersioned) SetIfVersion(ctx context.Context, collection, key string, value json.RawMessage, version string) (string, error) {
+	newVersion, err := v.versioned.SetIfVersion(ctx, collection, key, value, version)
+	return newVersion, v.record(err)
+}
+
+func (v recordingVersioned) DeleteIfVersion(ctx context.Context, collection, key, version string) error {
+	return v.record(v.versioned.DeleteIfVersion(ctx, collection, key, version))
+}
+
+// recordingModTime forwards ModTimeGetter, it is only embedded if the wrapped store is a ModTimeGetter
+type recordingModTime struct {
+	s       *errorRecordingStore
+	modTime ModTimeGetter
+}
+
+func (m recordingModTime) GetWithModTime(ctx context.Context, collection, key string, value *json.RawMessage) (time.Time, error) {
+	modTime, err := m.modTime.GetWithModTime(ctx, collection, key, value)
+	return modTime, m.s.record(err)
+}
+
+// StatusHandler renders a lightweight status page summarizing the state of a store, it gives small
+// deployments at-a-glance visibility without setting up a monitoring stack.
+// The page is served as html, or as plain text when requested with ?format=text.
+type StatusHandler struct {
+	Title string
+	Store JsonStorer
+	// Collections to display the document counts for
+	Collections []string
+	// Errors, if set, are displayed as the recent errors, see NewErrorRecordingStore
+	Errors *ErrorLog
+	// Metrics, if set, returns additional values to display, e.g. cache hit rates or replication lag
+	Metrics func(ctx context.Context) map[string]string
+}
+
+// Status is the data displayed by the StatusHandler
+type Status struct {
+	Title       string
+	Time        time.Time
+	Healthy     bool
+	HealthError string
+	Collections []CollectionStatus
+	Metrics     []Metric
+	Errors      []ErrorEntry
+}
+
+type CollectionStatus struct {
+	Name  string
+	Count int64
+	Error string
+}
+
+type Metric struct {
+	Name  string
+	Value string
+}
+
+// Status collects the data displayed in the status page
+func (h *StatusHandler) Status(ctx context.Context) Status {
+	s := Status{
+		Title:   h.Title,
+		Time:    time.Now(),
+		Healthy: true,
+	}
+	if s.Title == "" {
+		s.Title = "jsonstore status"
+	}
+
+	if hc, ok := h.Store.(HealthChecker); ok {
+		if err := hc.Health(ctx); err != nil {
+			s.Healthy = false
+			s.HealthError = err.Error()
+		}
+	}
+	// listing a single item of every collection is used both as health probe and to get the counts
+	probes := h.Collections
+	if len(probes) == 0 {
+		probes = []string{DefaultCollection}
+	}
+	for i, name := range probes {
+		_, total, err := h.Store.List(ctx, name, 1, 1)
+		if err != nil && !errors.Is(err, CollectionNotFoundErr) {
+			s.Healthy = false
+			s.HealthError = err.Error()
+		}
+		if i >= len(h.Collections) {
+			continue
+		}
+		c := CollectionStatus{Name: name, Count: total}
+		if err != nil && !errors.Is(err, CollectionNotFoundErr) {
+			c.Error = err.Error()
+		}
+		s.Collections = append(s.Collections, c)
+	}
+
+	if h.Metrics != nil {
+		for name, value := range h.Metrics(ctx) {
+			s.Metrics = append(s.Metrics, Metric{Name: name, Value: value})
+		}
+		sort.Slice(s.Metrics, func(i, j int) bool { return s.Metrics[i].Name < s.Metrics[j].Name })
+	}
+	if h.Errors != nil {
+		s.Errors = h.Errors.Recent()
+	}
+	return s
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.Status(r.Context())
+	status := http.StatusOK
+	if !s.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(s.Text()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = statusTmpl.Execute(w, s)
+}
+
+// Text renders the status as plain text
+func (s Status) Text() string {
+	b := strings.Builder{}
+	health := "ok"
+	if !s.Healthy {
+		health = "failing: " + s.HealthError
+	}
+	fmt.Fprintf(&b, "%s\n%s\n\nhealth: %s\n", s.Title, s.Time.Format(time.RFC3339), health)
+	if len(s.Collections) > 0 {
+		b.WriteString("\ncollections:\n")
+		for _, c := range s.Collections {
+			if c.Error != "" {
+				fmt.Fprintf(&b, "  %s: error: %s\n", c.Name, c.Error)
+				continue
+			}
+			fmt.Fprintf(&b, "  %s: %d\n", c.Name, c.Count)
+		}
+	}
+	if len(s.Metrics) > 0 {
+		b.WriteString("\nmetrics:\n")
+		for _, m := range s.Metrics {
+			fmt.Fprintf(&b, "  %s: %s\n", m.Name, m.Value)
+		}
+	}
+	if len(s.Errors) > 0 {
+		b.WriteString("\nrecent errors:\n")
+		for _, e := range s.Errors {
+			fmt.Fprintf(&b, "  %s %s\n", e.Time.Format(time.RFC3339), e.Error)
+		}
+	}
+	return b.String()
+}
+
+var statusTmpl = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.ok { color: #080; } .failing { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+<h2>Health</h2>
+{{if .Healthy}}<p class="ok">ok</p>{{else}}<p class="failing">failing: {{.HealthError}}</p>{{end}}
+{{if .Collections}}<h2>Collections</h2>
+<table><tr><th>collection</th><th>documents</th></tr>
+{{range .Collections}}<tr><td>{{.Name}}</td><td>{{if .Error}}error: {{.Error}}{{else}}{{.Count}}{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Metrics}}<h2>Metrics</h2>
+<table>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Errors}}<h2>Recent errors</h2>
+<table>
+{{range .Errors}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// recordingCollections forwards CollectionManager, it is only embedded if the wrapped store is a CollectionManager,
+// so that the callers fall back, e.g. to TruncateCollection, for the other stores
+type recordingCollections struct {
+	s  *errorRecordingStore
+	cm CollectionManager
+}
+
+func (c recordingCollections) Collections(ctx context.Context) ([]string, error) {
+	collections, err := c.cm.Collections(ctx)
+	return collections, c.s.record(err)
+}
+
+func (c recordingCollections) DropCollection(ctx context.Context, collection string) error {
+	return c.s.record(c.cm.DropCollection(ctx, collection))
+}
@@ -0,0 +1,259 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/jsonstoretest"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorLog(t *testing.T) {
+	log := jsonstore.NewErrorLog(2)
+	log.Add(fmt.Errorf("error 1"))
+	log.Add(nil)
+	log.Add(fmt.Errorf("error 2"))
+	log.Add(fmt.Errorf("error 3"))
+
+	got := log.Recent()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(got))
+	}
+	if got[0].Error != "error 3" || got[1].Error != "error 2" {
+		t.Errorf("unexpected errors: %+v", got)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	mockStorer := &jsonstoretest.FakeStore{
+		Data: map[string]map[string]json.RawMessage{
+			"users": {
+				"key1": []byte(`{"name":"item1"}`),
+				"key2": []byte(`{"name":"item2"}`),
+			},
+		},
+	}
+	errLog := jsonstore.NewErrorLog(10)
+	store := jsonstore.NewErrorRecordingStore(mockStorer, errLog)
+
+	handler := &jsonstore.StatusHandler{
+		Store:       store,
+		Collections: []string{"users"},
+		Errors:      errLog,
+		Metrics: func(ctx context.Context) map[string]string {
+			return map[string]string{"cache hit rate": "93%"}
+		},
+	}
+
+	t.Run("healthy store", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/status?format=text", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{"health: ok", "users: 2", "cache hit rate: 93%"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected body to contain %q, got:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("failing store", func(t *testing.T) {
+		mockStorer.Err = fmt.Errorf("storage error")
+		defer func() { mockStorer.Err = nil }()
+
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("expected an html response, got %q", ct)
+		}
+		if !strings.Contains(rec.Body.String(), "Recent errors") {
+			t.Errorf("expected the recorded error to be displayed")
+		}
+	})
+}
+
+func TestErrorRecordingStoreClose(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "store.json")
+	fileStore, err := jsonstore.NewFileStore(file, jsonstore.ManualFlush)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := jsonstore.NewErrorRecordingStore(fileStore, jsonstore.NewErrorLog(10))
+	if err = store.Set(ctx, "col1", "item1", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	closer, ok := store.(jsonstore.Closer)
+	if !ok {
+		t.Fatalf("expected the wrapped store to implement Closer")
+	}
+	if err = closer.Close(ctx); err != nil {
+		t.Fatalf("action: Close,  returned an error: %v", err)
+	}
+	// the pending write of the wrapped store was flushed
+	if _, ok := readJsonFile(t, file).(map[string]interface{})["col1"]; !ok {
+		t.Errorf("expected the pending write to be flushed on close")
+	}
+
+	// stores without resources are closed without errors
+	closer = jsonstore.NewErrorRecordingStore(&jsonstoretest.FakeStore{}, jsonstore.NewErrorLog(10)).(jsonstore.Closer)
+	if err = closer.Close(ctx); err != nil {
+		t.Errorf("action: Close,  returned an error: %v", err)
+	}
+}
+
+// pageListerStore is a PageLister and HealthChecker failing with err, counting the calls of its methods
+type pageListerStore struct {
+	*jsonstoretest.FakeStore
+	err   error
+	calls int
+}
+
+func (s *pageListerStore) ListPage(ctx context.Context, collection string, opts jsonstore.ListOptions) (jsonstore.Page, error) {
+	s.calls++
+	return jsonstore.Page{}, s.err
+}
+
+func (s *pageListerStore) Health(ctx context.Context) error {
+	s.calls++
+	return s.err
+}
+
+func TestErrorRecordingStoreInterfaces(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("forwarded interfaces", func(t *testing.T) {
+		tcs := []struct {
+			name  string
+			store jsonstore.JsonStorer
+		}{
+			{name: "fake store", store: &jsonstoretest.FakeStore{}},
+			{name: "mem store", store: jsonstore.NewMemStore()},
+			{name: "file store", store: newJsonFile(t)},
+			{name: "dir store", store: newDirStore(t)},
+			{name: "db store", store: newDbStore(t)},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				store := jsonstore.NewErrorRecordingStore(tc.store, jsonstore.NewErrorLog(10))
+				for name, implements := range map[string]func(s jsonstore.JsonStorer) bool{
+					"Watcher":         func(s jsonstore.JsonStorer) bool { _, ok := s.(jsonstore.Watcher); return ok },
+					"VersionedStorer": func(s jsonstore.JsonStorer) bool { _, ok := s.(jsonstore.VersionedStorer); return ok },
+					"ModTimeGetter":   func(s jsonstore.JsonStorer) bool { _, ok := s.(jsonstore.ModTimeGetter); return ok },
+					"CollectionManager": func(s jsonstore.JsonStorer) bool {
+						_, ok := s.(jsonstore.CollectionManager)
+						return ok
+					},
+				} {
+					if got, want := implements(store), implements(tc.store); got != want {
+						t.Errorf("expected the wrapped store to implement %s: %v, got %v", name, want, got)
+					}
+				}
+				if _, ok := store.(jsonstore.PageLister); !ok {
+					t.Errorf("expected the wrapped store to implement PageLister")
+				}
+				if _, ok := store.(jsonstore.ForEacher); !ok {
+					t.Errorf("expected the wrapped store to implement ForEacher")
+				}
+				if _, ok := store.(jsonstore.Closer); !ok {
+					t.Errorf("expected the wrapped store to implement Closer")
+				}
+			})
+		}
+	})
+
+	t.Run("optional interface of the wrapped store", func(t *testing.T) {
+		inner := &pageListerStore{FakeStore: &jsonstoretest.FakeStore{}, err: fmt.Errorf("storage error")}
+		errLog := jsonstore.NewErrorLog(10)
+		store := jsonstore.NewErrorRecordingStore(inner, errLog)
+
+		if _, err := jsonstore.ListPage(ctx, store, "col1", jsonstore.ListOptions{}); err == nil {
+			t.Errorf("expected the error of the wrapped ListPage")
+		}
+		if err := jsonstore.CheckHealth(ctx, store); err == nil {
+			t.Errorf("expected the error of the wrapped Health")
+		}
+		if inner.calls != 2 {
+			t.Errorf("expected the optional interfaces of the wrapped store to be called, got %d calls", inner.calls)
+		}
+		if got := len(errLog.Recent()); got != 2 {
+			t.Errorf("expected 2 recorded errors, got %d", got)
+		}
+	})
+
+	t.Run("admin endpoints of a plain store", func(t *testing.T) {
+		mem := jsonstore.NewMemStore()
+		for _, key := range []string{"a", "b"} {
+			if err := mem.Set(ctx, "users", key, json.RawMessage(`{}`)); err != nil {
+				t.Fatalf("action: Set,  returned an error: %v", err)
+			}
+		}
+		// hide the optional interfaces of the mem store
+		plain := struct{ jsonstore.JsonStorer }{mem}
+		errLog := jsonstore.NewErrorLog(10)
+		handler := jsonstore.Handler{
+			HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewErrorRecordingStore(plain, errLog)},
+			Collection: "users",
+			Admin:      true,
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/_collections", nil))
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("expected status %d, got %d: %s", http.StatusNotImplemented, rec.Code, rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/", nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+		}
+		_, total, err := mem.List(ctx, "users", 0, 0)
+		if err != nil && !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+			t.Fatalf("action: List,  returned an error: %v", err)
+		}
+		if total != 0 {
+			t.Errorf("expected the documents of the collection to be deleted, got %d", total)
+		}
+		if got := errLog.Recent(); len(got) != 0 {
+			t.Errorf("expected no recorded errors, got %+v", got)
+		}
+	})
+
+	t.Run("errors of the callers are not recorded", func(t *testing.T) {
+		errLog := jsonstore.NewErrorLog(10)
+		store := jsonstore.NewErrorRecordingStore(newDbStore(t), errLog)
+		if err := store.Set(ctx, "col1", "item1", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+		stop := fmt.Errorf("stop")
+		if err := jsonstore.ForEach(ctx, store, "col1", func(string, json.RawMessage) error { return stop }); err == nil {
+			t.Errorf("expected the error of the callback")
+		}
+		_, err := store.(jsonstore.VersionedStorer).SetIfVersion(ctx, "col1", "item1", json.RawMessage(`{}`), "42")
+		if !errors.Is(err, jsonstore.VersionConflictErr) {
+			t.Errorf("expected VersionConflictErr, got %v", err)
+		}
+		if got := errLog.Recent(); len(got) != 0 {
+			t.Errorf("expected no recorded errors, got %+v", got)
+		}
+	})
+}
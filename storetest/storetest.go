@@ -0,0 +1,271 @@
+// Package storetest provides a conformance test suite for the implementations of jsonstore.JsonStorer,
+// it allows the authors of custom backends to verify that their store behaves like the ones of this module:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.TestStorer(t, func() jsonstore.JsonStorer {
+//			return newMyStore(t)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestStorer runs the behavioral suite of the JsonStorer interface: set, get, update, delete, list, pagination,
+// the returned errors and concurrent access. newStore is called by every subtest and must return an empty store,
+// documents written by a subtest must not be visible to the others.
+func TestStorer(t *testing.T, newStore func() jsonstore.JsonStorer) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store jsonstore.JsonStorer)
+	}{
+		{"set and get", testSetGet},
+		{"update", testUpdate},
+		{"get missing", testGetMissing},
+		{"collections are isolated", testCollections},
+		{"delete", testDelete},
+		{"list", testList},
+		{"pagination", testPagination},
+		{"concurrency", testConcurrency},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore())
+		})
+	}
+}
+
+// jsonEqual compares json documents ignoring the formatting and the order of the object keys,
+// stores are allowed to normalize the documents, e.g. a postgres jsonb column
+func jsonEqual(t *testing.T, got, want json.RawMessage) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("the store returned invalid json %q: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatalf("invalid json %q: %v", want, err)
+	}
+	if diff := cmp.Diff(g, w); diff != "" {
+		t.Errorf("unexpected value (-got +want)\n%s", diff)
+	}
+}
+
+func set(t *testing.T, store jsonstore.JsonStorer, collection, key string, value json.RawMessage) {
+	t.Helper()
+	if err := store.Set(context.Background(), collection, key, value); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+}
+
+func get(t *testing.T, store jsonstore.JsonStorer, collection, key string) json.RawMessage {
+	t.Helper()
+	var got json.RawMessage
+	if err := store.Get(context.Background(), collection, key, &got); err != nil {
+		t.Fatalf("action: Get,  returned an error: %v", err)
+	}
+	return got
+}
+
+func testSetGet(t *testing.T, store jsonstore.JsonStorer) {
+	values := []json.RawMessage{
+		json.RawMessage(`{"name":"item1","tags":["a","b"],"nested":{"n":1.5,"ok":true,"none":null}}`),
+		json.RawMessage(`{}`),
+		json.RawMessage(`{"unicode":"ünïcode ✓"}`),
+	}
+	for i, value := range values {
+		key := fmt.Sprintf("item%d", i)
+		set(t, store, "col1", key, value)
+		jsonEqual(t, get(t, store, "col1", key), value)
+	}
+}
+
+func testUpdate(t *testing.T, store jsonstore.JsonStorer) {
+	set(t, store, "col1", "item1", json.RawMessage(`{"v":1}`))
+	set(t, store, "col1", "item1", json.RawMessage(`{"v":2}`))
+	jsonEqual(t, get(t, store, "col1", "item1"), json.RawMessage(`{"v":2}`))
+
+	_, total, err := store.List(context.Background(), "col1", 10, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected an update to keep a single document, got total %d", total)
+	}
+}
+
+func testGetMissing(t *testing.T, store jsonstore.JsonStorer) {
+	ctx := context.Background()
+	set(t, store, "col1", "item1", json.RawMessage(`{}`))
+
+	var got json.RawMessage
+	if err := store.Get(ctx, "col1", "missing", &got); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+		t.Errorf("expected ItemNotFoundErr getting a missing document, got: %v", err)
+	}
+	err := store.Get(ctx, "missing", "item1", &got)
+	if !errors.Is(err, jsonstore.ItemNotFoundErr) && !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+		t.Errorf("expected ItemNotFoundErr or CollectionNotFoundErr getting from a missing collection, got: %v", err)
+	}
+}
+
+func testCollections(t *testing.T, store jsonstore.JsonStorer) {
+	set(t, store, "col1", "item1", json.RawMessage(`{"c":1}`))
+	set(t, store, "col2", "item1", json.RawMessage(`{"c":2}`))
+	jsonEqual(t, get(t, store, "col1", "item1"), json.RawMessage(`{"c":1}`))
+	jsonEqual(t, get(t, store, "col2", "item1"), json.RawMessage(`{"c":2}`))
+
+	if _, err := store.Delete(context.Background(), "col1", "item1"); err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	jsonEqual(t, get(t, store, "col2", "item1"), json.RawMessage(`{"c":2}`))
+}
+
+func testDelete(t *testing.T, store jsonstore.JsonStorer) {
+	ctx := context.Background()
+	set(t, store, "col1", "item1", json.RawMessage(`{}`))
+	set(t, store, "col1", "item2", json.RawMessage(`{}`))
+
+	deleted, err := store.Delete(ctx, "col1", "item1")
+	if err != nil {
+		t.Fatalf("action: Delete,  returned an error: %v", err)
+	}
+	if !deleted {
+		t.Errorf("expected Delete to report the document as deleted")
+	}
+	var got json.RawMessage
+	if err = store.Get(ctx, "col1", "item1", &got); !errors.Is(err, jsonstore.ItemNotFoundErr) {
+		t.Errorf("expected ItemNotFoundErr after delete, got: %v", err)
+	}
+	get(t, store, "col1", "item2")
+
+	deleted, err = store.Delete(ctx, "col1", "item1")
+	if err != nil {
+		t.Errorf("expected no error deleting a missing document, got: %v", err)
+	}
+	if deleted {
+		t.Errorf("expected Delete to report a missing document as not deleted")
+	}
+}
+
+func testList(t *testing.T, store jsonstore.JsonStorer) {
+	ctx := context.Background()
+	want := map[string]json.RawMessage{
+		"item1": json.RawMessage(`{"n":1}`),
+		"item2": json.RawMessage(`{"n":2}`),
+		"item3": json.RawMessage(`{"n":3}`),
+	}
+	for key, value := range want {
+		set(t, store, "col1", key, value)
+	}
+	set(t, store, "col2", "other", json.RawMessage(`{}`))
+
+	items, total, err := store.List(ctx, "col1", 10, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if total != int64(len(want)) {
+		t.Errorf("expected total %d, got %d", len(want), total)
+	}
+	if diff := cmp.Diff(keys(items), keys(want)); diff != "" {
+		t.Errorf("unexpected keys (-got +want)\n%s", diff)
+	}
+	for key, value := range items {
+		jsonEqual(t, value, want[key])
+	}
+
+	items, total, err = store.List(ctx, "missing", 10, 1)
+	if err != nil && !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+		t.Fatalf("expected no error or CollectionNotFoundErr listing a missing collection, got: %v", err)
+	}
+	if len(items) != 0 || total != 0 {
+		t.Errorf("expected a missing collection to be empty, got %d items and total %d", len(items), total)
+	}
+}
+
+func testPagination(t *testing.T, store jsonstore.JsonStorer) {
+	ctx := context.Background()
+	const count = 5
+	var want []string
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("item%d", i)
+		want = append(want, key)
+		set(t, store, "col1", key, json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+
+	var got []string
+	for page, size := range []int{2, 2, 1, 0} {
+		items, total, err := store.List(ctx, "col1", 2, page+1)
+		if err != nil {
+			t.Fatalf("action: List page %d,  returned an error: %v", page+1, err)
+		}
+		if total != count {
+			t.Errorf("expected total %d on page %d, got %d", count, page+1, total)
+		}
+		if len(items) != size {
+			t.Errorf("expected %d items on page %d, got %d", size, page+1, len(items))
+		}
+		got = append(got, keys(items)...)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("expected every document on exactly one page (-got +want)\n%s", diff)
+	}
+}
+
+func testConcurrency(t *testing.T, store jsonstore.JsonStorer) {
+	ctx := context.Background()
+	const writers = 8
+	const docs = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*docs*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < docs; i++ {
+				key := fmt.Sprintf("w%d-item%d", w, i)
+				if err := store.Set(ctx, "col1", key, json.RawMessage(fmt.Sprintf(`{"w":%d,"i":%d}`, w, i))); err != nil {
+					errs <- fmt.Errorf("set %s: %v", key, err)
+					continue
+				}
+				var got json.RawMessage
+				if err := store.Get(ctx, "col1", key, &got); err != nil {
+					errs <- fmt.Errorf("get %s: %v", key, err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent access failed: %v", err)
+	}
+
+	_, total, err := store.List(ctx, "col1", 1, 1)
+	if err != nil {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	if total != writers*docs {
+		t.Errorf("expected %d documents after the concurrent writes, got %d", writers*docs, total)
+	}
+}
+
+// keys returns the sorted keys of the items
+func keys(items map[string]json.RawMessage) []string {
+	out := make([]string, 0, len(items))
+	for key := range items {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,72 @@
+package storetest_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/go-bumbu/jsonstore/storetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStorerImplementations(t *testing.T) {
+	implementations := []struct {
+		name     string
+		newStore func(t *testing.T) jsonstore.JsonStorer
+	}{
+		{"memstore", func(t *testing.T) jsonstore.JsonStorer { return jsonstore.NewMemStore() }},
+		{"jsonfile", func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewFileStore(filepath.Join(t.TempDir(), "store.json"))
+			if err != nil {
+				t.Fatalf("action: NewFileStore,  returned an error: %v", err)
+			}
+			return store
+		}},
+		{"dirstore", func(t *testing.T) jsonstore.JsonStorer {
+			store, err := jsonstore.NewDirStore(filepath.Join(t.TempDir(), "data"))
+			if err != nil {
+				t.Fatalf("action: NewDirStore,  returned an error: %v", err)
+			}
+			return store
+		}},
+		{"db", func(t *testing.T) jsonstore.JsonStorer {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "testdb.sqlite")), &gorm.Config{
+				Logger: logger.Discard,
+			})
+			if err != nil {
+				t.Fatalf("failed to open test database: %v", err)
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatalf("failed to get underlying DB: %v", err)
+			}
+			t.Cleanup(func() { sqlDB.Close() })
+			store, err := jsonstore.NewDbStore(db)
+			if err != nil {
+				t.Fatalf("NewDbStore returned an error: %v", err)
+			}
+			return store
+		}},
+		{"client", func(t *testing.T) jsonstore.JsonStorer {
+			server := httptest.NewServer(&jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: jsonstore.NewMemStore()}})
+			t.Cleanup(server.Close)
+			store, err := jsonstore.NewClientStore(server.URL, jsonstore.ClientStoreOptions{})
+			if err != nil {
+				t.Fatalf("action: NewClientStore,  returned an error: %v", err)
+			}
+			return store
+		}},
+		{"namespaced", func(t *testing.T) jsonstore.JsonStorer {
+			return jsonstore.NewNamespacedStore(jsonstore.NewMemStore(), "tenant1")
+		}},
+	}
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			storetest.TestStorer(t, func() jsonstore.JsonStorer { return impl.newStore(t) })
+		})
+	}
+}
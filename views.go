@@ -0,0 +1,235 @@
+package jsonstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// View is a derived collection holding the documents of the Source collection matching the Filters, projected to
+// the Fields, under the same keys; see ViewStore
+type View struct {
+	// Name is the collection of the view
+	Name   string
+	Source string
+	// Filters select the documents of the view, all of them if empty; see Query.Filters
+	Filters []Filter
+	// Fields project the documents of the view, whole documents if empty; see Project
+	Fields []string
+}
+
+// Validate returns an error wrapping InvalidQueryErr if the view is not valid
+func (v View) Validate() error {
+	if v.Name == "" || v.Source == "" {
+		return fmt.Errorf("%w: a view needs a name and a source", InvalidQueryErr)
+	}
+	if v.Name == v.Source {
+		return fmt.Errorf("%w: view %s cannot be its own source", InvalidQueryErr, v.Name)
+	}
+	return Query{Filters: v.Filters, Fields: v.Fields}.Validate()
+}
+
+// document returns the document of the view for a document of the source, false if it does not match the filters
+func (v View) document(value json.RawMessage) (json.RawMessage, bool, error) {
+	ok, err := Query{Filters: v.Filters}.Match(value)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	doc, err := Project(value, v.Fields)
+	if err != nil {
+		return nil, false, err
+	}
+	return doc, true, nil
+}
+
+// ViewStore maintains materialized views, the view collections are stored in the inner store and updated on every
+// Set and Delete of their source collection made through the ViewStore, so that they are read with the normal
+// Get and List, e.g. served by the HTTP handlers, without computing them on every read.
+// Writes to the view collections are rejected with ReadOnlyErr.
+//
+// The writes to the source and to the views are not atomic, if updating a view fails the write returns the error
+// and the view can be recomputed with Rebuild; writes made directly to the inner store are not reflected either.
+type ViewStore struct {
+	inner JsonStorer
+	// views by name and by source
+	views   map[string]View
+	sources map[string][]View
+	// mutex serializes the writes to the sources, so that the views are updated in the order of the writes
+	mutex sync.Mutex
+}
+
+// make sure the view store fulfills the optional interfaces
+var _ PageLister = &ViewStore{}
+var _ ForEacher = &ViewStore{}
+var _ CollectionManager = &ViewStore{}
+
+// NewViewStore returns a store maintaining the views over the collections of inner; views added to collections
+// already holding documents need to be built once with Rebuild
+func NewViewStore(inner JsonStorer, views ...View) (*ViewStore, error) {
+	s := &ViewStore{inner: inner, views: map[string]View{}, sources: map[string][]View{}}
+	for _, v := range views {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := s.views[v.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicated view %s", InvalidQueryErr, v.Name)
+		}
+		s.views[v.Name] = v
+		s.sources[v.Source] = append(s.sources[v.Source], v)
+	}
+	for _, v := range views {
+		if _, ok := s.views[v.Source]; ok {
+			return nil, fmt.Errorf("%w: the source of view %s is a view", InvalidQueryErr, v.Name)
+		}
+	}
+	return s, nil
+}
+
+// readOnly returns an error if the collection is a view
+func (s *ViewStore) readOnly(collection string) error {
+	if _, ok := s.views[collection]; ok {
+		return fmt.Errorf("collection %s is a view: %w", collection, ReadOnlyErr)
+	}
+	return nil
+}
+
+func (s *ViewStore) Set(ctx context.Context, collection, key string, value json.RawMessage) error {
+	if err := s.readOnly(collection); err != nil {
+		return err
+	}
+	views := s.sources[collection]
+	if len(views) == 0 {
+		return s.inner.Set(ctx, collection, key, value)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.inner.Set(ctx, collection, key, value); err != nil {
+		return err
+	}
+	for _, v := range views {
+		if err := s.update(ctx, v, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update writes the document of the view for a source document, or deletes it if it does not match the view
+func (s *ViewStore) update(ctx context.Context, v View, key string, value json.RawMessage) error {
+	doc, ok, err := v.document(value)
+	if err != nil {
+		return fmt.Errorf("failed to update view %s: %v", v.Name, err)
+	}
+	if ok {
+		err = s.inner.Set(ctx, v.Name, key, doc)
+	} else {
+		_, err = s.inner.Delete(ctx, v.Name, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update view %s: %v", v.Name, err)
+	}
+	return nil
+}
+
+func (s *ViewStore) Get(ctx context.Context, collection, key string, value *json.RawMessage) error {
+	return s.inner.Get(ctx, collection, key, value)
+}
+
+func (s *ViewStore) Delete(ctx context.Context, collection, key string) (bool, error) {
+	if err := s.readOnly(collection); err != nil {
+		return false, err
+	}
+	views := s.sources[collection]
+	if len(views) == 0 {
+		return s.inner.Delete(ctx, collection, key)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deleted, err := s.inner.Delete(ctx, collection, key)
+	if err != nil {
+		return deleted, err
+	}
+	for _, v := range views {
+		if _, err = s.inner.Delete(ctx, v.Name, key); err != nil {
+			return deleted, fmt.Errorf("failed to update view %s: %v", v.Name, err)
+		}
+	}
+	return deleted, nil
+}
+
+func (s *ViewStore) List(ctx context.Context, collection string, limit, page int) (map[string]json.RawMessage, int64, error) {
+	return s.inner.List(ctx, collection, limit, page)
+}
+
+// ListPage lists a page of the collection, with the PageLister of the inner store if available
+func (s *ViewStore) ListPage(ctx context.Context, collection string, opts ListOptions) (Page, error) {
+	return ListPage(ctx, s.inner, collection, opts)
+}
+
+// ForEach streams the documents of the collection, with the ForEacher of the inner store if available
+func (s *ViewStore) ForEach(ctx context.Context, collection string, fn func(key string, value json.RawMessage) error) error {
+	return ForEach(ctx, s.inner, collection, fn)
+}
+
+// Rebuild recomputes a view out of all the documents of its source, e.g. once after adding the view to a
+// collection already holding documents
+func (s *ViewStore) Rebuild(ctx context.Context, name string) error {
+	v, ok := s.views[name]
+	if !ok {
+		return fmt.Errorf("view %s: %w", name, CollectionNotFoundErr)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := TruncateCollection(ctx, s.inner, v.Name); err != nil && !errors.Is(err, CollectionNotFoundErr) {
+		return fmt.Errorf("failed to clear view %s: %v", v.Name, err)
+	}
+	// the documents are collected first, writing while iterating would hold the store locks or a db cursor
+	docs := map[string]json.RawMessage{}
+	err := ForEach(ctx, s.inner, v.Source, func(key string, value json.RawMessage) error {
+		docs[key] = value
+		return nil
+	})
+	if err != nil && !errors.Is(err, CollectionNotFoundErr) {
+		return err
+	}
+	for key, value := range docs {
+		if err = s.update(ctx, v, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Collections returns the collections of the inner store, including the views; it needs to be a CollectionManager
+func (s *ViewStore) Collections(ctx context.Context) ([]string, error) {
+	cm, ok := s.inner.(CollectionManager)
+	if !ok {
+		return nil, fmt.Errorf("the inner store of the views is not a CollectionManager")
+	}
+	return cm.Collections(ctx)
+}
+
+// DropCollection drops a collection of the inner store together with the documents of its views, views cannot be
+// dropped; the inner store needs to be a CollectionManager
+func (s *ViewStore) DropCollection(ctx context.Context, collection string) error {
+	if err := s.readOnly(collection); err != nil {
+		return err
+	}
+	cm, ok := s.inner.(CollectionManager)
+	if !ok {
+		return fmt.Errorf("the inner store of the views is not a CollectionManager")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := cm.DropCollection(ctx, collection); err != nil {
+		return err
+	}
+	for _, v := range s.sources[collection] {
+		if err := cm.DropCollection(ctx, v.Name); err != nil {
+			return fmt.Errorf("failed to drop view %s: %v", v.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package jsonstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-bumbu/jsonstore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newActiveUsersView(t *testing.T, inner jsonstore.JsonStorer) *jsonstore.ViewStore {
+	t.Helper()
+	store, err := jsonstore.NewViewStore(inner, jsonstore.View{
+		Name:    "active-users",
+		Source:  "users",
+		Filters: []jsonstore.Filter{{Path: "status", Op: jsonstore.OpEq, Value: "active"}},
+		Fields:  []string{"name"},
+	})
+	if err != nil {
+		t.Fatalf("action: NewViewStore,  returned an error: %v", err)
+	}
+	return store
+}
+
+func listView(t *testing.T, store jsonstore.JsonStorer) map[string]json.RawMessage {
+	t.Helper()
+	items, _, err := store.List(context.Background(), "active-users", 10, 1)
+	if err != nil && !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+		t.Fatalf("action: List,  returned an error: %v", err)
+	}
+	got := map[string]json.RawMessage{}
+	for key, value := range items {
+		got[key] = value
+	}
+	return got
+}
+
+func TestViewStore(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]func(t *testing.T) jsonstore.JsonStorer{
+		"MemStore":  func(t *testing.T) jsonstore.JsonStorer { return jsonstore.NewMemStore() },
+		"FileStore": func(t *testing.T) jsonstore.JsonStorer { return newJsonFile(t) },
+		"DbStore":   func(t *testing.T) jsonstore.JsonStorer { return newDbStore(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newActiveUsersView(t, newStore(t))
+			set := func(key, value string) {
+				t.Helper()
+				if err := store.Set(ctx, "users", key, json.RawMessage(value)); err != nil {
+					t.Fatalf("action: Set,  returned an error: %v", err)
+				}
+			}
+			set("alice", `{"name":"alice","status":"active","age":31}`)
+			set("bob", `{"name":"bob","status":"inactive","age":25}`)
+			set("carol", `{"name":"carol","status":"active","age":42}`)
+
+			want := map[string]json.RawMessage{
+				"alice": json.RawMessage(`{"name":"alice"}`),
+				"carol": json.RawMessage(`{"name":"carol"}`),
+			}
+			if diff := cmp.Diff(listView(t, store), want); diff != "" {
+				t.Errorf("unexpected view (-got +want):\n%s", diff)
+			}
+
+			// documents leave and join the view on updates, and leave it on deletes
+			set("alice", `{"name":"alice","status":"inactive"}`)
+			set("bob", `{"name":"bob","status":"active"}`)
+			if _, err := store.Delete(ctx, "users", "carol"); err != nil {
+				t.Fatalf("action: Delete,  returned an error: %v", err)
+			}
+			want = map[string]json.RawMessage{"bob": json.RawMessage(`{"name":"bob"}`)}
+			if diff := cmp.Diff(listView(t, store), want); diff != "" {
+				t.Errorf("unexpected view (-got +want):\n%s", diff)
+			}
+
+			var got json.RawMessage
+			if err := store.Get(ctx, "active-users", "bob", &got); err != nil {
+				t.Errorf("action: Get,  returned an error: %v", err)
+			}
+			if err := store.Set(ctx, "active-users", "dave", json.RawMessage(`{}`)); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr, got %v", err)
+			}
+			if _, err := store.Delete(ctx, "active-users", "bob"); !errors.Is(err, jsonstore.ReadOnlyErr) {
+				t.Errorf("expected ReadOnlyErr, got %v", err)
+			}
+
+			if err := store.DropCollection(ctx, "users"); err != nil {
+				t.Fatalf("action: DropCollection,  returned an error: %v", err)
+			}
+			if got := listView(t, store); len(got) != 0 {
+				t.Errorf("expected an empty view, got %v", got)
+			}
+		})
+	}
+}
+
+func TestViewStoreRebuild(t *testing.T) {
+	ctx := context.Background()
+	inner := jsonstore.NewMemStore()
+	for key, value := range map[string]string{
+		"alice": `{"name":"alice","status":"active"}`,
+		"bob":   `{"name":"bob","status":"inactive"}`,
+	} {
+		if err := inner.Set(ctx, "users", key, json.RawMessage(value)); err != nil {
+			t.Fatalf("action: Set,  returned an error: %v", err)
+		}
+	}
+	// a stale document written to the view directly
+	if err := inner.Set(ctx, "active-users", "stale", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("action: Set,  returned an error: %v", err)
+	}
+
+	store := newActiveUsersView(t, inner)
+	if err := store.Rebuild(ctx, "active-users"); err != nil {
+		t.Fatalf("action: Rebuild,  returned an error: %v", err)
+	}
+	want := map[string]json.RawMessage{"alice": json.RawMessage(`{"name":"alice"}`)}
+	if diff := cmp.Diff(listView(t, store), want); diff != "" {
+		t.Errorf("unexpected view (-got +want):\n%s", diff)
+	}
+	if err := store.Rebuild(ctx, "missing"); !errors.Is(err, jsonstore.CollectionNotFoundErr) {
+		t.Errorf("expected CollectionNotFoundErr, got %v", err)
+	}
+}
+
+func TestNewViewStoreErrors(t *testing.T) {
+	tcs := []struct {
+		name  string
+		views []jsonstore.View
+	}{
+		{name: "missing source", views: []jsonstore.View{{Name: "v"}}},
+		{name: "own source", views: []jsonstore.View{{Name: "v", Source: "v"}}},
+		{name: "duplicated", views: []jsonstore.View{{Name: "v", Source: "a"}, {Name: "v", Source: "b"}}},
+		{name: "view of a view", views: []jsonstore.View{{Name: "v1", Source: "a"}, {Name: "v2", Source: "v1"}}},
+		{name: "invalid filter", views: []jsonstore.View{{Name: "v", Source: "a", Filters: []jsonstore.Filter{{Op: jsonstore.OpEq}}}}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := jsonstore.NewViewStore(jsonstore.NewMemStore(), tc.views...); !errors.Is(err, jsonstore.InvalidQueryErr) {
+				t.Errorf("expected InvalidQueryErr, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandlerView(t *testing.T) {
+	store := newActiveUsersView(t, jsonstore.NewMemStore())
+	handler := &jsonstore.MultiHandler{HttpStorer: jsonstore.HttpStorer{Storer: store}}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/alice", strings.NewReader(`{"name":"alice","status":"active"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code >= 300 {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/active-users/alice", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if diff := cmp.Diff(strings.TrimSpace(rec.Body.String()), `{"name":"alice"}`); rec.Code != http.StatusOK || diff != "" {
+		t.Errorf("unexpected response %d (-got +want):\n%s", rec.Code, diff)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/active-users/bob", strings.NewReader(`{}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d: %s", http.StatusMethodNotAllowed, rec.Code, rec.Body.String())
+	}
+}